@@ -95,7 +95,8 @@ export const genCellValue = (colType: TAVCol, value: string | any) => {
                 type: colType,
                 number: {
                     content: parseFloat(value) || 0,
-                    isNotEmpty: true
+                    isNotEmpty: true,
+                    input: value
                 }
             };
         } else if (["text", "block", "url", "phone", "email", "template"].includes(colType)) {
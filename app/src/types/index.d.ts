@@ -1124,7 +1124,8 @@ interface IAVCellValue {
         content?: number,
         isNotEmpty: boolean,
         format?: string,
-        formattedContent?: string
+        formattedContent?: string,
+        input?: string
     },
     mSelect?: IAVCellSelectValue[]
     mAsset?: IAVCellAssetValue[]
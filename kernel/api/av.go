@@ -17,6 +17,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/88250/gulu"
@@ -124,6 +125,146 @@ func searchAttributeView(c *gin.Context) {
 	}
 }
 
+func searchRelatableAttributeViews(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, _ := util.JsonArg(c, ret)
+	if nil == arg {
+		return
+	}
+
+	keyword := arg["keyword"].(string)
+	var excludeAvIDs []string
+	if excludeAvIDArg := arg["excludeAvID"]; nil != excludeAvIDArg {
+		excludeAvIDs = append(excludeAvIDs, excludeAvIDArg.(string))
+	}
+
+	results, err := model.SearchRelatableAttributeViews(keyword, excludeAvIDs...)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = map[string]interface{}{
+		"results": results,
+	}
+}
+
+func getAttributeViewRelationGraph(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	graph, err := model.GetAttributeViewRelationGraph()
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	ret.Data = graph
+}
+
+func renderAttributeViewTransposed(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	rowID := arg["rowID"].(string)
+	var viewID string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	pivot, err := model.RenderAttributeViewTransposed(avID, viewID, rowID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"pivot": pivot,
+	}
+}
+
+func getRelationTargetInfo(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	destAvID, destAvName, mirrors, err := model.GetRelationTargetInfo(avID, keyID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"destAvID":   destAvID,
+		"destAvName": destAvName,
+		"mirrors":    mirrors,
+	}
+}
+
+func encodeAttributeViewQuery(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	var viewID string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	query, err := model.EncodeAttributeViewViewQuery(avID, viewID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"query": query,
+	}
+}
+
+func decodeAttributeViewQuery(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	query := arg["query"].(string)
+	filters, sorts, err := model.DecodeAttributeViewQuery(query)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"filters": filters,
+		"sorts":   sorts,
+	}
+}
+
 func renderSnapshotAttributeView(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -206,7 +347,7 @@ func renderHistoryAttributeView(c *gin.Context) {
 	}
 }
 
-func renderAttributeView(c *gin.Context) {
+func diffHistoryAttributeView(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
@@ -216,51 +357,608 @@ func renderAttributeView(c *gin.Context) {
 	}
 
 	id := arg["id"].(string)
-	viewIDArg := arg["viewID"]
-	var viewID string
-	if nil != viewIDArg {
-		viewID = viewIDArg.(string)
+	createdA := arg["createdA"].(string)
+	createdB := arg["createdB"].(string)
+	diff, err := model.DiffAttributeViews(id, createdA, createdB)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
 	}
-	page := 1
-	pageArg := arg["page"]
-	if nil != pageArg {
-		page = int(pageArg.(float64))
+
+	ret.Data = diff
+}
+
+func getAttributeViewValueTimeline(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
 	}
 
-	pageSize := -1
-	pageSizeArg := arg["pageSize"]
-	if nil != pageSizeArg {
-		pageSize = int(pageSizeArg.(float64))
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	rowID := arg["rowID"].(string)
+	timeline, err := model.GetAttributeViewValueTimeline(avID, keyID, rowID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = timeline
+}
+
+func sanitizeAttributeViewNumbers(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
 	}
 
-	view, attrView, err := model.RenderAttributeView(id, viewID, page, pageSize)
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	fixed, err := model.SanitizeAttributeViewNumbers(avID, keyID)
 	if nil != err {
 		ret.Code = -1
 		ret.Msg = err.Error()
 		return
 	}
 
-	var views []map[string]interface{}
-	for _, v := range attrView.Views {
-		view := map[string]interface{}{
-			"id":   v.ID,
-			"icon": v.Icon,
-			"name": v.Name,
-			"type": v.LayoutType,
-		}
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+	ret.Data = map[string]interface{}{
+		"fixed": fixed,
+	}
+}
 
-		views = append(views, view)
+func recomputeAttributeView(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	if err := model.RecomputeAttributeView(avID); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func setAttributeViewColRelationMaxEntries(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	maxEntries := int(arg["maxEntries"].(float64))
+	if err := model.SetAttributeViewColRelationMaxEntries(avID, keyID, maxEntries); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func importAttributeViewFromMarkdownTable(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	replace := false
+	if replaceArg := arg["replace"]; nil != replaceArg {
+		replace = replaceArg.(bool)
+	}
+	avID, err := model.ImportAttributeViewFromMarkdownTable(id, replace)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
 	}
 
 	ret.Data = map[string]interface{}{
-		"name":     attrView.Name,
-		"id":       attrView.ID,
-		"viewType": view.GetType(),
-		"viewID":   view.GetID(),
-		"views":    views,
-		"view":     view,
-		"isMirror": av.IsMirror(attrView.ID),
+		"avID": avID,
+	}
+}
+
+func getAttributeViewColumnStats(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	viewID := arg["viewID"].(string)
+	keyID := arg["keyID"].(string)
+	stats, err := model.GetAttributeViewColumnStats(avID, viewID, keyID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = stats
+}
+
+func materializeDetachedRow(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	rowID := arg["rowID"].(string)
+	parentID := arg["parentID"].(string)
+	blockID, err := model.MaterializeDetachedRow(avID, rowID, parentID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"blockID": blockID,
+	}
+}
+
+func applyAttributeViewFilterAsDeletion(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	viewID := arg["viewID"].(string)
+
+	// 默认仅统计行数，真正删除时需要传入 dryRun 为 false 并通过 confirmRemoved 确认 dryRun 返回的删除行数
+	kept, removed, err := model.ApplyFilterAsDeletion(avID, viewID, true)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	dryRun := true
+	if dryRunArg, ok := arg["dryRun"].(bool); ok {
+		dryRun = dryRunArg
+	}
+	if !dryRun {
+		confirmRemoved, ok := arg["confirmRemoved"].(float64)
+		if !ok || int(confirmRemoved) != removed {
+			ret.Code = -1
+			ret.Msg = fmt.Sprintf("the number of rows to remove is [%d], please run a dry run and confirm it first", removed)
+			ret.Data = map[string]interface{}{
+				"kept":    kept,
+				"removed": removed,
+			}
+			return
+		}
+
+		if kept, removed, err = model.ApplyFilterAsDeletion(avID, viewID, false); nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+
+	ret.Data = map[string]interface{}{
+		"kept":    kept,
+		"removed": removed,
+		"dryRun":  dryRun,
+	}
+}
+
+func convertAttributeViewMSelectToRelation(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	destAvID := arg["destAvID"].(string)
+	relKeyID, err := model.ConvertMSelectToRelation(avID, keyID, destAvID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+	ret.Data = map[string]interface{}{
+		"keyID": relKeyID,
+	}
+}
+
+func parseAttributeViewTextColumnToDate(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	textKeyID := arg["textKeyID"].(string)
+	dateKeyID := arg["dateKeyID"].(string)
+	layout := arg["layout"].(string)
+	converted, err := model.ParseTextColumnToDate(avID, textKeyID, dateKeyID, layout)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+	ret.Data = map[string]interface{}{
+		"converted": converted,
+	}
+}
+
+func findAttributeViewDuplicateRows(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	duplicates, err := model.FindAttributeViewDuplicateRows(avID, keyID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"rowIDs": duplicates,
+	}
+}
+
+func deduplicateAttributeViewRows(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	removed, err := model.DeduplicateAttributeViewRows(avID, keyID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"removed": removed,
+	}
+}
+
+func linkAttributeViewRelations(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	links := map[string][]string{}
+	for rowID, blockIDsArg := range arg["links"].(map[string]interface{}) {
+		for _, blockID := range blockIDsArg.([]interface{}) {
+			links[rowID] = append(links[rowID], blockID.(string))
+		}
+	}
+	if err := model.LinkAttributeViewRelations(avID, keyID, links); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func linkAttributeViewRelationsByText(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	textKeyID := arg["textKeyID"].(string)
+	relKeyID := arg["relKeyID"].(string)
+	destAvID := arg["destAvID"].(string)
+	linked, unmatched, err := model.LinkRelationsByText(avID, textKeyID, relKeyID, destAvID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+	if destAvID != avID {
+		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": destAvID})
+	}
+	ret.Data = map[string]interface{}{
+		"linked":    linked,
+		"unmatched": unmatched,
+	}
+}
+
+func restoreAttributeViewRow(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	created := arg["created"].(string)
+	rowID := arg["rowID"].(string)
+	if err := model.RestoreAttributeViewRow(avID, created, rowID); nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func duplicateAttributeView(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	name, _ := arg["name"].(string)
+	newAvID, err := model.DuplicateAttributeView(avID, name)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"avID": newAvID,
+	}
+}
+
+func getAttributeViewDistinctValues(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	viewID := arg["viewID"].(string)
+	keyID := arg["keyID"].(string)
+	values, err := model.GetAttributeViewDistinctValues(avID, viewID, keyID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = values
+}
+
+func getAttributeViewSchema(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	var viewID string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	columns, err := model.GetAttributeViewSchema(avID, viewID)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"columns": columns,
+	}
+}
+
+func getRelatedRowsPreview(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	keyID := arg["keyID"].(string)
+	rowID := arg["rowID"].(string)
+	var keyIDs []string
+	if keyIDsArg := arg["keyIDs"]; nil != keyIDsArg {
+		for _, id := range keyIDsArg.([]interface{}) {
+			keyIDs = append(keyIDs, id.(string))
+		}
+	}
+	rows, err := model.GetRelatedRowsPreview(avID, keyID, rowID, keyIDs)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"rows": rows,
+	}
+}
+
+func previewAttributeViewTemplate(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	rowID := arg["rowID"].(string)
+	tpl := arg["template"].(string)
+	rendered, err := model.PreviewAttributeViewTemplate(avID, rowID, tpl)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"content": rendered,
+	}
+}
+
+func renderAttributeView(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	viewIDArg := arg["viewID"]
+	var viewID string
+	if nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	page := 1
+	pageArg := arg["page"]
+	if nil != pageArg {
+		page = int(pageArg.(float64))
+	}
+
+	pageSize := -1
+	pageSizeArg := arg["pageSize"]
+	if nil != pageSizeArg {
+		pageSize = int(pageSizeArg.(float64))
+	}
+
+	opts := &model.RenderAttributeViewOptions{}
+	if expandRollupsArg := arg["expandRollups"]; nil != expandRollupsArg {
+		opts.ExpandRollups = expandRollupsArg.(bool)
+	}
+	if relationContextBlockIDArg := arg["relationContextBlockID"]; nil != relationContextBlockIDArg {
+		opts.RelationContextBlockID = relationContextBlockIDArg.(string)
+	}
+	if richRelationContentsArg := arg["richRelationContents"]; nil != richRelationContentsArg {
+		opts.RichRelationContents = richRelationContentsArg.(bool)
+	}
+	if localeArg := arg["locale"]; nil != localeArg {
+		opts.Locale = localeArg.(string)
+	}
+	if overrideSortsArg := arg["overrideSorts"]; nil != overrideSortsArg {
+		data, err := gulu.JSON.MarshalJSON(overrideSortsArg)
+		if nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+		if err = gulu.JSON.UnmarshalJSON(data, &opts.OverrideSorts); nil != err {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+
+	view, attrView, err := model.RenderAttributeView(id, viewID, page, pageSize, opts)
+	if nil != err {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	var views []map[string]interface{}
+	for _, v := range attrView.Views {
+		view := map[string]interface{}{
+			"id":   v.ID,
+			"icon": v.Icon,
+			"name": v.Name,
+			"type": v.LayoutType,
+		}
+
+		views = append(views, view)
+	}
+
+	data := map[string]interface{}{
+		"name":     attrView.Name,
+		"id":       attrView.ID,
+		"viewType": view.GetType(),
+		"viewID":   view.GetID(),
+		"views":    views,
+		"view":     view,
+		"isMirror": av.IsMirror(attrView.ID),
+	}
+
+	// 精简输出模式，用于减小大表格的传输体积
+	if compactArg := arg["compact"]; nil != compactArg && compactArg.(bool) {
+		if table, ok := view.(*av.Table); ok {
+			data["view"] = table.Compact()
+			data["compact"] = true
+		}
 	}
+	ret.Data = data
 }
 
 func getAttributeViewKeys(c *gin.Context) {
@@ -291,7 +989,7 @@ func setAttributeViewBlockAttr(c *gin.Context) {
 	rowID := arg["rowID"].(string)
 	cellID := arg["cellID"].(string)
 	value := arg["value"].(interface{})
-	blockAttributeViewKeys := model.UpdateAttributeViewCell(nil, avID, keyID, rowID, cellID, value)
+	blockAttributeViewKeys := model.UpdateAttributeViewCell(nil, avID, keyID, rowID, cellID, value, false)
 	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
 	ret.Data = blockAttributeViewKeys
 }
@@ -17,8 +17,10 @@
 package api
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -56,6 +58,126 @@ func exportAttributeView(c *gin.Context) {
 	}
 }
 
+func exportAttributeViewJSONSchema(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["id"].(string)
+	data, err := model.ExportAttributeViewJSONSchema(avID)
+	if nil != err {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"schema": json.RawMessage(data),
+	}
+}
+
+func exportAttributeViewJSON(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["id"].(string)
+	var viewID string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	includeComputed := false
+	if includeComputedArg := arg["includeComputed"]; nil != includeComputedArg {
+		includeComputed = includeComputedArg.(bool)
+	}
+	data, err := model.ExportAttributeViewJSON(avID, viewID, includeComputed)
+	if nil != err {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"rows": json.RawMessage(data),
+	}
+}
+
+func exportAttributeViewNotionJSON(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["id"].(string)
+	data, err := model.ExportAttributeViewNotionJSON(avID)
+	if nil != err {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"database": json.RawMessage(data),
+	}
+}
+
+func exportAttributeViewXLSX(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["id"].(string)
+	var viewID string
+	if viewIDArg := arg["viewID"]; nil != viewIDArg {
+		viewID = viewIDArg.(string)
+	}
+	data, err := model.ExportAttributeViewXLSX(avID, viewID)
+	if nil != err {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 7000}
+		return
+	}
+
+	exportFolder := filepath.Join(util.TempDir, "export", "xlsx")
+	if err = os.MkdirAll(exportFolder, 0755); nil != err {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		return
+	}
+	name := avID + ".xlsx"
+	if attrView := model.GetAttributeView(avID); nil != attrView && "" != util.FilterFileName(attrView.Name) {
+		name = util.FilterFileName(attrView.Name) + ".xlsx"
+	}
+	if err = os.WriteFile(filepath.Join(exportFolder, name), data, 0644); nil != err {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"path": "/export/xlsx/" + url.PathEscape(name),
+	}
+}
+
 func exportEPUB(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
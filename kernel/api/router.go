@@ -286,6 +286,10 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/export/exportRTF", model.CheckAuth, exportRTF)
 	ginServer.Handle("POST", "/api/export/exportEPUB", model.CheckAuth, exportEPUB)
 	ginServer.Handle("POST", "/api/export/exportAttributeView", model.CheckAuth, exportAttributeView)
+	ginServer.Handle("POST", "/api/export/exportAttributeViewXLSX", model.CheckAuth, exportAttributeViewXLSX)
+	ginServer.Handle("POST", "/api/export/exportAttributeViewJSONSchema", model.CheckAuth, exportAttributeViewJSONSchema)
+	ginServer.Handle("POST", "/api/export/exportAttributeViewJSON", model.CheckAuth, exportAttributeViewJSON)
+	ginServer.Handle("POST", "/api/export/exportAttributeViewNotionJSON", model.CheckAuth, exportAttributeViewNotionJSON)
 
 	ginServer.Handle("POST", "/api/import/importStdMd", model.CheckAuth, model.CheckReadonly, importStdMd)
 	ginServer.Handle("POST", "/api/import/importData", model.CheckAuth, model.CheckReadonly, importData)
@@ -390,11 +394,38 @@ func ServeAPI(ginServer *gin.Engine) {
 
 	ginServer.Handle("POST", "/api/av/renderAttributeView", model.CheckAuth, renderAttributeView)
 	ginServer.Handle("POST", "/api/av/renderHistoryAttributeView", model.CheckAuth, renderHistoryAttributeView)
+	ginServer.Handle("POST", "/api/av/diffHistoryAttributeView", model.CheckAuth, diffHistoryAttributeView)
+	ginServer.Handle("POST", "/api/av/getAttributeViewValueTimeline", model.CheckAuth, getAttributeViewValueTimeline)
+	ginServer.Handle("POST", "/api/av/previewAttributeViewTemplate", model.CheckAuth, previewAttributeViewTemplate)
+	ginServer.Handle("POST", "/api/av/getAttributeViewColumnStats", model.CheckAuth, getAttributeViewColumnStats)
+	ginServer.Handle("POST", "/api/av/getAttributeViewDistinctValues", model.CheckAuth, getAttributeViewDistinctValues)
+	ginServer.Handle("POST", "/api/av/materializeDetachedRow", model.CheckAuth, model.CheckReadonly, materializeDetachedRow)
+	ginServer.Handle("POST", "/api/av/applyAttributeViewFilterAsDeletion", model.CheckAuth, model.CheckReadonly, applyAttributeViewFilterAsDeletion)
+	ginServer.Handle("POST", "/api/av/convertAttributeViewMSelectToRelation", model.CheckAuth, model.CheckReadonly, convertAttributeViewMSelectToRelation)
+	ginServer.Handle("POST", "/api/av/parseAttributeViewTextColumnToDate", model.CheckAuth, model.CheckReadonly, parseAttributeViewTextColumnToDate)
+	ginServer.Handle("POST", "/api/av/findAttributeViewDuplicateRows", model.CheckAuth, findAttributeViewDuplicateRows)
+	ginServer.Handle("POST", "/api/av/deduplicateAttributeViewRows", model.CheckAuth, model.CheckReadonly, deduplicateAttributeViewRows)
+	ginServer.Handle("POST", "/api/av/linkAttributeViewRelations", model.CheckAuth, model.CheckReadonly, linkAttributeViewRelations)
+	ginServer.Handle("POST", "/api/av/linkAttributeViewRelationsByText", model.CheckAuth, model.CheckReadonly, linkAttributeViewRelationsByText)
+	ginServer.Handle("POST", "/api/av/restoreAttributeViewRow", model.CheckAuth, model.CheckReadonly, restoreAttributeViewRow)
+	ginServer.Handle("POST", "/api/av/duplicateAttributeView", model.CheckAuth, model.CheckReadonly, duplicateAttributeView)
+	ginServer.Handle("POST", "/api/av/getAttributeViewSchema", model.CheckAuth, getAttributeViewSchema)
+	ginServer.Handle("POST", "/api/av/getRelatedRowsPreview", model.CheckAuth, getRelatedRowsPreview)
 	ginServer.Handle("POST", "/api/av/renderSnapshotAttributeView", model.CheckAuth, renderSnapshotAttributeView)
 	ginServer.Handle("POST", "/api/av/getAttributeViewKeys", model.CheckAuth, getAttributeViewKeys)
 	ginServer.Handle("POST", "/api/av/setAttributeViewBlockAttr", model.CheckAuth, model.CheckReadonly, setAttributeViewBlockAttr)
+	ginServer.Handle("POST", "/api/av/sanitizeAttributeViewNumbers", model.CheckAuth, model.CheckReadonly, sanitizeAttributeViewNumbers)
+	ginServer.Handle("POST", "/api/av/recomputeAttributeView", model.CheckAuth, model.CheckReadonly, recomputeAttributeView)
+	ginServer.Handle("POST", "/api/av/setAttributeViewColRelationMaxEntries", model.CheckAuth, model.CheckReadonly, setAttributeViewColRelationMaxEntries)
+	ginServer.Handle("POST", "/api/av/importAttributeViewFromMarkdownTable", model.CheckAuth, model.CheckReadonly, importAttributeViewFromMarkdownTable)
 	ginServer.Handle("POST", "/api/av/searchAttributeView", model.CheckAuth, model.CheckReadonly, searchAttributeView)
 	ginServer.Handle("POST", "/api/av/getAttributeView", model.CheckAuth, model.CheckReadonly, getAttributeView)
+	ginServer.Handle("POST", "/api/av/renderAttributeViewTransposed", model.CheckAuth, renderAttributeViewTransposed)
+	ginServer.Handle("POST", "/api/av/getRelationTargetInfo", model.CheckAuth, getRelationTargetInfo)
+	ginServer.Handle("POST", "/api/av/getAttributeViewRelationGraph", model.CheckAuth, getAttributeViewRelationGraph)
+	ginServer.Handle("POST", "/api/av/encodeAttributeViewQuery", model.CheckAuth, encodeAttributeViewQuery)
+	ginServer.Handle("POST", "/api/av/decodeAttributeViewQuery", model.CheckAuth, decodeAttributeViewQuery)
+	ginServer.Handle("POST", "/api/av/searchRelatableAttributeViews", model.CheckAuth, model.CheckReadonly, searchRelatableAttributeViews)
 	ginServer.Handle("POST", "/api/av/searchAttributeViewRelationKey", model.CheckAuth, model.CheckReadonly, searchAttributeViewRelationKey)
 	ginServer.Handle("POST", "/api/av/searchAttributeViewNonRelationKey", model.CheckAuth, model.CheckReadonly, searchAttributeViewNonRelationKey)
 	ginServer.Handle("POST", "/api/av/getAttributeViewFilterSort", model.CheckAuth, model.CheckReadonly, getAttributeViewFilterSort)
@@ -41,6 +41,12 @@ type AttributeView struct {
 	KeyValues []*KeyValues `json:"keyValues"` // 属性视图属性列值
 	ViewID    string       `json:"viewID"`    // 当前视图 ID
 	Views     []*View      `json:"views"`     // 视图
+
+	CacheComputedCols bool `json:"cacheComputedCols,omitempty"` // 是否缓存汇总列和模板列的计算结果，直到依赖发生变化
+	KeepDeletedRows   bool `json:"keepDeletedRows,omitempty"`   // 块在属性视图外被删除时是否将行转换为游离行并保留值
+
+	PrimaryTemplate string        `json:"primaryTemplate,omitempty"` // 主键模板，添加游离行时根据其他列的值生成主键内容
+	SortPresets     []*SortPreset `json:"sortPresets,omitempty"`     // 排序预设，所有视图共用
 }
 
 // KeyValues 描述了属性视图属性列值的结构。
@@ -78,8 +84,54 @@ const (
 	KeyTypeCheckbox KeyType = "checkbox"
 	KeyTypeRelation KeyType = "relation"
 	KeyTypeRollup   KeyType = "rollup"
+
+	KeyTypeRunningTotal    KeyType = "runningTotal"    // 累计求和列，按当前渲染顺序对来源数字列累计求和
+	KeyTypeBlockAttr       KeyType = "blockAttr"       // 块属性列，读取绑定块的 IAL 属性，按文本处理
+	KeyTypeDateDelta       KeyType = "dateDelta"       // 日期间隔列，来源日期列距离今天的天数，按数字处理
+	KeyTypePercentOfColumn KeyType = "percentOfColumn" // 占比列，来源数字列的值占过滤后所有行总和的百分比，按数字处理
+	KeyTypeBacklinkCount   KeyType = "backlinkCount"   // 反链数列，引用绑定块的块数量，按数字处理
+	KeyTypeAge             KeyType = "age"             // 存在时长列，行创建至今的毫秒数，按数字处理
+	KeyTypeTextLength      KeyType = "textLength"      // 文本长度列，来源文本列、模板列或者主键的字符数或单词数，按数字处理
+	KeyTypeRowDelta        KeyType = "rowDelta"        // 行差值列，按当前渲染顺序计算来源数字列与上一行的差值，按数字处理
+	KeyTypeCreatedBy       KeyType = "createdBy"       // 创建者列，创建行的用户，按文本处理
+	KeyTypeUpdatedBy       KeyType = "updatedBy"       // 更新者列，最后修改行的用户，按文本处理
+	KeyTypeFormula         KeyType = "formula"         // 公式列，基于同一行中其他列的值计算，按数字处理
 )
 
+// IsComputedNumber 判断列是否为按数字处理的计算列，这些列的值在渲染时计算得出。
+func (t KeyType) IsComputedNumber() bool {
+	switch t {
+	case KeyTypeRunningTotal, KeyTypeDateDelta, KeyTypePercentOfColumn, KeyTypeBacklinkCount, KeyTypeAge, KeyTypeTextLength, KeyTypeRowDelta, KeyTypeFormula:
+		return true
+	}
+	return false
+}
+
+// IsComputedText 判断列是否为按文本处理的计算列。
+func (t KeyType) IsComputedText() bool {
+	switch t {
+	case KeyTypeBlockAttr, KeyTypeCreatedBy, KeyTypeUpdatedBy:
+		return true
+	}
+	return false
+}
+
+// IsComputed 判断列是否为计算列，计算列的值来自其他列、绑定块或者渲染时计算，不能直接修改。
+func (t KeyType) IsComputed() bool {
+	return t.IsComputedNumber() || t.IsComputedText()
+}
+
+// BaseType 返回列值的基础类型，按数字处理的计算列返回 KeyTypeNumber，按文本处理的计算列返回 KeyTypeText，其他列返回自身，用于按值的类型分别处理。
+func (t KeyType) BaseType() KeyType {
+	if t.IsComputedNumber() {
+		return KeyTypeNumber
+	}
+	if t.IsComputedText() {
+		return KeyTypeText
+	}
+	return t
+}
+
 // Key 描述了属性视图属性列的基础结构。
 type Key struct {
 	ID   string  `json:"id"`   // 列 ID
@@ -87,8 +139,16 @@ type Key struct {
 	Type KeyType `json:"type"` // 列类型
 	Icon string  `json:"icon"` // 列图标
 
+	Alias string `json:"alias,omitempty"` // 列别名，设置后不可修改，模板中通过 .alias.xxx 引用，不受列名修改影响
+
+	EmptyPlaceholder string `json:"emptyPlaceholder,omitempty"` // 空值占位符，仅用于单元格为空时的显示，不影响过滤、排序和计算
+
 	// 以下是某些列类型的特有属性
 
+	// 文本列
+	Mask       string `json:"mask,omitempty"`       // 输入掩码，比如 AAA-000
+	MaskStrict bool   `json:"maskStrict,omitempty"` // 是否拒绝不符合输入掩码的值，否则只作为输入提示
+
 	// 单选/多选列
 	Options []*SelectOption `json:"options,omitempty"` // 选项列表
 
@@ -103,6 +163,18 @@ type Key struct {
 
 	// 汇总列
 	Rollup *Rollup `json:"rollup,omitempty"` // 汇总信息
+
+	// 计算列（比如累计求和列、日期间隔列）
+	SourceKeyID string `json:"sourceKeyID,omitempty"` // 来源列 ID
+
+	// 文本长度列
+	CountMode TextLengthCountMode `json:"countMode,omitempty"` // 计数方式，为空时按字符计数
+
+	// 块属性列
+	AttrName string `json:"attrName,omitempty"` // 块属性名，比如 custom-priority
+
+	// 公式列
+	Formula string `json:"formula,omitempty"` // 公式表达式，比如 Price * Quantity
 }
 
 func NewKey(id, name, icon string, keyType KeyType) *Key {
@@ -118,17 +190,70 @@ type Rollup struct {
 	RelationKeyID string      `json:"relationKeyID"` // 关联列 ID
 	KeyID         string      `json:"keyID"`         // 目标列 ID
 	Calc          *RollupCalc `json:"calc"`          // 计算方式
+
+	AltKeyID  string      `json:"altKeyID,omitempty"`  // 备选目标列 ID，关联块满足 Condition 时使用
+	Condition *ViewFilter `json:"condition,omitempty"` // 选择目标列的条件，基于每个关联块在目标属性视图中的值计算
+}
+
+// GetTargetKeyID 返回关联块 blockID 实际使用的目标列 ID。
+// 关联块满足条件时使用备选目标列，否则使用主目标列；条件无法计算（比如条件列或备选目标列不存在）时回退到主目标列。
+func (r *Rollup) GetTargetKeyID(destAv *AttributeView, blockID string) string {
+	if "" == r.AltKeyID || nil == r.Condition || nil == r.Condition.Value || nil == destAv {
+		return r.KeyID
+	}
+
+	if altKey, _ := destAv.GetKey(r.AltKeyID); nil == altKey {
+		return r.KeyID
+	}
+	if condKey, _ := destAv.GetKey(r.Condition.Column); nil == condKey {
+		return r.KeyID
+	}
+
+	condVal := destAv.GetValue(r.Condition.Column, blockID)
+	if nil == condVal || !condVal.CompareOperator(r.Condition.Value, r.Condition.Operator, destAv, blockID) {
+		return r.KeyID
+	}
+	return r.AltKeyID
 }
 
 type RollupCalc struct {
 	Operator CalcOperator `json:"operator"`
 	Result   *Value       `json:"result"`
+
+	IgnoreZero bool `json:"ignoreZero,omitempty"` // 数字计算（求和、平均值等）时是否忽略 0 值，0 值不参与计算也不参与计数
+
+	DoneOption string `json:"doneOption,omitempty"` // 计算完成百分比时表示已完成的选项，仅用于目标列是单选或多选的情况
+}
+
+// isDone 判断计算完成百分比时值 v 是否表示已完成。
+func (calc *RollupCalc) isDone(v *Value) bool {
+	if nil != v.Checkbox {
+		return v.Checkbox.Checked
+	}
+
+	if "" == calc.DoneOption {
+		return false
+	}
+	for _, opt := range v.MSelect {
+		if opt.Content == calc.DoneOption {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreNumber 判断数字计算时是否忽略值 v。
+func (calc *RollupCalc) ignoreNumber(v *Value) bool {
+	return nil == v.Number || (calc.IgnoreZero && 0 == v.Number.Content)
 }
 
 type Relation struct {
 	AvID      string `json:"avID"`      // 关联的属性视图 ID
 	IsTwoWay  bool   `json:"isTwoWay"`  // 是否双向关联
 	BackKeyID string `json:"backKeyID"` // 双向关联时回链关联列的 ID
+
+	DisplayLimit int `json:"displayLimit,omitempty"` // 渲染时最多显示的关联内容数量，为 0 时不限制
+	MaxEntries   int `json:"maxEntries,omitempty"`   // 每个单元格最多关联的块数量，为 0 时不限制
 }
 
 type SelectOption struct {
@@ -311,7 +436,7 @@ func SaveAttributeView(av *AttributeView) (err error) {
 		}
 	}
 
-	data, err := gulu.JSON.MarshalJSON(av)
+	data, err := gulu.JSON.MarshalJSON(av.persisted())
 	if nil != err {
 		logging.LogErrorf("marshal attribute view [%s] failed: %s", av.ID, err)
 		return
@@ -322,6 +447,38 @@ func SaveAttributeView(av *AttributeView) (err error) {
 		logging.LogErrorf("save attribute view [%s] failed: %s", av.ID, err)
 		return
 	}
+	removeComputedValues(av.ID)
+	return
+}
+
+// persisted 返回用于保存的属性视图副本，去掉渲染时计算的字段（比如关联的有效块数量），不修改 av 本身。
+// 副本和 av 共享没有变化的列和值。
+func (av *AttributeView) persisted() (ret *AttributeView) {
+	ret = &AttributeView{}
+	*ret = *av
+	ret.KeyValues = make([]*KeyValues, 0, len(av.KeyValues))
+	for _, kv := range av.KeyValues {
+		if KeyTypeRelation != kv.Key.Type {
+			ret.KeyValues = append(ret.KeyValues, kv)
+			continue
+		}
+
+		kvCopy := &KeyValues{}
+		*kvCopy = *kv
+		kvCopy.Values = make([]*Value, 0, len(kv.Values))
+		for _, v := range kv.Values {
+			if nil != v.Relation && 0 != v.Relation.Count {
+				vCopy := &Value{}
+				*vCopy = *v
+				relation := *v.Relation
+				relation.Count = 0
+				vCopy.Relation = &relation
+				v = vCopy
+			}
+			kvCopy.Values = append(kvCopy.Values, v)
+		}
+		ret.KeyValues = append(ret.KeyValues, kvCopy)
+	}
 	return
 }
 
@@ -480,6 +637,7 @@ func getI18nName(name string) string {
 var (
 	ErrViewNotFound = errors.New("view not found")
 	ErrKeyNotFound  = errors.New("key not found")
+	ErrCellLocked   = errors.New("cell is locked")
 )
 
 const (
@@ -0,0 +1,83 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"sync"
+)
+
+// computedValues 缓存开启了 CacheComputedCols 的属性视图中汇总列和模板列的计算结果，键为属性视图 ID。
+var (
+	computedValues     = map[string]*computedValueCache{}
+	computedValuesLock = sync.Mutex{}
+)
+
+type computedValueCache struct {
+	deps   map[string]bool           // 依赖的其他属性视图 ID，比如汇总列关联的属性视图
+	values map[string]*computedValue // 键为列 ID + 行 ID
+}
+
+type computedValue struct {
+	signature string // 依赖签名，比如关联块的更新时间，签名不一致时需要重新计算
+	value     *Value
+}
+
+// GetComputedValue 返回属性视图 avID 中列 keyID 行 rowID 的缓存计算结果，没有缓存或者依赖签名 signature 不一致时返回 nil。
+func GetComputedValue(avID, keyID, rowID, signature string) (ret *Value) {
+	computedValuesLock.Lock()
+	defer computedValuesLock.Unlock()
+
+	cache := computedValues[avID]
+	if nil == cache {
+		return
+	}
+
+	cached := cache.values[keyID+rowID]
+	if nil == cached || signature != cached.signature {
+		return
+	}
+	return cached.value.Clone()
+}
+
+// PutComputedValue 缓存属性视图 avID 中列 keyID 行 rowID 的计算结果，depAvID 为计算结果依赖的其他属性视图 ID，没有依赖时传空。
+func PutComputedValue(avID, keyID, rowID, signature, depAvID string, value *Value) {
+	computedValuesLock.Lock()
+	defer computedValuesLock.Unlock()
+
+	cache := computedValues[avID]
+	if nil == cache {
+		cache = &computedValueCache{deps: map[string]bool{}, values: map[string]*computedValue{}}
+		computedValues[avID] = cache
+	}
+	if "" != depAvID {
+		cache.deps[depAvID] = true
+	}
+	cache.values[keyID+rowID] = &computedValue{signature: signature, value: value.Clone()}
+}
+
+// removeComputedValues 移除属性视图 avID 以及依赖 avID 的属性视图的缓存计算结果。
+func removeComputedValues(avID string) {
+	computedValuesLock.Lock()
+	defer computedValuesLock.Unlock()
+
+	delete(computedValues, avID)
+	for id, cache := range computedValues {
+		if cache.deps[avID] {
+			delete(computedValues, id)
+		}
+	}
+}
@@ -17,8 +17,10 @@
 package av
 
 import (
-	"github.com/siyuan-note/siyuan/kernel/util"
 	"strings"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
 type Filterable interface {
@@ -26,9 +28,94 @@ type Filterable interface {
 }
 
 type ViewFilter struct {
-	Column   string         `json:"column"`
-	Operator FilterOperator `json:"operator"`
-	Value    *Value         `json:"value"`
+	Column       string         `json:"column"`
+	Operator     FilterOperator `json:"operator"`
+	Value        *Value         `json:"value"`
+	RelativeDate *RelativeDate  `json:"relativeDate,omitempty"` // 相对日期范围，仅用于 Is relative to today
+	Days         int            `json:"days,omitempty"`         // 天数，仅用于 Value changed within
+}
+
+// RelativeDate 描述了相对于今天的日期范围，比如“今天”、“最近 7 天”和“未来 1 个月”。
+type RelativeDate struct {
+	Count     int                   `json:"count"`     // 数量
+	Unit      RelativeDateUnit      `json:"unit"`      // 单位
+	Direction RelativeDateDirection `json:"direction"` // 方向
+}
+
+type RelativeDateUnit string
+
+const (
+	RelativeDateUnitDay   RelativeDateUnit = "day"
+	RelativeDateUnitWeek  RelativeDateUnit = "week"
+	RelativeDateUnitMonth RelativeDateUnit = "month"
+	RelativeDateUnitYear  RelativeDateUnit = "year"
+)
+
+type RelativeDateDirection string
+
+const (
+	RelativeDateDirectionBefore RelativeDateDirection = "before" // 过去 Count 个单位（包含当前单位）
+	RelativeDateDirectionThis   RelativeDateDirection = "this"   // 当前单位，比如今天、本周
+	RelativeDateDirectionAfter  RelativeDateDirection = "after"  // 未来 Count 个单位（包含当前单位）
+)
+
+// Range 返回相对于 now 的时间范围 [start, end]，按本地时间计算。
+func (relativeDate *RelativeDate) Range(now time.Time) (start, end time.Time) {
+	now = now.Local()
+	year, month, day := now.Date()
+	switch relativeDate.Unit {
+	case RelativeDateUnitWeek:
+		weekday := (int(now.Weekday()) + 6) % 7 // 周一作为一周的开始
+		start = time.Date(year, month, day-weekday, 0, 0, 0, 0, time.Local)
+	case RelativeDateUnitMonth:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+	case RelativeDateUnitYear:
+		start = time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+	default:
+		start = time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+
+	endUnits := 1
+	switch relativeDate.Direction {
+	case RelativeDateDirectionBefore:
+		start = relativeDate.add(start, -relativeDate.Count)
+		endUnits = relativeDate.Count + 1
+	case RelativeDateDirectionAfter:
+		endUnits = relativeDate.Count + 1
+	}
+	end = relativeDate.add(start, endUnits).Add(-time.Millisecond)
+	return
+}
+
+func (relativeDate *RelativeDate) add(t time.Time, count int) time.Time {
+	switch relativeDate.Unit {
+	case RelativeDateUnitWeek:
+		return t.AddDate(0, 0, 7*count)
+	case RelativeDateUnitMonth:
+		return t.AddDate(0, count, 0)
+	case RelativeDateUnitYear:
+		return t.AddDate(count, 0, 0)
+	default:
+		return t.AddDate(0, 0, count)
+	}
+}
+
+// GetRelativeDateValue 返回用于比较的过滤值，相对日期范围会被计算为日期、创建时间和更新时间的起止值。
+func (filter *ViewFilter) GetRelativeDateValue() (ret *Value) {
+	if nil == filter.RelativeDate {
+		return filter.Value
+	}
+
+	if nil != filter.Value {
+		ret = filter.Value.Clone()
+	} else {
+		ret = &Value{}
+	}
+	start, end := filter.RelativeDate.Range(time.Now())
+	ret.Date = &ValueDate{Content: start.UnixMilli(), IsNotEmpty: true, HasEndDate: true, Content2: end.UnixMilli(), IsNotEmpty2: true}
+	ret.Created = &ValueCreated{Content: start.UnixMilli(), IsNotEmpty: true, Content2: end.UnixMilli(), IsNotEmpty2: true}
+	ret.Updated = &ValueUpdated{Content: start.UnixMilli(), IsNotEmpty: true, Content2: end.UnixMilli(), IsNotEmpty2: true}
+	return
 }
 
 type FilterOperator string
@@ -50,6 +137,14 @@ const (
 	FilterOperatorIsRelativeToToday FilterOperator = "Is relative to today"
 	FilterOperatorIsTrue            FilterOperator = "Is true"
 	FilterOperatorIsFalse           FilterOperator = "Is false"
+
+	FilterOperatorRelationMatchesContext FilterOperator = "Relation matches context" // 关联列包含渲染时传入的上下文块，用于主从视图联动
+	FilterOperatorRelationHasOrphan      FilterOperator = "Relation has orphan"      // 关联列引用了目标属性视图中已经不存在的块
+	FilterOperatorRelationAsymmetric     FilterOperator = "Relation asymmetric"      // 双向关联列引用的块在回链关联列中没有关联回当前行
+	FilterOperatorValueChangedWithin     FilterOperator = "Value changed within"     // 单元格的值在最近 Days 天内被修改过
+	FilterOperatorIsDuplicate            FilterOperator = "Is duplicate"             // 值和其他行重复，空值不匹配
+	FilterOperatorIsUnique               FilterOperator = "Is unique"                // 值和其他行都不重复，空值不匹配
+	FilterOperatorAuthorIsMe             FilterOperator = "Author is me"             // 创建者列或者更新者列是渲染时的当前用户，未登录时不匹配
 )
 
 func (filter *ViewFilter) GetAffectValue(key *Key) (ret *Value) {
@@ -0,0 +1,54 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTableFilterRowsIsDuplicate(t *testing.T) {
+	table := &Table{Columns: []*TableColumn{{ID: "block", Type: KeyTypeBlock}, {ID: "email", Type: KeyTypeText}}}
+	for _, row := range []struct{ name, email string }{{"a", "x@b3log.org"}, {"b", "x@b3log.org"}, {"c", "y@b3log.org"}, {"d", ""}, {"e", ""}} {
+		table.Rows = append(table.Rows, &TableRow{ID: row.name, Cells: []*TableCell{
+			{ValueType: KeyTypeBlock, Value: &Value{Type: KeyTypeBlock, Block: &ValueBlock{ID: row.name, Content: row.name}}},
+			{ValueType: KeyTypeText, Value: &Value{Type: KeyTypeText, Text: &ValueText{Content: row.email}}},
+		}})
+	}
+	allRows := table.Rows
+
+	matchedNames := func(operator FilterOperator) string {
+		table.Rows = allRows
+		table.Filters = []*ViewFilter{{Column: "email", Operator: operator}}
+		table.FilterRows(&AttributeView{})
+		var ret []string
+		for _, row := range table.Rows {
+			ret = append(ret, row.ID)
+		}
+		sort.Strings(ret)
+		return strings.Join(ret, ",")
+	}
+
+	// 空值不参与统计也不会被匹配
+	if got := matchedNames(FilterOperatorIsDuplicate); "a,b" != got {
+		t.Fatalf("expected rows a and b to be duplicates, got [%s]", got)
+	}
+	if got := matchedNames(FilterOperatorIsUnique); "c" != got {
+		t.Fatalf("expected only row c to be unique, got [%s]", got)
+	}
+}
@@ -0,0 +1,837 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 公式语法：
+//   - 字面量：数字 1.5、字符串 "abc" 或者 'abc'、布尔值 true 和 false
+//   - 列引用：列名或者列别名，比如 Budget；包含空格等字符的列名使用 prop("Unit price")
+//   - 运算符：+ - * / %、= == != < <= > >=、&& || !（也可以使用 and or not）和括号
+//   - 函数：if(条件, 值, 值)、abs(x)、round(x[, 小数位数])、min(x, ...)、max(x, ...)、empty(x)
+//
+// 引用的列为关联列时取关联的有效块数量，为汇总列时取汇总结果，为复选框列时取布尔值，为日期列时取毫秒时间戳，
+// 数字列和按数字处理的计算列取数字，其他列取文本。
+
+// Formula 描述了解析后的公式。
+type Formula struct {
+	root formulaNode
+	refs []string
+}
+
+// Refs 返回公式引用的列名或者列别名，不包含重复项。
+func (formula *Formula) Refs() []string {
+	return formula.refs
+}
+
+// ParseFormula 解析公式表达式 expr。
+func ParseFormula(expr string) (ret *Formula, err error) {
+	tokens, err := tokenizeFormula(expr)
+	if nil != err {
+		return
+	}
+
+	p := &formulaParser{tokens: tokens}
+	root, err := p.parseOr()
+	if nil != err {
+		return
+	}
+	if p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected token [%s]", p.tokens[p.pos].text)
+		return
+	}
+
+	ret = &Formula{root: root}
+	refs := map[string]bool{}
+	walkFormulaNode(root, func(node formulaNode) {
+		if ref, ok := node.(*formulaRefNode); ok && !refs[ref.name] {
+			refs[ref.name] = true
+			ret.refs = append(ret.refs, ref.name)
+		}
+	})
+	return
+}
+
+// FormulaContext 描述了计算一行公式时的上下文，同一行中的公式列可以相互引用。
+type FormulaContext struct {
+	Keys   []*Key            // 属性视图中的所有列
+	Values map[string]*Value // 列 ID -> 该行的值，引用的计算列需要已经渲染
+
+	results  map[string]*formulaValue
+	visiting map[string]bool
+}
+
+// EvalKey 计算公式列 key 在该行的值，结果为空值时返回空数字。
+func (ctx *FormulaContext) EvalKey(key *Key) (ret *ValueNumber, err error) {
+	val, err := ctx.evalKey(key)
+	if nil != err {
+		return
+	}
+
+	ret = &ValueNumber{}
+	if num, ok := val.number(); ok && !math.IsNaN(num) && !math.IsInf(num, 0) {
+		ret = NewFormattedValueNumber(num, key.NumberFormat)
+	}
+	return
+}
+
+// EvalBool 计算公式表达式 expr 在该行的真假，空值、0 和空字符串为假。
+func (ctx *FormulaContext) EvalBool(expr string) (ret bool, err error) {
+	formula, err := ParseFormula(expr)
+	if nil != err {
+		return
+	}
+
+	val, err := formula.root.eval(ctx)
+	if nil != err {
+		return
+	}
+	ret = val.truthy()
+	return
+}
+
+func (ctx *FormulaContext) evalKey(key *Key) (ret *formulaValue, err error) {
+	if nil == ctx.results {
+		ctx.results = map[string]*formulaValue{}
+		ctx.visiting = map[string]bool{}
+	}
+	if ret = ctx.results[key.ID]; nil != ret {
+		return
+	}
+	if ctx.visiting[key.ID] {
+		err = fmt.Errorf("formula [%s] references itself", key.Name)
+		return
+	}
+
+	formula, err := ParseFormula(key.Formula)
+	if nil != err {
+		return
+	}
+
+	ctx.visiting[key.ID] = true
+	ret, err = formula.root.eval(ctx)
+	delete(ctx.visiting, key.ID)
+	if nil != err {
+		return
+	}
+	ctx.results[key.ID] = ret
+	return
+}
+
+func (ctx *FormulaContext) resolve(name string) (ret *formulaValue, err error) {
+	key := GetFormulaRefKey(ctx.Keys, name)
+	if nil == key {
+		err = fmt.Errorf("key [%s] not found", name)
+		return
+	}
+
+	if KeyTypeFormula == key.Type {
+		return ctx.evalKey(key)
+	}
+	ret = newFormulaValue(ctx.Values[key.ID])
+	return
+}
+
+// GetFormulaRefKey 返回公式中通过列名或者列别名 name 引用的列，列名优先。
+func GetFormulaRefKey(keys []*Key, name string) *Key {
+	for _, key := range keys {
+		if key.Name == name {
+			return key
+		}
+	}
+	for _, key := range keys {
+		if "" != key.Alias && key.Alias == name {
+			return key
+		}
+	}
+	return nil
+}
+
+type formulaValueKind int
+
+const (
+	formulaValueEmpty formulaValueKind = iota
+	formulaValueNumber
+	formulaValueString
+	formulaValueBool
+)
+
+type formulaValue struct {
+	kind formulaValueKind
+	num  float64
+	str  string
+	b    bool
+}
+
+func newFormulaValue(value *Value) *formulaValue {
+	if nil == value {
+		return &formulaValue{}
+	}
+
+	switch value.Type.BaseType() {
+	case KeyTypeNumber:
+		if nil != value.Number && value.Number.IsNotEmpty {
+			return &formulaValue{kind: formulaValueNumber, num: value.Number.Content}
+		}
+		return &formulaValue{}
+	case KeyTypeDate:
+		if nil != value.Date && value.Date.IsNotEmpty {
+			return &formulaValue{kind: formulaValueNumber, num: float64(value.Date.Content)}
+		}
+		return &formulaValue{}
+	case KeyTypeCreated:
+		if nil != value.Created && value.Created.IsNotEmpty {
+			return &formulaValue{kind: formulaValueNumber, num: float64(value.Created.Content)}
+		}
+		return &formulaValue{}
+	case KeyTypeUpdated:
+		if nil != value.Updated && value.Updated.IsNotEmpty {
+			return &formulaValue{kind: formulaValueNumber, num: float64(value.Updated.Content)}
+		}
+		return &formulaValue{}
+	case KeyTypeCheckbox:
+		return &formulaValue{kind: formulaValueBool, b: nil != value.Checkbox && value.Checkbox.Checked}
+	case KeyTypeRelation:
+		if nil == value.Relation {
+			return &formulaValue{kind: formulaValueNumber}
+		}
+		return &formulaValue{kind: formulaValueNumber, num: float64(value.Relation.Count)}
+	case KeyTypeRollup:
+		if nil != value.Rollup && 1 == len(value.Rollup.Contents) {
+			return newFormulaValue(value.Rollup.Contents[0])
+		}
+	}
+
+	content := value.String()
+	if "" == content {
+		return &formulaValue{}
+	}
+	return &formulaValue{kind: formulaValueString, str: content}
+}
+
+func (v *formulaValue) number() (float64, bool) {
+	switch v.kind {
+	case formulaValueNumber:
+		return v.num, true
+	case formulaValueBool:
+		if v.b {
+			return 1, true
+		}
+		return 0, true
+	case formulaValueString:
+		num, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+		return num, nil == err
+	}
+	return 0, false
+}
+
+func (v *formulaValue) string() string {
+	switch v.kind {
+	case formulaValueNumber:
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	case formulaValueString:
+		return v.str
+	case formulaValueBool:
+		return strconv.FormatBool(v.b)
+	}
+	return ""
+}
+
+func (v *formulaValue) truthy() bool {
+	switch v.kind {
+	case formulaValueNumber:
+		return 0 != v.num
+	case formulaValueString:
+		return "" != v.str
+	case formulaValueBool:
+		return v.b
+	}
+	return false
+}
+
+// compare 比较两个值，都可以按数字处理时按数字比较，否则按文本比较。
+func (v *formulaValue) compare(other *formulaValue) int {
+	if formulaValueString != v.kind || formulaValueString != other.kind {
+		n1, ok1 := v.number()
+		n2, ok2 := other.number()
+		if formulaValueEmpty == v.kind {
+			n1, ok1 = 0, true
+		}
+		if formulaValueEmpty == other.kind {
+			n2, ok2 = 0, true
+		}
+		if ok1 && ok2 {
+			if n1 < n2 {
+				return -1
+			} else if n1 > n2 {
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(v.string(), other.string())
+}
+
+type formulaNode interface {
+	eval(ctx *FormulaContext) (*formulaValue, error)
+}
+
+type formulaLiteralNode struct {
+	value *formulaValue
+}
+
+func (node *formulaLiteralNode) eval(*FormulaContext) (*formulaValue, error) {
+	return node.value, nil
+}
+
+type formulaRefNode struct {
+	name string
+}
+
+func (node *formulaRefNode) eval(ctx *FormulaContext) (*formulaValue, error) {
+	return ctx.resolve(node.name)
+}
+
+type formulaUnaryNode struct {
+	op      string
+	operand formulaNode
+}
+
+func (node *formulaUnaryNode) eval(ctx *FormulaContext) (ret *formulaValue, err error) {
+	val, err := node.operand.eval(ctx)
+	if nil != err {
+		return
+	}
+
+	if "!" == node.op {
+		ret = &formulaValue{kind: formulaValueBool, b: !val.truthy()}
+		return
+	}
+
+	if formulaValueEmpty == val.kind {
+		ret = val
+		return
+	}
+	num, ok := val.number()
+	if !ok {
+		err = fmt.Errorf("[%s] is not a number", val.string())
+		return
+	}
+	ret = &formulaValue{kind: formulaValueNumber, num: -num}
+	return
+}
+
+type formulaBinaryNode struct {
+	op          string
+	left, right formulaNode
+}
+
+func (node *formulaBinaryNode) eval(ctx *FormulaContext) (ret *formulaValue, err error) {
+	left, err := node.left.eval(ctx)
+	if nil != err {
+		return
+	}
+
+	// 逻辑运算短路求值
+	switch node.op {
+	case "&&":
+		if !left.truthy() {
+			ret = &formulaValue{kind: formulaValueBool}
+			return
+		}
+	case "||":
+		if left.truthy() {
+			ret = &formulaValue{kind: formulaValueBool, b: true}
+			return
+		}
+	}
+
+	right, err := node.right.eval(ctx)
+	if nil != err {
+		return
+	}
+
+	switch node.op {
+	case "&&", "||":
+		ret = &formulaValue{kind: formulaValueBool, b: right.truthy()}
+	case "=", "==":
+		ret = &formulaValue{kind: formulaValueBool, b: 0 == left.compare(right)}
+	case "!=":
+		ret = &formulaValue{kind: formulaValueBool, b: 0 != left.compare(right)}
+	case "<":
+		ret = &formulaValue{kind: formulaValueBool, b: 0 > left.compare(right)}
+	case "<=":
+		ret = &formulaValue{kind: formulaValueBool, b: 0 >= left.compare(right)}
+	case ">":
+		ret = &formulaValue{kind: formulaValueBool, b: 0 < left.compare(right)}
+	case ">=":
+		ret = &formulaValue{kind: formulaValueBool, b: 0 <= left.compare(right)}
+	default:
+		ret, err = evalFormulaArithmetic(node.op, left, right)
+	}
+	return
+}
+
+func evalFormulaArithmetic(op string, left, right *formulaValue) (ret *formulaValue, err error) {
+	if "+" == op && (formulaValueString == left.kind || formulaValueString == right.kind) {
+		_, ok1 := left.number()
+		_, ok2 := right.number()
+		if !ok1 || !ok2 { // 有一边不是数字时拼接文本
+			ret = &formulaValue{kind: formulaValueString, str: left.string() + right.string()}
+			return
+		}
+	}
+
+	if formulaValueEmpty == left.kind || formulaValueEmpty == right.kind {
+		ret = &formulaValue{}
+		return
+	}
+
+	n1, ok1 := left.number()
+	n2, ok2 := right.number()
+	if !ok1 {
+		err = fmt.Errorf("[%s] is not a number", left.string())
+		return
+	}
+	if !ok2 {
+		err = fmt.Errorf("[%s] is not a number", right.string())
+		return
+	}
+
+	ret = &formulaValue{kind: formulaValueNumber}
+	switch op {
+	case "+":
+		ret.num = n1 + n2
+	case "-":
+		ret.num = n1 - n2
+	case "*":
+		ret.num = n1 * n2
+	case "/":
+		if 0 == n2 { // 除数为 0 时结果为空
+			ret = &formulaValue{}
+			return
+		}
+		ret.num = n1 / n2
+	case "%":
+		if 0 == n2 {
+			ret = &formulaValue{}
+			return
+		}
+		ret.num = math.Mod(n1, n2)
+	}
+	return
+}
+
+type formulaCallNode struct {
+	name string
+	args []formulaNode
+}
+
+func (node *formulaCallNode) eval(ctx *FormulaContext) (ret *formulaValue, err error) {
+	if "if" == node.name {
+		cond, evalErr := node.args[0].eval(ctx)
+		if nil != evalErr {
+			return nil, evalErr
+		}
+		if cond.truthy() {
+			return node.args[1].eval(ctx)
+		}
+		return node.args[2].eval(ctx)
+	}
+
+	var args []*formulaValue
+	for _, arg := range node.args {
+		val, evalErr := arg.eval(ctx)
+		if nil != evalErr {
+			return nil, evalErr
+		}
+		args = append(args, val)
+	}
+
+	if "empty" == node.name {
+		ret = &formulaValue{kind: formulaValueBool, b: formulaValueEmpty == args[0].kind || (formulaValueString == args[0].kind && "" == args[0].str)}
+		return
+	}
+
+	var nums []float64
+	for _, arg := range args {
+		if formulaValueEmpty == arg.kind {
+			continue
+		}
+		num, ok := arg.number()
+		if !ok {
+			err = fmt.Errorf("[%s] is not a number", arg.string())
+			return
+		}
+		nums = append(nums, num)
+	}
+	if 1 > len(nums) {
+		ret = &formulaValue{}
+		return
+	}
+
+	ret = &formulaValue{kind: formulaValueNumber, num: nums[0]}
+	switch node.name {
+	case "abs":
+		ret.num = math.Abs(nums[0])
+	case "round":
+		digits := 0.0
+		if 1 < len(nums) {
+			digits = nums[1]
+		}
+		pow := math.Pow(10, digits)
+		ret.num = math.Round(nums[0]*pow) / pow
+	case "min":
+		for _, num := range nums[1:] {
+			ret.num = math.Min(ret.num, num)
+		}
+	case "max":
+		for _, num := range nums[1:] {
+			ret.num = math.Max(ret.num, num)
+		}
+	}
+	return
+}
+
+// formulaFuncArgs 为支持的函数及其参数个数范围。
+var formulaFuncArgs = map[string][2]int{
+	"if":    {3, 3},
+	"abs":   {1, 1},
+	"round": {1, 2},
+	"min":   {1, math.MaxInt},
+	"max":   {1, math.MaxInt},
+	"empty": {1, 1},
+}
+
+func walkFormulaNode(node formulaNode, visit func(formulaNode)) {
+	visit(node)
+	switch n := node.(type) {
+	case *formulaUnaryNode:
+		walkFormulaNode(n.operand, visit)
+	case *formulaBinaryNode:
+		walkFormulaNode(n.left, visit)
+		walkFormulaNode(n.right, visit)
+	case *formulaCallNode:
+		for _, arg := range n.args {
+			walkFormulaNode(arg, visit)
+		}
+	}
+}
+
+type formulaTokenKind int
+
+const (
+	formulaTokenNumber formulaTokenKind = iota
+	formulaTokenString
+	formulaTokenIdent
+	formulaTokenOp
+)
+
+type formulaToken struct {
+	kind formulaTokenKind
+	text string
+}
+
+func tokenizeFormula(expr string) (ret []*formulaToken, err error) {
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || ('.' == r && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || '.' == runes[i]) {
+				i++
+			}
+			ret = append(ret, &formulaToken{kind: formulaTokenNumber, text: string(runes[start:i])})
+		case '"' == r || '\'' == r:
+			start := i + 1
+			i++
+			for i < len(runes) && runes[i] != r {
+				i++
+			}
+			if i >= len(runes) {
+				err = fmt.Errorf("unterminated string")
+				return
+			}
+			ret = append(ret, &formulaToken{kind: formulaTokenString, text: string(runes[start:i])})
+			i++
+		case unicode.IsLetter(r) || '_' == r:
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || '_' == runes[i]) {
+				i++
+			}
+			ret = append(ret, &formulaToken{kind: formulaTokenIdent, text: string(runes[start:i])})
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if 1 == len([]rune(op)) && !strings.ContainsRune("+-*/%=!<>(),", r) {
+				err = fmt.Errorf("unexpected character [%s]", op)
+				return
+			}
+			ret = append(ret, &formulaToken{kind: formulaTokenOp, text: op})
+			i += len([]rune(op))
+		}
+	}
+	return
+}
+
+type formulaParser struct {
+	tokens []*formulaToken
+	pos    int
+}
+
+func (p *formulaParser) peek() *formulaToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return nil
+}
+
+// acceptOp 如果下一个记号是 ops 中的运算符（或者对应的关键字）则消费它并返回规范化后的运算符。
+func (p *formulaParser) acceptOp(ops ...string) string {
+	token := p.peek()
+	if nil == token {
+		return ""
+	}
+
+	text := token.text
+	if formulaTokenIdent == token.kind {
+		switch strings.ToLower(text) {
+		case "and":
+			text = "&&"
+		case "or":
+			text = "||"
+		case "not":
+			text = "!"
+		default:
+			return ""
+		}
+	} else if formulaTokenOp != token.kind {
+		return ""
+	}
+
+	for _, op := range ops {
+		if op == text {
+			p.pos++
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *formulaParser) expectOp(op string) error {
+	if "" == p.acceptOp(op) {
+		if token := p.peek(); nil != token {
+			return fmt.Errorf("expected [%s] but got [%s]", op, token.text)
+		}
+		return fmt.Errorf("expected [%s] but got end of formula", op)
+	}
+	return nil
+}
+
+func (p *formulaParser) parseOr() (ret formulaNode, err error) {
+	if ret, err = p.parseAnd(); nil != err {
+		return
+	}
+	for "" != p.acceptOp("||") {
+		right, parseErr := p.parseAnd()
+		if nil != parseErr {
+			return nil, parseErr
+		}
+		ret = &formulaBinaryNode{op: "||", left: ret, right: right}
+	}
+	return
+}
+
+func (p *formulaParser) parseAnd() (ret formulaNode, err error) {
+	if ret, err = p.parseNot(); nil != err {
+		return
+	}
+	for "" != p.acceptOp("&&") {
+		right, parseErr := p.parseNot()
+		if nil != parseErr {
+			return nil, parseErr
+		}
+		ret = &formulaBinaryNode{op: "&&", left: ret, right: right}
+	}
+	return
+}
+
+func (p *formulaParser) parseNot() (ret formulaNode, err error) {
+	if "" != p.acceptOp("!") {
+		operand, parseErr := p.parseNot()
+		if nil != parseErr {
+			return nil, parseErr
+		}
+		return &formulaUnaryNode{op: "!", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *formulaParser) parseComparison() (ret formulaNode, err error) {
+	if ret, err = p.parseAdditive(); nil != err {
+		return
+	}
+	if op := p.acceptOp("=", "==", "!=", "<", "<=", ">", ">="); "" != op {
+		right, parseErr := p.parseAdditive()
+		if nil != parseErr {
+			return nil, parseErr
+		}
+		ret = &formulaBinaryNode{op: op, left: ret, right: right}
+	}
+	return
+}
+
+func (p *formulaParser) parseAdditive() (ret formulaNode, err error) {
+	if ret, err = p.parseMultiplicative(); nil != err {
+		return
+	}
+	for op := p.acceptOp("+", "-"); "" != op; op = p.acceptOp("+", "-") {
+		right, parseErr := p.parseMultiplicative()
+		if nil != parseErr {
+			return nil, parseErr
+		}
+		ret = &formulaBinaryNode{op: op, left: ret, right: right}
+	}
+	return
+}
+
+func (p *formulaParser) parseMultiplicative() (ret formulaNode, err error) {
+	if ret, err = p.parseUnary(); nil != err {
+		return
+	}
+	for op := p.acceptOp("*", "/", "%"); "" != op; op = p.acceptOp("*", "/", "%") {
+		right, parseErr := p.parseUnary()
+		if nil != parseErr {
+			return nil, parseErr
+		}
+		ret = &formulaBinaryNode{op: op, left: ret, right: right}
+	}
+	return
+}
+
+func (p *formulaParser) parseUnary() (ret formulaNode, err error) {
+	if "" != p.acceptOp("-") {
+		operand, parseErr := p.parseUnary()
+		if nil != parseErr {
+			return nil, parseErr
+		}
+		return &formulaUnaryNode{op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *formulaParser) parsePrimary() (ret formulaNode, err error) {
+	token := p.peek()
+	if nil == token {
+		err = fmt.Errorf("unexpected end of formula")
+		return
+	}
+
+	switch token.kind {
+	case formulaTokenNumber:
+		p.pos++
+		num, parseErr := strconv.ParseFloat(token.text, 64)
+		if nil != parseErr {
+			return nil, fmt.Errorf("invalid number [%s]", token.text)
+		}
+		return &formulaLiteralNode{value: &formulaValue{kind: formulaValueNumber, num: num}}, nil
+	case formulaTokenString:
+		p.pos++
+		return &formulaLiteralNode{value: &formulaValue{kind: formulaValueString, str: token.text}}, nil
+	case formulaTokenIdent:
+		p.pos++
+		switch strings.ToLower(token.text) {
+		case "true":
+			return &formulaLiteralNode{value: &formulaValue{kind: formulaValueBool, b: true}}, nil
+		case "false":
+			return &formulaLiteralNode{value: &formulaValue{kind: formulaValueBool}}, nil
+		}
+
+		if "" == p.acceptOp("(") {
+			return &formulaRefNode{name: token.text}, nil
+		}
+		return p.parseCall(strings.ToLower(token.text))
+	}
+
+	if "" != p.acceptOp("(") {
+		if ret, err = p.parseOr(); nil != err {
+			return
+		}
+		err = p.expectOp(")")
+		return
+	}
+	err = fmt.Errorf("unexpected token [%s]", token.text)
+	return
+}
+
+func (p *formulaParser) parseCall(name string) (ret formulaNode, err error) {
+	if "prop" == name {
+		token := p.peek()
+		if nil == token || formulaTokenString != token.kind {
+			err = fmt.Errorf("prop() requires a key name")
+			return
+		}
+		p.pos++
+		if err = p.expectOp(")"); nil != err {
+			return
+		}
+		return &formulaRefNode{name: token.text}, nil
+	}
+
+	argCount, ok := formulaFuncArgs[name]
+	if !ok {
+		err = fmt.Errorf("unknown function [%s]", name)
+		return
+	}
+
+	call := &formulaCallNode{name: name}
+	if "" == p.acceptOp(")") {
+		for {
+			arg, parseErr := p.parseOr()
+			if nil != parseErr {
+				return nil, parseErr
+			}
+			call.args = append(call.args, arg)
+			if "" != p.acceptOp(")") {
+				break
+			}
+			if err = p.expectOp(","); nil != err {
+				return
+			}
+		}
+	}
+	if len(call.args) < argCount[0] || len(call.args) > argCount[1] {
+		err = fmt.Errorf("function [%s] got %d arguments", name, len(call.args))
+		return
+	}
+	ret = call
+	return
+}
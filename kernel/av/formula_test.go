@@ -0,0 +1,75 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import "testing"
+
+func TestFormulaEval(t *testing.T) {
+	priceKey := &Key{ID: "price", Name: "Unit price", Type: KeyTypeNumber}
+	qtyKey := &Key{ID: "qty", Name: "Qty", Alias: "quantity", Type: KeyTypeNumber}
+	nameKey := &Key{ID: "name", Name: "Name", Type: KeyTypeText}
+	doneKey := &Key{ID: "done", Name: "Done", Type: KeyTypeCheckbox}
+	totalKey := &Key{ID: "total", Name: "Total", Type: KeyTypeFormula, Formula: "prop(\"Unit price\") * quantity"}
+	ctx := &FormulaContext{
+		Keys: []*Key{priceKey, qtyKey, nameKey, doneKey, totalKey},
+		Values: map[string]*Value{
+			"price": {Type: KeyTypeNumber, Number: NewFormattedValueNumber(2.5, NumberFormatNone)},
+			"qty":   {Type: KeyTypeNumber, Number: NewFormattedValueNumber(4, NumberFormatNone)},
+			"name":  {Type: KeyTypeText, Text: &ValueText{Content: "foo"}},
+			"done":  {Type: KeyTypeCheckbox, Checkbox: &ValueCheckbox{Checked: true}},
+		},
+	}
+
+	if num, err := ctx.EvalKey(totalKey); nil != err || 10 != num.Content {
+		t.Fatalf("expected total [10], got [%v] [%v]", num, err)
+	}
+
+	for expr, expected := range map[string]bool{
+		"Total > 9 && Done":              true,
+		"1 + 2 * 3 = 7":                  true,
+		"(1 + 2) * 3 = 7":                false,
+		"Name = 'foo' and not Done":      false,
+		"Name + '-' + Qty = \"foo-4\"":   true,
+		"if(Done, Qty, 0) = max(1, 4)":   true,
+		"round(Total / 3, 2) = 3.33":     true,
+		"empty(1 / 0) && !empty(Name)":   true,
+		"Total >= quantity * 2.5 || Bad": true,
+	} {
+		got, err := ctx.EvalBool(expr)
+		if nil != err {
+			t.Fatalf("eval [%s] failed: %s", expr, err)
+		}
+		if expected != got {
+			t.Fatalf("expected [%s] to be [%v]", expr, expected)
+		}
+	}
+
+	for _, expr := range []string{"Qty *", "Qty & 1", "foo(1)", "prop(Qty)", "'foo"} {
+		if _, err := ParseFormula(expr); nil == err {
+			t.Fatalf("expected [%s] invalid", expr)
+		}
+	}
+	if _, err := ctx.EvalBool("Unknown > 1"); nil == err {
+		t.Fatalf("expected unknown key error")
+	}
+
+	selfKey := &Key{ID: "self", Name: "Self", Type: KeyTypeFormula, Formula: "Self + 1"}
+	ctx.Keys = append(ctx.Keys, selfKey)
+	if _, err := ctx.EvalKey(selfKey); nil == err {
+		t.Fatalf("expected self reference error")
+	}
+}
@@ -0,0 +1,151 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// 渲染时可以指定区域设置（比如 en-US、de-DE，也支持 en_US 这种界面语言的写法），用于格式化数字的千位分隔符和小数点以及日期的年月日顺序。
+// 调用方没有指定区域设置时不做本地化，使用默认格式。
+
+// parseLocale 解析区域设置，无法解析时返回 false。
+func parseLocale(locale string) (ret language.Tag, ok bool) {
+	if "" == locale {
+		return
+	}
+
+	ret, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if nil != err {
+		return
+	}
+	ok = true
+	return
+}
+
+// formatLocaleNumberDecimals 按区域设置格式化数字并保留 decimals 位小数。
+func formatLocaleNumberDecimals(content float64, decimals int, locale string) string {
+	tag, ok := parseLocale(locale)
+	if !ok {
+		return strconv.FormatFloat(content, 'f', decimals, 64)
+	}
+	return message.NewPrinter(tag).Sprint(number.Decimal(content, number.NoSeparator(), number.Scale(decimals)))
+}
+
+// formatLocaleNumber 按区域设置格式化数字，区域设置为空或者无法解析时使用默认格式。
+func formatLocaleNumber(content float64, format NumberFormat, locale string) string {
+	tag, ok := parseLocale(locale)
+	if !ok {
+		return formatNumber(content, format)
+	}
+
+	p := message.NewPrinter(tag)
+	var symbol string
+	decimals := 2
+	switch format {
+	case NumberFormatNone:
+		return p.Sprint(number.Decimal(content, number.NoSeparator(), number.MaxFractionDigits(15)))
+	case NumberFormatCommas:
+		return p.Sprint(number.Decimal(content, number.MaxFractionDigits(6)))
+	case NumberFormatPercent:
+		return p.Sprint(number.Decimal(content*100, number.MaxFractionDigits(2))) + "%"
+	case NumberFormatUSDollar:
+		symbol = "$"
+	case NumberFormatYuan:
+		symbol = "CN¥"
+	case NumberFormatEuro:
+		symbol = "€"
+	case NumberFormatPound:
+		symbol = "£"
+	case NumberFormatYen:
+		symbol, decimals = "¥", 0
+	case NumberFormatRuble:
+		symbol = "₽"
+	case NumberFormatRupee:
+		symbol = "₹"
+	case NumberFormatWon:
+		symbol, decimals = "₩", 0
+	case NumberFormatCanadianDollar:
+		symbol = "CA$"
+	case NumberFormatFranc:
+		symbol = "CHF"
+	default:
+		return formatNumber(content, format)
+	}
+	return symbol + p.Sprint(number.Decimal(content, number.Scale(decimals)))
+}
+
+// FormatTime 按区域设置格式化时间，区域设置为空或者无法解析时使用 2006-01-02 15:04 格式。
+func FormatTime(t time.Time, isNotTime bool, locale string) string {
+	layout := "2006-01-02"
+	if tag, ok := parseLocale(locale); ok {
+		base, _ := tag.Base()
+		region, _ := tag.Region()
+		switch base.String() {
+		case "en":
+			if "US" == region.String() {
+				layout = "01/02/2006"
+			} else if "GB" == region.String() || "AU" == region.String() || "NZ" == region.String() || "IE" == region.String() {
+				layout = "02/01/2006"
+			}
+		case "de", "ru", "pl", "tr", "fi", "nb", "da", "cs", "uk":
+			layout = "02.01.2006"
+		case "fr", "es", "it", "pt", "vi":
+			layout = "02/01/2006"
+		case "zh", "ja":
+			layout = "2006/01/02"
+		}
+	}
+	if !isNotTime {
+		layout += " 15:04"
+	}
+	return t.Format(layout)
+}
+
+// FormatTime 按区域设置重新生成日期的格式化内容。
+func (date *ValueDate) FormatTime(locale string) {
+	if !date.IsNotEmpty {
+		return
+	}
+
+	date.FormattedContent = FormatTime(time.UnixMilli(date.Content), date.IsNotTime, locale)
+	if date.HasEndDate && date.IsNotEmpty2 {
+		date.FormattedContent += " → " + FormatTime(time.UnixMilli(date.Content2), date.IsNotTime, locale)
+	}
+}
+
+// FormatTime 按区域设置重新生成创建时间的格式化内容。
+func (created *ValueCreated) FormatTime(locale string) {
+	created.FormattedContent = FormatTime(time.UnixMilli(created.Content), false, locale)
+	if 0 < created.Content2 {
+		created.FormattedContent += " → " + FormatTime(time.UnixMilli(created.Content2), false, locale)
+	}
+}
+
+// FormatTime 按区域设置重新生成更新时间的格式化内容。
+func (updated *ValueUpdated) FormatTime(locale string) {
+	updated.FormattedContent = FormatTime(time.UnixMilli(updated.Content), false, locale)
+	if 0 < updated.Content2 {
+		updated.FormattedContent += " → " + FormatTime(time.UnixMilli(updated.Content2), false, locale)
+	}
+}
@@ -0,0 +1,54 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import "unicode"
+
+// 文本列输入掩码中的占位符：A 匹配一个字母，0 匹配一个数字，* 匹配任意一个字符，其他字符需要原样匹配。
+// 比如掩码 AAA-000 可以匹配 SKU-123。
+
+// MatchMask 判断 content 是否符合输入掩码 mask，掩码为空时总是符合。
+func MatchMask(content, mask string) bool {
+	if "" == mask {
+		return true
+	}
+
+	contentRunes, maskRunes := []rune(content), []rune(mask)
+	if len(contentRunes) != len(maskRunes) {
+		return false
+	}
+
+	for i, m := range maskRunes {
+		c := contentRunes[i]
+		switch m {
+		case 'A':
+			if !unicode.IsLetter(c) {
+				return false
+			}
+		case '0':
+			if !unicode.IsDigit(c) {
+				return false
+			}
+		case '*':
+		default:
+			if c != m {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+)
+
+// MergeAttributeViews 在值级别合并同一个属性视图的两个版本，用于处理多端同步冲突。
+// 以 remote 为基础，合并 local 中新增的列、行和视图；同一个单元格两端都有值时保留更新时间较新的值，时间相同时保留 remote 的值。
+// 合并只做并集，一端删除的列和行会被另一端保留下来。
+func MergeAttributeViews(local, remote *AttributeView) (ret *AttributeView) {
+	ret = &AttributeView{}
+	data, err := gulu.JSON.MarshalJSON(remote)
+	if nil != err {
+		logging.LogErrorf("marshal attribute view [%s] failed: %s", remote.ID, err)
+		return remote
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); nil != err {
+		logging.LogErrorf("unmarshal attribute view [%s] failed: %s", remote.ID, err)
+		return remote
+	}
+
+	for _, localKeyValues := range local.KeyValues {
+		keyValues, _ := ret.GetKeyValues(localKeyValues.Key.ID)
+		if nil == keyValues {
+			ret.KeyValues = append(ret.KeyValues, localKeyValues)
+			continue
+		}
+
+		for _, localVal := range localKeyValues.Values {
+			merged := false
+			for i, val := range keyValues.Values {
+				if val.BlockID != localVal.BlockID {
+					continue
+				}
+
+				if getValueUpdated(localVal) > getValueUpdated(val) {
+					keyValues.Values[i] = localVal
+				}
+				merged = true
+				break
+			}
+			if !merged {
+				keyValues.Values = append(keyValues.Values, localVal)
+			}
+		}
+	}
+
+	for _, localView := range local.Views {
+		view := ret.GetView(localView.ID)
+		if nil == view {
+			ret.Views = append(ret.Views, localView)
+			continue
+		}
+
+		if nil == view.Table || nil == localView.Table {
+			continue
+		}
+
+		for _, localCol := range localView.Table.Columns {
+			exist := false
+			for _, col := range view.Table.Columns {
+				if col.ID == localCol.ID {
+					exist = true
+					break
+				}
+			}
+			if !exist {
+				view.Table.Columns = append(view.Table.Columns, localCol)
+			}
+		}
+
+		for _, rowID := range localView.Table.RowIDs {
+			if !gulu.Str.Contains(rowID, view.Table.RowIDs) {
+				view.Table.RowIDs = append(view.Table.RowIDs, rowID)
+			}
+		}
+	}
+	return
+}
+
+// getValueUpdated 返回值的更新时间，主键值使用块的更新时间。
+func getValueUpdated(value *Value) (ret int64) {
+	ret = value.UpdatedAt
+	if nil != value.Block && value.Block.Updated > ret {
+		ret = value.Block.Updated
+	}
+	return
+}
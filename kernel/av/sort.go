@@ -25,6 +25,13 @@ type ViewSort struct {
 	Order  SortOrder `json:"order"`  // 排序顺序
 }
 
+// SortPreset 描述了命名的排序预设，属于整个属性视图，可以应用到任意视图。
+type SortPreset struct {
+	ID    string      `json:"id"`    // 预设 ID
+	Name  string      `json:"name"`  // 预设名称，比如“按截止日期”
+	Sorts []*ViewSort `json:"sorts"` // 排序规则，按顺序依次比较
+}
+
 type SortOrder string
 
 const (
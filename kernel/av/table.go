@@ -21,7 +21,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
@@ -35,8 +38,54 @@ type LayoutTable struct {
 	Filters  []*ViewFilter      `json:"filters"`  // 过滤规则
 	Sorts    []*ViewSort        `json:"sorts"`    // 排序规则
 	PageSize int                `json:"pageSize"` // 每页行数
+
+	DefaultSorts []*ViewSort `json:"defaultSorts,omitempty"` // 默认排序规则，重置排序时复制到 Sorts
+
+	CalcPosition CalcPosition `json:"calcPosition,omitempty"` // 计算行位置，为空时默认在底部
+
+	RowColors map[string]string `json:"rowColors,omitempty"` // 行颜色，行 ID -> 颜色
+
+	TopRowIDs []string `json:"topRowIds,omitempty"` // 置顶行 ID，这些行总是排在其他行之前，但仍然参与计算
+
+	ShowSummaryRow bool `json:"showSummaryRow,omitempty"` // 是否将计算结果作为汇总行显示
+
+	FrozenColumnCount int `json:"frozenColumnCount,omitempty"` // 冻结列数，横向滚动时前 N 列保持固定，仅用于布局提示
+
+	RowBindingState RowBindingState `json:"rowBindingState,omitempty"` // 按行是否绑定块过滤，独立于列过滤规则，为空时显示所有行
+
+	ColumnGroups []*ViewColumnGroup `json:"columnGroups,omitempty"` // 列分组，用于绘制跨越多列的分组表头，不在任何分组中的列不分组
+
+	GroupBy          string `json:"groupBy,omitempty"`          // 分组列 ID，按该列的值对行分组，为空时不分组
+	ShowGroupPercent bool   `json:"showGroupPercent,omitempty"` // 是否在分组表头中显示分组行数占所有过滤后行数的百分比
+
+	VisibilityFormula string `json:"visibilityFormula,omitempty"` // 行可见性公式，计算结果为假的行不显示，为空时显示所有行
+}
+
+// ViewColumnGroup 描述了表格视图中的列分组，一列最多属于一个分组。
+type ViewColumnGroup struct {
+	ID        string   `json:"id"`        // 分组 ID
+	Name      string   `json:"name"`      // 分组名称，比如“财务”
+	ColumnIDs []string `json:"columnIds"` // 分组中的列 ID，按视图中列的顺序排列
 }
 
+// CalcPosition 描述了计算行在表格中的显示位置。
+type CalcPosition string
+
+const (
+	CalcPositionTop    CalcPosition = "top"    // 顶部
+	CalcPositionBottom CalcPosition = "bottom" // 底部
+	CalcPositionBoth   CalcPosition = "both"   // 顶部和底部
+)
+
+// RowBindingState 描述了视图中按行是否绑定块过滤的状态。
+type RowBindingState string
+
+const (
+	RowBindingStateAny      RowBindingState = ""         // 所有行
+	RowBindingStateBound    RowBindingState = "bound"    // 仅显示绑定了块的行
+	RowBindingStateDetached RowBindingState = "detached" // 仅显示游离行
+)
+
 type ViewTableColumn struct {
 	ID string `json:"id"` // 列 ID
 
@@ -45,8 +94,18 @@ type ViewTableColumn struct {
 	Pin    bool        `json:"pin"`            // 是否固定
 	Width  string      `json:"width"`          // 列宽度
 	Calc   *ColumnCalc `json:"calc,omitempty"` // 计算
+
+	PinSide PinSide `json:"pinSide,omitempty"` // 固定在哪一侧，为空时固定在左侧
 }
 
+// PinSide 描述了列固定的位置。
+type PinSide string
+
+const (
+	PinSideLeft  PinSide = "left"  // 固定在左侧
+	PinSideRight PinSide = "right" // 固定在右侧，渲染时排在所有列之后
+)
+
 type Calculable interface {
 	CalcCols()
 }
@@ -79,6 +138,13 @@ const (
 	CalcOperatorUnchecked         CalcOperator = "Unchecked"
 	CalcOperatorPercentChecked    CalcOperator = "Percent checked"
 	CalcOperatorPercentUnchecked  CalcOperator = "Percent unchecked"
+
+	CalcOperatorLatestValue  CalcOperator = "Latest value"  // 汇总列：关联块中更新时间最新的非空值
+	CalcOperatorFirstRelated CalcOperator = "First related" // 汇总列：第一个关联块的值
+
+	CalcOperatorPercentOfParent CalcOperator = "Percent of parent" // 汇总列：通过自关联找到父行，计算当前行的值占父行下所有子行之和的百分比
+	CalcOperatorPercentDone     CalcOperator = "Percent done"      // 汇总列：关联行中已完成（复选框勾选或者选项等于 DoneOption）的百分比
+	CalcOperatorCountByOption   CalcOperator = "Count by option"   // 汇总列：按目标选项列的选项统计关联行数量，比如 3 High, 2 Low
 )
 
 func (value *Value) Compare(other *Value) int {
@@ -89,7 +155,7 @@ func (value *Value) Compare(other *Value) int {
 		return 1
 	}
 
-	switch value.Type {
+	switch value.Type.BaseType() {
 	case KeyTypeBlock:
 		if nil != value.Block && nil != other.Block {
 			return strings.Compare(value.Block.Content, other.Block.Content)
@@ -252,7 +318,7 @@ func (value *Value) CompareOperator(other *Value, operator FilterOperator, attrV
 		}
 
 		for _, blockID := range relVal.Relation.BlockIDs {
-			destVal := destAv.GetValue(rollupKey.Rollup.KeyID, blockID)
+			destVal := destAv.GetValue(rollupKey.Rollup.GetTargetKeyID(destAv, blockID), blockID)
 			if nil == destVal {
 				continue
 			}
@@ -391,7 +457,6 @@ func (value *Value) compareOperator(other *Value, operator FilterOperator, attrV
 		case FilterOperatorIsNotEmpty:
 			return value.Date.IsNotEmpty
 		case FilterOperatorIsRelativeToToday:
-			// TODO: date filter (relative to today)
 			return value.Date.Content >= other.Date.Content && value.Date.Content <= other.Date.Content2
 		}
 	}
@@ -419,8 +484,7 @@ func (value *Value) compareOperator(other *Value, operator FilterOperator, attrV
 		case FilterOperatorIsNotEmpty:
 			return value.Created.IsNotEmpty
 		case FilterOperatorIsRelativeToToday:
-			// TODO: date filter (relative to today)
-			return value.Date.Content >= other.Date.Content && value.Date.Content <= other.Date.Content2
+			return value.Created.Content >= other.Created.Content && value.Created.Content <= other.Created.Content2
 		}
 	}
 
@@ -447,8 +511,7 @@ func (value *Value) compareOperator(other *Value, operator FilterOperator, attrV
 		case FilterOperatorIsNotEmpty:
 			return value.Updated.IsNotEmpty
 		case FilterOperatorIsRelativeToToday:
-			// TODO: date filter (relative to today)
-			return value.Date.Content >= other.Date.Content && value.Date.Content <= other.Date.Content2
+			return value.Updated.Content >= other.Updated.Content && value.Updated.Content <= other.Updated.Content2
 		}
 	}
 
@@ -645,6 +708,24 @@ func (value *Value) compareOperator(other *Value, operator FilterOperator, attrV
 		}
 	}
 
+	if nil != value.Relation && nil != other.Number { // 按关联数量过滤
+		count := float64(value.Relation.Count)
+		switch operator {
+		case FilterOperatorIsEqual:
+			return count == other.Number.Content
+		case FilterOperatorIsNotEqual:
+			return count != other.Number.Content
+		case FilterOperatorIsGreater:
+			return count > other.Number.Content
+		case FilterOperatorIsGreaterOrEqual:
+			return count >= other.Number.Content
+		case FilterOperatorIsLess:
+			return count < other.Number.Content
+		case FilterOperatorIsLessOrEqual:
+			return count <= other.Number.Content
+		}
+	}
+
 	if nil != value.Relation && nil != other.Relation {
 		switch operator {
 		case FilterOperatorContains:
@@ -681,15 +762,46 @@ func (value *Value) compareOperator(other *Value, operator FilterOperator, attrV
 
 // Table 描述了表格实例的结构。
 type Table struct {
-	ID       string         `json:"id"`       // 表格布局 ID
-	Icon     string         `json:"icon"`     // 表格图标
-	Name     string         `json:"name"`     // 表格名称
-	Filters  []*ViewFilter  `json:"filters"`  // 过滤规则
-	Sorts    []*ViewSort    `json:"sorts"`    // 排序规则
-	Columns  []*TableColumn `json:"columns"`  // 表格列
-	Rows     []*TableRow    `json:"rows"`     // 表格行
-	RowCount int            `json:"rowCount"` // 表格总行数
-	PageSize int            `json:"pageSize"` // 每页行数
+	ID        string         `json:"id"`        // 表格布局 ID
+	Icon      string         `json:"icon"`      // 表格图标
+	Name      string         `json:"name"`      // 表格名称
+	Filters   []*ViewFilter  `json:"filters"`   // 过滤规则
+	Sorts     []*ViewSort    `json:"sorts"`     // 排序规则
+	Columns   []*TableColumn `json:"columns"`   // 表格列
+	Rows      []*TableRow    `json:"rows"`      // 表格行
+	RowCount  int            `json:"rowCount"`  // 表格总行数
+	PageSize  int            `json:"pageSize"`  // 每页行数
+	PageCount int            `json:"pageCount"` // 总页数
+	HasMore   bool           `json:"hasMore"`   // 当前页之后是否还有更多行
+
+	CalcPosition CalcPosition `json:"calcPosition"` // 计算行位置
+
+	AllColumnsHidden bool `json:"allColumnsHidden"` // 是否所有列都被隐藏，用于提示用户取消隐藏
+
+	TopRowIDs []string `json:"topRowIds"` // 置顶行 ID
+
+	FrozenColumnCount int `json:"frozenColumnCount"` // 冻结列数，横向滚动时前 N 列保持固定
+
+	RowBindingState RowBindingState `json:"rowBindingState"` // 按行是否绑定块过滤的状态
+
+	ColumnGroups []*ViewColumnGroup `json:"columnGroups"` // 列分组，只包含存在的列，按列的顺序排列
+
+	GroupBy          string                 `json:"groupBy"`                   // 分组列 ID，为空时不分组
+	ShowGroupPercent bool                   `json:"showGroupPercent"`          // 是否显示分组行数占比
+	Groups           []*TableGroup          `json:"groups,omitempty"`          // 行分组，基于过滤和排序后、分页前的所有行
+	GroupTotalCalcs  map[string]*ColumnCalc `json:"groupTotalCalcs,omitempty"` // 分组时所有过滤后的行的列计算结果，列 ID -> 计算结果
+
+	RelationContextBlockID string `json:"-"` // 渲染时传入的关联上下文块 ID，用于 Relation matches context 过滤
+	CurrentAuthor          string `json:"-"` // 渲染时的当前用户名，用于 Author is me 过滤
+}
+
+// TableGroup 描述了按分组列的值分组后的一组行。
+type TableGroup struct {
+	Value   string                 `json:"value"`             // 分组列的值，值为空的行在值为空字符串的分组中
+	RowIDs  []string               `json:"rowIds"`            // 分组中的行 ID，按渲染顺序排列
+	Count   int                    `json:"count"`             // 分组中的行数
+	Percent float64                `json:"percent,omitempty"` // 分组行数占所有过滤后行数的百分比，仅在显示分组行数占比时计算
+	Calcs   map[string]*ColumnCalc `json:"calcs"`             // 分组内的列计算结果，列 ID -> 计算结果
 }
 
 type TableColumn struct {
@@ -697,19 +809,31 @@ type TableColumn struct {
 	Name   string      `json:"name"`   // 列名
 	Type   KeyType     `json:"type"`   // 列类型
 	Icon   string      `json:"icon"`   // 列图标
+	Alias  string      `json:"alias"`  // 列别名
 	Wrap   bool        `json:"wrap"`   // 是否换行
 	Hidden bool        `json:"hidden"` // 是否隐藏
 	Pin    bool        `json:"pin"`    // 是否固定
 	Width  string      `json:"width"`  // 列宽度
 	Calc   *ColumnCalc `json:"calc"`   // 计算
 
+	PinSide PinSide `json:"pinSide"` // 列固定的位置
+
+	EmptyPlaceholder string `json:"emptyPlaceholder"` // 空值占位符
+
 	// 以下是某些列类型的特有属性
 
-	Options      []*SelectOption `json:"options,omitempty"`  // 选项列表
-	NumberFormat NumberFormat    `json:"numberFormat"`       // 列数字格式化
-	Template     string          `json:"template"`           // 模板内容
-	Relation     *Relation       `json:"relation,omitempty"` // 关联列
-	Rollup       *Rollup         `json:"rollup,omitempty"`   // 汇总列
+	Mask       string `json:"mask,omitempty"`       // 文本列的输入掩码，用于客户端输入提示
+	MaskStrict bool   `json:"maskStrict,omitempty"` // 是否拒绝不符合输入掩码的值
+
+	Options      []*SelectOption     `json:"options,omitempty"`     // 选项列表
+	NumberFormat NumberFormat        `json:"numberFormat"`          // 列数字格式化
+	Template     string              `json:"template"`              // 模板内容
+	Relation     *Relation           `json:"relation,omitempty"`    // 关联列
+	Rollup       *Rollup             `json:"rollup,omitempty"`      // 汇总列
+	SourceKeyID  string              `json:"sourceKeyID,omitempty"` // 计算列的来源列 ID
+	CountMode    TextLengthCountMode `json:"countMode,omitempty"`   // 文本长度列的计数方式
+	AttrName     string              `json:"attrName,omitempty"`    // 块属性列的块属性名
+	Formula      string              `json:"formula,omitempty"`     // 公式列的公式表达式
 }
 
 type TableCell struct {
@@ -721,8 +845,10 @@ type TableCell struct {
 }
 
 type TableRow struct {
-	ID    string       `json:"id"`
-	Cells []*TableCell `json:"cells"`
+	ID      string       `json:"id"`
+	Cells   []*TableCell `json:"cells"`
+	Color   string       `json:"color,omitempty"`   // 行颜色
+	Summary bool         `json:"summary,omitempty"` // 是否为汇总行，汇总行的单元格值为各列的计算结果
 }
 
 func (row *TableRow) GetBlockValue() (ret *Value) {
@@ -735,6 +861,159 @@ func (row *TableRow) GetBlockValue() (ret *Value) {
 	return
 }
 
+// CompactTable 描述了表格实例的精简结构，用于减小大表格的传输体积。
+// 单元格按列顺序排列，空单元格为 null，单元格值中只保留非空的字段。
+type CompactTable struct {
+	ID               string             `json:"id"`
+	Icon             string             `json:"icon"`
+	Name             string             `json:"name"`
+	Filters          []*ViewFilter      `json:"filters"`
+	Sorts            []*ViewSort        `json:"sorts"`
+	Columns          []*TableColumn     `json:"columns"`
+	Rows             []*CompactTableRow `json:"rows"`
+	RowCount         int                `json:"rowCount"`
+	PageSize         int                `json:"pageSize"`
+	PageCount        int                `json:"pageCount"`
+	HasMore          bool               `json:"hasMore"`
+	AllColumnsHidden bool               `json:"allColumnsHidden,omitempty"`
+
+	CalcPosition      CalcPosition       `json:"calcPosition"`
+	TopRowIDs         []string           `json:"topRowIds,omitempty"`
+	FrozenColumnCount int                `json:"frozenColumnCount,omitempty"`
+	RowBindingState   RowBindingState    `json:"rowBindingState,omitempty"`
+	ColumnGroups      []*ViewColumnGroup `json:"columnGroups,omitempty"`
+
+	GroupBy          string                 `json:"groupBy,omitempty"`
+	ShowGroupPercent bool                   `json:"showGroupPercent,omitempty"`
+	Groups           []*TableGroup          `json:"groups,omitempty"`
+	GroupTotalCalcs  map[string]*ColumnCalc `json:"groupTotalCalcs,omitempty"`
+}
+
+type CompactTableRow struct {
+	ID      string              `json:"id"`
+	Cells   []*CompactTableCell `json:"cells"`
+	Color   string              `json:"color,omitempty"`
+	Summary bool                `json:"summary,omitempty"`
+}
+
+type CompactTableCell struct {
+	ID      string `json:"id"`
+	Value   *Value `json:"value,omitempty"`
+	Color   string `json:"color,omitempty"`
+	BgColor string `json:"bgColor,omitempty"`
+}
+
+// NewSummaryRow 使用各列的计算结果生成汇总行，需要在 CalcCols 之后调用。
+func (table *Table) NewSummaryRow() (ret *TableRow) {
+	ret = &TableRow{ID: ast.NewNodeID(), Summary: true}
+	for _, col := range table.Columns {
+		cell := &TableCell{ID: ast.NewNodeID(), ValueType: col.Type}
+		if nil != col.Calc && CalcOperatorNone != col.Calc.Operator {
+			cell.Value = col.Calc.Result
+		}
+		ret.Cells = append(ret.Cells, cell)
+	}
+	return
+}
+
+// Compact 返回表格的精简结构。
+func (table *Table) Compact() (ret *CompactTable) {
+	ret = &CompactTable{
+		ID:               table.ID,
+		Icon:             table.Icon,
+		Name:             table.Name,
+		Filters:          table.Filters,
+		Sorts:            table.Sorts,
+		Columns:          table.Columns,
+		Rows:             []*CompactTableRow{},
+		RowCount:         table.RowCount,
+		PageSize:         table.PageSize,
+		PageCount:        table.PageCount,
+		HasMore:          table.HasMore,
+		AllColumnsHidden: table.AllColumnsHidden,
+
+		CalcPosition:      table.CalcPosition,
+		TopRowIDs:         table.TopRowIDs,
+		FrozenColumnCount: table.FrozenColumnCount,
+		RowBindingState:   table.RowBindingState,
+		ColumnGroups:      table.ColumnGroups,
+
+		GroupBy:          table.GroupBy,
+		ShowGroupPercent: table.ShowGroupPercent,
+		Groups:           table.Groups,
+		GroupTotalCalcs:  table.GroupTotalCalcs,
+	}
+
+	for _, row := range table.Rows {
+		compactRow := &CompactTableRow{ID: row.ID, Color: row.Color, Summary: row.Summary}
+		for _, cell := range row.Cells {
+			var val *Value
+			if nil != cell.Value {
+				val = cell.Value.compact()
+			}
+			if nil == val && "" == cell.Color && "" == cell.BgColor {
+				compactRow.Cells = append(compactRow.Cells, nil)
+				continue
+			}
+			compactRow.Cells = append(compactRow.Cells, &CompactTableCell{ID: cell.ID, Value: val, Color: cell.Color, BgColor: cell.BgColor})
+		}
+		ret.Rows = append(ret.Rows, compactRow)
+	}
+	return
+}
+
+// compact 返回去掉空字段后的值，所属列和行可以从表格结构中得到所以也会去掉，值为空时返回 nil。
+func (value *Value) compact() (ret *Value) {
+	ret = &Value{ID: value.ID, IsDetached: value.IsDetached, IsBlockDeleted: value.IsBlockDeleted, Locked: value.Locked, Block: value.Block}
+	empty := nil == value.Block && !value.Locked
+	if nil != value.Text && "" != value.Text.Content {
+		ret.Text, empty = value.Text, false
+	}
+	if nil != value.Number && value.Number.IsNotEmpty {
+		ret.Number, empty = value.Number, false
+	}
+	if nil != value.Date && value.Date.IsNotEmpty {
+		ret.Date, empty = value.Date, false
+	}
+	if 0 < len(value.MSelect) {
+		ret.MSelect, empty = value.MSelect, false
+	}
+	if nil != value.URL && "" != value.URL.Content {
+		ret.URL, empty = value.URL, false
+	}
+	if nil != value.Email && "" != value.Email.Content {
+		ret.Email, empty = value.Email, false
+	}
+	if nil != value.Phone && "" != value.Phone.Content {
+		ret.Phone, empty = value.Phone, false
+	}
+	if 0 < len(value.MAsset) {
+		ret.MAsset, empty = value.MAsset, false
+	}
+	if nil != value.Template && "" != value.Template.Content {
+		ret.Template, empty = value.Template, false
+	}
+	if nil != value.Created && value.Created.IsNotEmpty {
+		ret.Created, empty = value.Created, false
+	}
+	if nil != value.Updated && value.Updated.IsNotEmpty {
+		ret.Updated, empty = value.Updated, false
+	}
+	if nil != value.Checkbox && value.Checkbox.Checked {
+		ret.Checkbox, empty = value.Checkbox, false
+	}
+	if nil != value.Relation && 0 < len(value.Relation.BlockIDs) {
+		ret.Relation, empty = value.Relation, false
+	}
+	if nil != value.Rollup && 0 < len(value.Rollup.Contents) {
+		ret.Rollup, empty = value.Rollup, false
+	}
+	if empty {
+		return nil
+	}
+	return
+}
+
 func (table *Table) GetType() LayoutType {
 	return LayoutTypeTable
 }
@@ -744,6 +1023,8 @@ func (table *Table) GetID() string {
 }
 
 func (table *Table) SortRows() {
+	defer table.moveTopRows()
+
 	if 1 > len(table.Sorts) {
 		return
 	}
@@ -763,7 +1044,8 @@ func (table *Table) SortRows() {
 		}
 	}
 
-	sort.Slice(table.Rows, func(i, j int) bool {
+	// 排序值相同的行最后按行 ID 排序，保证每次渲染的顺序一致，避免分页加载时行顺序跳动
+	sort.SliceStable(table.Rows, func(i, j int) bool {
 		for _, colIndexSort := range colIndexSorts {
 			result := table.Rows[i].Cells[colIndexSort.Index].Value.Compare(table.Rows[j].Cells[colIndexSort.Index].Value)
 			if 0 == result {
@@ -775,10 +1057,60 @@ func (table *Table) SortRows() {
 			}
 			return 0 < result
 		}
-		return false
+		return table.Rows[i].ID < table.Rows[j].ID
 	})
 }
 
+// moveTopRows 将置顶行按照置顶顺序移动到最前面，其他行保持排序后的顺序。
+func (table *Table) moveTopRows() {
+	if 1 > len(table.TopRowIDs) {
+		return
+	}
+
+	rows := map[string]*TableRow{}
+	for _, row := range table.Rows {
+		rows[row.ID] = row
+	}
+
+	var topRows []*TableRow
+	for _, rowID := range table.TopRowIDs {
+		if row := rows[rowID]; nil != row {
+			topRows = append(topRows, row)
+			delete(rows, rowID)
+		}
+	}
+	if 1 > len(topRows) {
+		return
+	}
+
+	for _, row := range table.Rows {
+		if _, ok := rows[row.ID]; ok {
+			topRows = append(topRows, row)
+		}
+	}
+	table.Rows = topRows
+}
+
+// FilterRowsByBindingState 按行是否绑定块过滤行，独立于列过滤规则。
+// 按过滤规则删除行等操作不应调用该函数，否则会把被隐藏的行当作不满足过滤规则的行。
+func (table *Table) FilterRowsByBindingState() {
+	if RowBindingStateAny == table.RowBindingState {
+		return
+	}
+
+	rows := []*TableRow{}
+	for _, row := range table.Rows {
+		blockValue := row.GetBlockValue()
+		if nil == blockValue {
+			continue
+		}
+		if (RowBindingStateBound == table.RowBindingState) != blockValue.IsDetached {
+			rows = append(rows, row)
+		}
+	}
+	table.Rows = rows
+}
+
 func (table *Table) FilterRows(attrView *AttributeView) {
 	if 1 > len(table.Filters) {
 		return
@@ -794,12 +1126,159 @@ func (table *Table) FilterRows(attrView *AttributeView) {
 		}
 	}
 
+	// 关联列目标属性视图中现存的块，用于 Relation has orphan 过滤
+	liveDestBlockIDs := map[string]map[string]bool{}
+	for _, f := range table.Filters {
+		if FilterOperatorRelationHasOrphan != f.Operator {
+			continue
+		}
+
+		relKey, _ := attrView.GetKey(f.Column)
+		if nil == relKey || nil == relKey.Relation {
+			continue
+		}
+
+		if _, ok := liveDestBlockIDs[relKey.ID]; ok {
+			continue
+		}
+
+		destAv := attrView
+		if relKey.Relation.AvID != attrView.ID {
+			destAv, _ = ParseAttributeView(relKey.Relation.AvID)
+		}
+		blockIDs := map[string]bool{}
+		if nil != destAv {
+			for _, v := range destAv.GetBlockKeyValues().Values {
+				blockIDs[v.BlockID] = true
+			}
+		}
+		liveDestBlockIDs[relKey.ID] = blockIDs
+	}
+
+	// 双向关联列的回链，目标块 ID -> 回链到的行 ID，用于 Relation asymmetric 过滤
+	backRelBlockIDs := map[string]map[string]map[string]bool{}
+	for _, f := range table.Filters {
+		if FilterOperatorRelationAsymmetric != f.Operator {
+			continue
+		}
+
+		relKey, _ := attrView.GetKey(f.Column)
+		if nil == relKey || nil == relKey.Relation || !relKey.Relation.IsTwoWay {
+			continue
+		}
+
+		if _, ok := backRelBlockIDs[relKey.ID]; ok {
+			continue
+		}
+
+		destAv := attrView
+		if relKey.Relation.AvID != attrView.ID {
+			destAv, _ = ParseAttributeView(relKey.Relation.AvID)
+		}
+		backLinks := map[string]map[string]bool{}
+		if nil != destAv {
+			if backKeyValues, _ := destAv.GetKeyValues(relKey.Relation.BackKeyID); nil != backKeyValues {
+				for _, v := range backKeyValues.Values {
+					if nil == v.Relation {
+						continue
+					}
+
+					backLinks[v.BlockID] = map[string]bool{}
+					for _, blockID := range v.Relation.BlockIDs {
+						backLinks[v.BlockID][blockID] = true
+					}
+				}
+			}
+		}
+		backRelBlockIDs[relKey.ID] = backLinks
+	}
+
+	now := time.Now()
 	rows := []*TableRow{}
 	for _, row := range table.Rows {
 		pass := true
 		for j, index := range colIndexes {
 			operator := table.Filters[j].Operator
 
+			if FilterOperatorIsDuplicate == operator || FilterOperatorIsUnique == operator {
+				continue // 需要基于其他过滤条件的结果统计，后面再过滤
+			}
+
+			if FilterOperatorRelationHasOrphan == operator {
+				blockIDs, ok := liveDestBlockIDs[table.Filters[j].Column]
+				value := row.Cells[index].Value
+				if !ok || nil == value || nil == value.Relation {
+					pass = false
+					break
+				}
+
+				hasOrphan := false
+				for _, blockID := range value.Relation.BlockIDs {
+					if !blockIDs[blockID] {
+						hasOrphan = true
+						break
+					}
+				}
+				if !hasOrphan {
+					pass = false
+					break
+				}
+				continue
+			}
+
+			if FilterOperatorRelationAsymmetric == operator {
+				backLinks, ok := backRelBlockIDs[table.Filters[j].Column]
+				value := row.Cells[index].Value
+				if !ok || nil == value || nil == value.Relation {
+					pass = false
+					break
+				}
+
+				isAsymmetric := false
+				for _, blockID := range value.Relation.BlockIDs {
+					if !backLinks[blockID][row.ID] {
+						isAsymmetric = true
+						break
+					}
+				}
+				if !isAsymmetric {
+					pass = false
+					break
+				}
+				continue
+			}
+
+			if FilterOperatorValueChangedWithin == operator {
+				value := row.Cells[index].Value
+				if nil == value || !value.IsChangedWithin(table.Filters[j].Days, now) {
+					pass = false
+					break
+				}
+				continue
+			}
+
+			if FilterOperatorRelationMatchesContext == operator {
+				if "" == table.RelationContextBlockID { // 没有上下文时不过滤
+					continue
+				}
+
+				value := row.Cells[index].Value
+				if nil == value || nil == value.Relation || !gulu.Str.Contains(table.RelationContextBlockID, value.Relation.BlockIDs) {
+					pass = false
+					break
+				}
+				continue
+			}
+
+			if FilterOperatorAuthorIsMe == operator {
+				value := row.Cells[index].Value
+				if "" == table.CurrentAuthor || nil == value || nil == value.Text || value.Text.Content != table.CurrentAuthor {
+					pass = false
+					break
+				}
+				continue
+			}
+
 			if nil == row.Cells[index].Value {
 				if FilterOperatorIsNotEmpty == operator {
 					pass = false
@@ -808,13 +1287,17 @@ func (table *Table) FilterRows(attrView *AttributeView) {
 					break
 				}
 
-				if KeyTypeText != row.Cells[index].ValueType {
+				if KeyTypeText != row.Cells[index].ValueType.BaseType() {
 					pass = false
 				}
 				break
 			}
 
-			if !row.Cells[index].Value.CompareOperator(table.Filters[j].Value, operator, attrView, row.ID) {
+			filterValue := table.Filters[j].Value
+			if FilterOperatorIsRelativeToToday == operator {
+				filterValue = table.Filters[j].GetRelativeDateValue()
+			}
+			if !row.Cells[index].Value.CompareOperator(filterValue, operator, attrView, row.ID) {
 				pass = false
 				break
 			}
@@ -823,9 +1306,108 @@ func (table *Table) FilterRows(attrView *AttributeView) {
 			rows = append(rows, row)
 		}
 	}
+
+	// 统计其他条件过滤后各个值的出现次数，用于 Is duplicate 和 Is unique 过滤，空值不参与统计也不会被匹配
+	cellContent := func(cell *TableCell) string {
+		if nil == cell.Value {
+			return ""
+		}
+		return cell.Value.String()
+	}
+	valueCounts := map[int]map[string]int{}
+	for j, index := range colIndexes {
+		operator := table.Filters[j].Operator
+		if FilterOperatorIsDuplicate != operator && FilterOperatorIsUnique != operator {
+			continue
+		}
+
+		counts := map[string]int{}
+		for _, row := range rows {
+			if content := cellContent(row.Cells[index]); "" != content {
+				counts[content]++
+			}
+		}
+		valueCounts[j] = counts
+	}
+	for j, counts := range valueCounts {
+		index := colIndexes[j]
+		isDuplicate := FilterOperatorIsDuplicate == table.Filters[j].Operator
+		matched := []*TableRow{}
+		for _, row := range rows {
+			content := cellContent(row.Cells[index])
+			if "" != content && isDuplicate == (1 < counts[content]) {
+				matched = append(matched, row)
+			}
+		}
+		rows = matched
+	}
 	table.Rows = rows
 }
 
+// GroupRows 按分组列的值对行分组，分组按第一行出现的顺序排列，并分别计算各个分组和所有行的列计算结果。
+// 需要在过滤和排序之后、分页之前调用。
+func (table *Table) GroupRows() {
+	table.Groups, table.GroupTotalCalcs = nil, nil
+	index := -1
+	for i, col := range table.Columns {
+		if col.ID == table.GroupBy {
+			index = i
+			break
+		}
+	}
+	if -1 == index {
+		return
+	}
+
+	groups := map[string]*TableGroup{}
+	groupRows := map[string][]*TableRow{}
+	for _, row := range table.Rows {
+		var value string
+		if nil != row.Cells[index].Value {
+			value = row.Cells[index].Value.String()
+		}
+
+		group := groups[value]
+		if nil == group {
+			group = &TableGroup{Value: value, RowIDs: []string{}}
+			groups[value] = group
+			table.Groups = append(table.Groups, group)
+		}
+		group.RowIDs = append(group.RowIDs, row.ID)
+		group.Count++
+		groupRows[value] = append(groupRows[value], row)
+	}
+
+	for _, group := range table.Groups {
+		group.Calcs = table.calcRows(groupRows[group.Value])
+		if table.ShowGroupPercent {
+			group.Percent = float64(group.Count) * 100 / float64(len(table.Rows))
+		}
+	}
+	table.GroupTotalCalcs = table.calcRows(table.Rows)
+}
+
+// calcRows 按各列的计算方式对 rows 进行计算，返回列 ID 到计算结果的映射，不会修改表格列上的计算结果。
+func (table *Table) calcRows(rows []*TableRow) (ret map[string]*ColumnCalc) {
+	sub := &Table{Rows: rows}
+	for _, col := range table.Columns {
+		c := *col
+		if nil != col.Calc {
+			c.Calc = &ColumnCalc{Operator: col.Calc.Operator}
+		}
+		sub.Columns = append(sub.Columns, &c)
+	}
+	sub.CalcCols()
+
+	ret = map[string]*ColumnCalc{}
+	for _, col := range sub.Columns {
+		if nil != col.Calc && CalcOperatorNone != col.Calc.Operator {
+			ret[col.ID] = col.Calc
+		}
+	}
+	return
+}
+
 func (table *Table) CalcCols() {
 	for i, col := range table.Columns {
 		if nil == col.Calc {
@@ -836,7 +1418,7 @@ func (table *Table) CalcCols() {
 			continue
 		}
 
-		switch col.Type {
+		switch col.Type.BaseType() {
 		case KeyTypeBlock:
 			table.calcColBlock(col, i)
 		case KeyTypeText:
@@ -2084,6 +2666,24 @@ func (table *Table) calcColRelation(col *TableColumn, colIndex int) {
 		if 0 < len(table.Rows) {
 			col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(countNotEmpty)/float64(len(table.Rows)), NumberFormatPercent)}
 		}
+	case CalcOperatorSum: // 关联数量求和
+		sum := 0
+		for _, row := range table.Rows {
+			if nil != row.Cells[colIndex] && nil != row.Cells[colIndex].Value && nil != row.Cells[colIndex].Value.Relation {
+				sum += row.Cells[colIndex].Value.Relation.Count
+			}
+		}
+		col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(sum), NumberFormatNone)}
+	case CalcOperatorAverage: // 关联数量平均值
+		sum := 0
+		for _, row := range table.Rows {
+			if nil != row.Cells[colIndex] && nil != row.Cells[colIndex].Value && nil != row.Cells[colIndex].Value.Relation {
+				sum += row.Cells[colIndex].Value.Relation.Count
+			}
+		}
+		if 0 < len(table.Rows) {
+			col.Calc.Result = &Value{Number: NewFormattedValueNumber(float64(sum)/float64(len(table.Rows)), NumberFormatNone)}
+		}
 	}
 }
 
@@ -0,0 +1,113 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/88250/gulu"
+)
+
+func TestTableCompact(t *testing.T) {
+	table := &Table{
+		ID: "table",
+		Columns: []*TableColumn{
+			{ID: "block", Type: KeyTypeBlock},
+			{ID: "text", Type: KeyTypeText},
+			{ID: "number", Type: KeyTypeNumber},
+			{ID: "date", Type: KeyTypeDate},
+		},
+	}
+	for i := 0; i < 100; i++ {
+		rowID := "row" + strconv.Itoa(i)
+		table.Rows = append(table.Rows, &TableRow{ID: rowID, Cells: []*TableCell{
+			{ID: "b" + rowID, ValueType: KeyTypeBlock, Value: &Value{ID: "b" + rowID, KeyID: "block", BlockID: rowID, Type: KeyTypeBlock, Block: &ValueBlock{ID: rowID, Content: rowID}}},
+			{ID: "t" + rowID, ValueType: KeyTypeText, Value: &Value{ID: "t" + rowID, KeyID: "text", BlockID: rowID, Type: KeyTypeText, Text: &ValueText{}}},
+			{ID: "n" + rowID, ValueType: KeyTypeNumber, Value: &Value{ID: "n" + rowID, KeyID: "number", BlockID: rowID, Type: KeyTypeNumber, Number: NewFormattedValueNumber(float64(i), NumberFormatNone)}},
+			{ID: "d" + rowID, ValueType: KeyTypeDate, Value: &Value{ID: "d" + rowID, KeyID: "date", BlockID: rowID, Type: KeyTypeDate, Date: &ValueDate{}}},
+		}})
+	}
+	table.RowCount = len(table.Rows)
+
+	full, _ := gulu.JSON.MarshalJSON(table)
+	compactTable := table.Compact()
+	compact, _ := gulu.JSON.MarshalJSON(compactTable)
+	if len(compact) >= len(full) {
+		t.Fatalf("expected compact output [%d] to be smaller than full output [%d]", len(compact), len(full))
+	}
+
+	row := compactTable.Rows[1]
+	if 4 != len(row.Cells) || nil != row.Cells[1] || nil != row.Cells[3] {
+		t.Fatalf("expected empty cells to be null")
+	}
+	if nil == row.Cells[2] || 1 != row.Cells[2].Value.Number.Content || "" != row.Cells[2].Value.KeyID {
+		t.Fatalf("unexpected compact number cell")
+	}
+	if "row1" != row.Cells[0].Value.Block.Content {
+		t.Fatalf("unexpected compact block cell")
+	}
+
+	table.Rows[2].Color = "red"
+	table.Rows[2].Cells[1].Value.Locked = true
+	table.Rows[2].Cells[0].Value.IsBlockDeleted = true
+	table.CalcPosition = CalcPositionTop
+	table.TopRowIDs = []string{"row2"}
+	table.FrozenColumnCount = 1
+	table.RowBindingState = RowBindingStateBound
+	table.ColumnGroups = []*ViewColumnGroup{{ID: "group", Name: "Group"}}
+	compactTable = table.Compact()
+	row = compactTable.Rows[2]
+	if "red" != row.Color {
+		t.Fatalf("expected compact row color to be kept")
+	}
+	if nil == row.Cells[1] || !row.Cells[1].Value.Locked {
+		t.Fatalf("expected locked empty cell to be kept")
+	}
+	if !row.Cells[0].Value.IsBlockDeleted {
+		t.Fatalf("expected block deleted flag to be kept")
+	}
+	if CalcPositionTop != compactTable.CalcPosition || 1 != len(compactTable.TopRowIDs) || 1 != compactTable.FrozenColumnCount ||
+		RowBindingStateBound != compactTable.RowBindingState || 1 != len(compactTable.ColumnGroups) {
+		t.Fatalf("expected compact table to keep view state")
+	}
+}
+
+func TestTableSortRowsTiebreak(t *testing.T) {
+	table := &Table{
+		Columns: []*TableColumn{{ID: "number", Type: KeyTypeNumber}},
+		Sorts:   []*ViewSort{{Column: "number", Order: SortOrderDesc}},
+	}
+	// 倒序添加行，排序值相同的行需要按行 ID 排列，而不是保持输入顺序
+	for i := 9; i >= 0; i-- {
+		rowID := "row" + strconv.Itoa(i)
+		table.Rows = append(table.Rows, &TableRow{ID: rowID, Cells: []*TableCell{
+			{ValueType: KeyTypeNumber, Value: &Value{Type: KeyTypeNumber, Number: NewFormattedValueNumber(float64(i%2), NumberFormatNone)}},
+		}})
+	}
+
+	table.SortRows()
+	var got []string
+	for _, row := range table.Rows {
+		got = append(got, row.ID)
+	}
+	expected := []string{"row1", "row3", "row5", "row7", "row9", "row0", "row2", "row4", "row6", "row8"}
+	if strings.Join(expected, ",") != strings.Join(got, ",") {
+		t.Fatalf("expected rows %v, got %v", expected, got)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/88250/gulu"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -31,11 +32,14 @@ import (
 )
 
 type Value struct {
-	ID         string  `json:"id,omitempty"`
-	KeyID      string  `json:"keyID,omitempty"`
-	BlockID    string  `json:"blockID,omitempty"`
-	Type       KeyType `json:"type,omitempty"`
-	IsDetached bool    `json:"isDetached,omitempty"`
+	ID             string  `json:"id,omitempty"`
+	KeyID          string  `json:"keyID,omitempty"`
+	BlockID        string  `json:"blockID,omitempty"`
+	Type           KeyType `json:"type,omitempty"`
+	IsDetached     bool    `json:"isDetached,omitempty"`
+	IsBlockDeleted bool    `json:"isBlockDeleted,omitempty"` // 绑定的块已被删除，行被转换为游离行
+	UpdatedAt      int64   `json:"updatedAt,omitempty"`      // 单元格值的更新时间戳（毫秒），没有修改过的值为 0
+	Locked         bool    `json:"locked,omitempty"`         // 单元格是否被锁定，锁定后不能修改，和视图是否只读无关
 
 	Block    *ValueBlock    `json:"block,omitempty"`
 	Text     *ValueText     `json:"text,omitempty"`
@@ -55,7 +59,7 @@ type Value struct {
 }
 
 func (value *Value) String() string {
-	switch value.Type {
+	switch value.Type.BaseType() {
 	case KeyTypeBlock:
 		if nil == value.Block {
 			return ""
@@ -168,6 +172,14 @@ func (value *Value) ToJSONString() string {
 	return string(data)
 }
 
+// IsChangedWithin 判断单元格的值是否在 now 之前的 days 天内被修改过。
+func (value *Value) IsChangedWithin(days int, now time.Time) bool {
+	if 0 == value.UpdatedAt || 0 > days {
+		return false
+	}
+	return value.UpdatedAt >= now.AddDate(0, 0, -days).UnixMilli()
+}
+
 func (value *Value) Clone() (ret *Value) {
 	data, err := gulu.JSON.MarshalJSON(value)
 	if nil != err {
@@ -185,6 +197,9 @@ type ValueBlock struct {
 	Content string `json:"content"`
 	Created int64  `json:"created"`
 	Updated int64  `json:"updated"`
+
+	CreatedBy string `json:"createdBy,omitempty"` // 创建者用户名，未登录时为空
+	UpdatedBy string `json:"updatedBy,omitempty"` // 最后修改者用户名，未登录时为空
 }
 
 type ValueText struct {
@@ -196,6 +211,8 @@ type ValueNumber struct {
 	IsNotEmpty       bool         `json:"isNotEmpty"`
 	Format           NumberFormat `json:"format"`
 	FormattedContent string       `json:"formattedContent"`
+	Decimals         int          `json:"decimals,omitempty"` // 输入数字时的小数位数，数字格式为空时按该位数显示，保留末尾的 0
+	Input            string       `json:"input,omitempty"`    // 用户输入的原始文本，仅在写入时用于推断小数位数，不会保存
 }
 
 type NumberFormat string
@@ -216,6 +233,99 @@ const (
 	NumberFormatFranc          NumberFormat = "franc"
 )
 
+// NewFormattedValueDateDelta 按本地时间计算日期 target 距离 now 所在日期的天数差，target 在今天之后为正数。
+func NewFormattedValueDateDelta(target, now time.Time) (ret *ValueNumber) {
+	y, m, d := target.Local().Date()
+	targetDay := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	y, m, d = now.Local().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	days := int(math.Round(targetDay.Sub(today).Hours() / 24)) // 夏令时切换时一天不一定是 24 小时
+
+	ret = &ValueNumber{Content: float64(days), IsNotEmpty: true}
+	switch {
+	case 0 == days:
+		ret.FormattedContent = getI18nName("dateDeltaToday")
+	case 1 == days:
+		ret.FormattedContent = getI18nName("dateDeltaIn1Day")
+	case -1 == days:
+		ret.FormattedContent = getI18nName("dateDelta1DayAgo")
+	case 0 < days:
+		ret.FormattedContent = fmt.Sprintf(getI18nName("dateDeltaInXDays"), days)
+	default:
+		ret.FormattedContent = fmt.Sprintf(getI18nName("dateDeltaXDaysAgo"), -days)
+	}
+	return
+}
+
+type TextLengthCountMode string
+
+const (
+	TextLengthCountModeChars TextLengthCountMode = "chars" // 按字符计数
+	TextLengthCountModeWords TextLengthCountMode = "words" // 按空白分隔的单词计数
+)
+
+// NewFormattedValueTextLength 按计数方式 mode 计算文本 content 的长度。
+func NewFormattedValueTextLength(content string, mode TextLengthCountMode) (ret *ValueNumber) {
+	content = strings.TrimSpace(content)
+	var length int
+	switch mode {
+	case TextLengthCountModeWords:
+		length = len(strings.Fields(content))
+	default:
+		length = utf8.RuneCountInString(content)
+	}
+	return NewFormattedValueNumber(float64(length), NumberFormatNone)
+}
+
+// NewFormattedValueAge 计算创建时间 created 距离 now 的毫秒数，格式化为可读的时长，比如 3 months。
+func NewFormattedValueAge(created, now time.Time) (ret *ValueNumber) {
+	age := now.Sub(created)
+	if 0 > age {
+		age = 0
+	}
+
+	ret = &ValueNumber{Content: float64(age.Milliseconds()), IsNotEmpty: true}
+
+	// 时长使用时间语言包中的文案，文案中的“以前”、“距现在”部分传入空字符串
+	labels := util.TimeLangs[util.Lang]
+	var n int
+	var unit string
+	switch days := int(age.Hours() / 24); {
+	case 365 <= days:
+		n, unit = days/365, "y"
+	case 30 <= days:
+		n, unit = days/30, "M"
+	case 7 <= days:
+		n, unit = days/7, "w"
+	case 1 <= days:
+		n, unit = days, "d"
+	case time.Hour <= age:
+		n, unit = int(age.Hours()), "h"
+	case time.Minute <= age:
+		n, unit = int(age.Minutes()), "m"
+	default:
+		ret.FormattedContent = labels["now"].(string)
+		return
+	}
+	if 1 == n {
+		ret.FormattedContent = strings.TrimSpace(fmt.Sprintf(labels["1"+unit].(string), ""))
+	} else {
+		ret.FormattedContent = strings.TrimSpace(fmt.Sprintf(labels["x"+unit].(string), n, ""))
+	}
+	return
+}
+
+// NewFormattedValuePercentOfColumn 返回 value 占 total 的百分比，total 为 0 时返回空值。
+func NewFormattedValuePercentOfColumn(value, total float64) (ret *ValueNumber) {
+	if 0 == total {
+		return &ValueNumber{}
+	}
+
+	ret = &ValueNumber{Content: value / total * 100, IsNotEmpty: true}
+	ret.FormattedContent = formatNumber(value/total, NumberFormatPercent)
+	return
+}
+
 func NewFormattedValueNumber(content float64, format NumberFormat) (ret *ValueNumber) {
 	ret = &ValueNumber{
 		Content:          content,
@@ -234,8 +344,31 @@ func NewFormattedValueNumber(content float64, format NumberFormat) (ret *ValueNu
 	return
 }
 
-func (number *ValueNumber) FormatNumber() {
-	number.FormattedContent = formatNumber(number.Content, number.Format)
+// FormatNumber 按数字格式生成格式化内容，locale 为区域设置（比如 de-DE），为空时不做本地化。
+func (number *ValueNumber) FormatNumber(locale string) {
+	if NumberFormatNone == number.Format && 0 < number.Decimals {
+		// 数字格式为空时按输入的小数位数显示，比如输入 3.50 时显示 3.50 而不是 3.5
+		number.FormattedContent = formatLocaleNumberDecimals(number.Content, number.Decimals, locale)
+		return
+	}
+	number.FormattedContent = formatLocaleNumber(number.Content, number.Format, locale)
+}
+
+// maxNumberDecimals 是推断输入的小数位数时允许的最大位数，超出 float64 的有效位数没有意义。
+const maxNumberDecimals = 15
+
+// InferNumberDecimals 根据用户输入的数字文本 input 推断小数位数，比如 3.50 返回 2，input 不是普通小数写法（比如科学计数法）时返回 0。
+func InferNumberDecimals(input string) int {
+	input = strings.TrimSpace(input)
+	if _, err := strconv.ParseFloat(input, 64); nil != err || strings.ContainsAny(input, "eExX") {
+		return 0
+	}
+
+	idx := strings.Index(input, ".")
+	if 0 > idx {
+		return 0
+	}
+	return min(len(input)-idx-1, maxNumberDecimals)
 }
 
 func formatNumber(content float64, format NumberFormat) string {
@@ -476,10 +609,47 @@ type ValueCheckbox struct {
 type ValueRelation struct {
 	Contents []string `json:"contents"`
 	BlockIDs []string `json:"blockIDs"`
+	Count    int      `json:"count"`          // 关联的有效块数量（不包含目标属性视图中已经不存在的块），渲染时计算，不会被保存
+	More     int      `json:"more,omitempty"` // 超出显示数量限制而未渲染的关联内容数量
+}
+
+// LimitContents 将关联内容限制为最多 limit 个，剩余的数量记录在 More 中。limit 小于 1 时不限制。
+func (r *ValueRelation) LimitContents(limit int) {
+	if 1 > limit || len(r.Contents) <= limit {
+		return
+	}
+
+	r.More = len(r.Contents) - limit
+	r.Contents = r.Contents[:limit]
 }
 
 type ValueRollup struct {
 	Contents []*Value `json:"contents"`
+	Details  []*Value `json:"details,omitempty"` // 参与汇总计算的各个值，值的 BlockID 为来源块 ID，仅在渲染时按需返回
+
+	Breakdown map[string]int `json:"breakdown,omitempty"` // 选项 -> 关联块数量，仅用于 Count by option
+}
+
+// RollupNoneOption 是按选项统计时没有选项的关联块所属的分组名称。
+const RollupNoneOption = "(none)"
+
+// KeepLatestValue 仅保留关联块更新时间最新的非空值，updated 为关联块 ID 到更新时间的映射，更新时间相同时块 ID 较大的优先。
+func (r *ValueRollup) KeepLatestValue(updated map[string]int64) {
+	var latest *Value
+	for _, v := range r.Contents {
+		if "" == v.String() {
+			continue
+		}
+
+		if nil == latest || updated[v.BlockID] > updated[latest.BlockID] || (updated[v.BlockID] == updated[latest.BlockID] && v.BlockID > latest.BlockID) {
+			latest = v
+		}
+	}
+
+	r.Contents = []*Value{}
+	if nil != latest {
+		r.Contents = append(r.Contents, latest)
+	}
 }
 
 func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
@@ -489,6 +659,12 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 
 	switch calc.Operator {
 	case CalcOperatorNone:
+	case CalcOperatorLatestValue: // 需要关联块的更新时间，在解析关联块时通过 KeepLatestValue 处理
+	case CalcOperatorPercentOfParent: // 需要所有兄弟行的值，在渲染汇总列时处理
+	case CalcOperatorFirstRelated:
+		if 1 < len(r.Contents) {
+			r.Contents = r.Contents[:1]
+		}
 	case CalcOperatorCountAll:
 		r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber(float64(len(r.Contents)), NumberFormatNone)}}
 	case CalcOperatorCountValues:
@@ -542,7 +718,7 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 	case CalcOperatorSum:
 		sum := 0.0
 		for _, v := range r.Contents {
-			if nil != v.Number {
+			if !calc.ignoreNumber(v) {
 				sum += v.Number.Content
 			}
 		}
@@ -551,7 +727,7 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 		sum := 0.0
 		count := 0
 		for _, v := range r.Contents {
-			if nil != v.Number {
+			if !calc.ignoreNumber(v) {
 				sum += v.Number.Content
 				count++
 			}
@@ -562,7 +738,7 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 	case CalcOperatorMedian:
 		var numbers []float64
 		for _, v := range r.Contents {
-			if nil != v.Number {
+			if !calc.ignoreNumber(v) {
 				numbers = append(numbers, v.Number.Content)
 			}
 		}
@@ -573,7 +749,7 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 	case CalcOperatorMin:
 		minVal := math.MaxFloat64
 		for _, v := range r.Contents {
-			if nil != v.Number {
+			if !calc.ignoreNumber(v) {
 				if v.Number.Content < minVal {
 					minVal = v.Number.Content
 				}
@@ -583,7 +759,7 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 	case CalcOperatorMax:
 		maxVal := -math.MaxFloat64
 		for _, v := range r.Contents {
-			if nil != v.Number {
+			if !calc.ignoreNumber(v) {
 				if v.Number.Content > maxVal {
 					maxVal = v.Number.Content
 				}
@@ -594,7 +770,7 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 		minVal := math.MaxFloat64
 		maxVal := -math.MaxFloat64
 		for _, v := range r.Contents {
-			if nil != v.Number {
+			if !calc.ignoreNumber(v) {
 				if v.Number.Content < minVal {
 					minVal = v.Number.Content
 				}
@@ -648,5 +824,137 @@ func (r *ValueRollup) RenderContents(calc *RollupCalc, destKey *Key) {
 		if 0 < len(r.Contents) {
 			r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValueNumber(float64(countUnchecked*100/len(r.Contents)), NumberFormatNone)}}
 		}
+	case CalcOperatorCountByOption:
+		if 1 > len(r.Contents) {
+			break
+		}
+
+		r.Breakdown = map[string]int{}
+		for _, v := range r.Contents {
+			if 1 > len(v.MSelect) {
+				r.Breakdown[RollupNoneOption]++
+				continue
+			}
+			for _, opt := range v.MSelect {
+				r.Breakdown[opt.Content]++
+			}
+		}
+
+		// 数量多的排在前面，数量相同时按照选项定义的顺序，没有选项的排在最后
+		optionIndexes := map[string]int{RollupNoneOption: math.MaxInt}
+		if nil != destKey {
+			for i, opt := range destKey.Options {
+				optionIndexes[opt.Name] = i
+			}
+		}
+		var options []string
+		for option := range r.Breakdown {
+			if _, ok := optionIndexes[option]; !ok {
+				optionIndexes[option] = math.MaxInt - 1 // 已经从列中删除的选项排在定义的选项之后
+			}
+			options = append(options, option)
+		}
+		sort.Slice(options, func(i, j int) bool {
+			if r.Breakdown[options[i]] != r.Breakdown[options[j]] {
+				return r.Breakdown[options[i]] > r.Breakdown[options[j]]
+			}
+			if optionIndexes[options[i]] != optionIndexes[options[j]] {
+				return optionIndexes[options[i]] < optionIndexes[options[j]]
+			}
+			return options[i] < options[j]
+		})
+		var parts []string
+		for _, option := range options {
+			parts = append(parts, strconv.Itoa(r.Breakdown[option])+" "+option)
+		}
+		r.Contents = []*Value{{Type: KeyTypeText, Text: &ValueText{Content: strings.Join(parts, ", ")}}}
+	case CalcOperatorEarliest, CalcOperatorLatest:
+		// 日期列：最早取开始时间，最晚取结束时间（日期范围）或者开始时间，空日期不参与计算
+		// 结果按目标列的类型格式化，创建时间列和更新时间列使用各自的格式
+		var ret int64
+		var found, isNotTime bool
+		for _, v := range r.Contents {
+			start, end, valIsNotTime, ok := getRollupDateRange(v, destKey)
+			if !ok {
+				continue
+			}
+
+			t := start
+			if CalcOperatorLatest == calc.Operator {
+				t = end
+			}
+			if !found || (CalcOperatorEarliest == calc.Operator && t < ret) || (CalcOperatorLatest == calc.Operator && t > ret) {
+				ret = t
+				isNotTime = valIsNotTime
+				found = true
+			}
+		}
+		if found {
+			r.Contents = []*Value{newRollupDateValue(ret, isNotTime, destKey)}
+		}
+	case CalcOperatorPercentDone:
+		countDone := 0
+		for _, v := range r.Contents {
+			if calc.isDone(v) {
+				countDone++
+			}
+		}
+		if 0 < len(r.Contents) {
+			r.Contents = []*Value{{Type: KeyTypeNumber, Number: NewFormattedValuePercentOfColumn(float64(countDone), float64(len(r.Contents)))}}
+		}
+	}
+}
+
+// getRollupDateRange 返回汇总列中日期值 v 的开始和结束时间，日期范围的结束时间为第二个时间，非日期范围时和开始时间相同。
+// 目标列 destKey 为创建时间列或者更新时间列时使用对应的值，空值返回 ok 为 false。
+func getRollupDateRange(v *Value, destKey *Key) (start, end int64, isNotTime, ok bool) {
+	keyType := KeyTypeDate
+	if nil != destKey {
+		keyType = destKey.Type
+	}
+
+	switch keyType {
+	case KeyTypeCreated:
+		if nil == v.Created || 0 == v.Created.Content {
+			return
+		}
+		return v.Created.Content, v.Created.Content, false, true
+	case KeyTypeUpdated:
+		if nil == v.Updated || 0 == v.Updated.Content {
+			return
+		}
+		return v.Updated.Content, v.Updated.Content, false, true
+	default:
+		if nil == v.Date || !v.Date.IsNotEmpty {
+			return
+		}
+		start, end = v.Date.Content, v.Date.Content
+		if v.Date.HasEndDate && v.Date.IsNotEmpty2 && end < v.Date.Content2 {
+			end = v.Date.Content2
+		}
+		return start, end, v.Date.IsNotTime, true
+	}
+}
+
+// newRollupDateValue 使用目标列 destKey 的格式构造汇总列最早和最晚日期的结果值。
+func newRollupDateValue(content int64, isNotTime bool, destKey *Key) *Value {
+	keyType := KeyTypeDate
+	if nil != destKey {
+		keyType = destKey.Type
+	}
+
+	switch keyType {
+	case KeyTypeCreated:
+		created := NewFormattedValueCreated(content, 0, CreatedFormatNone)
+		created.IsNotEmpty = true
+		return &Value{Type: KeyTypeCreated, Created: created}
+	case KeyTypeUpdated:
+		updated := NewFormattedValueUpdated(content, 0, UpdatedFormatNone)
+		updated.IsNotEmpty = true
+		return &Value{Type: KeyTypeUpdated, Updated: updated}
+	default:
+		date := NewFormattedValueDate(content, 0, DateFormatNone, isNotTime)
+		date.IsNotEmpty, date.IsNotTime = true, isNotTime
+		return &Value{Type: KeyTypeDate, Date: date}
 	}
 }
@@ -47,6 +47,7 @@ type Editor struct {
 	OnlySearchForDoc                bool     `json:"onlySearchForDoc"`                // 是否启用 [[ 仅搜索文档块
 	BacklinkExpandCount             int      `json:"backlinkExpandCount"`             // 反向链接默认展开数量
 	BackmentionExpandCount          int      `json:"backmentionExpandCount"`          // 反链提及默认展开数量
+	AllowedAttrViewKeyTypes         []string `json:"allowedAttrViewKeyTypes"`         // 允许添加的数据库列类型，为空时允许所有类型
 }
 
 func NewEditor() *Editor {
@@ -76,5 +77,6 @@ func NewEditor() *Editor {
 		RTL:                             false,
 		BacklinkExpandCount:             8,
 		BackmentionExpandCount:          8,
+		AllowedAttrViewKeyTypes:         []string{},
 	}
 }
@@ -839,7 +839,7 @@ func (parser *PdfAssetParser) Parse(absPath string) (ret *AssetParseResult) {
 				logging.LogInfof("set PDF asset content index max size to [%s]", humanize.Bytes(maxSize))
 			}
 		} else {
-			logging.LogWarnf("invalid env [SIYUAN_PDF_ASSET_CONTENT_INDEX_MAX_SIZE]: [%s], parsing failed: ", maxSizeVal, parseErr)
+			logging.LogWarnf("invalid env [SIYUAN_PDF_ASSET_CONTENT_INDEX_MAX_SIZE]: [%s], parsing failed: %s", maxSizeVal, parseErr)
 		}
 	}
 
@@ -18,13 +18,20 @@ package model
 
 import (
 	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"text/template"
 	"time"
+	"unicode"
 
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
@@ -59,6 +66,148 @@ func GetAttributeViewFilterSort(id string) (filters []*av.ViewFilter, sorts []*a
 	return
 }
 
+// attributeViewQuery 是可分享的过滤和排序查询，编码后可以放在链接中，打开时还原视图的过滤和排序。
+type attributeViewQuery struct {
+	Filters []*av.ViewFilter `json:"f,omitempty"`
+	Sorts   []*av.ViewSort   `json:"s,omitempty"`
+}
+
+// EncodeAttributeViewQuery 将过滤和排序编码为紧凑的 URL 安全字符串。
+func EncodeAttributeViewQuery(filters []*av.ViewFilter, sorts []*av.ViewSort) (ret string, err error) {
+	data, err := gulu.JSON.MarshalJSON(&attributeViewQuery{Filters: filters, Sorts: sorts})
+	if nil != err {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	writer, err := flate.NewWriter(buf, flate.BestCompression)
+	if nil != err {
+		return
+	}
+	if _, err = writer.Write(data); nil != err {
+		return
+	}
+	if err = writer.Close(); nil != err {
+		return
+	}
+	ret = base64.RawURLEncoding.EncodeToString(buf.Bytes())
+	return
+}
+
+// EncodeAttributeViewViewQuery 将视图 viewID 的过滤和排序编码为可分享的查询字符串，viewID 为空时使用当前视图。
+func EncodeAttributeViewViewQuery(avID, viewID string) (ret string, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	var view *av.View
+	if "" != viewID {
+		if view = attrView.GetView(viewID); nil == view {
+			err = av.ErrViewNotFound
+			return
+		}
+	} else if view, err = attrView.GetCurrentView(); nil != err {
+		return
+	}
+
+	filters, sorts := []*av.ViewFilter{}, []*av.ViewSort{}
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		filters, sorts = view.Table.Filters, view.Table.Sorts
+	}
+	ret, err = EncodeAttributeViewQuery(filters, sorts)
+	return
+}
+
+// DecodeAttributeViewQuery 解码 EncodeAttributeViewQuery 生成的字符串，并校验过滤和排序的结构。
+func DecodeAttributeViewQuery(query string) (filters []*av.ViewFilter, sorts []*av.ViewSort, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(query))
+	if nil != err {
+		err = fmt.Errorf("invalid query [%s]: %s", query, err)
+		return
+	}
+
+	reader := flate.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+	data, err = io.ReadAll(io.LimitReader(reader, 1024*1024))
+	if nil != err {
+		err = fmt.Errorf("invalid query [%s]: %s", query, err)
+		return
+	}
+
+	q := &attributeViewQuery{}
+	if err = gulu.JSON.UnmarshalJSON(data, q); nil != err {
+		err = fmt.Errorf("invalid query [%s]: %s", query, err)
+		return
+	}
+
+	for i, filter := range q.Filters {
+		if err = checkAttributeViewQueryFilter(filter); nil != err {
+			err = fmt.Errorf("invalid filter [%d]: %s", i, err)
+			return
+		}
+	}
+	for i, s := range q.Sorts {
+		if nil == s || "" == s.Column {
+			err = fmt.Errorf("invalid sort [%d]: column is empty", i)
+			return
+		}
+		if av.SortOrderAsc != s.Order && av.SortOrderDesc != s.Order {
+			err = fmt.Errorf("invalid sort [%d]: unknown order [%s]", i, s.Order)
+			return
+		}
+	}
+
+	filters, sorts = q.Filters, q.Sorts
+	if nil == filters {
+		filters = []*av.ViewFilter{}
+	}
+	if nil == sorts {
+		sorts = []*av.ViewSort{}
+	}
+	return
+}
+
+func checkAttributeViewQueryFilter(filter *av.ViewFilter) error {
+	if nil == filter || "" == filter.Column {
+		return fmt.Errorf("column is empty")
+	}
+
+	switch filter.Operator {
+	case av.FilterOperatorIsEqual, av.FilterOperatorIsNotEqual, av.FilterOperatorIsGreater, av.FilterOperatorIsGreaterOrEqual,
+		av.FilterOperatorIsLess, av.FilterOperatorIsLessOrEqual, av.FilterOperatorContains, av.FilterOperatorDoesNotContain,
+		av.FilterOperatorStartsWith, av.FilterOperatorEndsWith, av.FilterOperatorIsBetween:
+		if nil == filter.Value {
+			return fmt.Errorf("value of operator [%s] is empty", filter.Operator)
+		}
+	case av.FilterOperatorIsEmpty, av.FilterOperatorIsNotEmpty, av.FilterOperatorIsTrue, av.FilterOperatorIsFalse,
+		av.FilterOperatorRelationMatchesContext, av.FilterOperatorRelationHasOrphan, av.FilterOperatorRelationAsymmetric, av.FilterOperatorIsDuplicate, av.FilterOperatorIsUnique,
+		av.FilterOperatorAuthorIsMe:
+	case av.FilterOperatorIsRelativeToToday:
+		if nil == filter.RelativeDate {
+			return fmt.Errorf("relative date is empty")
+		}
+		switch filter.RelativeDate.Unit {
+		case av.RelativeDateUnitDay, av.RelativeDateUnitWeek, av.RelativeDateUnitMonth, av.RelativeDateUnitYear:
+		default:
+			return fmt.Errorf("unknown relative date unit [%s]", filter.RelativeDate.Unit)
+		}
+		switch filter.RelativeDate.Direction {
+		case av.RelativeDateDirectionBefore, av.RelativeDateDirectionThis, av.RelativeDateDirectionAfter:
+		default:
+			return fmt.Errorf("unknown relative date direction [%s]", filter.RelativeDate.Direction)
+		}
+	case av.FilterOperatorValueChangedWithin:
+		if 1 > filter.Days {
+			return fmt.Errorf("days must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown operator [%s]", filter.Operator)
+	}
+	return nil
+}
+
 func SearchAttributeViewNonRelationKey(avID, keyword string) (ret []*av.Key) {
 	waitForSyncingStorages()
 
@@ -70,10 +219,16 @@ func SearchAttributeViewNonRelationKey(avID, keyword string) (ret []*av.Key) {
 	}
 
 	for _, keyValues := range attrView.KeyValues {
-		if av.KeyTypeRelation != keyValues.Key.Type && av.KeyTypeRollup != keyValues.Key.Type && av.KeyTypeTemplate != keyValues.Key.Type && av.KeyTypeCreated != keyValues.Key.Type && av.KeyTypeUpdated != keyValues.Key.Type {
-			if strings.Contains(strings.ToLower(keyValues.Key.Name), strings.ToLower(keyword)) {
-				ret = append(ret, keyValues.Key)
-			}
+		if keyValues.Key.Type.IsComputedNumber() {
+			continue
+		}
+		switch keyValues.Key.Type {
+		case av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeBlockAttr, av.KeyTypeCreatedBy, av.KeyTypeUpdatedBy:
+			continue
+		}
+
+		if strings.Contains(strings.ToLower(keyValues.Key.Name), strings.ToLower(keyword)) {
+			ret = append(ret, keyValues.Key)
 		}
 	}
 	return
@@ -165,16 +320,7 @@ func SearchAttributeView(keyword string, page int, pageSize int) (ret []*SearchA
 			}
 		}
 
-		var hPath string
-		baseBlock := treenode.GetBlockTreeRootByPath(node.Box, node.Path)
-		if nil != baseBlock {
-			hPath = baseBlock.HPath
-		}
-		box := Conf.Box(node.Box)
-		if nil != box {
-			hPath = box.Name + hPath
-		}
-
+		hPath := getAttributeViewBlockHPath(node.Box, node.Path)
 		if !exist {
 			ret = append(ret, &SearchAttributeViewResult{
 				AvID:    avID,
@@ -187,6 +333,204 @@ func SearchAttributeView(keyword string, page int, pageSize int) (ret []*SearchA
 	return
 }
 
+// SearchRelatableAttributeViews 搜索新建关联字段时可以关联的数据库，keyword 为空时返回所有数据库。
+// excludeAvIDs 用于排除指定的数据库，比如当前数据库。
+func SearchRelatableAttributeViews(keyword string, excludeAvIDs ...string) (ret []*SearchAttributeViewResult, err error) {
+	waitForSyncingStorages()
+	ret = []*SearchAttributeViewResult{}
+
+	storageAvDir := filepath.Join(util.DataDir, "storage", "av")
+	if !gulu.File.IsDir(storageAvDir) {
+		return
+	}
+
+	entries, err := os.ReadDir(storageAvDir)
+	if nil != err {
+		logging.LogErrorf("read dir [%s] failed: %s", storageAvDir, err)
+		return
+	}
+
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	for _, entry := range entries {
+		avID := strings.TrimSuffix(entry.Name(), ".json")
+		if !strings.HasSuffix(entry.Name(), ".json") || !ast.IsNodeIDPattern(avID) {
+			continue
+		}
+
+		if gulu.Str.Contains(avID, excludeAvIDs) {
+			continue
+		}
+
+		attrView, parseErr := av.ParseAttributeView(avID)
+		if nil != parseErr {
+			logging.LogErrorf("parse attribute view [%s] failed: %s", avID, parseErr)
+			continue
+		}
+
+		if "" != keyword && !strings.Contains(strings.ToLower(attrView.Name), keyword) {
+			continue
+		}
+
+		result := &SearchAttributeViewResult{AvID: avID, AvName: attrView.Name}
+		if mirrors := getAttributeViewMirrors(attrView); 0 < len(mirrors) {
+			result = mirrors[0]
+		}
+		ret = append(ret, result)
+	}
+	return
+}
+
+// GetRelationTargetInfo 返回关联列 keyID 关联的目标属性视图 ID、名称和目标属性视图所在的数据库块，用于跳转到关联的数据库。
+// 目标属性视图不存在时只返回目标属性视图 ID。
+func GetRelationTargetInfo(avID, keyID string) (destAvID, destAvName string, mirrors []*SearchAttributeViewResult, err error) {
+	waitForSyncingStorages()
+	mirrors = []*SearchAttributeViewResult{}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(keyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeRelation != key.Type || nil == key.Relation || "" == key.Relation.AvID {
+		err = fmt.Errorf("key [%s] is not a relation key", keyID)
+		return
+	}
+
+	destAvID = key.Relation.AvID
+	destAv, parseErr := av.ParseAttributeView(destAvID)
+	if nil != parseErr {
+		logging.LogWarnf("parse relation target attribute view [%s] failed: %s", destAvID, parseErr)
+		return
+	}
+
+	destAvName = destAv.Name
+	mirrors = getAttributeViewMirrors(destAv)
+	return
+}
+
+// AVRelationGraph 描述了所有属性视图之间的关联关系，节点是属性视图，边是关联列。
+type AVRelationGraph struct {
+	Nodes []*AVRelationGraphNode `json:"nodes"`
+	Edges []*AVRelationGraphEdge `json:"edges"`
+}
+
+type AVRelationGraphNode struct {
+	AvID   string `json:"avID"`
+	AvName string `json:"avName"`
+}
+
+type AVRelationGraphEdge struct {
+	SrcAvID     string `json:"srcAvID"`     // 源属性视图 ID
+	SrcKeyID    string `json:"srcKeyID"`    // 源关联列 ID
+	SrcKeyName  string `json:"srcKeyName"`  // 源关联列名称
+	DestAvID    string `json:"destAvID"`    // 目标属性视图 ID
+	DestKeyID   string `json:"destKeyID"`   // 双向关联时目标属性视图中回链关联列的 ID
+	DestKeyName string `json:"destKeyName"` // 双向关联时目标属性视图中回链关联列的名称
+	IsTwoWay    bool   `json:"isTwoWay"`    // 是否双向关联
+}
+
+// GetAttributeViewRelationGraph 扫描所有属性视图的关联列生成关联关系图，双向关联只生成一条边，目标属性视图不存在的关联列会被忽略。
+func GetAttributeViewRelationGraph() (ret *AVRelationGraph, err error) {
+	waitForSyncingStorages()
+	ret = &AVRelationGraph{Nodes: []*AVRelationGraphNode{}, Edges: []*AVRelationGraphEdge{}}
+
+	storageAvDir := filepath.Join(util.DataDir, "storage", "av")
+	if !gulu.File.IsDir(storageAvDir) {
+		return
+	}
+
+	entries, err := os.ReadDir(storageAvDir)
+	if nil != err {
+		logging.LogErrorf("read dir [%s] failed: %s", storageAvDir, err)
+		return
+	}
+
+	var attrViews []*av.AttributeView
+	attrViewMap := map[string]*av.AttributeView{}
+	for _, entry := range entries {
+		avID := strings.TrimSuffix(entry.Name(), ".json")
+		if !strings.HasSuffix(entry.Name(), ".json") || !ast.IsNodeIDPattern(avID) {
+			continue
+		}
+
+		attrView, parseErr := av.ParseAttributeView(avID)
+		if nil != parseErr {
+			logging.LogErrorf("parse attribute view [%s] failed: %s", avID, parseErr)
+			continue
+		}
+
+		attrViews = append(attrViews, attrView)
+		attrViewMap[avID] = attrView
+		ret.Nodes = append(ret.Nodes, &AVRelationGraphNode{AvID: avID, AvName: attrView.Name})
+	}
+
+	visitedKeys := map[string]bool{}
+	for _, attrView := range attrViews {
+		for _, keyValues := range attrView.KeyValues {
+			key := keyValues.Key
+			if av.KeyTypeRelation != key.Type || nil == key.Relation || visitedKeys[key.ID] {
+				continue
+			}
+
+			destAv := attrViewMap[key.Relation.AvID]
+			if nil == destAv {
+				continue
+			}
+
+			visitedKeys[key.ID] = true
+			edge := &AVRelationGraphEdge{SrcAvID: attrView.ID, SrcKeyID: key.ID, SrcKeyName: key.Name, DestAvID: destAv.ID}
+			if key.Relation.IsTwoWay {
+				// 只有回链关联列也指回当前关联列时才是有效的双向关联
+				backKey, _ := destAv.GetKey(key.Relation.BackKeyID)
+				if nil != backKey && nil != backKey.Relation && backKey.Relation.IsTwoWay && backKey.Relation.AvID == attrView.ID && backKey.Relation.BackKeyID == key.ID {
+					visitedKeys[backKey.ID] = true
+					edge.DestKeyID = backKey.ID
+					edge.DestKeyName = backKey.Name
+					edge.IsTwoWay = true
+				}
+			}
+			ret.Edges = append(ret.Edges, edge)
+		}
+	}
+	return
+}
+
+// getAttributeViewMirrors 返回属性视图所在的数据库块，跳过块树中已经不存在的块。
+func getAttributeViewMirrors(attrView *av.AttributeView) (ret []*SearchAttributeViewResult) {
+	ret = []*SearchAttributeViewResult{}
+	for _, blockID := range av.GetMirrorBlockIDs(attrView.ID) {
+		bt := treenode.GetBlockTree(blockID)
+		if nil == bt {
+			continue
+		}
+
+		ret = append(ret, &SearchAttributeViewResult{
+			AvID:    attrView.ID,
+			AvName:  attrView.Name,
+			BlockID: blockID,
+			HPath:   getAttributeViewBlockHPath(bt.BoxID, bt.Path),
+		})
+	}
+	return
+}
+
+// getAttributeViewBlockHPath 返回数据库块所在文档的可读路径（包含笔记本名称）。
+func getAttributeViewBlockHPath(boxID, p string) (ret string) {
+	baseBlock := treenode.GetBlockTreeRootByPath(boxID, p)
+	if nil != baseBlock {
+		ret = baseBlock.HPath
+	}
+	box := Conf.Box(boxID)
+	if nil != box {
+		ret = box.Name + ret
+	}
+	return
+}
+
 type BlockAttributeViewKeys struct {
 	AvID      string          `json:"avID"`
 	AvName    string          `json:"avName"`
@@ -240,6 +584,24 @@ func GetBlockAttributeViewKeys(blockID string) (ret []*BlockAttributeViewKeys) {
 				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeCreated})
 			case av.KeyTypeUpdated:
 				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeUpdated})
+			case av.KeyTypeBlockAttr:
+				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeBlockAttr, Text: &av.ValueText{Content: attrs[kValues.Key.AttrName]}})
+			case av.KeyTypeCreatedBy, av.KeyTypeUpdatedBy:
+				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: kValues.Key.Type, Text: &av.ValueText{Content: getAttributeViewRowAuthor(attrView, blockID, kValues.Key.Type)}})
+			case av.KeyTypeBacklinkCount:
+				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeBacklinkCount, Number: av.NewFormattedValueNumber(float64(sql.QueryRefCount([]string{blockID})[blockID]), av.NumberFormatNone)})
+			case av.KeyTypeAge:
+				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeAge, Number: av.NewFormattedValueAge(getAttributeViewRowCreated(attrView, blockID), time.Now())})
+			case av.KeyTypeDateDelta:
+				deltaVal := &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeDateDelta, Number: &av.ValueNumber{}}
+				if dateVal := attrView.GetValue(kValues.Key.SourceKeyID, blockID); nil != dateVal && nil != dateVal.Date && dateVal.Date.IsNotEmpty {
+					deltaVal.Number = av.NewFormattedValueDateDelta(time.UnixMilli(dateVal.Date.Content), time.Now())
+				}
+				kValues.Values = append(kValues.Values, deltaVal)
+			case av.KeyTypeTextLength:
+				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeTextLength, Number: &av.ValueNumber{}})
+			case av.KeyTypeFormula:
+				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeFormula, Number: &av.ValueNumber{}})
 			}
 
 			if 0 < len(kValues.Values) {
@@ -265,24 +627,39 @@ func GetBlockAttributeViewKeys(blockID string) (ret []*BlockAttributeViewKeys) {
 					break
 				}
 
+				if nil != kv.Key.Rollup.Calc && av.CalcOperatorPercentOfParent == kv.Key.Rollup.Calc.Operator {
+					if nil != relKey.Relation && relKey.Relation.AvID == attrView.ID {
+						parentSums := getAttributeViewParentSums(attrView, relKey.ID, kv.Key.Rollup.KeyID)
+						kv.Values[0].Rollup.Contents = []*av.Value{newRollupPercentOfParentValue(attrView, kv.Key.Rollup, blockID, parentSums)}
+					}
+					break
+				}
+
 				relVal := attrView.GetValue(kv.Key.Rollup.RelationKeyID, kv.Values[0].BlockID)
 				if nil != relVal && nil != relVal.Relation {
 					destAv, _ := av.ParseAttributeView(relKey.Relation.AvID)
 					destKey, _ := destAv.GetKey(kv.Key.Rollup.KeyID)
 					if nil != destAv && nil != destKey {
 						for _, bID := range relVal.Relation.BlockIDs {
-							destVal := destAv.GetValue(kv.Key.Rollup.KeyID, bID)
+							targetKey := getAttributeViewRollupTargetKey(destAv, kv.Key.Rollup, bID, destKey)
+							destVal := destAv.GetValue(targetKey.ID, bID)
+							if av.KeyTypeFormula == targetKey.Type {
+								destVal = getAttributeViewFormulaValue(destAv, targetKey, bID)
+							}
 							if nil == destVal {
-								destVal = treenode.GetAttributeViewDefaultValue(ast.NewNodeID(), kv.Key.Rollup.KeyID, blockID, destKey.Type)
+								destVal = treenode.GetAttributeViewDefaultValue(ast.NewNodeID(), targetKey.ID, blockID, targetKey.Type)
 							}
 
-							if av.KeyTypeNumber == destKey.Type {
-								destVal.Number.Format = destKey.NumberFormat
-								destVal.Number.FormatNumber()
+							if av.KeyTypeNumber == targetKey.Type {
+								destVal.Number.Format = targetKey.NumberFormat
+								destVal.Number.FormatNumber("")
 							}
 
 							kv.Values[0].Rollup.Contents = append(kv.Values[0].Rollup.Contents, destVal.Clone())
 						}
+						if nil != kv.Key.Rollup.Calc && av.CalcOperatorLatestValue == kv.Key.Rollup.Calc.Operator {
+							kv.Values[0].Rollup.KeepLatestValue(getAttributeViewRowsUpdated(destAv))
+						}
 						kv.Values[0].Rollup.RenderContents(kv.Key.Rollup.Calc, destKey)
 					}
 				}
@@ -300,8 +677,13 @@ func GetBlockAttributeViewKeys(blockID string) (ret []*BlockAttributeViewKeys) {
 				for _, blockValue := range destAv.GetBlockKeyValues().Values {
 					blocks[blockValue.BlockID] = blockValue.Block.Content
 				}
+				kv.Values[0].Relation.Count = 0
 				for _, bID := range kv.Values[0].Relation.BlockIDs {
-					kv.Values[0].Relation.Contents = append(kv.Values[0].Relation.Contents, blocks[bID])
+					content, exist := blocks[bID]
+					kv.Values[0].Relation.Contents = append(kv.Values[0].Relation.Contents, content)
+					if exist {
+						kv.Values[0].Relation.Count++
+					}
 				}
 			case av.KeyTypeCreated:
 				createdStr := blockID[:len("20060102150405")]
@@ -336,11 +718,53 @@ func GetBlockAttributeViewKeys(blockID string) (ret []*BlockAttributeViewKeys) {
 					if nil != block && !block.IsDetached {
 						ial = GetBlockAttrsWithoutWaitWriting(blockID)
 					}
-					kv.Values[0].Template.Content = renderTemplateCol(ial, kv.Key.Template, keyValues)
+					kv.Values[0].Template.Content = renderTemplateCol(ial, kv.Key.Template, keyValues, 0, 0)
+				}
+			}
+		}
+
+		// 文本长度列的来源列可能是模板列，所以在模板列之后处理
+		for _, kv := range keyValues {
+			if av.KeyTypeTextLength == kv.Key.Type && 0 < len(kv.Values) {
+				var content string
+				for _, sourceKv := range keyValues {
+					if isAttributeViewTextLengthSource(kv.Key, sourceKv.Key) && 0 < len(sourceKv.Values) {
+						content = sourceKv.Values[0].String()
+						break
+					}
+				}
+				kv.Values[0].Number = av.NewFormattedValueTextLength(content, kv.Key.CountMode)
+			}
+		}
+
+		// 公式列可以引用汇总列、关联列等计算列，所以在其他列之后处理
+		formulaValues := map[string]*av.Value{}
+		for _, kv := range attrView.KeyValues {
+			if v := kv.GetValue(blockID); nil != v {
+				formulaValues[kv.Key.ID] = v
+			}
+		}
+		for _, kv := range keyValues {
+			if 0 < len(kv.Values) {
+				formulaValues[kv.Key.ID] = kv.Values[0]
+			}
+		}
+		formulaCtx := newAttributeViewFormulaContext(attrView, formulaValues)
+		for _, kv := range keyValues {
+			if av.KeyTypeFormula == kv.Key.Type && 0 < len(kv.Values) {
+				if num, evalErr := formulaCtx.EvalKey(kv.Key); nil == evalErr {
+					kv.Values[0].Number = num
 				}
 			}
 		}
 
+		// 模板列渲染需要完整的关联内容，所以最后再限制关联列的显示数量
+		for _, kv := range keyValues {
+			if av.KeyTypeRelation == kv.Key.Type && nil != kv.Key.Relation && nil != kv.Values[0].Relation {
+				kv.Values[0].Relation.LimitContents(kv.Key.Relation.DisplayLimit)
+			}
+		}
+
 		// Attribute Panel - Database sort attributes by view column order https://github.com/siyuan-note/siyuan/issues/9319
 		view, _ := attrView.GetCurrentView()
 		if nil != view {
@@ -432,55 +856,445 @@ func RenderRepoSnapshotAttributeView(indexID, avID string) (viewable av.Viewable
 		}
 	}
 
-	viewable, err = renderAttributeView(attrView, "", 1, -1)
+	viewable, err = renderAttributeView(attrView, "", 1, -1, nil)
 	return
 }
 
 func RenderHistoryAttributeView(avID, created string) (viewable av.Viewable, attrView *av.AttributeView, err error) {
-	createdUnix, parseErr := strconv.ParseInt(created, 10, 64)
-	if nil != parseErr {
-		logging.LogErrorf("parse created [%s] failed: %s", created, parseErr)
+	attrView, err = getHistoryAttributeView(avID, created)
+	if nil != err || nil == attrView {
 		return
 	}
 
-	dirPrefix := time.Unix(createdUnix, 0).Format("2006-01-02-150405")
-	globPath := filepath.Join(util.HistoryDir, dirPrefix+"*")
-	matches, err := filepath.Glob(globPath)
+	viewable, err = renderAttributeView(attrView, "", 1, -1, nil)
+	return
+}
+
+// RestoreAttributeViewRow 将行 rowID 恢复为历史版本 created 中的值，行不存在时重新创建，其他行不受影响。
+// 恢复后双向关联列会同步更新目标属性视图中的回链。
+func RestoreAttributeViewRow(avID, created, rowID string) (err error) {
+	histAv, err := getHistoryAttributeView(avID, created)
 	if nil != err {
-		logging.LogErrorf("glob [%s] failed: %s", globPath, err)
 		return
 	}
-	if 1 > len(matches) {
+	if nil == histAv || nil == histAv.GetBlockKeyValues() || nil == histAv.GetBlockKeyValues().GetValue(rowID) {
+		err = fmt.Errorf("row [%s] not found in attribute view [%s] history [%s]", rowID, avID, created)
 		return
 	}
 
-	historyDir := matches[0]
-	avJSONPath := filepath.Join(historyDir, "storage", "av", avID+".json")
-	if !gulu.File.IsExist(avJSONPath) {
-		avJSONPath = filepath.Join(util.DataDir, "storage", "av", avID+".json")
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
 	}
-	if !gulu.File.IsExist(avJSONPath) {
-		attrView = av.NewAttributeView(avID)
-	} else {
-		data, readErr := os.ReadFile(avJSONPath)
-		if nil != readErr {
-			logging.LogErrorf("read attribute view [%s] failed: %s", avID, readErr)
-			return
+
+	isNewRow := nil == attrView.GetBlockKeyValues().GetValue(rowID)
+	destAvs := map[string]*av.AttributeView{}
+	for _, histKeyValues := range histAv.KeyValues {
+		keyValues, _ := attrView.GetKeyValues(histKeyValues.Key.ID)
+		if nil == keyValues {
+			// 历史版本中的列已经被删除
+			continue
 		}
 
-		attrView = &av.AttributeView{}
-		if err = gulu.JSON.UnmarshalJSON(data, attrView); nil != err {
-			logging.LogErrorf("unmarshal attribute view [%s] failed: %s", avID, err)
-			return
+		var oldRelationBlockIDs []string
+		tmp := keyValues.Values[:0]
+		for _, v := range keyValues.Values {
+			if v.BlockID == rowID {
+				if nil != v.Relation {
+					oldRelationBlockIDs = v.Relation.BlockIDs
+				}
+				continue
+			}
+			tmp = append(tmp, v)
 		}
-	}
+		keyValues.Values = tmp
 
-	viewable, err = renderAttributeView(attrView, "", 1, -1)
-	return
-}
+		var newRelationBlockIDs []string
+		if histVal := histKeyValues.GetValue(rowID); nil != histVal {
+			restored := histVal.Clone()
+			restored.Type = keyValues.Key.Type
+			keyValues.Values = append(keyValues.Values, restored)
+			if nil != restored.Relation {
+				newRelationBlockIDs = restored.Relation.BlockIDs
+			}
+		}
 
-func RenderAttributeView(avID, viewID string, page, pageSize int) (viewable av.Viewable, attrView *av.AttributeView, err error) {
-	waitForSyncingStorages()
+		relKey := keyValues.Key
+		if av.KeyTypeRelation != relKey.Type || nil == relKey.Relation || !relKey.Relation.IsTwoWay {
+			continue
+		}
+
+		destAv := destAvs[relKey.Relation.AvID]
+		if nil == destAv {
+			if relKey.Relation.AvID == attrView.ID {
+				destAv = attrView
+			} else if destAv, _ = av.ParseAttributeView(relKey.Relation.AvID); nil == destAv {
+				continue
+			}
+			destAvs[destAv.ID] = destAv
+		}
+
+		backKeyValues, _ := destAv.GetKeyValues(relKey.Relation.BackKeyID)
+		if nil == backKeyValues {
+			continue
+		}
+
+		for _, blockID := range oldRelationBlockIDs {
+			if gulu.Str.Contains(blockID, newRelationBlockIDs) {
+				continue
+			}
+			if backVal := backKeyValues.GetValue(blockID); nil != backVal && nil != backVal.Relation {
+				backVal.Relation.BlockIDs = gulu.Str.RemoveElem(backVal.Relation.BlockIDs, rowID)
+			}
+		}
+		for _, blockID := range newRelationBlockIDs {
+			if nil == destAv.GetBlockKeyValues().GetValue(blockID) {
+				continue
+			}
+
+			backVal := backKeyValues.GetValue(blockID)
+			if nil == backVal {
+				backVal = &av.Value{ID: ast.NewNodeID(), KeyID: backKeyValues.Key.ID, BlockID: blockID, Type: av.KeyTypeRelation, Relation: &av.ValueRelation{}}
+				backKeyValues.Values = append(backKeyValues.Values, backVal)
+			}
+			if nil == backVal.Relation {
+				backVal.Relation = &av.ValueRelation{}
+			}
+			backVal.Relation.BlockIDs = gulu.Str.RemoveDuplicatedElem(append(backVal.Relation.BlockIDs, rowID))
+		}
+	}
+
+	if isNewRow {
+		for _, view := range attrView.Views {
+			if nil != view.Table && 0 < len(view.Table.RowIDs) {
+				view.Table.RowIDs = append(view.Table.RowIDs, rowID)
+			}
+		}
+	}
+
+	if err = av.SaveAttributeView(attrView); nil != err {
+		return
+	}
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": attrView.ID})
+	for _, destAv := range destAvs {
+		if destAv.ID == attrView.ID {
+			continue
+		}
+		if err = av.SaveAttributeView(destAv); nil != err {
+			return
+		}
+		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": destAv.ID})
+	}
+	return
+}
+
+// AVDiff 描述了属性视图两个历史版本之间的差异，行按行 ID 标识，列按列 ID 标识。
+type AVDiff struct {
+	AddedRows    []string      `json:"addedRows"`    // 新增的行 ID
+	RemovedRows  []string      `json:"removedRows"`  // 删除的行 ID
+	ChangedCells []*AVCellDiff `json:"changedCells"` // 变更的单元格
+	AddedKeys    []*av.Key     `json:"addedKeys"`    // 新增的列
+	RemovedKeys  []*av.Key     `json:"removedKeys"`  // 删除的列
+}
+
+// AVCellDiff 描述了一个单元格在两个版本中的值。
+type AVCellDiff struct {
+	RowID    string    `json:"rowID"`
+	KeyID    string    `json:"keyID"`
+	OldValue *av.Value `json:"oldValue"`
+	NewValue *av.Value `json:"newValue"`
+}
+
+// DiffAttributeViews 比较属性视图在 createdA 和 createdB 两个历史版本之间的差异。
+func DiffAttributeViews(avID, createdA, createdB string) (ret *AVDiff, err error) {
+	attrViewA, err := getHistoryAttributeView(avID, createdA)
+	if nil != err {
+		return
+	}
+	attrViewB, err := getHistoryAttributeView(avID, createdB)
+	if nil != err {
+		return
+	}
+	if nil == attrViewA || nil == attrViewB {
+		err = fmt.Errorf("history of attribute view [%s] not found", avID)
+		return
+	}
+
+	cellsA, err := renderAttributeViewAllCells(attrViewA)
+	if nil != err {
+		return
+	}
+	cellsB, err := renderAttributeViewAllCells(attrViewB)
+	if nil != err {
+		return
+	}
+
+	ret = &AVDiff{AddedRows: []string{}, RemovedRows: []string{}, ChangedCells: []*AVCellDiff{}, AddedKeys: []*av.Key{}, RemovedKeys: []*av.Key{}}
+	for _, kv := range attrViewB.KeyValues {
+		if key, _ := attrViewA.GetKey(kv.Key.ID); nil == key {
+			ret.AddedKeys = append(ret.AddedKeys, kv.Key)
+		}
+	}
+	for _, kv := range attrViewA.KeyValues {
+		if key, _ := attrViewB.GetKey(kv.Key.ID); nil == key {
+			ret.RemovedKeys = append(ret.RemovedKeys, kv.Key)
+		}
+	}
+
+	for rowID := range cellsA {
+		if _, ok := cellsB[rowID]; !ok {
+			ret.RemovedRows = append(ret.RemovedRows, rowID)
+		}
+	}
+	for rowID, rowB := range cellsB {
+		rowA, ok := cellsA[rowID]
+		if !ok {
+			ret.AddedRows = append(ret.AddedRows, rowID)
+			continue
+		}
+
+		for keyID, valB := range rowB {
+			valA, ok := rowA[keyID]
+			if !ok { // 新增的列不算作单元格变更
+				continue
+			}
+			if valA.String() != valB.String() {
+				ret.ChangedCells = append(ret.ChangedCells, &AVCellDiff{RowID: rowID, KeyID: keyID, OldValue: valA, NewValue: valB})
+			}
+		}
+	}
+
+	sort.Strings(ret.AddedRows)
+	sort.Strings(ret.RemovedRows)
+	sort.Slice(ret.ChangedCells, func(i, j int) bool {
+		if ret.ChangedCells[i].RowID == ret.ChangedCells[j].RowID {
+			return ret.ChangedCells[i].KeyID < ret.ChangedCells[j].KeyID
+		}
+		return ret.ChangedCells[i].RowID < ret.ChangedCells[j].RowID
+	})
+	return
+}
+
+// TimelineEntry 描述了单元格在一段时间内保持的值，时间均为毫秒时间戳。
+type TimelineEntry struct {
+	Value    *av.Value `json:"value"`    // 单元格的值，该时间段内单元格为空时为 nil
+	Content  string    `json:"content"`  // 值的文本形式
+	Start    int64     `json:"start"`    // 开始持有该值的时间
+	End      int64     `json:"end"`      // 不再持有该值的时间，当前值为当前时间
+	Duration int64     `json:"duration"` // 持有该值的时长
+	Current  bool      `json:"current"`  // 是否是当前值
+}
+
+// GetAttributeViewValueTimeline 根据属性视图历史版本计算单元格值的变化时间线，按时间先后排列，相邻且相同的值会合并。
+// 值的开始时间优先使用单元格的更新时间，更新时间缺失时使用首次出现该值的历史版本时间，用于统计状态的停留时长。
+func GetAttributeViewValueTimeline(avID, keyID, rowID string) (ret []*TimelineEntry, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+	if _, err = attrView.GetKey(keyID); nil != err {
+		return
+	}
+
+	type snapshot struct {
+		created  int64
+		attrView *av.AttributeView
+	}
+	var snapshots []*snapshot
+	globPath := filepath.Join(util.HistoryDir, "*", "storage", "av", avID+".json")
+	matches, err := filepath.Glob(globPath)
+	if nil != err {
+		logging.LogErrorf("glob [%s] failed: %s", globPath, err)
+		return
+	}
+	const layout = "2006-01-02-150405" // 和历史目录名的时间前缀格式保持一致
+	for _, avJSONPath := range matches {
+		historyDirName := filepath.Base(filepath.Dir(filepath.Dir(filepath.Dir(avJSONPath))))
+		if len(layout) > len(historyDirName) {
+			continue
+		}
+		created, parseErr := time.ParseInLocation(layout, historyDirName[:len(layout)], time.Local)
+		if nil != parseErr {
+			continue
+		}
+
+		data, readErr := os.ReadFile(avJSONPath)
+		if nil != readErr {
+			logging.LogErrorf("read attribute view [%s] failed: %s", avJSONPath, readErr)
+			continue
+		}
+		histAv := &av.AttributeView{}
+		if unmarshalErr := gulu.JSON.UnmarshalJSON(data, histAv); nil != unmarshalErr {
+			logging.LogErrorf("unmarshal attribute view [%s] failed: %s", avJSONPath, unmarshalErr)
+			continue
+		}
+		snapshots = append(snapshots, &snapshot{created: created.UnixMilli(), attrView: histAv})
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].created < snapshots[j].created })
+
+	now := time.Now().UnixMilli()
+	snapshots = append(snapshots, &snapshot{created: now, attrView: attrView})
+
+	ret = []*TimelineEntry{}
+	for _, s := range snapshots {
+		if nil == s.attrView.GetBlockKeyValues() || nil == s.attrView.GetBlockKeyValues().GetValue(rowID) {
+			// 该版本中不存在该行
+			continue
+		}
+
+		val := s.attrView.GetValue(keyID, rowID)
+		var content string
+		if nil != val {
+			content = val.String()
+		}
+
+		if 0 < len(ret) && ret[len(ret)-1].Content == content {
+			continue
+		}
+
+		start := s.created
+		if nil != val && 0 < val.UpdatedAt && val.UpdatedAt <= s.created {
+			start = val.UpdatedAt
+		}
+		if 0 < len(ret) {
+			last := ret[len(ret)-1]
+			if start < last.Start {
+				start = last.Start
+			}
+			last.End = start
+			last.Duration = last.End - last.Start
+		}
+		ret = append(ret, &TimelineEntry{Value: val, Content: content, Start: start})
+	}
+
+	if 0 < len(ret) {
+		last := ret[len(ret)-1]
+		last.End = now
+		last.Duration = last.End - last.Start
+		last.Current = true
+	}
+	return
+}
+
+// renderAttributeViewAllCells 使用包含所有列的临时视图渲染属性视图，不做过滤和分页，返回 rowID -> keyID -> value。
+func renderAttributeViewAllCells(attrView *av.AttributeView) (ret map[string]map[string]*av.Value, err error) {
+	view := &av.View{ID: ast.NewNodeID(), LayoutType: av.LayoutTypeTable, Table: &av.LayoutTable{ID: ast.NewNodeID()}}
+	for _, kv := range attrView.KeyValues {
+		view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: kv.Key.ID})
+	}
+
+	table, err := renderAttributeViewTable(attrView, view, nil)
+	if nil != err {
+		return
+	}
+
+	ret = map[string]map[string]*av.Value{}
+	for _, row := range table.Rows {
+		cells := map[string]*av.Value{}
+		for i, cell := range row.Cells {
+			cells[table.Columns[i].ID] = cell.Value
+		}
+		ret[row.ID] = cells
+	}
+	return
+}
+
+// getHistoryAttributeView 读取 created 对应历史版本中的属性视图，历史版本中不存在时使用当前版本。
+func getHistoryAttributeView(avID, created string) (attrView *av.AttributeView, err error) {
+	createdUnix, parseErr := strconv.ParseInt(created, 10, 64)
+	if nil != parseErr {
+		logging.LogErrorf("parse created [%s] failed: %s", created, parseErr)
+		return
+	}
+
+	dirPrefix := time.Unix(createdUnix, 0).Format("2006-01-02-150405")
+	globPath := filepath.Join(util.HistoryDir, dirPrefix+"*")
+	matches, err := filepath.Glob(globPath)
+	if nil != err {
+		logging.LogErrorf("glob [%s] failed: %s", globPath, err)
+		return
+	}
+	if 1 > len(matches) {
+		return
+	}
+
+	historyDir := matches[0]
+	avJSONPath := filepath.Join(historyDir, "storage", "av", avID+".json")
+	if !gulu.File.IsExist(avJSONPath) {
+		avJSONPath = filepath.Join(util.DataDir, "storage", "av", avID+".json")
+	}
+	if !gulu.File.IsExist(avJSONPath) {
+		attrView = av.NewAttributeView(avID)
+	} else {
+		data, readErr := os.ReadFile(avJSONPath)
+		if nil != readErr {
+			logging.LogErrorf("read attribute view [%s] failed: %s", avID, readErr)
+			return
+		}
+
+		attrView = &av.AttributeView{}
+		if err = gulu.JSON.UnmarshalJSON(data, attrView); nil != err {
+			logging.LogErrorf("unmarshal attribute view [%s] failed: %s", avID, err)
+			return
+		}
+	}
+	return
+}
+
+// filterAttributeViewRowsByVisibilityFormula 按可见性公式过滤行，需要在计算列渲染完成后调用。
+// 公式无效（比如无法解析或者引用的列不存在）时显示所有行，单行计算出错时该行仍然显示。
+func filterAttributeViewRowsByVisibilityFormula(attrView *av.AttributeView, table *av.Table, expr string) {
+	if "" == strings.TrimSpace(expr) {
+		return
+	}
+
+	formula, err := av.ParseFormula(expr)
+	if nil != err {
+		logging.LogWarnf("parse visibility formula [%s] of attribute view [%s] failed: %s", expr, attrView.ID, err)
+		return
+	}
+	var keys []*av.Key
+	for _, kv := range attrView.KeyValues {
+		keys = append(keys, kv.Key)
+	}
+	for _, ref := range formula.Refs() {
+		if nil == av.GetFormulaRefKey(keys, ref) {
+			logging.LogWarnf("key [%s] referenced by visibility formula [%s] of attribute view [%s] not found", ref, expr, attrView.ID)
+			return
+		}
+	}
+
+	rows := []*av.TableRow{}
+	for _, row := range table.Rows {
+		visible, evalErr := newAttributeViewFormulaContext(attrView, getAttributeViewRowCellValues(attrView, table.Columns, row)).EvalBool(expr)
+		if nil != evalErr || visible {
+			rows = append(rows, row)
+		}
+	}
+	table.Rows = rows
+}
+
+// formatAttributeViewCalcResults 按区域设置格式化列计算结果中的数字。
+func formatAttributeViewCalcResults(calcs map[string]*av.ColumnCalc, locale string) {
+	for _, calc := range calcs {
+		if nil != calc && nil != calc.Result && nil != calc.Result.Number && calc.Result.Number.IsNotEmpty {
+			calc.Result.Number.FormatNumber(locale)
+		}
+	}
+}
+
+// RenderAttributeViewOptions 描述了渲染属性视图时的可选项，为 nil 时使用默认值。
+type RenderAttributeViewOptions struct {
+	ExpandRollups          bool   // 是否在汇总列单元格中返回参与计算的各个值（Rollup.Details），默认不返回以减小响应体积
+	RelationContextBlockID string // 关联上下文块 ID，用于 Relation matches context 过滤，比如主视图中选中的块
+	RichRelationContents   bool   // 是否将关联列内容解析为目标块的引用锚文本并保留行级元素格式，游离行仍使用纯文本
+
+	OverrideSorts []*av.ViewSort // 临时排序规则，不为 nil 时代替视图中保存的排序规则，不会被保存
+	Locale        string         // 区域设置，比如 en-US、de-DE，用于格式化数字、日期、创建时间和更新时间，为空时不做本地化
+}
+
+func RenderAttributeView(avID, viewID string, page, pageSize int, opts *RenderAttributeViewOptions) (viewable av.Viewable, attrView *av.AttributeView, err error) {
+	waitForSyncingStorages()
 
 	if avJSONPath := av.GetAttributeViewDataPath(avID); !filelock.IsExist(avJSONPath) {
 		attrView = av.NewAttributeView(avID)
@@ -496,11 +1310,15 @@ func RenderAttributeView(avID, viewID string, page, pageSize int) (viewable av.V
 		return
 	}
 
-	viewable, err = renderAttributeView(attrView, viewID, page, pageSize)
+	viewable, err = renderAttributeView(attrView, viewID, page, pageSize, opts)
 	return
 }
 
-func renderAttributeView(attrView *av.AttributeView, viewID string, page, pageSize int) (viewable av.Viewable, err error) {
+func renderAttributeView(attrView *av.AttributeView, viewID string, page, pageSize int, opts *RenderAttributeViewOptions) (viewable av.Viewable, err error) {
+	if nil == opts {
+		opts = &RenderAttributeViewOptions{}
+	}
+
 	if 1 > len(attrView.Views) {
 		view, _ := av.NewTableViewWithBlockKey(ast.NewNodeID())
 		attrView.Views = append(attrView.Views, view)
@@ -580,12 +1398,42 @@ func renderAttributeView(attrView *av.AttributeView, viewID string, page, pageSi
 		}
 		view.Table.Sorts = tmpSorts
 
-		viewable, err = renderAttributeViewTable(attrView, view)
+		if 0 < len(view.Table.DefaultSorts) {
+			tmpDefaultSorts := []*av.ViewSort{}
+			for _, s := range view.Table.DefaultSorts {
+				if k, _ := attrView.GetKey(s.Column); nil != k {
+					tmpDefaultSorts = append(tmpDefaultSorts, s)
+				}
+			}
+			view.Table.DefaultSorts = tmpDefaultSorts
+		}
+
+		viewable, err = renderAttributeViewTable(attrView, view, opts)
 	}
 
 	viewable.FilterRows(attrView)
+	if table, ok := viewable.(*av.Table); ok {
+		table.FilterRowsByBindingState()
+		filterAttributeViewRowsByVisibilityFormula(attrView, table, view.Table.VisibilityFormula)
+	}
 	viewable.SortRows()
+	renderAttributeViewOrderedCols(attrView, viewable)
 	viewable.CalcCols()
+	if table, ok := viewable.(*av.Table); ok {
+		table.GroupRows()
+		if "" != opts.Locale {
+			// 列计算结果和分组计算结果也需要按区域设置格式化
+			calcs := map[string]*av.ColumnCalc{}
+			for _, col := range table.Columns {
+				calcs[col.ID] = col.Calc
+			}
+			formatAttributeViewCalcResults(calcs, opts.Locale)
+			for _, group := range table.Groups {
+				formatAttributeViewCalcResults(group.Calcs, opts.Locale)
+			}
+			formatAttributeViewCalcResults(table.GroupTotalCalcs, opts.Locale)
+		}
+	}
 
 	// 分页
 	switch viewable.GetType() {
@@ -600,17 +1448,55 @@ func renderAttributeView(attrView *av.AttributeView, viewID string, page, pageSi
 			pageSize = table.PageSize
 		}
 
+		table.PageCount = (table.RowCount + pageSize - 1) / pageSize
+
 		start := (page - 1) * pageSize
 		end := start + pageSize
 		if len(table.Rows) < end {
 			end = len(table.Rows)
 		}
+		table.HasMore = end < table.RowCount
 		table.Rows = table.Rows[start:end]
+
+		// 汇总行不计入总行数也不参与分页
+		if view.Table.ShowSummaryRow {
+			switch table.CalcPosition {
+			case av.CalcPositionTop:
+				table.Rows = append([]*av.TableRow{table.NewSummaryRow()}, table.Rows...)
+			case av.CalcPositionBoth:
+				table.Rows = append([]*av.TableRow{table.NewSummaryRow()}, table.Rows...)
+				table.Rows = append(table.Rows, table.NewSummaryRow())
+			default:
+				table.Rows = append(table.Rows, table.NewSummaryRow())
+			}
+		}
+
+		// 过滤、排序和计算都需要完整的关联内容，所以分页后再限制关联列的显示数量
+		for i, col := range table.Columns {
+			if av.KeyTypeRelation != col.Type || nil == col.Relation || 1 > col.Relation.DisplayLimit {
+				continue
+			}
+
+			for _, row := range table.Rows {
+				if cell := row.Cells[i]; nil != cell.Value && nil != cell.Value.Relation {
+					cell.Value.Relation.LimitContents(col.Relation.DisplayLimit)
+				}
+			}
+		}
 	}
 	return
 }
 
-func renderTemplateCol(ial map[string]string, tplContent string, rowValues []*av.KeyValues) string {
+// renderTemplateCol 渲染模板列，rowIndex（从 1 开始）和 rowCount 是行在过滤排序后的视图中的位置和行数，不在视图中渲染时为 0。
+func renderTemplateCol(ial map[string]string, tplContent string, rowValues []*av.KeyValues, rowIndex, rowCount int) string {
+	ret, err := renderTemplateCol0(ial, tplContent, rowValues, rowIndex, rowCount)
+	if nil != err {
+		logging.LogWarnf("render template [%s] failed: %s", tplContent, err)
+	}
+	return ret
+}
+
+func renderTemplateCol0(ial map[string]string, tplContent string, rowValues []*av.KeyValues, rowIndex, rowCount int) (ret string, err error) {
 	if "" == ial["id"] {
 		block := getRowBlockValue(rowValues)
 		if nil != block && nil != block.Block {
@@ -628,10 +1514,9 @@ func renderTemplateCol(ial map[string]string, tplContent string, rowValues []*av
 	tplFuncMap := util.BuiltInTemplateFuncs()
 	SQLTemplateFuncs(&tplFuncMap)
 	goTpl = goTpl.Funcs(tplFuncMap)
-	tpl, tplErr := goTpl.Parse(tplContent)
-	if nil != tplErr {
-		logging.LogWarnf("parse template [%s] failed: %s", tplContent, tplErr)
-		return ""
+	tpl, err := goTpl.Parse(tplContent)
+	if nil != err {
+		return
 	}
 
 	buf := &bytes.Buffer{}
@@ -659,59 +1544,371 @@ func renderTemplateCol(ial map[string]string, tplContent string, rowValues []*av
 			dataModel["updated"] = time.Now()
 		}
 	}
+	aliasDataModel := map[string]interface{}{} // 列别名，通过 .alias.xxx 引用
 	for _, rowValue := range rowValues {
 		if 0 < len(rowValue.Values) {
 			v := rowValue.Values[0]
+			var val interface{}
 			if av.KeyTypeNumber == v.Type {
-				dataModel[rowValue.Key.Name] = v.Number.Content
+				val = v.Number.Content
 			} else if av.KeyTypeDate == v.Type {
-				dataModel[rowValue.Key.Name] = time.UnixMilli(v.Date.Content)
+				val = time.UnixMilli(v.Date.Content)
 			} else {
-				dataModel[rowValue.Key.Name] = v.String()
+				val = v.String()
+			}
+			dataModel[rowValue.Key.Name] = val
+			if "" != rowValue.Key.Alias {
+				aliasDataModel[rowValue.Key.Alias] = val
 			}
 		}
 	}
-	if err := tpl.Execute(buf, dataModel); nil != err {
-		logging.LogWarnf("execute template [%s] failed: %s", tplContent, err)
-	}
-	return buf.String()
+	dataModel["alias"] = aliasDataModel
+	dataModel["rowIndex"] = rowIndex
+	dataModel["rowCount"] = rowCount
+	err = tpl.Execute(buf, dataModel)
+	ret = buf.String()
+	return
 }
 
-func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *av.Table, err error) {
-	ret = &av.Table{
-		ID:      view.ID,
-		Icon:    view.Icon,
-		Name:    view.Name,
-		Columns: []*av.TableColumn{},
-		Rows:    []*av.TableRow{},
-		Filters: view.Table.Filters,
-		Sorts:   view.Table.Sorts,
+// getAttributeViewParentSums 返回自关联列 relKeyID 中每个父行下所有子行在数字列 keyID 中的值之和，子行关联的第一行作为父行。
+func getAttributeViewParentSums(attrView *av.AttributeView, relKeyID, keyID string) (ret map[string]float64) {
+	ret = map[string]float64{}
+	relKeyValues, _ := attrView.GetKeyValues(relKeyID)
+	if nil == relKeyValues {
+		return
 	}
 
-	// 组装列
-	for _, col := range view.Table.Columns {
-		key, getErr := attrView.GetKey(col.ID)
-		if nil != getErr {
-			err = getErr
-			return
+	for _, relVal := range relKeyValues.Values {
+		if nil == relVal.Relation || 1 > len(relVal.Relation.BlockIDs) {
+			continue
 		}
+		ret[relVal.Relation.BlockIDs[0]] += getAttributeViewRowNumber(attrView, keyID, relVal.BlockID)
+	}
+	return
+}
 
-		ret.Columns = append(ret.Columns, &av.TableColumn{
-			ID:           key.ID,
-			Name:         key.Name,
-			Type:         key.Type,
-			Icon:         key.Icon,
-			Options:      key.Options,
-			NumberFormat: key.NumberFormat,
-			Template:     key.Template,
-			Relation:     key.Relation,
-			Rollup:       key.Rollup,
-			Wrap:         col.Wrap,
-			Hidden:       col.Hidden,
-			Width:        col.Width,
-			Pin:          col.Pin,
-			Calc:         col.Calc,
-		})
+// newRollupPercentOfParentValue 返回 rowID 行在数字列中的值占父行下所有子行之和的百分比，没有父行时为 100%。
+func newRollupPercentOfParentValue(attrView *av.AttributeView, rollup *av.Rollup, rowID string, parentSums map[string]float64) *av.Value {
+	var parentID string
+	if relVal := attrView.GetValue(rollup.RelationKeyID, rowID); nil != relVal && nil != relVal.Relation && 0 < len(relVal.Relation.BlockIDs) {
+		parentID = relVal.Relation.BlockIDs[0]
+	}
+	if "" == parentID {
+		return &av.Value{Type: av.KeyTypeNumber, Number: av.NewFormattedValuePercentOfColumn(1, 1)}
+	}
+	return &av.Value{Type: av.KeyTypeNumber, Number: av.NewFormattedValuePercentOfColumn(getAttributeViewRowNumber(attrView, rollup.KeyID, rowID), parentSums[parentID])}
+}
+
+func getAttributeViewRowNumber(attrView *av.AttributeView, keyID, rowID string) float64 {
+	if val := attrView.GetValue(keyID, rowID); nil != val && nil != val.Number && val.Number.IsNotEmpty {
+		return val.Number.Content
+	}
+	return 0
+}
+
+// renderAttributeViewOrderedCols 渲染依赖行顺序或者过滤结果的列，比如累计求和列、行差值列、占比列和引用了行位置的模板列，需要在过滤和排序之后调用。
+func renderAttributeViewOrderedCols(attrView *av.AttributeView, viewable av.Viewable) {
+	switch viewable.GetType() {
+	case av.LayoutTypeTable:
+		table := viewable.(*av.Table)
+		for i, col := range table.Columns {
+			switch col.Type {
+			case av.KeyTypeRunningTotal:
+				sourceKey, _ := attrView.GetKey(col.SourceKeyID)
+				format := av.NumberFormatNone
+				if nil != sourceKey {
+					format = sourceKey.NumberFormat
+				}
+
+				total := 0.0
+				for _, row := range table.Rows {
+					if nil != sourceKey {
+						if sourceVal := attrView.GetValue(sourceKey.ID, row.ID); nil != sourceVal && nil != sourceVal.Number && sourceVal.Number.IsNotEmpty {
+							total += sourceVal.Number.Content
+						}
+					}
+					row.Cells[i].Value.Number = av.NewFormattedValueNumber(total, format)
+				}
+			case av.KeyTypeRowDelta:
+				sourceKey, _ := attrView.GetKey(col.SourceKeyID)
+				if nil == sourceKey {
+					break
+				}
+
+				// 第一行以及当前行或者上一行没有值时为空
+				var prevVal *av.Value
+				for _, row := range table.Rows {
+					sourceVal := attrView.GetValue(sourceKey.ID, row.ID)
+					if nil != sourceVal && (nil == sourceVal.Number || !sourceVal.Number.IsNotEmpty) {
+						sourceVal = nil
+					}
+					if nil != sourceVal && nil != prevVal {
+						row.Cells[i].Value.Number = av.NewFormattedValueNumber(sourceVal.Number.Content-prevVal.Number.Content, sourceKey.NumberFormat)
+					} else {
+						row.Cells[i].Value.Number = &av.ValueNumber{}
+					}
+					prevVal = sourceVal
+				}
+			case av.KeyTypePercentOfColumn:
+				sourceKey, _ := attrView.GetKey(col.SourceKeyID)
+				if nil == sourceKey {
+					break
+				}
+
+				total := 0.0
+				for _, row := range table.Rows {
+					if sourceVal := attrView.GetValue(sourceKey.ID, row.ID); nil != sourceVal && nil != sourceVal.Number && sourceVal.Number.IsNotEmpty {
+						total += sourceVal.Number.Content
+					}
+				}
+				for _, row := range table.Rows {
+					if sourceVal := attrView.GetValue(sourceKey.ID, row.ID); nil != sourceVal && nil != sourceVal.Number && sourceVal.Number.IsNotEmpty {
+						row.Cells[i].Value.Number = av.NewFormattedValuePercentOfColumn(sourceVal.Number.Content, total)
+					}
+				}
+			case av.KeyTypeTemplate:
+				// 模板中引用了 .rowIndex 或 .rowCount 时，使用过滤排序后的行位置重新渲染
+				if !strings.Contains(col.Template, ".rowIndex") && !strings.Contains(col.Template, ".rowCount") {
+					break
+				}
+
+				for j, row := range table.Rows {
+					ial := map[string]string{}
+					if block := row.GetBlockValue(); nil != block && !block.IsDetached {
+						ial = GetBlockAttrsWithoutWaitWriting(row.ID)
+					}
+					content := renderTemplateCol(ial, col.Template, getAttributeViewRowValues(attrView, row.ID), j+1, len(table.Rows))
+					row.Cells[i].Value.Template = &av.ValueTemplate{Content: content}
+				}
+			}
+		}
+	}
+}
+
+// getComputedValueSignature 使用块 blockIDs 及其更新时间生成计算列缓存的依赖签名，游离行没有块树，仅使用行 ID。
+func getComputedValueSignature(blockIDs []string) string {
+	buf := bytes.Buffer{}
+	for _, blockID := range blockIDs {
+		buf.WriteString(blockID)
+		if bt := treenode.GetBlockTree(blockID); nil != bt {
+			buf.WriteString(bt.Updated)
+		}
+		buf.WriteByte(',')
+	}
+	return buf.String()
+}
+
+// attributeViewVolatileTemplateRegexp 匹配模板中引用的当前时间、创建和更新时间、SQL 查询等函数和变量，
+// 这些模板的结果会随时间或者其他块的变化而变化，无法通过依赖签名判断是否需要重新计算。
+var attributeViewVolatileTemplateRegexp = regexp.MustCompile(`\b(now|ago|parseTime|created|updated|queryBlocks|querySpans)\b`)
+
+// isAttributeViewTemplateCacheable 判断模板列的模板 tplContent 的计算结果是否可以缓存。
+func isAttributeViewTemplateCacheable(tplContent string) bool {
+	return !attributeViewVolatileTemplateRegexp.MatchString(tplContent)
+}
+
+// getAttributeViewBacklinkCounts 查询绑定块被引用的次数，返回行 ID 到反链数的映射。
+func getAttributeViewBacklinkCounts(rows []*av.TableRow) (ret map[string]int) {
+	var ids []string
+	for _, row := range rows {
+		if block := row.GetBlockValue(); nil != block && !block.IsDetached {
+			ids = append(ids, row.ID)
+		}
+	}
+	if 1 > len(ids) {
+		return map[string]int{}
+	}
+	return sql.QueryRefCount(ids)
+}
+
+// GetAttributeViewSchema 返回表格视图的列（类型、选项、数字格式、关联和汇总配置等），不渲染行，用于构建过滤和排序选择器。
+// viewID 为空时使用当前视图。
+func GetAttributeViewSchema(avID, viewID string) (columns []*av.TableColumn, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	var view *av.View
+	if "" != viewID {
+		view = attrView.GetView(viewID)
+		if nil == view {
+			err = av.ErrViewNotFound
+			return
+		}
+	} else if view, err = attrView.GetCurrentView(); nil != err {
+		return
+	}
+	if av.LayoutTypeTable != view.LayoutType || nil == view.Table {
+		err = fmt.Errorf("view [%s] is not a table view", view.ID)
+		return
+	}
+
+	columns, err = renderAttributeViewTableColumns(attrView, view)
+	return
+}
+
+// GetRelatedRowsPreview 返回关联列单元格中各个关联块在目标属性视图中的预览，用于悬浮预览关联行。
+// 每个关联块返回一个列 ID 到渲染后的值的映射，并使用 id 返回关联块 ID；keyIDs 为空时只返回主键。目标属性视图中已经不存在的块会被跳过。
+func GetRelatedRowsPreview(avID, keyID, rowID string, keyIDs []string) (rows []map[string]string, err error) {
+	rows = []map[string]string{}
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	relKey, err := attrView.GetKey(keyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeRelation != relKey.Type || nil == relKey.Relation {
+		err = fmt.Errorf("key [%s] is not a relation", keyID)
+		return
+	}
+
+	relVal := attrView.GetValue(keyID, rowID)
+	if nil == relVal || nil == relVal.Relation || 1 > len(relVal.Relation.BlockIDs) {
+		return
+	}
+
+	destAv, err := av.ParseAttributeView(relKey.Relation.AvID)
+	if nil != err {
+		return
+	}
+
+	if 1 > len(keyIDs) {
+		keyIDs = []string{destAv.GetBlockKeyValues().Key.ID}
+	}
+	// 使用只包含预览列的临时视图渲染，这样模板列和汇总列等计算列也能得到渲染后的值
+	view := &av.View{ID: ast.NewNodeID(), LayoutType: av.LayoutTypeTable, Table: &av.LayoutTable{ID: ast.NewNodeID(), Filters: []*av.ViewFilter{}, Sorts: []*av.ViewSort{}}}
+	for _, id := range keyIDs {
+		view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: id})
+	}
+	table, err := renderAttributeViewTable(destAv, view, nil)
+	if nil != err {
+		return
+	}
+
+	tableRows := map[string]*av.TableRow{}
+	for _, row := range table.Rows {
+		tableRows[row.ID] = row
+	}
+	for _, blockID := range relVal.Relation.BlockIDs {
+		row := tableRows[blockID]
+		if nil == row {
+			continue
+		}
+
+		preview := map[string]string{"id": blockID}
+		for i, col := range table.Columns {
+			if value := row.Cells[i].Value; nil != value {
+				preview[col.ID] = value.String()
+			} else {
+				preview[col.ID] = ""
+			}
+		}
+		rows = append(rows, preview)
+	}
+	return
+}
+
+// RenderAttributeViewTransposed 将视图 viewID 中的 rowID 行转置渲染，返回列名和渲染后的值组成的键值对，按照视图中的列顺序排列。
+// 计算列使用渲染后的值，视图的过滤条件不影响结果，只读不保存。viewID 为空时使用当前视图。
+func RenderAttributeViewTransposed(avID, viewID, rowID string) (pivot [][]string, err error) {
+	waitForSyncingStorages()
+	pivot = [][]string{}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	var view *av.View
+	if "" != viewID {
+		view = attrView.GetView(viewID)
+	} else {
+		view, _ = attrView.GetCurrentView()
+	}
+	if nil == view || av.LayoutTypeTable != view.LayoutType {
+		err = av.ErrViewNotFound
+		return
+	}
+
+	table, err := renderAttributeViewTable(attrView, view, nil)
+	if nil != err {
+		return
+	}
+	table.SortRows()
+	renderAttributeViewOrderedCols(attrView, table)
+
+	for _, row := range table.Rows {
+		if rowID != row.ID {
+			continue
+		}
+
+		for i, col := range table.Columns {
+			var content string
+			if value := row.Cells[i].Value; nil != value {
+				content = value.String()
+			}
+			pivot = append(pivot, []string{col.Name, content})
+		}
+		return
+	}
+	err = fmt.Errorf("row [%s] not found in attribute view [%s]", rowID, avID)
+	return
+}
+
+func renderAttributeViewTable(attrView *av.AttributeView, view *av.View, opts *RenderAttributeViewOptions) (ret *av.Table, err error) {
+	if nil == opts {
+		opts = &RenderAttributeViewOptions{}
+	}
+	locale := opts.Locale
+
+	ret = &av.Table{
+		ID:      view.ID,
+		Icon:    view.Icon,
+		Name:    view.Name,
+		Columns: []*av.TableColumn{},
+		Rows:    []*av.TableRow{},
+		Filters: view.Table.Filters,
+		Sorts:   view.Table.Sorts,
+
+		CalcPosition:      view.Table.CalcPosition,
+		TopRowIDs:         view.Table.TopRowIDs,
+		FrozenColumnCount: view.Table.FrozenColumnCount,
+		RowBindingState:   view.Table.RowBindingState,
+		GroupBy:           view.Table.GroupBy,
+		ShowGroupPercent:  view.Table.ShowGroupPercent,
+
+		RelationContextBlockID: opts.RelationContextBlockID,
+		CurrentAuthor:          getAttributeViewCurrentAuthor(),
+	}
+	if nil != opts.OverrideSorts {
+		ret.Sorts = opts.OverrideSorts
+	}
+
+	// 组装列
+	if ret.Columns, err = renderAttributeViewTableColumns(attrView, view); nil != err {
+		return
+	}
+
+	if "" == ret.CalcPosition {
+		ret.CalcPosition = av.CalcPositionBottom
+	}
+	if ret.FrozenColumnCount > len(ret.Columns) {
+		// 删除列后冻结列数可能超过列数
+		ret.FrozenColumnCount = len(ret.Columns)
+	}
+	ret.ColumnGroups = renderAttributeViewColumnGroups(view.Table.ColumnGroups, ret.Columns)
+
+	// 所有列都被隐藏时表格是空的，需要标记出来让前端提示取消隐藏，没有列时不算隐藏
+	ret.AllColumnsHidden = 0 < len(ret.Columns)
+	for _, col := range ret.Columns {
+		if !col.Hidden {
+			ret.AllColumnsHidden = false
+			break
+		}
 	}
 
 	// 生成行
@@ -755,6 +1952,12 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 		}
 
 		if treenode.GetBlockTree(blockID) == nil {
+			if attrView.KeepDeletedRows && nil != blockValue.Block {
+				// 块在属性视图外被删除时仅在本次渲染中将行标记为游离行，渲染时不保存，持久化由删除块时的同步处理
+				blockValue.IsDetached = true
+				blockValue.IsBlockDeleted = true
+				continue
+			}
 			notFound = append(notFound, blockID)
 		}
 	}
@@ -789,7 +1992,11 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 			case av.KeyTypeNumber: // 格式化数字
 				if nil != tableCell.Value && nil != tableCell.Value.Number && tableCell.Value.Number.IsNotEmpty {
 					tableCell.Value.Number.Format = col.NumberFormat
-					tableCell.Value.Number.FormatNumber()
+					tableCell.Value.Number.FormatNumber(locale)
+				}
+			case av.KeyTypeDate: // 按区域设置格式化日期
+				if "" != locale && nil != tableCell.Value && nil != tableCell.Value.Date {
+					tableCell.Value.Date.FormatTime(locale)
 				}
 			case av.KeyTypeTemplate: // 渲染模板列
 				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeTemplate, Template: &av.ValueTemplate{Content: col.Template}}
@@ -797,6 +2004,26 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeCreated}
 			case av.KeyTypeUpdated: // 填充更新时间列值，后面再渲染
 				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeUpdated}
+			case av.KeyTypeRunningTotal: // 填充累计求和列值，排序后再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeRunningTotal}
+			case av.KeyTypeRowDelta: // 填充行差值列值，排序后再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeRowDelta, Number: &av.ValueNumber{}}
+			case av.KeyTypeBacklinkCount: // 填充反链数列值，后面再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeBacklinkCount, Number: &av.ValueNumber{}}
+			case av.KeyTypePercentOfColumn: // 填充占比列值，过滤后再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypePercentOfColumn, Number: &av.ValueNumber{}}
+			case av.KeyTypeBlockAttr: // 填充块属性列值，后面再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeBlockAttr, Text: &av.ValueText{}}
+			case av.KeyTypeCreatedBy, av.KeyTypeUpdatedBy: // 填充创建者列和更新者列值
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: col.Type, Text: &av.ValueText{Content: getAttributeViewRowAuthor(attrView, rowID, col.Type)}}
+			case av.KeyTypeAge: // 填充存在时长列值，后面再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeAge, Number: &av.ValueNumber{}}
+			case av.KeyTypeDateDelta: // 填充日期间隔列值，后面再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeDateDelta, Number: &av.ValueNumber{}}
+			case av.KeyTypeTextLength: // 填充文本长度列值，其他列渲染完成后再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeTextLength, Number: &av.ValueNumber{}}
+			case av.KeyTypeFormula: // 填充公式列值，其他列渲染完成后再渲染
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeFormula, Number: &av.ValueNumber{}}
 			case av.KeyTypeRelation: // 清空关联列值，后面再渲染 https://ld246.com/article/1703831044435
 				if nil != tableCell.Value && nil != tableCell.Value.Relation {
 					tableCell.Value.Relation.Contents = nil
@@ -807,22 +2034,60 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 
 			tableRow.Cells = append(tableRow.Cells, tableCell)
 		}
+		tableRow.Color = view.Table.RowColors[rowID]
 		ret.Rows = append(ret.Rows, &tableRow)
 	}
 
 	// 渲染自动生成的列值，比如模板列、关联列、汇总列、创建时间列和更新时间列
+	now := time.Now()
+	relationTrees := map[string]*parse.Tree{} // 解析关联列富文本锚文本时缓存已加载的文档树
+	var backlinkCounts map[string]int         // 绑定块的反链数，渲染反链数列时一次性查询
+
+	percentOfParentSums := map[string]map[string]float64{} // 汇总列 ID -> 父行 ID -> 子行之和，渲染占父行百分比时一次性计算
 	for _, row := range ret.Rows {
 		for _, cell := range row.Cells {
 			switch cell.ValueType {
 			case av.KeyTypeTemplate: // 渲染模板列
+				var signature string
+				cacheable := attrView.CacheComputedCols && isAttributeViewTemplateCacheable(cell.Value.Template.Content)
+				if cacheable {
+					signature = locale + getComputedValueSignature([]string{row.ID})
+					if cached := av.GetComputedValue(attrView.ID, cell.Value.KeyID, row.ID, signature); nil != cached && nil != cached.Template {
+						cell.Value.Template = cached.Template
+						break
+					}
+				}
+
 				keyValues := rows[row.ID]
 				ial := map[string]string{}
 				block := row.GetBlockValue()
 				if nil != block && !block.IsDetached {
 					ial = GetBlockAttrsWithoutWaitWriting(row.ID)
 				}
-				content := renderTemplateCol(ial, cell.Value.Template.Content, keyValues)
+				content := renderTemplateCol(ial, cell.Value.Template.Content, keyValues, 0, 0)
 				cell.Value.Template.Content = content
+				if cacheable {
+					av.PutComputedValue(attrView.ID, cell.Value.KeyID, row.ID, signature, "", cell.Value)
+				}
+			case av.KeyTypeBlockAttr: // 渲染块属性列，游离行没有绑定块，所以为空
+				attrKey, _ := attrView.GetKey(cell.Value.KeyID)
+				block := row.GetBlockValue()
+				if nil != attrKey && "" != attrKey.AttrName && nil != block && !block.IsDetached {
+					cell.Value.Text.Content = GetBlockAttrsWithoutWaitWriting(row.ID)[attrKey.AttrName]
+				}
+			case av.KeyTypeBacklinkCount: // 渲染反链数列，游离行没有绑定块，所以为 0
+				if nil == backlinkCounts {
+					backlinkCounts = getAttributeViewBacklinkCounts(ret.Rows)
+				}
+				cell.Value.Number = av.NewFormattedValueNumber(float64(backlinkCounts[row.ID]), av.NumberFormatNone)
+			case av.KeyTypeAge: // 渲染存在时长列，每次渲染都基于当前时间重新计算
+				cell.Value.Number = av.NewFormattedValueAge(getAttributeViewRowCreated(attrView, row.ID), now)
+			case av.KeyTypeDateDelta: // 渲染日期间隔列，每次渲染都基于当前时间重新计算
+				if deltaKey, _ := attrView.GetKey(cell.Value.KeyID); nil != deltaKey {
+					if dateVal := attrView.GetValue(deltaKey.SourceKeyID, row.ID); nil != dateVal && nil != dateVal.Date && dateVal.Date.IsNotEmpty {
+						cell.Value.Number = av.NewFormattedValueDateDelta(time.UnixMilli(dateVal.Date.Content), now)
+					}
+				}
 			case av.KeyTypeRollup: // 渲染汇总列
 				rollupKey, _ := attrView.GetKey(cell.Value.KeyID)
 				if nil == rollupKey || nil == rollupKey.Rollup {
@@ -834,11 +2099,30 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 					break
 				}
 
+				if nil != rollupKey.Rollup.Calc && av.CalcOperatorPercentOfParent == rollupKey.Rollup.Calc.Operator {
+					if relKey.Relation.AvID == attrView.ID {
+						if nil == percentOfParentSums[rollupKey.ID] {
+							percentOfParentSums[rollupKey.ID] = getAttributeViewParentSums(attrView, relKey.ID, rollupKey.Rollup.KeyID)
+						}
+						cell.Value.Rollup.Contents = []*av.Value{newRollupPercentOfParentValue(attrView, rollupKey.Rollup, row.ID, percentOfParentSums[rollupKey.ID])}
+					}
+					break
+				}
+
 				relVal := attrView.GetValue(relKey.ID, row.ID)
 				if nil == relVal || nil == relVal.Relation {
 					break
 				}
 
+				var signature string
+				if attrView.CacheComputedCols {
+					signature = locale + strconv.FormatBool(opts.ExpandRollups) + getComputedValueSignature(relVal.Relation.BlockIDs)
+					if cached := av.GetComputedValue(attrView.ID, cell.Value.KeyID, row.ID, signature); nil != cached && nil != cached.Rollup {
+						cell.Value.Rollup = cached.Rollup
+						break
+					}
+				}
+
 				destAv, _ := av.ParseAttributeView(relKey.Relation.AvID)
 				if nil == destAv {
 					break
@@ -850,30 +2134,78 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 				}
 
 				for _, blockID := range relVal.Relation.BlockIDs {
-					destVal := destAv.GetValue(rollupKey.Rollup.KeyID, blockID)
+					targetKey := getAttributeViewRollupTargetKey(destAv, rollupKey.Rollup, blockID, destKey)
+					destVal := destAv.GetValue(targetKey.ID, blockID)
+					if av.KeyTypeFormula == targetKey.Type {
+						destVal = getAttributeViewFormulaValue(destAv, targetKey, blockID)
+					}
 					if nil == destVal {
-						destVal = treenode.GetAttributeViewDefaultValue(ast.NewNodeID(), rollupKey.Rollup.KeyID, blockID, destKey.Type)
+						destVal = treenode.GetAttributeViewDefaultValue(ast.NewNodeID(), targetKey.ID, blockID, targetKey.Type)
+					}
+					if av.KeyTypeNumber == targetKey.Type {
+						destVal.Number.Format = targetKey.NumberFormat
+						destVal.Number.FormatNumber(locale)
 					}
-					if av.KeyTypeNumber == destKey.Type {
-						destVal.Number.Format = destKey.NumberFormat
-						destVal.Number.FormatNumber()
+					// 创建时间和更新时间是渲染时计算的，汇总时需要补全
+					if av.KeyTypeCreated == targetKey.Type && (nil == destVal.Created || 0 == destVal.Created.Content) {
+						destVal.Created = av.NewFormattedValueCreated(getAttributeViewRowCreated(destAv, blockID).UnixMilli(), 0, av.CreatedFormatNone)
+						destVal.Created.IsNotEmpty = true
+					}
+					if av.KeyTypeUpdated == targetKey.Type && (nil == destVal.Updated || 0 == destVal.Updated.Content) {
+						if blockVal := destAv.GetBlockKeyValues().GetValue(blockID); nil != blockVal && nil != blockVal.Block && 0 < blockVal.Block.Updated {
+							destVal.Updated = av.NewFormattedValueUpdated(blockVal.Block.Updated, 0, av.UpdatedFormatNone)
+							destVal.Updated.IsNotEmpty = true
+						}
 					}
 
 					cell.Value.Rollup.Contents = append(cell.Value.Rollup.Contents, destVal.Clone())
 				}
 
+				if opts.ExpandRollups {
+					cell.Value.Rollup.Details = cell.Value.Rollup.Contents
+				}
+				if nil != rollupKey.Rollup.Calc && av.CalcOperatorLatestValue == rollupKey.Rollup.Calc.Operator {
+					cell.Value.Rollup.KeepLatestValue(getAttributeViewRowsUpdated(destAv))
+				}
 				cell.Value.Rollup.RenderContents(rollupKey.Rollup.Calc, destKey)
+				if "" != locale && nil != rollupKey.Rollup.Calc {
+					switch rollupKey.Rollup.Calc.Operator {
+					case av.CalcOperatorNone, av.CalcOperatorLatestValue, av.CalcOperatorFirstRelated, av.CalcOperatorPercentOfParent, av.CalcOperatorPercentDone:
+						// 这些计算保留原始值或者自行格式化了结果
+					default:
+						// 汇总计算的结果也需要按区域设置格式化
+						for _, content := range cell.Value.Rollup.Contents {
+							if nil != content.Number && content.Number.IsNotEmpty {
+								content.Number.FormatNumber(locale)
+							}
+						}
+					}
+				}
+				if attrView.CacheComputedCols {
+					av.PutComputedValue(attrView.ID, cell.Value.KeyID, row.ID, signature, destAv.ID, cell.Value)
+				}
 			case av.KeyTypeRelation: // 渲染关联列
 				relKey, _ := attrView.GetKey(cell.Value.KeyID)
 				if nil != relKey && nil != relKey.Relation {
 					destAv, _ := av.ParseAttributeView(relKey.Relation.AvID)
 					if nil != destAv {
-						blocks := map[string]string{}
+						blocks, detachedBlocks := map[string]string{}, map[string]bool{}
 						for _, blockValue := range destAv.GetBlockKeyValues().Values {
 							blocks[blockValue.BlockID] = blockValue.Block.Content
+							detachedBlocks[blockValue.BlockID] = blockValue.IsDetached
 						}
+						cell.Value.Relation.Count = 0
 						for _, blockID := range cell.Value.Relation.BlockIDs {
-							cell.Value.Relation.Contents = append(cell.Value.Relation.Contents, blocks[blockID])
+							content, exist := blocks[blockID]
+							if exist && opts.RichRelationContents && !detachedBlocks[blockID] {
+								if richText := getBlockRichRefText(blockID, relationTrees); "" != richText {
+									content = richText
+								}
+							}
+							cell.Value.Relation.Contents = append(cell.Value.Relation.Contents, content)
+							if exist {
+								cell.Value.Relation.Count++
+							}
 						}
 					}
 				}
@@ -886,6 +2218,9 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 				} else {
 					cell.Value.Created = av.NewFormattedValueCreated(time.Now().UnixMilli(), 0, av.CreatedFormatNone)
 				}
+				if "" != locale {
+					cell.Value.Created.FormatTime(locale)
+				}
 			case av.KeyTypeUpdated: // 渲染更新时间
 				ial := map[string]string{}
 				block := row.GetBlockValue()
@@ -894,7 +2229,13 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 				}
 				updatedStr := ial["updated"]
 				if "" == updatedStr && nil != block {
-					cell.Value.Updated = av.NewFormattedValueUpdated(block.Block.Updated, 0, av.UpdatedFormatNone)
+					updated := block.Block.Updated
+					if block.IsDetached { // 游离行优先使用更新时间列中保存的值
+						if storedVal := attrView.GetValue(cell.Value.KeyID, row.ID); nil != storedVal && nil != storedVal.Updated && storedVal.Updated.IsNotEmpty {
+							updated = storedVal.Updated.Content
+						}
+					}
+					cell.Value.Updated = av.NewFormattedValueUpdated(updated, 0, av.UpdatedFormatNone)
 					cell.Value.Updated.IsNotEmpty = true
 				} else {
 					updated, parseErr := time.ParseInLocation("20060102150405", updatedStr, time.Local)
@@ -905,6 +2246,60 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 						cell.Value.Updated = av.NewFormattedValueUpdated(time.Now().UnixMilli(), 0, av.UpdatedFormatNone)
 					}
 				}
+				if "" != locale {
+					cell.Value.Updated.FormatTime(locale)
+				}
+			}
+		}
+	}
+
+	// 渲染文本长度列，来源列可能是模板列，所以在其他列渲染完成后再计算
+	for i, col := range ret.Columns {
+		if av.KeyTypeTextLength != col.Type {
+			continue
+		}
+
+		lengthKey, _ := attrView.GetKey(col.ID)
+		if nil == lengthKey {
+			continue
+		}
+		sourceIndex := -1
+		for j, sourceCol := range ret.Columns {
+			if sourceKey, _ := attrView.GetKey(sourceCol.ID); nil != sourceKey && isAttributeViewTextLengthSource(lengthKey, sourceKey) {
+				sourceIndex = j
+				break
+			}
+		}
+
+		for _, row := range ret.Rows {
+			var content string
+			if -1 < sourceIndex && nil != row.Cells[sourceIndex].Value {
+				content = row.Cells[sourceIndex].Value.String()
+			}
+			row.Cells[i].Value.Number = av.NewFormattedValueTextLength(content, lengthKey.CountMode)
+		}
+	}
+
+	// 渲染公式列，公式可以引用汇总列、关联列和文本长度列等计算列，所以在这些列渲染完成后再计算
+	// 累计求和列、占比列和行差值列依赖过滤和排序的结果，在公式中按空值计算
+	var formulaIndexes []int
+	for i, col := range ret.Columns {
+		if av.KeyTypeFormula == col.Type {
+			formulaIndexes = append(formulaIndexes, i)
+		}
+	}
+	if 0 < len(formulaIndexes) {
+		for _, row := range ret.Rows {
+			formulaCtx := newAttributeViewFormulaContext(attrView, getAttributeViewRowCellValues(attrView, ret.Columns, row))
+			for _, i := range formulaIndexes {
+				formulaKey, _ := attrView.GetKey(ret.Columns[i].ID)
+				if nil == formulaKey {
+					continue
+				}
+				if num, evalErr := formulaCtx.EvalKey(formulaKey); nil == evalErr {
+					num.FormatNumber(locale)
+					row.Cells[i].Value.Number = num
+				}
 			}
 		}
 	}
@@ -917,7 +2312,7 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 		}
 	}
 
-	sort.Slice(ret.Rows, func(i, j int) bool {
+	sort.SliceStable(ret.Rows, func(i, j int) bool {
 		iv := sortRowIDs[ret.Rows[i].ID]
 		jv := sortRowIDs[ret.Rows[j].ID]
 		if iv == jv {
@@ -928,6 +2323,58 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 	return
 }
 
+// renderAttributeViewTableColumns 根据视图的列配置组装表格列，不渲染行。
+func renderAttributeViewTableColumns(attrView *av.AttributeView, view *av.View) (ret []*av.TableColumn, err error) {
+	ret = []*av.TableColumn{}
+	for _, col := range view.Table.Columns {
+		key, getErr := attrView.GetKey(col.ID)
+		if nil != getErr {
+			err = getErr
+			return
+		}
+
+		ret = append(ret, &av.TableColumn{
+			ID:               key.ID,
+			Name:             key.Name,
+			Type:             key.Type,
+			Icon:             key.Icon,
+			Alias:            key.Alias,
+			Options:          key.Options,
+			EmptyPlaceholder: key.EmptyPlaceholder,
+			Mask:             key.Mask,
+			MaskStrict:       key.MaskStrict,
+			NumberFormat:     key.NumberFormat,
+			Template:         key.Template,
+			Relation:         key.Relation,
+			Rollup:           key.Rollup,
+			SourceKeyID:      key.SourceKeyID,
+			CountMode:        key.CountMode,
+			AttrName:         key.AttrName,
+			Formula:          key.Formula,
+			Wrap:             col.Wrap,
+			Hidden:           col.Hidden,
+			Width:            col.Width,
+			Pin:              col.Pin,
+			Calc:             col.Calc,
+			PinSide:          col.PinSide,
+		})
+	}
+
+	// 固定在右侧的列按照相对顺序排在所有列之后
+	var leftCols, rightCols []*av.TableColumn
+	for _, col := range ret {
+		if col.Pin && av.PinSideRight == col.PinSide {
+			rightCols = append(rightCols, col)
+		} else {
+			leftCols = append(leftCols, col)
+		}
+	}
+	if 0 < len(rightCols) {
+		ret = append(leftCols, rightCols...)
+	}
+	return
+}
+
 func getRowBlockValue(keyValues []*av.KeyValues) (ret *av.Value) {
 	for _, kv := range keyValues {
 		if av.KeyTypeBlock == kv.Key.Type && 0 < len(kv.Values) {
@@ -938,6 +2385,19 @@ func getRowBlockValue(keyValues []*av.KeyValues) (ret *av.Value) {
 	return
 }
 
+// getAttributeViewRollupTargetKey 返回汇总列在关联块 blockID 上实际使用的目标列，primaryKey 为主目标列。
+func getAttributeViewRollupTargetKey(destAv *av.AttributeView, rollup *av.Rollup, blockID string, primaryKey *av.Key) *av.Key {
+	keyID := rollup.GetTargetKeyID(destAv, blockID)
+	if keyID == primaryKey.ID {
+		return primaryKey
+	}
+
+	if ret, _ := destAv.GetKey(keyID); nil != ret {
+		return ret
+	}
+	return primaryKey
+}
+
 func (tx *Transaction) doUpdateAttrViewColRollup(operation *Operation) (ret *TxErr) {
 	err := updateAttributeViewColRollup(operation)
 	if nil != err {
@@ -951,7 +2411,7 @@ func updateAttributeViewColRollup(operation *Operation) (err error) {
 	// operation.ID 汇总列 ID
 	// operation.ParentID 汇总列基于的关联列 ID
 	// operation.KeyID 目标列 ID
-	// operation.Data 计算方式
+	// operation.Data 计算方式、备选目标列 ID 和选择目标列的条件
 
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
@@ -981,6 +2441,24 @@ func updateAttributeViewColRollup(operation *Operation) (err error) {
 				return
 			}
 		}
+		if altKeyID, ok := data["altKeyID"].(string); ok {
+			rollUpKey.Rollup.AltKeyID = altKeyID
+		}
+		if nil != data["condition"] {
+			conditionData, jsonErr := gulu.JSON.MarshalJSON(data["condition"])
+			if nil != jsonErr {
+				err = jsonErr
+				return
+			}
+			if jsonErr = gulu.JSON.UnmarshalJSON(conditionData, &rollUpKey.Rollup.Condition); nil != jsonErr {
+				err = jsonErr
+				return
+			}
+		}
+	}
+
+	if err = checkAttributeViewFormulaCycle(attrView, rollUpKey.ID); nil != err {
+		return
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -1124,6 +2602,76 @@ func updateAttributeViewColRelation(operation *Operation) (err error) {
 	return
 }
 
+func (tx *Transaction) doSplitTwoWayRelation(operation *Operation) (ret *TxErr) {
+	err := splitTwoWayRelation(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// splitTwoWayRelation 将双向关联拆分为两个互相独立的单向关联列，两侧已有的关联值都保留。
+func splitTwoWayRelation(operation *Operation) (err error) {
+	// operation.AvID 源 avID
+	// operation.KeyID 源 av 关联列 ID
+
+	srcAv, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	srcKey, err := srcAv.GetKey(operation.KeyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeRelation != srcKey.Type || nil == srcKey.Relation {
+		err = fmt.Errorf("key [%s] is not a relation", operation.KeyID)
+		return
+	}
+	if !srcKey.Relation.IsTwoWay {
+		return
+	}
+
+	destAv := srcAv
+	isSameAv := srcKey.Relation.AvID == srcAv.ID
+	if !isSameAv {
+		destAv, err = av.ParseAttributeView(srcKey.Relation.AvID)
+		if nil != err {
+			return
+		}
+	}
+
+	backKeyID := srcKey.Relation.BackKeyID
+	srcKey.Relation.IsTwoWay = false
+	srcKey.Relation.BackKeyID = ""
+	backKey, _ := destAv.GetKey(backKeyID)
+	if nil != backKey && nil != backKey.Relation && backKey.Relation.AvID == srcAv.ID {
+		backKey.Relation.IsTwoWay = false
+		backKey.Relation.BackKeyID = ""
+	} else {
+		backKey = nil
+	}
+
+	err = av.SaveAttributeView(srcAv)
+	if nil != err {
+		return
+	}
+	if !isSameAv {
+		err = av.SaveAttributeView(destAv)
+		if nil != err {
+			return
+		}
+		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": destAv.ID})
+	}
+
+	// 拆分后两侧都是单向关联，分别记录引用关系
+	av.UpsertAvBackRel(srcAv.ID, destAv.ID)
+	if nil != backKey {
+		av.UpsertAvBackRel(destAv.ID, srcAv.ID)
+	}
+	return
+}
+
 func (tx *Transaction) doSortAttrViewView(operation *Operation) (ret *TxErr) {
 	avID := operation.AvID
 	attrView, err := av.ParseAttributeView(avID)
@@ -1168,6 +2716,69 @@ func (tx *Transaction) doSortAttrViewView(operation *Operation) (ret *TxErr) {
 	return
 }
 
+func (tx *Transaction) doSortAttrViewViews(operation *Operation) (ret *TxErr) {
+	err := sortAttributeViewViews(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func sortAttributeViewViews(operation *Operation) (err error) {
+	// operation.Data 为排序方式：
+	//   "asc"/"desc" 按视图名称升序/降序
+	//   "created" 按视图创建时间
+	//   视图 ID 数组时按给定的顺序排列，未给出的视图按原顺序排在后面
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	switch data := operation.Data.(type) {
+	case string:
+		switch data {
+		case "asc":
+			sort.SliceStable(attrView.Views, func(i, j int) bool {
+				return util.PinYinCompare(attrView.Views[i].Name, attrView.Views[j].Name)
+			})
+		case "desc":
+			sort.SliceStable(attrView.Views, func(i, j int) bool {
+				return util.PinYinCompare(attrView.Views[j].Name, attrView.Views[i].Name)
+			})
+		case "created":
+			sort.SliceStable(attrView.Views, func(i, j int) bool {
+				return attrView.Views[i].ID < attrView.Views[j].ID
+			})
+		default:
+			err = fmt.Errorf("invalid view sort criterion [%s]", data)
+			return
+		}
+	case []interface{}:
+		order := map[string]int{}
+		for i, id := range data {
+			if viewID, ok := id.(string); ok {
+				if _, exist := order[viewID]; !exist {
+					order[viewID] = i
+				}
+			}
+		}
+		sort.SliceStable(attrView.Views, func(i, j int) bool {
+			io, iok := order[attrView.Views[i].ID]
+			jo, jok := order[attrView.Views[j].ID]
+			if iok && jok {
+				return io < jo
+			}
+			return iok && !jok
+		})
+	default:
+		err = fmt.Errorf("invalid view sort criterion [%v]", data)
+		return
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
 func (tx *Transaction) doRemoveAttrViewView(operation *Operation) (ret *TxErr) {
 	var err error
 	avID := operation.AvID
@@ -1203,6 +2814,155 @@ func (tx *Transaction) doRemoveAttrViewView(operation *Operation) (ret *TxErr) {
 	return
 }
 
+// DuplicateAttributeView 复制属性视图 avID 的列、值和所有视图到一个新的属性视图中，所有 ID 都会重新生成。
+//
+// 关联到其他属性视图的关联列会被保留，但是因为回链列属于原属性视图，所以副本中的双向关联会变为单向关联；
+// 自关联会重新指向新的属性视图。绑定块的行在副本中会变为游离行，以免同一个块被重复绑定。
+func DuplicateAttributeView(avID, newName string) (newAvID string, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(attrView)
+	if nil != err {
+		return
+	}
+	ret := &av.AttributeView{}
+	if err = gulu.JSON.UnmarshalJSON(data, ret); nil != err {
+		return
+	}
+
+	ret.ID = ast.NewNodeID()
+	if "" != strings.TrimSpace(newName) {
+		ret.Name = strings.TrimSpace(newName)
+	}
+
+	keyIDMap, rowIDMap := map[string]string{}, map[string]string{}
+	remapKeyID := func(id string) string {
+		if newID := keyIDMap[id]; "" != newID {
+			return newID
+		}
+		return id
+	}
+	remapRowID := func(id string) string {
+		if newID := rowIDMap[id]; "" != newID {
+			return newID
+		}
+		return id
+	}
+
+	for _, keyValues := range ret.KeyValues {
+		if err = checkAttributeViewKeyTypeAllowed(keyValues.Key.Type); nil != err {
+			return
+		}
+		keyIDMap[keyValues.Key.ID] = ast.NewNodeID()
+	}
+	if blockKeyValues := ret.GetBlockKeyValues(); nil != blockKeyValues {
+		for _, blockValue := range blockKeyValues.Values {
+			rowIDMap[blockValue.BlockID] = ast.NewNodeID()
+		}
+	}
+
+	for _, keyValues := range ret.KeyValues {
+		key := keyValues.Key
+		key.ID = keyIDMap[key.ID]
+		key.SourceKeyID = remapKeyID(key.SourceKeyID)
+		if nil != key.Relation {
+			if avID == key.Relation.AvID {
+				key.Relation.AvID = ret.ID
+				key.Relation.BackKeyID = remapKeyID(key.Relation.BackKeyID)
+			} else {
+				key.Relation.IsTwoWay = false
+				key.Relation.BackKeyID = ""
+			}
+		}
+		if nil != key.Rollup {
+			key.Rollup.RelationKeyID = remapKeyID(key.Rollup.RelationKeyID)
+			key.Rollup.KeyID = remapKeyID(key.Rollup.KeyID)
+			key.Rollup.AltKeyID = remapKeyID(key.Rollup.AltKeyID)
+			if nil != key.Rollup.Condition {
+				key.Rollup.Condition.Column = remapKeyID(key.Rollup.Condition.Column)
+			}
+		}
+
+		for _, value := range keyValues.Values {
+			value.ID = ast.NewNodeID()
+			value.KeyID = key.ID
+			value.BlockID = remapRowID(value.BlockID)
+			value.IsDetached = true
+			if nil != value.Block {
+				value.Block.ID = value.BlockID
+			}
+			if nil != value.Relation && av.KeyTypeRelation == key.Type && nil != key.Relation && ret.ID == key.Relation.AvID {
+				for i, blockID := range value.Relation.BlockIDs {
+					value.Relation.BlockIDs[i] = remapRowID(blockID)
+				}
+			}
+		}
+	}
+
+	ret.ViewID = ""
+	for _, view := range ret.Views {
+		oldViewID := view.ID
+		view.ID = ast.NewNodeID()
+		if attrView.ViewID == oldViewID {
+			ret.ViewID = view.ID
+		}
+		if nil == view.Table {
+			continue
+		}
+
+		view.Table.ID = ast.NewNodeID()
+		for _, col := range view.Table.Columns {
+			col.ID = remapKeyID(col.ID)
+		}
+		for i, rowID := range view.Table.RowIDs {
+			view.Table.RowIDs[i] = remapRowID(rowID)
+		}
+		for _, filter := range view.Table.Filters {
+			filter.Column = remapKeyID(filter.Column)
+		}
+		for _, s := range view.Table.Sorts {
+			s.Column = remapKeyID(s.Column)
+		}
+		for _, s := range view.Table.DefaultSorts {
+			s.Column = remapKeyID(s.Column)
+		}
+		for _, group := range view.Table.ColumnGroups {
+			group.ID = ast.NewNodeID()
+			for i, colID := range group.ColumnIDs {
+				group.ColumnIDs[i] = remapKeyID(colID)
+			}
+		}
+		if 0 < len(view.Table.RowColors) {
+			rowColors := map[string]string{}
+			for rowID, color := range view.Table.RowColors {
+				rowColors[remapRowID(rowID)] = color
+			}
+			view.Table.RowColors = rowColors
+		}
+		for i, rowID := range view.Table.TopRowIDs {
+			view.Table.TopRowIDs[i] = remapRowID(rowID)
+		}
+	}
+	if "" == ret.ViewID && 0 < len(ret.Views) {
+		ret.ViewID = ret.Views[0].ID
+	}
+	for _, preset := range ret.SortPresets {
+		preset.ID = ast.NewNodeID()
+		for _, s := range preset.Sorts {
+			s.Column = remapKeyID(s.Column)
+		}
+	}
+
+	if err = av.SaveAttributeView(ret); nil != err {
+		return
+	}
+	newAvID = ret.ID
+	return
+}
+
 func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr) {
 	var err error
 	avID := operation.AvID
@@ -1229,12 +2989,13 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 
 	for _, col := range masterView.Table.Columns {
 		view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{
-			ID:     col.ID,
-			Wrap:   col.Wrap,
-			Hidden: col.Hidden,
-			Pin:    col.Pin,
-			Width:  col.Width,
-			Calc:   col.Calc,
+			ID:      col.ID,
+			Wrap:    col.Wrap,
+			Hidden:  col.Hidden,
+			Pin:     col.Pin,
+			Width:   col.Width,
+			Calc:    col.Calc,
+			PinSide: col.PinSide,
 		})
 	}
 
@@ -1253,7 +3014,32 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 		})
 	}
 
+	for _, s := range masterView.Table.DefaultSorts {
+		view.Table.DefaultSorts = append(view.Table.DefaultSorts, &av.ViewSort{
+			Column: s.Column,
+			Order:  s.Order,
+		})
+	}
+
+	if 0 < len(masterView.Table.RowColors) {
+		view.Table.RowColors = map[string]string{}
+		for rowID, color := range masterView.Table.RowColors {
+			view.Table.RowColors[rowID] = color
+		}
+	}
+	view.Table.TopRowIDs = append([]string{}, masterView.Table.TopRowIDs...)
+
 	view.Table.PageSize = masterView.Table.PageSize
+	view.Table.CalcPosition = masterView.Table.CalcPosition
+	view.Table.ShowSummaryRow = masterView.Table.ShowSummaryRow
+	view.Table.FrozenColumnCount = masterView.Table.FrozenColumnCount
+	view.Table.RowBindingState = masterView.Table.RowBindingState
+	view.Table.GroupBy = masterView.Table.GroupBy
+	view.Table.ShowGroupPercent = masterView.Table.ShowGroupPercent
+	view.Table.VisibilityFormula = masterView.Table.VisibilityFormula
+	for _, group := range masterView.Table.ColumnGroups {
+		view.Table.ColumnGroups = append(view.Table.ColumnGroups, &av.ViewColumnGroup{ID: ast.NewNodeID(), Name: group.Name, ColumnIDs: append([]string{}, group.ColumnIDs...)})
+	}
 	view.Table.RowIDs = masterView.Table.RowIDs
 
 	if err = av.SaveAttributeView(attrView); nil != err {
@@ -1320,6 +3106,44 @@ func (tx *Transaction) doSetAttrViewViewName(operation *Operation) (ret *TxErr)
 	return
 }
 
+func (tx *Transaction) doSetAttrViewViewLayout(operation *Operation) (ret *TxErr) {
+	var err error
+	avID := operation.AvID
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return &TxErr{code: TxErrWriteAttributeView, id: avID}
+	}
+
+	viewID := operation.ID
+	view := attrView.GetView(viewID)
+	if nil == view {
+		logging.LogErrorf("get view [%s] failed: %s", viewID, err)
+		return &TxErr{code: TxErrWriteAttributeView, id: viewID}
+	}
+
+	// 切换布局时保留过滤、排序和列等共享设置，目前仅支持表格布局，后续增加看板等布局时在这里初始化对应的布局配置
+	layoutType := av.LayoutType(operation.Data.(string))
+	switch layoutType {
+	case av.LayoutTypeTable:
+		if nil == view.Table {
+			view.Table = &av.LayoutTable{ID: ast.NewNodeID(), Filters: []*av.ViewFilter{}, Sorts: []*av.ViewSort{}, PageSize: 50}
+			for _, keyValues := range attrView.KeyValues {
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: keyValues.Key.ID})
+			}
+		}
+	default:
+		return &TxErr{code: TxErrWriteAttributeView, msg: fmt.Sprintf("unsupported layout type [%s]", layoutType), id: avID}
+	}
+
+	view.LayoutType = layoutType
+	if err = av.SaveAttributeView(attrView); nil != err {
+		logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
+		return &TxErr{code: TxErrWriteAttributeView, msg: err.Error(), id: avID}
+	}
+	return
+}
+
 func (tx *Transaction) doSetAttrViewViewIcon(operation *Operation) (ret *TxErr) {
 	var err error
 	avID := operation.AvID
@@ -1445,15 +3269,15 @@ func setAttributeViewSorts(operation *Operation) (err error) {
 	return
 }
 
-func (tx *Transaction) doSetAttrViewPageSize(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewPageSize(operation)
+func (tx *Transaction) doSetAttrViewDefaultSorts(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewDefaultSorts(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewPageSize(operation *Operation) (err error) {
+func setAttributeViewDefaultSorts(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
@@ -1464,24 +3288,33 @@ func setAttributeViewPageSize(operation *Operation) (err error) {
 		return
 	}
 
+	operationData := operation.Data.([]interface{})
+	data, err := gulu.JSON.MarshalJSON(operationData)
+	if nil != err {
+		return
+	}
+
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		view.Table.PageSize = int(operation.Data.(float64))
+		if err = gulu.JSON.UnmarshalJSON(data, &view.Table.DefaultSorts); nil != err {
+			return
+		}
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSetAttrViewColCalc(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewColumnCalc(operation)
+func (tx *Transaction) doResetAttrViewSorts(operation *Operation) (ret *TxErr) {
+	err := resetAttributeViewSorts(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewColumnCalc(operation *Operation) (err error) {
+// resetAttributeViewSorts 将当前视图的排序规则重置为默认排序规则。
+func resetAttributeViewSorts(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
@@ -1492,24 +3325,11 @@ func setAttributeViewColumnCalc(operation *Operation) (err error) {
 		return
 	}
 
-	operationData := operation.Data.(interface{})
-	data, err := gulu.JSON.MarshalJSON(operationData)
-	if nil != err {
-		return
-	}
-
-	calc := &av.ColumnCalc{}
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		if err = gulu.JSON.UnmarshalJSON(data, calc); nil != err {
-			return
-		}
-
-		for _, column := range view.Table.Columns {
-			if column.ID == operation.ID {
-				column.Calc = calc
-				break
-			}
+		view.Table.Sorts = []*av.ViewSort{}
+		for _, s := range view.Table.DefaultSorts {
+			view.Table.Sorts = append(view.Table.Sorts, &av.ViewSort{Column: s.Column, Order: s.Order})
 		}
 	}
 
@@ -1517,255 +3337,99 @@ func setAttributeViewColumnCalc(operation *Operation) (err error) {
 	return
 }
 
-func (tx *Transaction) doInsertAttrViewBlock(operation *Operation) (ret *TxErr) {
-	for _, id := range operation.SrcIDs {
-		tree, err := tx.loadTree(id)
-		if nil != err && !operation.IsDetached {
-			logging.LogErrorf("load tree [%s] failed: %s", id, err)
-			return &TxErr{code: TxErrCodeBlockNotFound, id: id, msg: err.Error()}
-		}
-
-		var avErr error
-		if avErr = addAttributeViewBlock(id, operation, tree, tx); nil != avErr {
-			return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: avErr.Error()}
-		}
+func (tx *Transaction) doAddAttrViewSortPreset(operation *Operation) (ret *TxErr) {
+	err := addAttributeViewSortPreset(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func addAttributeViewBlock(blockID string, operation *Operation, tree *parse.Tree, tx *Transaction) (err error) {
-	var node *ast.Node
-	if !operation.IsDetached {
-		node = treenode.GetNodeInTree(tree, blockID)
-		if nil == node {
-			err = ErrBlockNotFound
-			return
-		}
+func addAttributeViewSortPreset(operation *Operation) (err error) {
+	// operation.ID 预设 ID
+	// operation.Name 预设名称
+	// operation.Data 排序规则
 
-		if ast.NodeAttributeView == node.Type {
-			// 不能将一个属性视图拖拽到另一个属性视图中
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	name := strings.TrimSpace(operation.Name)
+	if "" == name {
+		err = fmt.Errorf("sort preset name is empty")
+		return
+	}
+	for _, preset := range attrView.SortPresets {
+		if preset.ID == operation.ID {
+			err = fmt.Errorf("sort preset [%s] already exists", operation.ID)
 			return
 		}
-	} else {
-		if "" == blockID {
-			blockID = ast.NewNodeID()
-			logging.LogWarnf("detached block id is empty, generate a new one [%s]", blockID)
-		}
 	}
 
-	attrView, err := av.ParseAttributeView(operation.AvID)
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
 	if nil != err {
 		return
 	}
-
-	// 不允许重复添加相同的块到属性视图中
-	blockValues := attrView.GetBlockKeyValues()
-	for _, blockValue := range blockValues.Values {
-		if blockValue.Block.ID == blockID {
+	preset := &av.SortPreset{ID: operation.ID, Name: name, Sorts: []*av.ViewSort{}}
+	if err = gulu.JSON.UnmarshalJSON(data, &preset.Sorts); nil != err {
+		return
+	}
+	for _, s := range preset.Sorts {
+		if _, err = attrView.GetKey(s.Column); nil != err {
+			return
+		}
+		if av.SortOrderAsc != s.Order && av.SortOrderDesc != s.Order {
+			err = fmt.Errorf("invalid sort order [%s]", s.Order)
 			return
 		}
 	}
 
-	var content string
-	if !operation.IsDetached {
-		content = getNodeRefText(node)
-	}
-	now := time.Now().UnixMilli()
-	blockValue := &av.Value{ID: ast.NewNodeID(), KeyID: blockValues.Key.ID, BlockID: blockID, Type: av.KeyTypeBlock, IsDetached: operation.IsDetached, Block: &av.ValueBlock{ID: blockID, Content: content, Created: now, Updated: now}}
-	blockValues.Values = append(blockValues.Values, blockValue)
-
-	// 如果存在排序和过滤条件，则将排序和过滤条件应用到新添加的块上
-	view, _ := attrView.GetCurrentView()
-	if nil != view && (0 < len(view.Table.Filters) || 0 < len(view.Table.Sorts)) {
-		viewable, _ := renderAttributeViewTable(attrView, view)
-		viewable.FilterRows(attrView)
-		viewable.SortRows()
-
-		affectKeyIDs := map[string]bool{}
-		for _, f := range view.Table.Filters {
-			affectKeyIDs[f.Column] = true
-		}
-		for _, s := range view.Table.Sorts {
-			affectKeyIDs[s.Column] = true
-		}
-
-		addedValues := map[string]bool{}
-		if 0 < len(viewable.Rows) {
-			row := GetLastSortRow(viewable.Rows)
-			if nil != row {
-				for affectKeyID, _ := range affectKeyIDs {
-					for _, cell := range row.Cells {
-						if nil != cell.Value && cell.Value.KeyID == affectKeyID {
-							if av.KeyTypeBlock == cell.ValueType {
-								blockValue.Block.Content = cell.Value.Block.Content
-								continue
-							}
-
-							newValue := cell.Value.Clone()
-							newValue.ID = ast.NewNodeID()
-							newValue.BlockID = blockID
-							newValue.IsDetached = operation.IsDetached
-							values, _ := attrView.GetKeyValues(affectKeyID)
-							values.Values = append(values.Values, newValue)
-							addedValues[affectKeyID] = true
-							break
-						}
-					}
-				}
-			}
-		}
-
-		notAddedValues := map[string]bool{}
-		for affectKeyID, _ := range affectKeyIDs {
-			if !addedValues[affectKeyID] {
-				notAddedValues[affectKeyID] = true
-				break
-			}
-		}
-
-		if 0 < len(notAddedValues) {
-			for _, filter := range view.Table.Filters {
-				if !notAddedValues[filter.Column] {
-					continue
-				}
-
-				for _, keyValues := range attrView.KeyValues {
-					if keyValues.Key.ID == filter.Column {
-						newValue := filter.GetAffectValue(keyValues.Key)
-						newValue.ID = ast.NewNodeID()
-						newValue.KeyID = keyValues.Key.ID
-						newValue.BlockID = blockID
-						newValue.IsDetached = operation.IsDetached
-						keyValues.Values = append(keyValues.Values, newValue)
-						break
-					}
-				}
-			}
-
-			// 仅使用上面的过滤条件计算受影响的值并插入兜底，受影响的排序条件不进行计算值插入
-		}
-	}
-
-	if !operation.IsDetached {
-		attrs := parse.IAL2Map(node.KramdownIAL)
-
-		if "" == attrs[av.NodeAttrNameAvs] {
-			attrs[av.NodeAttrNameAvs] = operation.AvID
-		} else {
-			avIDs := strings.Split(attrs[av.NodeAttrNameAvs], ",")
-			avIDs = append(avIDs, operation.AvID)
-			avIDs = gulu.Str.RemoveDuplicatedElem(avIDs)
-			attrs[av.NodeAttrNameAvs] = strings.Join(avIDs, ",")
-		}
-
-		if err = setNodeAttrsWithTx(tx, node, tree, attrs); nil != err {
-			return
-		}
-	}
-
-	for _, view := range attrView.Views {
-		switch view.LayoutType {
-		case av.LayoutTypeTable:
-			if "" != operation.PreviousID {
-				changed := false
-				for i, id := range view.Table.RowIDs {
-					if id == operation.PreviousID {
-						view.Table.RowIDs = append(view.Table.RowIDs[:i+1], append([]string{blockID}, view.Table.RowIDs[i+1:]...)...)
-						changed = true
-						break
-					}
-				}
-				if !changed {
-					view.Table.RowIDs = append(view.Table.RowIDs, blockID)
-				}
-			} else {
-				view.Table.RowIDs = append([]string{blockID}, view.Table.RowIDs...)
-			}
-		}
-	}
-
+	attrView.SortPresets = append(attrView.SortPresets, preset)
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func GetLastSortRow(rows []*av.TableRow) *av.TableRow {
-	for i := len(rows) - 1; i >= 0; i-- {
-		row := rows[i]
-		blockVal := row.GetBlockValue()
-		if nil != blockVal {
-			if nil != blockVal.Block && blockVal.Block.Created == blockVal.Block.Updated {
-				// 说明是刚刚创建的块，跳过
-				continue
-			}
-			return row
-		}
-	}
-	return nil
-}
-
-func (tx *Transaction) doRemoveAttrViewBlock(operation *Operation) (ret *TxErr) {
-	err := tx.removeAttributeViewBlock(operation)
+func (tx *Transaction) doApplyAttrViewSortPreset(operation *Operation) (ret *TxErr) {
+	err := applyAttributeViewSortPreset(operation)
 	if nil != err {
-		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID}
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func (tx *Transaction) removeAttributeViewBlock(operation *Operation) (err error) {
+// applyAttributeViewSortPreset 将排序预设 operation.ID 中的排序规则复制到当前视图，已经被删除的列会被跳过。
+func applyAttributeViewSortPreset(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
 	}
 
-	trees := map[string]*parse.Tree{}
-	for _, keyValues := range attrView.KeyValues {
-		tmp := keyValues.Values[:0]
-		for i, values := range keyValues.Values {
-			if !gulu.Str.Contains(values.BlockID, operation.SrcIDs) {
-				tmp = append(tmp, keyValues.Values[i])
-			} else {
-				// Remove av block also remove node attr https://github.com/siyuan-note/siyuan/issues/9091#issuecomment-1709824006
-				if bt := treenode.GetBlockTree(values.BlockID); nil != bt {
-					tree := trees[bt.RootID]
-					if nil == tree {
-						tree, _ = loadTreeByBlockID(values.BlockID)
-					}
-
-					if nil != tree {
-						trees[bt.RootID] = tree
-						if node := treenode.GetNodeInTree(tree, values.BlockID); nil != node {
-							attrs := parse.IAL2Map(node.KramdownIAL)
-							if ast.NodeDocument == node.Type {
-								delete(attrs, "custom-hidden")
-								node.RemoveIALAttr("custom-hidden")
-							}
-
-							if avs := attrs[av.NodeAttrNameAvs]; "" != avs {
-								avIDs := strings.Split(avs, ",")
-								avIDs = gulu.Str.RemoveElem(avIDs, operation.AvID)
-								if 0 == len(avIDs) {
-									delete(attrs, av.NodeAttrNameAvs)
-									node.RemoveIALAttr(av.NodeAttrNameAvs)
-								} else {
-									attrs[av.NodeAttrNameAvs] = strings.Join(avIDs, ",")
-									node.SetIALAttr(av.NodeAttrNameAvs, strings.Join(avIDs, ","))
-								}
-							}
-
-							if err = setNodeAttrsWithTx(tx, node, tree, attrs); nil != err {
-								return
-							}
-						}
-					}
-				}
-			}
+	var preset *av.SortPreset
+	for _, p := range attrView.SortPresets {
+		if p.ID == operation.ID {
+			preset = p
+			break
 		}
-		keyValues.Values = tmp
+	}
+	if nil == preset {
+		err = fmt.Errorf("sort preset [%s] not found", operation.ID)
+		return
 	}
 
-	for _, view := range attrView.Views {
-		for _, blockID := range operation.SrcIDs {
-			view.Table.RowIDs = gulu.Str.RemoveElem(view.Table.RowIDs, blockID)
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		view.Table.Sorts = []*av.ViewSort{}
+		for _, s := range preset.Sorts {
+			if key, _ := attrView.GetKey(s.Column); nil == key {
+				continue
+			}
+			view.Table.Sorts = append(view.Table.Sorts, &av.ViewSort{Column: s.Column, Order: s.Order})
 		}
 	}
 
@@ -1773,15 +3437,15 @@ func (tx *Transaction) removeAttributeViewBlock(operation *Operation) (err error
 	return
 }
 
-func (tx *Transaction) doSetAttrViewColumnWidth(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewColWidth(operation)
+func (tx *Transaction) doSetAttrViewPageSize(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewPageSize(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewColWidth(operation *Operation) (err error) {
+func setAttributeViewPageSize(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
@@ -1794,27 +3458,22 @@ func setAttributeViewColWidth(operation *Operation) (err error) {
 
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		for _, column := range view.Table.Columns {
-			if column.ID == operation.ID {
-				column.Width = operation.Data.(string)
-				break
-			}
-		}
+		view.Table.PageSize = int(operation.Data.(float64))
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSetAttrViewColumnWrap(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewColWrap(operation)
+func (tx *Transaction) doSetAttrViewCalcPosition(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewCalcPosition(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewColWrap(operation *Operation) (err error) {
+func setAttributeViewCalcPosition(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
@@ -1825,29 +3484,32 @@ func setAttributeViewColWrap(operation *Operation) (err error) {
 		return
 	}
 
+	position := av.CalcPosition(operation.Data.(string))
+	switch position {
+	case av.CalcPositionTop, av.CalcPositionBottom, av.CalcPositionBoth:
+	default:
+		err = fmt.Errorf("invalid calc position [%s]", position)
+		return
+	}
+
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		for _, column := range view.Table.Columns {
-			if column.ID == operation.ID {
-				column.Wrap = operation.Data.(bool)
-				break
-			}
-		}
+		view.Table.CalcPosition = position
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSetAttrViewColumnHidden(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewColHidden(operation)
+func (tx *Transaction) doSetAttrViewShowSummaryRow(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewShowSummaryRow(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewColHidden(operation *Operation) (err error) {
+func setAttributeViewShowSummaryRow(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
@@ -1860,27 +3522,22 @@ func setAttributeViewColHidden(operation *Operation) (err error) {
 
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		for _, column := range view.Table.Columns {
-			if column.ID == operation.ID {
-				column.Hidden = operation.Data.(bool)
-				break
-			}
-		}
+		view.Table.ShowSummaryRow = operation.Data.(bool)
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSetAttrViewColumnPin(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewColPin(operation)
+func (tx *Transaction) doSetAttrViewFrozenColumns(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewFrozenColumns(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewColPin(operation *Operation) (err error) {
+func setAttributeViewFrozenColumns(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
@@ -1891,54 +3548,68 @@ func setAttributeViewColPin(operation *Operation) (err error) {
 		return
 	}
 
+	count := int(operation.Data.(float64))
+	if 0 > count {
+		err = fmt.Errorf("invalid frozen column count [%d]", count)
+		return
+	}
+
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		for _, column := range view.Table.Columns {
-			if column.ID == operation.ID {
-				column.Pin = operation.Data.(bool)
-				break
-			}
-		}
+		view.Table.FrozenColumnCount = count
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSetAttrViewColumnIcon(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewColIcon(operation)
+func (tx *Transaction) doSetAttrViewRowBindingFilter(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewRowBindingFilter(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewColIcon(operation *Operation) (err error) {
+func setAttributeViewRowBindingFilter(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
 	}
 
-	for _, keyValues := range attrView.KeyValues {
-		if keyValues.Key.ID == operation.ID {
-			keyValues.Key.Icon = operation.Data.(string)
-			break
-		}
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	state := av.RowBindingState(operation.Data.(string))
+	switch state {
+	case av.RowBindingStateAny, av.RowBindingStateBound, av.RowBindingStateDetached:
+	default:
+		err = fmt.Errorf("invalid row binding state [%s]", state)
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		view.Table.RowBindingState = state
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSortAttrViewRow(operation *Operation) (ret *TxErr) {
-	err := sortAttributeViewRow(operation)
+func (tx *Transaction) doSetAttrViewGroupBy(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewGroupBy(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func sortAttributeViewRow(operation *Operation) (err error) {
+func setAttributeViewGroupBy(operation *Operation) (err error) {
+	// operation.KeyID 分组列 ID，为空时取消分组
+
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
@@ -1949,46 +3620,30 @@ func sortAttributeViewRow(operation *Operation) (err error) {
 		return
 	}
 
-	var rowID string
-	var index, previousIndex int
-	for i, r := range view.Table.RowIDs {
-		if r == operation.ID {
-			rowID = r
-			index = i
-			break
+	if "" != operation.KeyID {
+		if _, err = attrView.GetKey(operation.KeyID); nil != err {
+			return
 		}
 	}
-	if "" == rowID {
-		rowID = operation.ID
-		view.Table.RowIDs = append(view.Table.RowIDs, rowID)
-		index = len(view.Table.RowIDs) - 1
-	}
 
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		view.Table.RowIDs = append(view.Table.RowIDs[:index], view.Table.RowIDs[index+1:]...)
-		for i, r := range view.Table.RowIDs {
-			if r == operation.PreviousID {
-				previousIndex = i + 1
-				break
-			}
-		}
-		view.Table.RowIDs = util.InsertElem(view.Table.RowIDs, previousIndex, rowID)
+		view.Table.GroupBy = operation.KeyID
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSortAttrViewColumn(operation *Operation) (ret *TxErr) {
-	err := sortAttributeViewColumn(operation)
+func (tx *Transaction) doSetAttrViewShowGroupPercent(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewShowGroupPercent(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func sortAttributeViewColumn(operation *Operation) (err error) {
+func setAttributeViewShowGroupPercent(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
@@ -2001,173 +3656,217 @@ func sortAttributeViewColumn(operation *Operation) (err error) {
 
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		var col *av.ViewTableColumn
-		var index, previousIndex int
-		for i, column := range view.Table.Columns {
-			if column.ID == operation.ID {
-				col = column
-				index = i
-				break
-			}
-		}
-		if nil == col {
-			return
-		}
-
-		view.Table.Columns = append(view.Table.Columns[:index], view.Table.Columns[index+1:]...)
-		for i, column := range view.Table.Columns {
-			if column.ID == operation.PreviousID {
-				previousIndex = i + 1
-				break
-			}
-		}
-		view.Table.Columns = util.InsertElem(view.Table.Columns, previousIndex, col)
+		view.Table.ShowGroupPercent = operation.Data.(bool)
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doAddAttrViewColumn(operation *Operation) (ret *TxErr) {
-	err := addAttributeViewColumn(operation)
+func (tx *Transaction) doSetAttrViewVisibilityFormula(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewVisibilityFormula(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func addAttributeViewColumn(operation *Operation) (err error) {
+func setAttributeViewVisibilityFormula(operation *Operation) (err error) {
+	// operation.Data 行可见性公式，为空时显示所有行
+
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
 	}
 
-	keyType := av.KeyType(operation.Typ)
-	switch keyType {
-	case av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
-		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup:
-		var icon string
-		if nil != operation.Data {
-			icon = operation.Data.(string)
-		}
-		key := av.NewKey(operation.ID, operation.Name, icon, keyType)
-		if av.KeyTypeRollup == keyType {
-			key.Rollup = &av.Rollup{Calc: &av.RollupCalc{Operator: av.CalcOperatorNone}}
-		}
-
-		attrView.KeyValues = append(attrView.KeyValues, &av.KeyValues{Key: key})
-
-		for _, view := range attrView.Views {
-			switch view.LayoutType {
-			case av.LayoutTypeTable:
-				if "" == operation.PreviousID {
-					view.Table.Columns = append([]*av.ViewTableColumn{{ID: key.ID}}, view.Table.Columns...)
-					break
-				}
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
 
-				added := false
-				for i, column := range view.Table.Columns {
-					if column.ID == operation.PreviousID {
-						view.Table.Columns = append(view.Table.Columns[:i+1], append([]*av.ViewTableColumn{{ID: key.ID}}, view.Table.Columns[i+1:]...)...)
-						added = true
-						break
-					}
-				}
-				if !added {
-					view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: key.ID})
-				}
-			}
+	expr := strings.TrimSpace(operation.Data.(string))
+	if "" != expr {
+		if _, err = av.ParseFormula(expr); nil != err {
+			err = fmt.Errorf("invalid visibility formula [%s]: %s", expr, err)
+			return
 		}
 	}
 
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		view.Table.VisibilityFormula = expr
+	}
+
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doUpdateAttrViewColTemplate(operation *Operation) (ret *TxErr) {
-	err := updateAttributeViewColTemplate(operation)
+func (tx *Transaction) doSetAttrViewColumnGroups(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColumnGroups(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func updateAttributeViewColTemplate(operation *Operation) (err error) {
+func setAttributeViewColumnGroups(operation *Operation) (err error) {
+	// operation.Data 当前视图的所有列分组，会替换原有的列分组
+
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
 	}
 
-	colType := av.KeyType(operation.Typ)
-	switch colType {
-	case av.KeyTypeTemplate:
-		for _, keyValues := range attrView.KeyValues {
-			if keyValues.Key.ID == operation.ID && av.KeyTypeTemplate == keyValues.Key.Type {
-				keyValues.Key.Template = operation.Data.(string)
-				break
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
+	if nil != err {
+		return
+	}
+	groups := []*av.ViewColumnGroup{}
+	if err = gulu.JSON.UnmarshalJSON(data, &groups); nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		viewColIDs := map[string]bool{}
+		for _, col := range view.Table.Columns {
+			viewColIDs[col.ID] = true
+		}
+
+		groupedColIDs := map[string]bool{}
+		for _, group := range groups {
+			group.Name = strings.TrimSpace(group.Name)
+			if "" == group.Name {
+				err = fmt.Errorf("column group name is empty")
+				return
+			}
+			if "" == group.ID {
+				group.ID = ast.NewNodeID()
+			}
+
+			for _, colID := range group.ColumnIDs {
+				if !viewColIDs[colID] {
+					err = fmt.Errorf("column [%s] not found in view [%s]", colID, view.ID)
+					return
+				}
+				if groupedColIDs[colID] {
+					err = fmt.Errorf("column [%s] belongs to more than one group", colID)
+					return
+				}
+				groupedColIDs[colID] = true
 			}
 		}
+
+		view.Table.ColumnGroups = groups
+		normalizeAttributeViewColumnGroups(view.Table)
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doUpdateAttrViewColNumberFormat(operation *Operation) (ret *TxErr) {
-	err := updateAttributeViewColNumberFormat(operation)
+// normalizeAttributeViewColumnGroups 按表格中列的顺序重新排列列分组中的列 ID，并移除已经不在表格中的列，在调整或者删除列后调用以保持分组和列一致。
+func normalizeAttributeViewColumnGroups(table *av.LayoutTable) {
+	if 1 > len(table.ColumnGroups) {
+		return
+	}
+
+	colIndexes := map[string]int{}
+	for i, col := range table.Columns {
+		colIndexes[col.ID] = i
+	}
+	for _, group := range table.ColumnGroups {
+		var colIDs []string
+		for _, colID := range group.ColumnIDs {
+			if _, ok := colIndexes[colID]; ok {
+				colIDs = append(colIDs, colID)
+			}
+		}
+		sort.SliceStable(colIDs, func(i, j int) bool { return colIndexes[colIDs[i]] < colIndexes[colIDs[j]] })
+		group.ColumnIDs = colIDs
+	}
+}
+
+// renderAttributeViewColumnGroups 根据渲染后的列生成列分组，只包含渲染出来的列，没有列的分组会被忽略。
+func renderAttributeViewColumnGroups(groups []*av.ViewColumnGroup, columns []*av.TableColumn) (ret []*av.ViewColumnGroup) {
+	ret = []*av.ViewColumnGroup{}
+	for _, group := range groups {
+		rendered := &av.ViewColumnGroup{ID: group.ID, Name: group.Name, ColumnIDs: []string{}}
+		for _, col := range columns {
+			if gulu.Str.Contains(col.ID, group.ColumnIDs) {
+				rendered.ColumnIDs = append(rendered.ColumnIDs, col.ID)
+			}
+		}
+		if 0 < len(rendered.ColumnIDs) {
+			ret = append(ret, rendered)
+		}
+	}
+	return
+}
+
+func (tx *Transaction) doClearAttrViewRowOrder(operation *Operation) (ret *TxErr) {
+	err := clearAttributeViewRowOrder(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func updateAttributeViewColNumberFormat(operation *Operation) (err error) {
+// clearAttributeViewRowOrder 清空当前视图拖拽产生的自定义行顺序，行会按照排序规则或者默认顺序显示，不会删除任何数据。
+func clearAttributeViewRowOrder(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
 	}
 
-	colType := av.KeyType(operation.Typ)
-	switch colType {
-	case av.KeyTypeNumber:
-		for _, keyValues := range attrView.KeyValues {
-			if keyValues.Key.ID == operation.ID && av.KeyTypeNumber == keyValues.Key.Type {
-				keyValues.Key.NumberFormat = av.NumberFormat(operation.Format)
-				break
-			}
-		}
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		view.Table.RowIDs = []string{}
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doUpdateAttrViewColumn(operation *Operation) (ret *TxErr) {
-	err := updateAttributeViewColumn(operation)
+func (tx *Transaction) doSetAttrViewRowColor(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewRowColor(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func updateAttributeViewColumn(operation *Operation) (err error) {
+func setAttributeViewRowColor(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
 	}
 
-	colType := av.KeyType(operation.Typ)
-	switch colType {
-	case av.KeyTypeBlock, av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
-		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup:
-		for _, keyValues := range attrView.KeyValues {
-			if keyValues.Key.ID == operation.ID {
-				keyValues.Key.Name = strings.TrimSpace(operation.Name)
-				keyValues.Key.Type = colType
-				break
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	color, _ := operation.Data.(string)
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		if "" == color {
+			delete(view.Table.RowColors, operation.ID)
+		} else {
+			if nil == view.Table.RowColors {
+				view.Table.RowColors = map[string]string{}
 			}
+			view.Table.RowColors[operation.ID] = color
 		}
 	}
 
@@ -2175,88 +3874,32 @@ func updateAttributeViewColumn(operation *Operation) (err error) {
 	return
 }
 
-func (tx *Transaction) doRemoveAttrViewColumn(operation *Operation) (ret *TxErr) {
-	err := removeAttributeViewColumn(operation)
+func (tx *Transaction) doSetAttrViewRowTop(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewRowTop(operation)
 	if nil != err {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func removeAttributeViewColumn(operation *Operation) (err error) {
+// setAttributeViewRowTop 置顶或者取消置顶当前视图中的行，operation.Data 为 true 时置顶。
+func setAttributeViewRowTop(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
 	}
 
-	var removedKey *av.Key
-	for i, keyValues := range attrView.KeyValues {
-		if keyValues.Key.ID == operation.ID {
-			attrView.KeyValues = append(attrView.KeyValues[:i], attrView.KeyValues[i+1:]...)
-			removedKey = keyValues.Key
-			break
-		}
-	}
-
-	if nil != removedKey && av.KeyTypeRelation == removedKey.Type && nil != removedKey.Relation {
-		if removedKey.Relation.IsTwoWay {
-			// 删除双向关联的目标列
-
-			destAv, _ := av.ParseAttributeView(removedKey.Relation.AvID)
-			if nil != destAv {
-				destAvRelSrcAv := false
-				for i, keyValues := range destAv.KeyValues {
-					if keyValues.Key.ID == removedKey.Relation.BackKeyID {
-						destAv.KeyValues = append(destAv.KeyValues[:i], destAv.KeyValues[i+1:]...)
-						continue
-					}
-
-					if av.KeyTypeRelation == keyValues.Key.Type && keyValues.Key.Relation.AvID == attrView.ID {
-						destAvRelSrcAv = true
-					}
-				}
-
-				for _, view := range destAv.Views {
-					switch view.LayoutType {
-					case av.LayoutTypeTable:
-						for i, column := range view.Table.Columns {
-							if column.ID == removedKey.Relation.BackKeyID {
-								view.Table.Columns = append(view.Table.Columns[:i], view.Table.Columns[i+1:]...)
-								break
-							}
-						}
-					}
-				}
-
-				av.SaveAttributeView(destAv)
-				util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": destAv.ID})
-
-				if !destAvRelSrcAv {
-					av.RemoveAvRel(destAv.ID, attrView.ID)
-				}
-			}
-
-			srcAvRelDestAv := false
-			for _, keyValues := range attrView.KeyValues {
-				if av.KeyTypeRelation == keyValues.Key.Type && nil != keyValues.Key.Relation && keyValues.Key.Relation.AvID == removedKey.Relation.AvID {
-					srcAvRelDestAv = true
-				}
-			}
-			if !srcAvRelDestAv {
-				av.RemoveAvRel(attrView.ID, removedKey.Relation.AvID)
-			}
-		}
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
 	}
 
-	for _, view := range attrView.Views {
-		switch view.LayoutType {
-		case av.LayoutTypeTable:
-			for i, column := range view.Table.Columns {
-				if column.ID == operation.ID {
-					view.Table.Columns = append(view.Table.Columns[:i], view.Table.Columns[i+1:]...)
-					break
-				}
-			}
+	top, _ := operation.Data.(bool)
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		view.Table.TopRowIDs = gulu.Str.RemoveElem(view.Table.TopRowIDs, operation.ID)
+		if top {
+			view.Table.TopRowIDs = append(view.Table.TopRowIDs, operation.ID)
 		}
 	}
 
@@ -2264,483 +3907,4188 @@ func removeAttributeViewColumn(operation *Operation) (err error) {
 	return
 }
 
-func (tx *Transaction) doReplaceAttrViewBlock(operation *Operation) (ret *TxErr) {
-	err := replaceAttributeViewBlock(operation, tx)
+func (tx *Transaction) doSetAttrViewColCalc(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColumnCalc(operation)
 	if nil != err {
-		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID}
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func replaceAttributeViewBlock(operation *Operation, tx *Transaction) (err error) {
+func setAttributeViewColumnCalc(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if nil != err {
 		return
 	}
 
-	var node *ast.Node
-	if !operation.IsDetached {
-		node, _, _ = getNodeByBlockID(tx, operation.NextID)
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
 	}
 
-	for _, keyValues := range attrView.KeyValues {
-		for _, value := range keyValues.Values {
-			if value.BlockID == operation.PreviousID {
-				if value.BlockID != operation.NextID {
-					// 换绑
-					unbindBlockAv(tx, operation.AvID, value.BlockID)
-				}
-
-				value.BlockID = operation.NextID
-				if nil != value.Block {
-					value.Block.ID = operation.NextID
-					value.IsDetached = operation.IsDetached
-					if !operation.IsDetached {
-						value.Block.Content = getNodeRefText(node)
-					}
-				}
+	operationData := operation.Data.(interface{})
+	data, err := gulu.JSON.MarshalJSON(operationData)
+	if nil != err {
+		return
+	}
 
-				if !operation.IsDetached {
+	calc := &av.ColumnCalc{}
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		if err = gulu.JSON.UnmarshalJSON(data, calc); nil != err {
+			return
+		}
+
+		for _, column := range view.Table.Columns {
+			if column.ID == operation.ID {
+				column.Calc = calc
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doInsertAttrViewBlock(operation *Operation) (ret *TxErr) {
+	for _, id := range operation.SrcIDs {
+		tree, err := tx.loadTree(id)
+		if nil != err && !operation.IsDetached {
+			logging.LogErrorf("load tree [%s] failed: %s", id, err)
+			return &TxErr{code: TxErrCodeBlockNotFound, id: id, msg: err.Error()}
+		}
+
+		var avErr error
+		if avErr = addAttributeViewBlock(id, operation, tree, tx); nil != avErr {
+			return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: avErr.Error()}
+		}
+	}
+	return
+}
+
+func addAttributeViewBlock(blockID string, operation *Operation, tree *parse.Tree, tx *Transaction) (err error) {
+	var node *ast.Node
+	if !operation.IsDetached {
+		node = treenode.GetNodeInTree(tree, blockID)
+		if nil == node {
+			err = ErrBlockNotFound
+			return
+		}
+
+		if ast.NodeAttributeView == node.Type {
+			// 不能将一个属性视图拖拽到另一个属性视图中
+			return
+		}
+	} else {
+		if "" == blockID {
+			blockID = ast.NewNodeID()
+			logging.LogWarnf("detached block id is empty, generate a new one [%s]", blockID)
+		}
+	}
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	// 不允许重复添加相同的块到属性视图中
+	blockValues := attrView.GetBlockKeyValues()
+	for _, blockValue := range blockValues.Values {
+		if blockValue.Block.ID == blockID {
+			return
+		}
+	}
+
+	var content string
+	if !operation.IsDetached {
+		content = getNodeRefText(node)
+	}
+	now := time.Now().UnixMilli()
+	author := getAttributeViewCurrentAuthor()
+	blockValue := &av.Value{ID: ast.NewNodeID(), KeyID: blockValues.Key.ID, BlockID: blockID, Type: av.KeyTypeBlock, IsDetached: operation.IsDetached, Block: &av.ValueBlock{ID: blockID, Content: content, Created: now, Updated: now, CreatedBy: author, UpdatedBy: author}}
+	blockValues.Values = append(blockValues.Values, blockValue)
+
+	// 如果存在排序和过滤条件，则将排序和过滤条件应用到新添加的块上
+	view, _ := attrView.GetCurrentView()
+	if nil != view && (0 < len(view.Table.Filters) || 0 < len(view.Table.Sorts)) {
+		viewable, _ := renderAttributeViewTable(attrView, view, nil)
+		viewable.FilterRows(attrView)
+		viewable.FilterRowsByBindingState()
+		viewable.SortRows()
+
+		affectKeyIDs := map[string]bool{}
+		for _, f := range view.Table.Filters {
+			affectKeyIDs[f.Column] = true
+		}
+		for _, s := range view.Table.Sorts {
+			affectKeyIDs[s.Column] = true
+		}
+
+		addedValues := map[string]bool{}
+		if 0 < len(viewable.Rows) {
+			row := GetLastSortRow(viewable.Rows)
+			if nil != row {
+				for affectKeyID, _ := range affectKeyIDs {
+					for _, cell := range row.Cells {
+						if nil != cell.Value && cell.Value.KeyID == affectKeyID {
+							if av.KeyTypeBlock == cell.ValueType {
+								blockValue.Block.Content = cell.Value.Block.Content
+								continue
+							}
+
+							newValue := cell.Value.Clone()
+							newValue.ID = ast.NewNodeID()
+							newValue.BlockID = blockID
+							newValue.IsDetached = operation.IsDetached
+							values, _ := attrView.GetKeyValues(affectKeyID)
+							values.Values = append(values.Values, newValue)
+							addedValues[affectKeyID] = true
+							break
+						}
+					}
+				}
+			}
+		}
+
+		notAddedValues := map[string]bool{}
+		for affectKeyID, _ := range affectKeyIDs {
+			if !addedValues[affectKeyID] {
+				notAddedValues[affectKeyID] = true
+				break
+			}
+		}
+
+		if 0 < len(notAddedValues) {
+			for _, filter := range view.Table.Filters {
+				if !notAddedValues[filter.Column] || nil == filter.Value {
+					continue
+				}
+
+				for _, keyValues := range attrView.KeyValues {
+					if keyValues.Key.ID == filter.Column {
+						newValue := filter.GetAffectValue(keyValues.Key)
+						newValue.ID = ast.NewNodeID()
+						newValue.KeyID = keyValues.Key.ID
+						newValue.BlockID = blockID
+						newValue.IsDetached = operation.IsDetached
+						keyValues.Values = append(keyValues.Values, newValue)
+						break
+					}
+				}
+			}
+
+			// 仅使用上面的过滤条件计算受影响的值并插入兜底，受影响的排序条件不进行计算值插入
+		}
+	}
+
+	if operation.IsDetached && "" != attrView.PrimaryTemplate {
+		// 绑定块的行使用块内容，只有游离行才根据主键模板生成内容
+		if content := renderAttributeViewPrimaryTemplate(attrView, blockID); "" != content {
+			blockValue.Block.Content = content
+		}
+	}
+
+	if !operation.IsDetached {
+		attrs := parse.IAL2Map(node.KramdownIAL)
+
+		if "" == attrs[av.NodeAttrNameAvs] {
+			attrs[av.NodeAttrNameAvs] = operation.AvID
+		} else {
+			avIDs := strings.Split(attrs[av.NodeAttrNameAvs], ",")
+			avIDs = append(avIDs, operation.AvID)
+			avIDs = gulu.Str.RemoveDuplicatedElem(avIDs)
+			attrs[av.NodeAttrNameAvs] = strings.Join(avIDs, ",")
+		}
+
+		if err = setNodeAttrsWithTx(tx, node, tree, attrs); nil != err {
+			return
+		}
+	}
+
+	for _, view := range attrView.Views {
+		switch view.LayoutType {
+		case av.LayoutTypeTable:
+			if "" != operation.PreviousID {
+				changed := false
+				for i, id := range view.Table.RowIDs {
+					if id == operation.PreviousID {
+						view.Table.RowIDs = append(view.Table.RowIDs[:i+1], append([]string{blockID}, view.Table.RowIDs[i+1:]...)...)
+						changed = true
+						break
+					}
+				}
+				if !changed {
+					view.Table.RowIDs = append(view.Table.RowIDs, blockID)
+				}
+			} else {
+				view.Table.RowIDs = append([]string{blockID}, view.Table.RowIDs...)
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func GetLastSortRow(rows []*av.TableRow) *av.TableRow {
+	for i := len(rows) - 1; i >= 0; i-- {
+		row := rows[i]
+		blockVal := row.GetBlockValue()
+		if nil != blockVal {
+			if nil != blockVal.Block && blockVal.Block.Created == blockVal.Block.Updated {
+				// 说明是刚刚创建的块，跳过
+				continue
+			}
+			return row
+		}
+	}
+	return nil
+}
+
+func (tx *Transaction) doRemoveAttrViewBlock(operation *Operation) (ret *TxErr) {
+	err := removeAttributeViewBlock(tx, operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID}
+	}
+	return
+}
+
+// removeAttributeViewBlock 从属性视图中移除 operation.SrcIDs 对应的行，并清理块上的属性视图属性。
+// tx 为空时（非事务调用，比如维护操作）直接写入块属性。
+func removeAttributeViewBlock(tx *Transaction, operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	trees := map[string]*parse.Tree{}
+	for _, keyValues := range attrView.KeyValues {
+		tmp := keyValues.Values[:0]
+		for i, values := range keyValues.Values {
+			if !gulu.Str.Contains(values.BlockID, operation.SrcIDs) {
+				tmp = append(tmp, keyValues.Values[i])
+			} else {
+				// Remove av block also remove node attr https://github.com/siyuan-note/siyuan/issues/9091#issuecomment-1709824006
+				if bt := treenode.GetBlockTree(values.BlockID); nil != bt {
+					tree := trees[bt.RootID]
+					if nil == tree {
+						tree, _ = loadTreeByBlockID(values.BlockID)
+					}
+
+					if nil != tree {
+						trees[bt.RootID] = tree
+						if node := treenode.GetNodeInTree(tree, values.BlockID); nil != node {
+							attrs := parse.IAL2Map(node.KramdownIAL)
+							if ast.NodeDocument == node.Type {
+								delete(attrs, "custom-hidden")
+								node.RemoveIALAttr("custom-hidden")
+							}
+
+							if avs := attrs[av.NodeAttrNameAvs]; "" != avs {
+								avIDs := strings.Split(avs, ",")
+								avIDs = gulu.Str.RemoveElem(avIDs, operation.AvID)
+								if 0 == len(avIDs) {
+									delete(attrs, av.NodeAttrNameAvs)
+									node.RemoveIALAttr(av.NodeAttrNameAvs)
+								} else {
+									attrs[av.NodeAttrNameAvs] = strings.Join(avIDs, ",")
+									node.SetIALAttr(av.NodeAttrNameAvs, strings.Join(avIDs, ","))
+								}
+							}
+
+							if nil != tx {
+								err = setNodeAttrsWithTx(tx, node, tree, attrs)
+							} else {
+								err = setNodeAttrs(node, tree, attrs)
+							}
+							if nil != err {
+								return
+							}
+						}
+					}
+				}
+			}
+		}
+		keyValues.Values = tmp
+	}
+
+	for _, view := range attrView.Views {
+		for _, blockID := range operation.SrcIDs {
+			view.Table.RowIDs = gulu.Str.RemoveElem(view.Table.RowIDs, blockID)
+			delete(view.Table.RowColors, blockID)
+			view.Table.TopRowIDs = gulu.Str.RemoveElem(view.Table.TopRowIDs, blockID)
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColumnWidth(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColWidth(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColWidth(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		for _, column := range view.Table.Columns {
+			if column.ID == operation.ID {
+				column.Width = operation.Data.(string)
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColumnWidthAllViews(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColWidthAllViews(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// setAttributeViewColWidthAllViews 设置所有包含该列的视图中的列宽，不包含该列的视图跳过。
+func setAttributeViewColWidthAllViews(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	for _, view := range attrView.Views {
+		switch view.LayoutType {
+		case av.LayoutTypeTable:
+			for _, column := range view.Table.Columns {
+				if column.ID == operation.ID {
+					column.Width = operation.Data.(string)
+					break
+				}
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColumnWrap(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColWrap(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColWrap(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		for _, column := range view.Table.Columns {
+			if column.ID == operation.ID {
+				column.Wrap = operation.Data.(bool)
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColumnHidden(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColHidden(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColHidden(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		for _, column := range view.Table.Columns {
+			if column.ID == operation.ID {
+				column.Hidden = operation.Data.(bool)
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColumnPin(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColPin(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColPin(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		for _, column := range view.Table.Columns {
+			if column.ID == operation.ID {
+				column.Pin = operation.Data.(bool)
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColPinSide(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColPinSide(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColPinSide(operation *Operation) (err error) {
+	// operation.ID 列 ID
+	// operation.Data 固定位置，设置后列会被固定
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	side := av.PinSide(operation.Data.(string))
+	switch side {
+	case av.PinSideLeft, av.PinSideRight:
+	default:
+		err = fmt.Errorf("invalid pin side [%s]", side)
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		for _, column := range view.Table.Columns {
+			if column.ID == operation.ID {
+				column.Pin = true
+				column.PinSide = side
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColumnIcon(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColIcon(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColIcon(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if keyValues.Key.ID == operation.ID {
+			keyValues.Key.Icon = operation.Data.(string)
+			break
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSortAttrViewRow(operation *Operation) (ret *TxErr) {
+	err := sortAttributeViewRow(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func sortAttributeViewRow(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	var rowID string
+	var index, previousIndex int
+	for i, r := range view.Table.RowIDs {
+		if r == operation.ID {
+			rowID = r
+			index = i
+			break
+		}
+	}
+	if "" == rowID {
+		rowID = operation.ID
+		view.Table.RowIDs = append(view.Table.RowIDs, rowID)
+		index = len(view.Table.RowIDs) - 1
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		view.Table.RowIDs = append(view.Table.RowIDs[:index], view.Table.RowIDs[index+1:]...)
+		for i, r := range view.Table.RowIDs {
+			if r == operation.PreviousID {
+				previousIndex = i + 1
+				break
+			}
+		}
+		view.Table.RowIDs = util.InsertElem(view.Table.RowIDs, previousIndex, rowID)
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSortAttrViewColumn(operation *Operation) (ret *TxErr) {
+	err := sortAttributeViewColumn(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func sortAttributeViewColumn(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	view, err := attrView.GetCurrentView()
+	if nil != err {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		var col *av.ViewTableColumn
+		var index, previousIndex int
+		for i, column := range view.Table.Columns {
+			if column.ID == operation.ID {
+				col = column
+				index = i
+				break
+			}
+		}
+		if nil == col {
+			return
+		}
+
+		view.Table.Columns = append(view.Table.Columns[:index], view.Table.Columns[index+1:]...)
+		for i, column := range view.Table.Columns {
+			if column.ID == operation.PreviousID {
+				previousIndex = i + 1
+				break
+			}
+		}
+		view.Table.Columns = util.InsertElem(view.Table.Columns, previousIndex, col)
+		normalizeAttributeViewColumnGroups(view.Table)
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doAddAttrViewColumn(operation *Operation) (ret *TxErr) {
+	err := addAttributeViewColumn(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func addAttributeViewColumn(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	keyType := av.KeyType(operation.Typ)
+	switch keyType.BaseType() {
+	case av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
+		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
+		av.KeyTypeRelation, av.KeyTypeRollup:
+		if err = checkAttributeViewKeyTypeAllowed(keyType); nil != err {
+			return
+		}
+
+		var icon string
+		if nil != operation.Data {
+			icon = operation.Data.(string)
+		}
+		key := av.NewKey(operation.ID, operation.Name, icon, keyType)
+		if av.KeyTypeRollup == keyType {
+			key.Rollup = &av.Rollup{Calc: &av.RollupCalc{Operator: av.CalcOperatorNone}}
+		}
+
+		insertAttributeViewKey(attrView, key, operation.PreviousID)
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// checkAttributeViewKeyTypeAllowed 检查配置是否允许添加 keyType 类型的列，没有配置允许的列类型时允许所有类型，主键列总是允许。
+func checkAttributeViewKeyTypeAllowed(keyType av.KeyType) error {
+	if av.KeyTypeBlock == keyType || nil == Conf || nil == Conf.Editor || 1 > len(Conf.Editor.AllowedAttrViewKeyTypes) {
+		return nil
+	}
+
+	if !gulu.Str.Contains(string(keyType), Conf.Editor.AllowedAttrViewKeyTypes) {
+		return fmt.Errorf("key type [%s] is not allowed", keyType)
+	}
+	return nil
+}
+
+// insertAttributeViewKey 添加列 key，并在所有视图中插入到列 previousID 之后，previousID 为空时插入到最前面。
+func insertAttributeViewKey(attrView *av.AttributeView, key *av.Key, previousID string) {
+	attrView.KeyValues = append(attrView.KeyValues, &av.KeyValues{Key: key})
+
+	for _, view := range attrView.Views {
+		switch view.LayoutType {
+		case av.LayoutTypeTable:
+			if "" == previousID {
+				view.Table.Columns = append([]*av.ViewTableColumn{{ID: key.ID}}, view.Table.Columns...)
+				break
+			}
+
+			added := false
+			for i, column := range view.Table.Columns {
+				if column.ID == previousID {
+					view.Table.Columns = append(view.Table.Columns[:i+1], append([]*av.ViewTableColumn{{ID: key.ID}}, view.Table.Columns[i+1:]...)...)
+					added = true
+					break
+				}
+			}
+			if !added {
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: key.ID})
+			}
+		}
+	}
+}
+
+func (tx *Transaction) doAddAttrViewColumnWithConfig(operation *Operation) (ret *TxErr) {
+	err := addAttributeViewColumnWithConfig(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func addAttributeViewColumnWithConfig(operation *Operation) (err error) {
+	// operation.ID 列 ID
+	// operation.PreviousID 插入到该列之后，为空时插入到最前面
+	// operation.Data 完整的列定义，比如选项、数字格式、关联和汇总配置，和 av.Key 的 JSON 结构一致
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
+	if nil != err {
+		return
+	}
+	key := &av.Key{}
+	if err = gulu.JSON.UnmarshalJSON(data, key); nil != err {
+		return
+	}
+	key.ID = operation.ID
+	key.Name = strings.TrimSpace(key.Name)
+
+	switch key.Type.BaseType() {
+	case av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
+		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
+		av.KeyTypeRelation, av.KeyTypeRollup:
+	default:
+		err = fmt.Errorf("invalid key type [%s]", key.Type)
+		return
+	}
+	if err = checkAttributeViewKeyTypeAllowed(key.Type); nil != err {
+		return
+	}
+	if _, getErr := attrView.GetKey(key.ID); nil == getErr {
+		err = fmt.Errorf("key [%s] already exists", key.ID)
+		return
+	}
+
+	// 只保留当前列类型相关的配置，避免写入无效状态
+	if av.KeyTypeSelect != key.Type && av.KeyTypeMSelect != key.Type {
+		key.Options = nil
+	} else {
+		var options []*av.SelectOption
+		names := map[string]bool{}
+		for _, opt := range key.Options {
+			if nil == opt || "" == strings.TrimSpace(opt.Name) || names[strings.TrimSpace(opt.Name)] {
+				continue
+			}
+			opt.Name = strings.TrimSpace(opt.Name)
+			names[opt.Name] = true
+			options = append(options, opt)
+		}
+		key.Options = options
+	}
+	if av.KeyTypeNumber != key.Type {
+		key.NumberFormat = av.NumberFormatNone
+	}
+	if av.KeyTypeTemplate != key.Type {
+		key.Template = ""
+	}
+	if av.KeyTypeBlockAttr != key.Type {
+		key.AttrName = ""
+	}
+	if av.KeyTypeFormula != key.Type {
+		key.Formula = ""
+	}
+
+	if "" != key.Alias {
+		if err = checkAttributeViewColAlias(attrView, key.Alias); nil != err {
+			return
+		}
+	}
+
+	if "" != key.SourceKeyID {
+		sourceKey, getErr := attrView.GetKey(key.SourceKeyID)
+		if nil != getErr {
+			err = getErr
+			return
+		}
+		if err = checkAttributeViewColSourceKey(key, sourceKey); nil != err {
+			return
+		}
+	}
+
+	if av.KeyTypeRollup != key.Type {
+		key.Rollup = nil
+	} else {
+		if nil == key.Rollup {
+			key.Rollup = &av.Rollup{}
+		}
+		if nil == key.Rollup.Calc {
+			key.Rollup.Calc = &av.RollupCalc{Operator: av.CalcOperatorNone}
+		}
+		if "" != key.Rollup.RelationKeyID {
+			relKey, getErr := attrView.GetKey(key.Rollup.RelationKeyID)
+			if nil != getErr {
+				err = getErr
+				return
+			}
+			if av.KeyTypeRelation != relKey.Type {
+				err = fmt.Errorf("key [%s] is not a relation key", relKey.ID)
+				return
+			}
+		}
+	}
+
+	var destAv *av.AttributeView
+	if av.KeyTypeRelation != key.Type {
+		key.Relation = nil
+	} else if nil != key.Relation {
+		if key.Relation.AvID == attrView.ID {
+			destAv = attrView
+		} else if destAv, err = av.ParseAttributeView(key.Relation.AvID); nil != err {
+			return
+		}
+
+		if key.Relation.IsTwoWay {
+			// 双向关联时在目标属性视图中创建回链关联列
+			if "" == key.Relation.BackKeyID {
+				key.Relation.BackKeyID = ast.NewNodeID()
+			}
+			if _, getErr := destAv.GetKey(key.Relation.BackKeyID); nil == getErr {
+				err = fmt.Errorf("back key [%s] already exists", key.Relation.BackKeyID)
+				return
+			}
+
+			backKey := &av.Key{
+				ID:       key.Relation.BackKeyID,
+				Name:     strings.TrimSpace(attrView.Name + " " + key.Name),
+				Type:     av.KeyTypeRelation,
+				Relation: &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: key.ID},
+			}
+			destAv.KeyValues = append(destAv.KeyValues, &av.KeyValues{Key: backKey})
+			for _, v := range destAv.Views {
+				switch v.LayoutType {
+				case av.LayoutTypeTable:
+					v.Table.Columns = append(v.Table.Columns, &av.ViewTableColumn{ID: backKey.ID})
+				}
+			}
+		} else {
+			key.Relation.BackKeyID = ""
+		}
+	}
+
+	insertAttributeViewKey(attrView, key, operation.PreviousID)
+	switch key.Type {
+	case av.KeyTypeFormula:
+		if err = checkAttributeViewFormula(attrView, key); nil != err {
+			return
+		}
+	case av.KeyTypeRollup:
+		if err = checkAttributeViewFormulaCycle(attrView, key.ID); nil != err {
+			return
+		}
+	}
+	if err = av.SaveAttributeView(attrView); nil != err {
+		return
+	}
+
+	if nil != destAv {
+		if destAv != attrView && key.Relation.IsTwoWay {
+			if err = av.SaveAttributeView(destAv); nil != err {
+				return
+			}
+			util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": destAv.ID})
+		}
+		av.UpsertAvBackRel(attrView.ID, destAv.ID)
+	}
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColTemplate(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColTemplate(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColTemplate(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	colType := av.KeyType(operation.Typ)
+	switch colType {
+	case av.KeyTypeTemplate:
+		for _, keyValues := range attrView.KeyValues {
+			if keyValues.Key.ID == operation.ID && av.KeyTypeTemplate == keyValues.Key.Type {
+				keyValues.Key.Template = operation.Data.(string)
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColSourceKey(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColSourceKey(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColSourceKey(operation *Operation) (err error) {
+	// operation.ID 计算列 ID
+	// operation.KeyID 来源列 ID
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+
+	sourceKey, err := attrView.GetKey(operation.KeyID)
+	if nil != err {
+		return
+	}
+
+	if err = checkAttributeViewColSourceKey(key, sourceKey); nil != err {
+		return
+	}
+
+	key.SourceKeyID = sourceKey.ID
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColCountMode(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColCountMode(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColCountMode(operation *Operation) (err error) {
+	// operation.ID 文本长度列 ID
+	// operation.Data 计数方式
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeTextLength != key.Type {
+		err = fmt.Errorf("key [%s] is not a text length key", operation.ID)
+		return
+	}
+
+	mode := av.TextLengthCountMode(operation.Data.(string))
+	switch mode {
+	case av.TextLengthCountModeChars, av.TextLengthCountModeWords:
+	default:
+		err = fmt.Errorf("invalid count mode [%s]", mode)
+		return
+	}
+
+	key.CountMode = mode
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColFormula(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColFormula(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColFormula(operation *Operation) (err error) {
+	// operation.ID 公式列 ID
+	// operation.Data 公式表达式
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeFormula != key.Type {
+		err = fmt.Errorf("key [%s] is not a formula key", operation.ID)
+		return
+	}
+
+	key.Formula = strings.TrimSpace(operation.Data.(string))
+	if err = checkAttributeViewFormula(attrView, key); nil != err {
+		return
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// checkAttributeViewFormula 检查公式列 key 的公式是否可以解析、引用的列是否存在以及是否存在循环引用。
+func checkAttributeViewFormula(attrView *av.AttributeView, key *av.Key) error {
+	if "" == key.Formula {
+		return nil
+	}
+
+	formula, err := av.ParseFormula(key.Formula)
+	if nil != err {
+		return fmt.Errorf("invalid formula [%s]: %s", key.Formula, err)
+	}
+
+	var keys []*av.Key
+	for _, kv := range attrView.KeyValues {
+		keys = append(keys, kv.Key)
+	}
+	for _, ref := range formula.Refs() {
+		if nil == av.GetFormulaRefKey(keys, ref) {
+			return fmt.Errorf("key [%s] referenced by formula not found", ref)
+		}
+	}
+	return checkAttributeViewFormulaCycle(attrView, key.ID)
+}
+
+// mergeConflictedAttributeView 将同步冲突时保留的本地版本 conflictPath 合并到同步下来的属性视图 avID 中。
+func mergeConflictedAttributeView(avID, conflictPath string) (err error) {
+	data, err := os.ReadFile(conflictPath)
+	if nil != err {
+		return
+	}
+
+	local := &av.AttributeView{}
+	if err = gulu.JSON.UnmarshalJSON(data, local); nil != err {
+		return
+	}
+
+	remote, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	merged := av.MergeAttributeViews(local, remote)
+	if err = av.SaveAttributeView(merged); nil != err {
+		return
+	}
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+	return
+}
+
+// getAttributeViewRowCreated 返回行的创建时间，优先从块 ID 中解析，游离行使用保存的块创建时间。
+func getAttributeViewRowCreated(attrView *av.AttributeView, rowID string) (ret time.Time) {
+	if blockKeyValues := attrView.GetBlockKeyValues(); nil != blockKeyValues {
+		if blockVal := blockKeyValues.GetValue(rowID); nil != blockVal && blockVal.IsDetached && nil != blockVal.Block && 0 < blockVal.Block.Created {
+			return time.UnixMilli(blockVal.Block.Created)
+		}
+	}
+
+	ret = time.Now()
+	if len("20060102150405") > len(rowID) {
+		return
+	}
+	if created, parseErr := time.ParseInLocation("20060102150405", rowID[:len("20060102150405")], time.Local); nil == parseErr {
+		ret = created
+	}
+	return
+}
+
+// isAttributeViewTextLengthSource 判断 key 是否是文本长度列 lengthKey 的来源列，没有设置来源列时使用主键。
+func isAttributeViewTextLengthSource(lengthKey, key *av.Key) bool {
+	if "" == lengthKey.SourceKeyID {
+		return av.KeyTypeBlock == key.Type
+	}
+	return key.ID == lengthKey.SourceKeyID
+}
+
+// checkAttributeViewColSourceKey 检查计算列 key 是否可以使用 sourceKey 作为来源列。
+func checkAttributeViewColSourceKey(key, sourceKey *av.Key) (err error) {
+	switch key.Type {
+	case av.KeyTypeRunningTotal, av.KeyTypePercentOfColumn, av.KeyTypeRowDelta:
+		if av.KeyTypeNumber != sourceKey.Type {
+			err = fmt.Errorf("invalid source key type [%s]", sourceKey.Type)
+		}
+	case av.KeyTypeDateDelta:
+		if av.KeyTypeDate != sourceKey.Type {
+			err = fmt.Errorf("invalid source key type [%s]", sourceKey.Type)
+		}
+	case av.KeyTypeTextLength:
+		switch sourceKey.Type {
+		case av.KeyTypeBlock, av.KeyTypeText, av.KeyTypeTemplate:
+		default:
+			err = fmt.Errorf("invalid source key type [%s]", sourceKey.Type)
+		}
+	default:
+		err = fmt.Errorf("key type [%s] does not support source key", key.Type)
+	}
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColAlias(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColAlias(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColAlias(operation *Operation) (err error) {
+	// operation.ID 列 ID
+	// operation.Data 列别名
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+
+	alias := strings.TrimSpace(operation.Data.(string))
+	if alias == key.Alias {
+		return
+	}
+	if "" != key.Alias {
+		err = fmt.Errorf("key [%s] already has alias [%s]", key.ID, key.Alias)
+		return
+	}
+
+	if err = checkAttributeViewColAlias(attrView, alias); nil != err {
+		return
+	}
+
+	key.Alias = alias
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// checkAttributeViewColAlias 检查列别名 alias 是否合法且在属性视图中唯一。
+func checkAttributeViewColAlias(attrView *av.AttributeView, alias string) (err error) {
+	// 别名需要能在模板中通过 .alias.xxx 引用，所以只允许字母、数字和下划线，且不能以数字开头
+	if "" == alias {
+		err = fmt.Errorf("invalid alias [%s]", alias)
+		return
+	}
+	for i, r := range alias {
+		if !unicode.IsLetter(r) && '_' != r && (0 == i || !unicode.IsDigit(r)) {
+			err = fmt.Errorf("invalid alias [%s]", alias)
+			return
+		}
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if keyValues.Key.Alias == alias {
+			err = fmt.Errorf("alias [%s] already exists", alias)
+			return
+		}
+	}
+	return
+}
+
+func (tx *Transaction) doSetAttrViewCacheComputedCols(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewCacheComputedCols(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewCacheComputedCols(operation *Operation) (err error) {
+	// operation.Data 是否缓存汇总列和模板列的计算结果
+	// 模板中使用了当前时间等与依赖无关的函数时不应该开启
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	attrView.CacheComputedCols = operation.Data.(bool)
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewKeepDeletedRows(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewKeepDeletedRows(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewKeepDeletedRows(operation *Operation) (err error) {
+	// operation.Data 块在属性视图外被删除时是否将行转换为游离行并保留值
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	attrView.KeepDeletedRows = operation.Data.(bool)
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewPrimaryTemplate(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewPrimaryTemplate(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewPrimaryTemplate(operation *Operation) (err error) {
+	// operation.Data 主键模板，为空时不生成主键内容
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	tplContent := strings.TrimSpace(operation.Data.(string))
+	if "" != tplContent {
+		if _, err = renderTemplateCol0(map[string]string{}, tplContent, nil, 0, 0); nil != err {
+			return
+		}
+	}
+
+	attrView.PrimaryTemplate = tplContent
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// renderAttributeViewPrimaryTemplate 根据属性视图的主键模板和行 rowID 在其他列中的值生成主键内容。
+func renderAttributeViewPrimaryTemplate(attrView *av.AttributeView, rowID string) string {
+	var rowValues []*av.KeyValues
+	for _, keyValues := range attrView.KeyValues {
+		if av.KeyTypeBlock == keyValues.Key.Type {
+			continue
+		}
+
+		if val := keyValues.GetValue(rowID); nil != val {
+			rowValues = append(rowValues, &av.KeyValues{Key: keyValues.Key, Values: []*av.Value{val}})
+		}
+	}
+	return strings.TrimSpace(renderTemplateCol(map[string]string{"id": rowID}, attrView.PrimaryTemplate, rowValues, 0, 0))
+}
+
+func (tx *Transaction) doSetAttrViewColEmptyPlaceholder(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColEmptyPlaceholder(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColEmptyPlaceholder(operation *Operation) (err error) {
+	// operation.ID 列 ID
+	// operation.Data 空值占位符，为空时不显示占位符
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+
+	key.EmptyPlaceholder = operation.Data.(string)
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColMask(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColMask(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColMask(operation *Operation) (err error) {
+	// operation.ID 列 ID
+	// operation.Data {"mask": "AAA-000", "strict": true}，mask 为空时取消输入掩码
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeText != key.Type {
+		err = fmt.Errorf("key type [%s] does not support input mask", key.Type)
+		return
+	}
+
+	data, _ := operation.Data.(map[string]interface{})
+	key.Mask, _ = data["mask"].(string)
+	key.MaskStrict, _ = data["strict"].(bool)
+	if "" == key.Mask {
+		key.MaskStrict = false
+	}
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColRelationDisplayLimit(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColRelationDisplayLimit(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColRelationDisplayLimit(operation *Operation) (err error) {
+	// operation.ID 关联列 ID
+	// operation.Data 最多显示的关联内容数量，为 0 时不限制
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+
+	if av.KeyTypeRelation != key.Type || nil == key.Relation {
+		err = fmt.Errorf("key [%s] is not a relation key", key.ID)
+		return
+	}
+
+	limit := int(operation.Data.(float64))
+	if 0 > limit {
+		limit = 0
+	}
+	key.Relation.DisplayLimit = limit
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// SetAttributeViewColRelationMaxEntries 设置关联列 keyID 每个单元格最多关联的块数量，为 0 时不限制。
+func SetAttributeViewColRelationMaxEntries(avID, keyID string, maxEntries int) (err error) {
+	if err = setAttributeViewColRelationMaxEntries(&Operation{AvID: avID, ID: keyID, Data: float64(maxEntries)}); nil != err {
+		return
+	}
+
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColRelationMaxEntries(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColRelationMaxEntries(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColRelationMaxEntries(operation *Operation) (err error) {
+	// operation.ID 关联列 ID
+	// operation.Data 每个单元格最多关联的块数量，为 0 时不限制
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+
+	if av.KeyTypeRelation != key.Type || nil == key.Relation {
+		err = fmt.Errorf("key [%s] is not a relation key", key.ID)
+		return
+	}
+
+	maxEntries := int(operation.Data.(float64))
+	if 0 > maxEntries {
+		maxEntries = 0
+	}
+
+	if 0 < maxEntries {
+		// 已有单元格超出限制时拒绝设置，避免保存后出现无法编辑的单元格
+		keyValues, _ := attrView.GetKeyValues(key.ID)
+		for _, value := range keyValues.Values {
+			if nil != value.Relation && maxEntries < len(value.Relation.BlockIDs) {
+				err = fmt.Errorf("relation key [%s] has a cell with %d entries, more than %d", key.ID, len(value.Relation.BlockIDs), maxEntries)
+				return
+			}
+		}
+	}
+
+	key.Relation.MaxEntries = maxEntries
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColAttrName(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColAttrName(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColAttrName(operation *Operation) (err error) {
+	// operation.ID 块属性列 ID
+	// operation.Data 块属性名
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+
+	if av.KeyTypeBlockAttr != key.Type {
+		err = fmt.Errorf("key [%s] is not a block attribute key", key.ID)
+		return
+	}
+
+	key.AttrName = strings.TrimSpace(operation.Data.(string))
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColNumberFormat(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColNumberFormat(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColNumberFormat(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	colType := av.KeyType(operation.Typ)
+	switch colType {
+	case av.KeyTypeNumber:
+		for _, keyValues := range attrView.KeyValues {
+			if keyValues.Key.ID == operation.ID && av.KeyTypeNumber == keyValues.Key.Type {
+				keyValues.Key.NumberFormat = av.NumberFormat(operation.Format)
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColumn(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColumn(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColumn(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	colType := av.KeyType(operation.Typ)
+	switch colType.BaseType() {
+	case av.KeyTypeBlock, av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
+		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
+		av.KeyTypeRelation, av.KeyTypeRollup:
+		for _, keyValues := range attrView.KeyValues {
+			if keyValues.Key.ID == operation.ID {
+				if keyValues.Key.Type != colType {
+					if err = checkAttributeViewKeyTypeAllowed(colType); nil != err {
+						return
+					}
+				}
+
+				keyValues.Key.Name = strings.TrimSpace(operation.Name)
+				keyValues.Key.Type = colType
+				break
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doConvertAttrViewColTextToSelect(operation *Operation) (ret *TxErr) {
+	// operation.Data 是否转换为多选列
+	_, err := ConvertTextToSelect(operation.AvID, operation.ID, operation.Data.(bool))
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// ConvertTextToSelect 将文本列转换为单选列（multi 为 true 时转换为多选列），返回转换后的列 ID。
+// 不重复的非空文本作为选项并自动分配颜色，多选时使用逗号分隔文本，空单元格转换后为空。
+func ConvertTextToSelect(avID, textKeyID string, multi bool) (keyID string, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	keyValues, err := attrView.GetKeyValues(textKeyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeText != keyValues.Key.Type {
+		err = fmt.Errorf("key [%s] is not a text key", textKeyID)
+		return
+	}
+
+	keyType := av.KeyTypeSelect
+	if multi {
+		keyType = av.KeyTypeMSelect
+	}
+	if err = checkAttributeViewKeyTypeAllowed(keyType); nil != err {
+		return
+	}
+
+	// 转换列类型会修改所有单元格，存在锁定的单元格时不能转换
+	for _, value := range keyValues.Values {
+		if err = checkAttributeViewCellLocked(attrView, textKeyID, value.BlockID); nil != err {
+			return
+		}
+	}
+
+	key := keyValues.Key
+	key.Type = keyType
+
+	colors := map[string]string{}
+	for _, value := range keyValues.Values {
+		var contents []string
+		if nil != value.Text {
+			if multi {
+				contents = strings.FieldsFunc(value.Text.Content, func(r rune) bool { return ',' == r || '，' == r })
+			} else {
+				contents = []string{value.Text.Content}
+			}
+		}
+
+		value.Type = key.Type
+		value.Text = nil
+		value.MSelect = []*av.ValueSelect{}
+		for _, content := range contents {
+			content = strings.TrimSpace(content)
+			if "" == content {
+				continue
+			}
+
+			color, ok := colors[content]
+			if !ok {
+				// 和前端新建选项时的颜色分配方式保持一致
+				color = strconv.Itoa(len(key.Options)%13 + 1)
+				colors[content] = color
+				key.Options = append(key.Options, &av.SelectOption{Name: content, Color: color})
+			} else if gulu.Str.Contains(content, selectContents(value.MSelect)) {
+				continue
+			}
+			value.MSelect = append(value.MSelect, &av.ValueSelect{Content: content, Color: color})
+		}
+	}
+
+	if err = av.SaveAttributeView(attrView); nil != err {
+		return
+	}
+	keyID = key.ID
+	return
+}
+
+func selectContents(values []*av.ValueSelect) (ret []string) {
+	for _, v := range values {
+		ret = append(ret, v.Content)
+	}
+	return
+}
+
+// SanitizeAttributeViewNumbers 清理数字列中无效的数字（没有数字值、NaN 或者无穷大），将其置为空值，返回清理的单元格数量。
+// 有效的数字会按列的数字格式重新格式化，被锁定的单元格保持不变。
+func SanitizeAttributeViewNumbers(avID, keyID string) (fixed int, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	fixed, err = sanitizeAttributeViewNumbers(attrView, keyID)
+	if nil != err {
+		return
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func sanitizeAttributeViewNumbers(attrView *av.AttributeView, keyID string) (fixed int, err error) {
+	keyValues, err := attrView.GetKeyValues(keyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeNumber != keyValues.Key.Type {
+		err = fmt.Errorf("key [%s] is not a number key", keyID)
+		return
+	}
+
+	for _, value := range keyValues.Values {
+		if nil != checkAttributeViewCellLocked(attrView, keyID, value.BlockID) {
+			continue
+		}
+
+		value.Type = av.KeyTypeNumber
+		if nil == value.Number || math.IsNaN(value.Number.Content) || math.IsInf(value.Number.Content, 0) {
+			value.Number = &av.ValueNumber{Format: keyValues.Key.NumberFormat}
+			fixed++
+			continue
+		}
+
+		value.Number.Format = keyValues.Key.NumberFormat
+		if value.Number.IsNotEmpty {
+			value.Number.FormatNumber("")
+		} else {
+			value.Number.Content = 0
+			value.Number.FormattedContent = ""
+		}
+	}
+	return
+}
+
+func (tx *Transaction) doRemoveAttrViewColumn(operation *Operation) (ret *TxErr) {
+	err := removeAttributeViewColumn(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func removeAttributeViewColumn(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	var removedKey *av.Key
+	for i, keyValues := range attrView.KeyValues {
+		if keyValues.Key.ID == operation.ID {
+			attrView.KeyValues = append(attrView.KeyValues[:i], attrView.KeyValues[i+1:]...)
+			removedKey = keyValues.Key
+			break
+		}
+	}
+
+	if nil != removedKey && av.KeyTypeRelation == removedKey.Type && nil != removedKey.Relation {
+		removeAttributeViewBackRelation(attrView, removedKey.Relation)
+	}
+
+	for _, view := range attrView.Views {
+		switch view.LayoutType {
+		case av.LayoutTypeTable:
+			for i, column := range view.Table.Columns {
+				if column.ID == operation.ID {
+					view.Table.Columns = append(view.Table.Columns[:i], view.Table.Columns[i+1:]...)
+					break
+				}
+			}
+			normalizeAttributeViewColumnGroups(view.Table)
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// removeAttributeViewBackRelation 删除双向关联 relation 在目标属性视图中的回链列，调用前关联列应该已经从 attrView 中移除或者清空了关联配置。
+func removeAttributeViewBackRelation(attrView *av.AttributeView, relation *av.Relation) {
+	if !relation.IsTwoWay {
+		return
+	}
+
+	// 删除双向关联的目标列
+
+	destAv := attrView
+	if relation.AvID != attrView.ID {
+		destAv, _ = av.ParseAttributeView(relation.AvID)
+	}
+	if nil != destAv {
+		destAvRelSrcAv := false
+		for i, keyValues := range destAv.KeyValues {
+			if keyValues.Key.ID == relation.BackKeyID {
+				destAv.KeyValues = append(destAv.KeyValues[:i], destAv.KeyValues[i+1:]...)
+				continue
+			}
+
+			if av.KeyTypeRelation == keyValues.Key.Type && nil != keyValues.Key.Relation && keyValues.Key.Relation.AvID == attrView.ID {
+				destAvRelSrcAv = true
+			}
+		}
+
+		for _, view := range destAv.Views {
+			switch view.LayoutType {
+			case av.LayoutTypeTable:
+				for i, column := range view.Table.Columns {
+					if column.ID == relation.BackKeyID {
+						view.Table.Columns = append(view.Table.Columns[:i], view.Table.Columns[i+1:]...)
+						break
+					}
+				}
+				normalizeAttributeViewColumnGroups(view.Table)
+			}
+		}
+
+		if destAv != attrView {
+			av.SaveAttributeView(destAv)
+			util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": destAv.ID})
+		}
+
+		if !destAvRelSrcAv {
+			av.RemoveAvRel(destAv.ID, attrView.ID)
+		}
+	}
+
+	srcAvRelDestAv := false
+	for _, keyValues := range attrView.KeyValues {
+		if av.KeyTypeRelation == keyValues.Key.Type && nil != keyValues.Key.Relation && keyValues.Key.Relation.AvID == relation.AvID {
+			srcAvRelDestAv = true
+		}
+	}
+	if !srcAvRelDestAv {
+		av.RemoveAvRel(attrView.ID, relation.AvID)
+	}
+}
+
+func (tx *Transaction) doClearAttrViewColRelationRollup(operation *Operation) (ret *TxErr) {
+	err := clearAttributeViewColRelationRollup(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func clearAttributeViewColRelationRollup(operation *Operation) (err error) {
+	// operation.ID 关联列或者汇总列 ID，清空配置后变为文本列，列在各个视图中的位置保持不变
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	keyValues, err := attrView.GetKeyValues(operation.ID)
+	if nil != err {
+		return
+	}
+
+	key := keyValues.Key
+	if av.KeyTypeRelation != key.Type && av.KeyTypeRollup != key.Type {
+		err = fmt.Errorf("key [%s] is not a relation or rollup key", key.ID)
+		return
+	}
+
+	relation := key.Relation
+	key.Type = av.KeyTypeText
+	key.Relation = nil
+	key.Rollup = nil
+	keyValues.Values = nil // 关联和汇总的值无法转换为文本值
+	if nil != relation {
+		removeAttributeViewBackRelation(attrView, relation)
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doReplaceAttrViewBlock(operation *Operation) (ret *TxErr) {
+	err := replaceAttributeViewBlock(operation, tx)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID}
+	}
+	return
+}
+
+func replaceAttributeViewBlock(operation *Operation, tx *Transaction) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	var node *ast.Node
+	if !operation.IsDetached {
+		node, _, _ = getNodeByBlockID(tx, operation.NextID)
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		for _, value := range keyValues.Values {
+			if value.BlockID == operation.PreviousID {
+				if value.BlockID != operation.NextID {
+					// 换绑
+					unbindBlockAv(tx, operation.AvID, value.BlockID)
+				}
+
+				value.BlockID = operation.NextID
+				if nil != value.Block {
+					value.Block.ID = operation.NextID
+					value.IsDetached = operation.IsDetached
+					if !operation.IsDetached {
+						value.Block.Content = getNodeRefText(node)
+					}
+				}
+
+				if !operation.IsDetached {
 					bindBlockAv(tx, operation.AvID, operation.NextID)
 				}
 			}
 		}
 	}
 
-	replacedRowID := false
-	for _, v := range attrView.Views {
-		switch v.LayoutType {
+	replacedRowID := false
+	for _, v := range attrView.Views {
+		switch v.LayoutType {
+		case av.LayoutTypeTable:
+			for i, rowID := range v.Table.RowIDs {
+				if rowID == operation.PreviousID {
+					v.Table.RowIDs[i] = operation.NextID
+					replacedRowID = true
+					break
+				}
+			}
+
+			if !replacedRowID {
+				v.Table.RowIDs = append(v.Table.RowIDs, operation.NextID)
+			}
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// MaterializeDetachedRow 在块 parentBlockID 下新建一个以游离行 rowID 主键内容为内容的块（父块是列表时新建列表项，否则新建段落），
+// 并将游离行绑定到新建的块上，行 ID 会被替换为新块 ID（包括值、视图中的行 ID 以及其他属性视图中关联到该行的关联列）。
+func MaterializeDetachedRow(avID, rowID, parentBlockID string) (newBlockID string, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	blockValue := attrView.GetBlockKeyValues().GetValue(rowID)
+	if nil == blockValue || nil == blockValue.Block {
+		err = fmt.Errorf("row [%s] not found in attribute view [%s]", rowID, avID)
+		return
+	}
+	if !blockValue.IsDetached {
+		err = fmt.Errorf("row [%s] is not a detached row", rowID)
+		return
+	}
+
+	tree, err := loadTreeByBlockID(parentBlockID)
+	if nil != err {
+		return
+	}
+	parent := treenode.GetNodeInTree(tree, parentBlockID)
+	if nil == parent || !parent.IsContainerBlock() {
+		err = fmt.Errorf("block [%s] can not contain child blocks", parentBlockID)
+		return
+	}
+
+	newBlockID = ast.NewNodeID()
+	paragraph := &ast.Node{Type: ast.NodeParagraph, ID: newBlockID}
+	if ast.NodeList == parent.Type {
+		paragraph.ID = ast.NewNodeID()
+	}
+	paragraph.SetIALAttr("id", paragraph.ID)
+	paragraph.SetIALAttr("updated", paragraph.ID[:14])
+	if "" != blockValue.Block.Content {
+		paragraph.AppendChild(&ast.Node{Type: ast.NodeText, Tokens: []byte(blockValue.Block.Content)})
+	}
+
+	newBlock := paragraph
+	if ast.NodeList == parent.Type {
+		// 沿用前一个列表项的标识符、缩进等，有序列表需要接着前一项的序号
+		listData := &ast.ListData{Typ: parent.ListData.Typ}
+		if prev := parent.LastChild; nil != prev && ast.NodeListItem == prev.Type && nil != prev.ListData {
+			data := *prev.ListData
+			listData = &data
+			listData.Checked = false
+			if 0 < listData.Num {
+				listData.Num++
+				listData.Marker = []byte(strconv.Itoa(listData.Num) + string(listData.Delimiter))
+			}
+		}
+		if 3 == listData.Typ {
+			paragraph.PrependChild(&ast.Node{Type: ast.NodeTaskListItemMarker})
+		}
+		newBlock = &ast.Node{ID: newBlockID, Type: ast.NodeListItem, ListData: listData, Tokens: listData.Marker}
+		newBlock.SetIALAttr("id", newBlockID)
+		newBlock.SetIALAttr("updated", newBlockID[:14])
+		newBlock.AppendChild(paragraph)
+	}
+	parent.AppendChild(newBlock)
+	if err = indexWriteJSONQueue(tree); nil != err {
+		return
+	}
+
+	remapRowID := func(id string) string {
+		if id == rowID {
+			return newBlockID
+		}
+		return id
+	}
+	for _, keyValues := range attrView.KeyValues {
+		for _, value := range keyValues.Values {
+			if value.BlockID == rowID {
+				value.BlockID = newBlockID
+				if nil != value.Block {
+					value.Block.ID = newBlockID
+					value.IsDetached = false
+				}
+			}
+		}
+	}
+	for _, view := range attrView.Views {
+		if nil == view.Table {
+			continue
+		}
+
+		for i, id := range view.Table.RowIDs {
+			view.Table.RowIDs[i] = remapRowID(id)
+		}
+		for i, id := range view.Table.TopRowIDs {
+			view.Table.TopRowIDs[i] = remapRowID(id)
+		}
+		if color, ok := view.Table.RowColors[rowID]; ok {
+			delete(view.Table.RowColors, rowID)
+			view.Table.RowColors[newBlockID] = color
+		}
+	}
+
+	// 其他属性视图（包括自身）中关联到该行的关联列需要使用新的块 ID
+	srcAvs := map[string]*av.AttributeView{attrView.ID: attrView}
+	for _, srcAvID := range av.GetSrcAvIDs(avID) {
+		if _, ok := srcAvs[srcAvID]; ok {
+			continue
+		}
+		if srcAv, parseErr := av.ParseAttributeView(srcAvID); nil == parseErr {
+			srcAvs[srcAvID] = srcAv
+		}
+	}
+	for _, srcAv := range srcAvs {
+		changed := srcAv.ID == attrView.ID
+		for _, keyValues := range srcAv.KeyValues {
+			if av.KeyTypeRelation != keyValues.Key.Type || nil == keyValues.Key.Relation || avID != keyValues.Key.Relation.AvID {
+				continue
+			}
+
+			for _, value := range keyValues.Values {
+				if nil == value.Relation {
+					continue
+				}
+				for i, blockID := range value.Relation.BlockIDs {
+					if blockID == rowID {
+						value.Relation.BlockIDs[i] = newBlockID
+						changed = true
+					}
+				}
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if err = av.SaveAttributeView(srcAv); nil != err {
+			return
+		}
+		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": srcAv.ID})
+	}
+
+	err = bindBlockAv(nil, avID, newBlockID)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewCell(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewCell(operation, tx)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewCell(operation *Operation, tx *Transaction) (err error) {
+	err = UpdateAttributeViewCell(tx, operation.AvID, operation.KeyID, operation.RowID, operation.ID, operation.Data, operation.OverrideLock)
+	return
+}
+
+func (tx *Transaction) doFillDownAttrViewCell(operation *Operation) (ret *TxErr) {
+	err := FillDownAttributeViewCell(tx, operation.AvID, operation.KeyID, operation.RowID, operation.SrcIDs)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// FillDownAttributeViewCell 将 keyID 列中 srcRowID 行的值复制到 rowIDs 这些行中（向下填充），只保存一次属性视图。
+func FillDownAttributeViewCell(tx *Transaction, avID, keyID, srcRowID string, rowIDs []string) (err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(keyID)
+	if nil != err {
+		return
+	}
+
+	unsupported := key.Type.IsComputed()
+	switch key.Type {
+	case av.KeyTypeBlock, av.KeyTypeTemplate, av.KeyTypeRollup, av.KeyTypeCreated, av.KeyTypeUpdated:
+		unsupported = true
+	}
+	if unsupported {
+		err = fmt.Errorf("key type [%s] does not support fill down", key.Type)
+		return
+	}
+
+	srcVal := attrView.GetValue(keyID, srcRowID)
+	if nil == srcVal {
+		srcVal = treenode.GetAttributeViewDefaultValue(ast.NewNodeID(), keyID, srcRowID, key.Type)
+	}
+
+	destAvs := map[string]*av.AttributeView{}
+	blockKeyValues := attrView.GetBlockKeyValues()
+	for _, rowID := range rowIDs {
+		if rowID == srcRowID || nil == blockKeyValues.GetValue(rowID) {
+			continue
+		}
+
+		if err = checkAttributeViewCellLocked(attrView, keyID, rowID); nil != err {
+			return
+		}
+
+		cellID := ast.NewNodeID()
+		if val := attrView.GetValue(keyID, rowID); nil != val {
+			cellID = val.ID
+		}
+
+		// 复制值时清空标识字段，避免覆盖目标单元格
+		valueData := srcVal.Clone()
+		valueData.ID, valueData.KeyID, valueData.BlockID = "", "", ""
+		if _, err = updateAttributeViewValue(tx, attrView, destAvs, keyID, rowID, cellID, valueData); nil != err {
+			return
+		}
+	}
+
+	err = saveAttributeViewWithDestAvs(attrView, destAvs)
+	return
+}
+
+func (tx *Transaction) doAddAttrViewMSelectOptionToRows(operation *Operation) (ret *TxErr) {
+	err := AddAttributeViewMSelectOptionToRows(tx, operation.AvID, operation.KeyID, operation.Data.(string), operation.SrcIDs)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// AddAttributeViewMSelectOptionToRows 将选项 option 追加到 keyID 多选列中 rowIDs 这些行的单元格上（批量打标签），只保存一次属性视图。
+// rowIDs 为空时使用当前视图过滤后的行；已经包含该选项的单元格保持不变，选项不存在时会先新建该选项。
+func AddAttributeViewMSelectOptionToRows(tx *Transaction, avID, keyID, option string, rowIDs []string) (err error) {
+	option = strings.TrimSpace(option)
+	if "" == option {
+		err = fmt.Errorf("option is empty")
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(keyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeMSelect != key.Type {
+		err = fmt.Errorf("key [%s] is not a multi-select key", keyID)
+		return
+	}
+
+	if 1 > len(rowIDs) {
+		view, getErr := attrView.GetCurrentView()
+		if nil != getErr {
+			err = getErr
+			return
+		}
+		if av.LayoutTypeTable != view.LayoutType || nil == view.Table {
+			err = fmt.Errorf("view [%s] is not a table view", view.ID)
+			return
+		}
+
+		table, renderErr := renderAttributeViewTable(attrView, view, nil)
+		if nil != renderErr {
+			err = renderErr
+			return
+		}
+		table.FilterRows(attrView)
+		table.FilterRowsByBindingState()
+		for _, row := range table.Rows {
+			rowIDs = append(rowIDs, row.ID)
+		}
+	}
+
+	var color string
+	for _, opt := range key.Options {
+		if opt.Name == option {
+			color = opt.Color
+			break
+		}
+	}
+	if "" == color {
+		// 和前端新建选项时的颜色分配方式保持一致
+		color = strconv.Itoa(len(key.Options)%13 + 1)
+		key.Options = append(key.Options, &av.SelectOption{Name: option, Color: color})
+	}
+
+	destAvs := map[string]*av.AttributeView{}
+	blockKeyValues := attrView.GetBlockKeyValues()
+	for _, rowID := range gulu.Str.RemoveDuplicatedElem(rowIDs) {
+		if nil == blockKeyValues.GetValue(rowID) {
+			continue
+		}
+
+		cellID := ast.NewNodeID()
+		var mSelect []*av.ValueSelect
+		if val := attrView.GetValue(keyID, rowID); nil != val {
+			if gulu.Str.Contains(option, selectContents(val.MSelect)) {
+				continue
+			}
+			if err = checkAttributeViewCellLocked(attrView, keyID, rowID); nil != err {
+				return
+			}
+			cellID = val.ID
+			mSelect = append(mSelect, val.MSelect...)
+		}
+		mSelect = append(mSelect, &av.ValueSelect{Content: option, Color: color})
+
+		if _, err = updateAttributeViewValue(tx, attrView, destAvs, keyID, rowID, cellID, &av.Value{MSelect: mSelect}); nil != err {
+			return
+		}
+	}
+
+	err = saveAttributeViewWithDestAvs(attrView, destAvs)
+	return
+}
+
+// checkAttributeViewCellLocked 检查 keyID 列中 rowID 行的单元格是否被锁定，锁定时返回 av.ErrCellLocked。
+// 所有修改单元格值的路径都需要先调用该函数，只有显式覆盖锁定的更新才可以跳过。
+func checkAttributeViewCellLocked(attrView *av.AttributeView, keyID, rowID string) (err error) {
+	if val := attrView.GetValue(keyID, rowID); nil != val && val.Locked {
+		err = av.ErrCellLocked
+	}
+	return
+}
+
+// UpdateAttributeViewCell 更新单元格的值，单元格被锁定时返回 av.ErrCellLocked，除非 overrideLock 为 true。
+func UpdateAttributeViewCell(tx *Transaction, avID, keyID, rowID, cellID string, valueData interface{}, overrideLock bool) (err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	if !overrideLock {
+		if err = checkAttributeViewCellLocked(attrView, keyID, rowID); nil != err {
+			return
+		}
+	}
+
+	destAvs := map[string]*av.AttributeView{}
+	unchanged, err := updateAttributeViewValue(tx, attrView, destAvs, keyID, rowID, cellID, valueData)
+	if nil != err || unchanged {
+		return
+	}
+
+	err = saveAttributeViewWithDestAvs(attrView, destAvs)
+	return
+}
+
+func (tx *Transaction) doToggleAttrViewCheckbox(operation *Operation) (ret *TxErr) {
+	_, err := ToggleAttributeViewCheckbox(operation.AvID, operation.KeyID, operation.RowID, operation.ID)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+// ToggleAttributeViewCheckbox 切换复选框单元格的勾选状态，单元格不存在时创建，返回切换后的状态。
+// 相比 UpdateAttributeViewCell 不需要传入完整的值。
+func ToggleAttributeViewCheckbox(avID, keyID, rowID, cellID string) (checked bool, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	keyValues, err := attrView.GetKeyValues(keyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeCheckbox != keyValues.Key.Type {
+		err = fmt.Errorf("key [%s] is not a checkbox", keyID)
+		return
+	}
+	if nil == attrView.GetBlockKeyValues().GetValue(rowID) {
+		err = fmt.Errorf("row [%s] not found", rowID)
+		return
+	}
+	if err = checkAttributeViewCellLocked(attrView, keyID, rowID); nil != err {
+		return
+	}
+
+	val := keyValues.GetValue(rowID)
+	if nil == val {
+		if "" == cellID {
+			cellID = ast.NewNodeID()
+		}
+		val = &av.Value{ID: cellID, KeyID: keyID, BlockID: rowID, Type: av.KeyTypeCheckbox}
+		keyValues.Values = append(keyValues.Values, val)
+	}
+	if nil == val.Checkbox {
+		val.Checkbox = &av.ValueCheckbox{}
+	}
+	val.Checkbox.Checked = !val.Checkbox.Checked
+	val.UpdatedAt = time.Now().UnixMilli()
+	touchAttributeViewRow(attrView, rowID)
+	checked = val.Checkbox.Checked
+
+	if err = av.SaveAttributeView(attrView); nil != err {
+		return
+	}
+
+	relatedAvIDs := av.GetSrcAvIDs(avID)
+	for _, relatedAvID := range relatedAvIDs {
+		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": relatedAvID})
+	}
+	return
+}
+
+func (tx *Transaction) doSetAttrViewCellLocked(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewCellLocked(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewCellLocked(operation *Operation) (err error) {
+	// operation.KeyID 列 ID
+	// operation.RowID 行 ID
+	// operation.Data 是否锁定单元格
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	keyValues, err := attrView.GetKeyValues(operation.KeyID)
+	if nil != err {
+		return
+	}
+	if nil == attrView.GetBlockKeyValues().GetValue(operation.RowID) {
+		err = fmt.Errorf("row [%s] not found", operation.RowID)
+		return
+	}
+
+	val := keyValues.GetValue(operation.RowID)
+	if nil == val { // 锁定空单元格时需要先创建值
+		val = treenode.GetAttributeViewDefaultValue(ast.NewNodeID(), operation.KeyID, operation.RowID, keyValues.Key.Type)
+		keyValues.Values = append(keyValues.Values, val)
+	}
+	val.Locked = operation.Data.(bool)
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// updateAttributeViewValue 更新已经加载的属性视图中的单元格值，不保存该属性视图。
+// 双向关联的回链修改写入 destAvs 中已加载的目标属性视图（目标是 attrView 自身时直接写入 attrView），
+// 由调用方通过 saveAttributeViewWithDestAvs 统一保存，这样批量更新时每个目标属性视图只需要解析和保存一次。
+// 返回 unchanged 为 true 时说明无需保存。
+func updateAttributeViewValue(tx *Transaction, attrView *av.AttributeView, destAvs map[string]*av.AttributeView, keyID, rowID, cellID string, valueData interface{}) (unchanged bool, err error) {
+	avID := attrView.ID
+	var blockVal *av.Value
+	for _, kv := range attrView.KeyValues {
+		if av.KeyTypeBlock == kv.Key.Type {
+			for _, v := range kv.Values {
+				if rowID == v.Block.ID {
+					blockVal = v
+					break
+				}
+			}
+			break
+		}
+	}
+
+	var val *av.Value
+	oldIsDetached := true
+	if nil != blockVal {
+		oldIsDetached = blockVal.IsDetached
+	}
+	for _, keyValues := range attrView.KeyValues {
+		if keyID != keyValues.Key.ID {
+			continue
+		}
+
+		if keyValues.Key.Type.IsComputed() {
+			// 计算列的值来自其他列、绑定块或者渲染时计算，不能直接修改
+			err = fmt.Errorf("key [%s] is read-only", keyID)
+			return
+		}
+
+		for _, value := range keyValues.Values {
+			if cellID == value.ID {
+				val = value
+				val.Type = keyValues.Key.Type
+				break
+			}
+		}
+
+		if nil == val {
+			val = &av.Value{ID: cellID, KeyID: keyValues.Key.ID, BlockID: rowID, Type: keyValues.Key.Type}
+			keyValues.Values = append(keyValues.Values, val)
+		}
+		break
+	}
+
+	isUpdatingBlockKey := av.KeyTypeBlock == val.Type
+	oldBoundBlockID := val.BlockID
+	var oldRelationBlockIDs []string
+	if av.KeyTypeRelation == val.Type {
+		if nil != val.Relation {
+			for _, bID := range val.Relation.BlockIDs {
+				oldRelationBlockIDs = append(oldRelationBlockIDs, bID)
+			}
+		}
+	}
+	data, err := gulu.JSON.MarshalJSON(valueData)
+	if nil != err {
+		return
+	}
+	locked := val.Locked
+	if err = gulu.JSON.UnmarshalJSON(data, &val); nil != err {
+		return
+	}
+	val.Locked = locked // 锁定状态只能通过 setAttrViewCellLocked 修改
+	if av.KeyTypeNumber == val.Type && nil != val.Number {
+		// 前端在 input 中传入用户输入的原始文本，据此推断小数位数；
+		// 没有传入或者和数字不一致时不保留小数位数
+		val.Number.Decimals = 0
+		input := strings.TrimSpace(val.Number.Input)
+		if inputNum, parseErr := strconv.ParseFloat(input, 64); nil == parseErr && inputNum == val.Number.Content && val.Number.IsNotEmpty {
+			val.Number.Decimals = av.InferNumberDecimals(input)
+		}
+		val.Number.Input = ""
+	}
+	if av.KeyTypeText == val.Type && nil != val.Text && "" != val.Text.Content {
+		if textKey, _ := attrView.GetKey(val.KeyID); nil != textKey && textKey.MaskStrict && !av.MatchMask(val.Text.Content, textKey.Mask) {
+			err = fmt.Errorf("value [%s] does not match the input mask [%s] of key [%s]", val.Text.Content, textKey.Mask, textKey.ID)
+			return
+		}
+	}
+
+	relationChangeMode := 0 // 0：不变（仅排序），1：增加，2：减少
+	if av.KeyTypeRelation == val.Type {
+		// 关联列得 content 是自动渲染的，所以不需要保存
+		val.Relation.Contents = nil
+
+		if relKey, _ := attrView.GetKey(val.KeyID); nil != relKey && nil != relKey.Relation && 0 < relKey.Relation.MaxEntries && relKey.Relation.MaxEntries < len(val.Relation.BlockIDs) {
+			err = fmt.Errorf("relation key [%s] allows at most %d entries", relKey.ID, relKey.Relation.MaxEntries)
+			return
+		}
+
+		// 计算关联变更模式
+		if len(oldRelationBlockIDs) == len(val.Relation.BlockIDs) {
+			relationChangeMode = 0
+		} else {
+			if len(oldRelationBlockIDs) > len(val.Relation.BlockIDs) {
+				relationChangeMode = 2
+			} else {
+				relationChangeMode = 1
+			}
+		}
+
+		// 双向关联时回链单元格被锁定则不能修改关联
+		if err = checkAttributeViewBackRelationLocked(attrView, destAvs, val.KeyID, rowID, oldRelationBlockIDs, val.Relation.BlockIDs); nil != err {
+			return
+		}
+	}
+
+	// val.IsDetached 只有更新主键的时候才会传入，所以下面需要结合 isUpdatingBlockKey 来判断
+
+	if oldIsDetached { // 之前是游离行
+		if !val.IsDetached { // 现在绑定了块
+			// 将游离行绑定到新建的块上
+			bindBlockAv(tx, avID, rowID)
+		}
+	} else { // 之前绑定了块
+		if isUpdatingBlockKey { // 正在更新主键
+			if val.IsDetached { // 现在是游离行
+				// 将绑定的块从属性视图中移除
+				unbindBlockAv(tx, avID, rowID)
+			} else { // 现在绑定了块
+				if oldBoundBlockID != val.BlockID { // 之前绑定的块和现在绑定的块不一样
+					// 换绑块
+					unbindBlockAv(tx, avID, oldBoundBlockID)
+					bindBlockAv(tx, avID, val.BlockID)
+				} else { // 之前绑定的块和现在绑定的块一样
+					// 直接返回，因为锚文本不允许更改
+					unchanged = true
+					return
+				}
+			}
+		}
+	}
+
+	if nil != blockVal && isUpdatingBlockKey {
+		blockVal.IsDetached = val.IsDetached
+		if !val.IsDetached {
+			blockVal.IsBlockDeleted = false
+		}
+	}
+	val.UpdatedAt = time.Now().UnixMilli()
+	touchAttributeViewRow(attrView, rowID)
+
+	key, _ := attrView.GetKey(val.KeyID)
+	if nil != key && av.KeyTypeRelation == key.Type && nil != key.Relation {
+		destAv := getAttributeViewRelationDestAv(attrView, destAvs, key.Relation.AvID)
+		if nil != destAv {
+			if key.Relation.IsTwoWay {
+				// relationChangeMode
+				// 0：关联列值不变（仅排序），不影响目标值
+				// 1：关联列值增加，增加目标值
+				// 2：关联列值减少，减少目标值
+
+				if 1 == relationChangeMode {
+					addBlockIDs := val.Relation.BlockIDs
+					for _, bID := range oldRelationBlockIDs {
+						addBlockIDs = gulu.Str.RemoveElem(addBlockIDs, bID)
+					}
+
+					for _, blockID := range addBlockIDs {
+						for _, keyValues := range destAv.KeyValues {
+							if keyValues.Key.ID != key.Relation.BackKeyID {
+								continue
+							}
+
+							destVal := keyValues.GetValue(blockID)
+							if nil == destVal {
+								destVal = &av.Value{ID: ast.NewNodeID(), KeyID: keyValues.Key.ID, BlockID: blockID, Type: keyValues.Key.Type, Relation: &av.ValueRelation{}}
+								keyValues.Values = append(keyValues.Values, destVal)
+							}
+
+							destVal.Relation.BlockIDs = append(destVal.Relation.BlockIDs, rowID)
+							destVal.Relation.BlockIDs = gulu.Str.RemoveDuplicatedElem(destVal.Relation.BlockIDs)
+							destVal.UpdatedAt = val.UpdatedAt
+							touchAttributeViewRow(destAv, blockID)
+							break
+						}
+					}
+				} else if 2 == relationChangeMode {
+					removeBlockIDs := oldRelationBlockIDs
+					for _, bID := range val.Relation.BlockIDs {
+						removeBlockIDs = gulu.Str.RemoveElem(removeBlockIDs, bID)
+					}
+
+					for _, blockID := range removeBlockIDs {
+						for _, keyValues := range destAv.KeyValues {
+							if keyValues.Key.ID != key.Relation.BackKeyID {
+								continue
+							}
+
+							for _, value := range keyValues.Values {
+								if value.BlockID == blockID {
+									value.Relation.BlockIDs = gulu.Str.RemoveElem(value.Relation.BlockIDs, rowID)
+									value.UpdatedAt = val.UpdatedAt
+									touchAttributeViewRow(destAv, blockID)
+									break
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// getAttributeViewRelationDestAv 返回关联目标属性视图 destAvID，目标是 attrView 自身时返回 attrView，
+// 否则优先使用 destAvs 中已加载的属性视图，没有时解析并放入 destAvs。
+func getAttributeViewRelationDestAv(attrView *av.AttributeView, destAvs map[string]*av.AttributeView, destAvID string) (ret *av.AttributeView) {
+	if destAvID == attrView.ID {
+		return attrView
+	}
+
+	if ret = destAvs[destAvID]; nil != ret {
+		return
+	}
+
+	ret, _ = av.ParseAttributeView(destAvID)
+	if nil != ret && nil != destAvs {
+		destAvs[destAvID] = ret
+	}
+	return
+}
+
+// saveAttributeViewWithDestAvs 保存 attrView 以及 updateAttributeViewValue 修改过的关联目标属性视图 destAvs，
+// 保存成功后通知前端刷新相关的属性视图。
+func saveAttributeViewWithDestAvs(attrView *av.AttributeView, destAvs map[string]*av.AttributeView) (err error) {
+	if err = av.SaveAttributeView(attrView); nil != err {
+		return
+	}
+
+	for _, destAv := range destAvs {
+		if destAv.ID == attrView.ID {
+			continue
+		}
+		if err = av.SaveAttributeView(destAv); nil != err {
+			return
+		}
+	}
+
+	relatedAvIDs := av.GetSrcAvIDs(attrView.ID)
+	for _, relatedAvID := range relatedAvIDs {
+		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": relatedAvID})
+	}
+	return
+}
+
+// checkAttributeViewBackRelationLocked 检查双向关联列 relKeyID 中 rowID 行的关联从 oldBlockIDs 变为 newBlockIDs 时，
+// 需要同步修改的回链单元格是否被锁定，锁定时返回 av.ErrCellLocked。
+func checkAttributeViewBackRelationLocked(attrView *av.AttributeView, destAvs map[string]*av.AttributeView, relKeyID, rowID string, oldBlockIDs, newBlockIDs []string) (err error) {
+	relKey, _ := attrView.GetKey(relKeyID)
+	if nil == relKey || nil == relKey.Relation || !relKey.Relation.IsTwoWay {
+		return
+	}
+
+	destAv := getAttributeViewRelationDestAv(attrView, destAvs, relKey.Relation.AvID)
+	if nil == destAv {
+		return
+	}
+
+	for _, blockID := range gulu.Str.RemoveDuplicatedElem(append(append([]string{}, oldBlockIDs...), newBlockIDs...)) {
+		if gulu.Str.Contains(blockID, oldBlockIDs) && gulu.Str.Contains(blockID, newBlockIDs) {
+			continue
+		}
+		if err = checkAttributeViewCellLocked(destAv, relKey.Relation.BackKeyID, blockID); nil != err {
+			return
+		}
+	}
+	return
+}
+
+// getAttributeViewRowsUpdated 返回属性视图中各行的更新时间，行 ID -> 更新时间戳（毫秒）。
+func getAttributeViewRowsUpdated(attrView *av.AttributeView) (ret map[string]int64) {
+	ret = map[string]int64{}
+	for _, v := range attrView.GetBlockKeyValues().Values {
+		if nil != v.Block {
+			ret[v.BlockID] = v.Block.Updated
+		}
+	}
+	return
+}
+
+// newAttributeViewFormulaContext 构造计算一行公式的上下文，values 为列 ID 到该行值的映射。
+func newAttributeViewFormulaContext(attrView *av.AttributeView, values map[string]*av.Value) *av.FormulaContext {
+	var keys []*av.Key
+	for _, kv := range attrView.KeyValues {
+		keys = append(keys, kv.Key)
+	}
+	return &av.FormulaContext{Keys: keys, Values: values}
+}
+
+// getAttributeViewRowCellValues 返回渲染后的行中列 ID 到值的映射，不在视图中的列使用保存的值。
+func getAttributeViewRowCellValues(attrView *av.AttributeView, cols []*av.TableColumn, row *av.TableRow) (ret map[string]*av.Value) {
+	ret = map[string]*av.Value{}
+	for _, kv := range attrView.KeyValues {
+		if v := kv.GetValue(row.ID); nil != v {
+			ret[kv.Key.ID] = v
+		}
+	}
+	for i, col := range cols {
+		if nil != row.Cells[i].Value {
+			ret[col.ID] = row.Cells[i].Value
+		}
+	}
+	return
+}
+
+// getAttributeViewFormulaValue 基于保存的值计算公式列 key 在行 rowID 的值，用于汇总其他属性视图中的公式列。
+// 被引用的汇总列等计算列没有保存的值，按空值计算。
+func getAttributeViewFormulaValue(attrView *av.AttributeView, key *av.Key, rowID string) (ret *av.Value) {
+	values := map[string]*av.Value{}
+	for _, kv := range attrView.KeyValues {
+		if v := kv.GetValue(rowID); nil != v {
+			values[kv.Key.ID] = v
+		}
+	}
+
+	ret = &av.Value{ID: ast.NewNodeID(), KeyID: key.ID, BlockID: rowID, Type: av.KeyTypeFormula, Number: &av.ValueNumber{}}
+	if num, err := newAttributeViewFormulaContext(attrView, values).EvalKey(key); nil == err {
+		ret.Number = num
+	}
+	return
+}
+
+// checkAttributeViewFormulaCycle 检查列 keyID 的依赖中是否存在循环，比如公式列引用了汇总列，而汇总列的目标列又是引用了该汇总列的公式列。
+// attrView 为修改后还没有保存的属性视图，依赖的其他属性视图从数据目录中读取。
+func checkAttributeViewFormulaCycle(attrView *av.AttributeView, keyID string) error {
+	attrViews := map[string]*av.AttributeView{attrView.ID: attrView}
+	visiting, visited := map[string]bool{}, map[string]bool{}
+	var hasCycle func(avID, keyID string) bool
+	hasCycle = func(avID, keyID string) bool {
+		node := avID + "/" + keyID
+		if visiting[node] {
+			return true
+		}
+		if visited[node] {
+			return false
+		}
+
+		a := attrViews[avID]
+		if nil == a {
+			a, _ = av.ParseAttributeView(avID)
+			if nil == a {
+				return false
+			}
+			attrViews[avID] = a
+		}
+		key, _ := a.GetKey(keyID)
+		if nil == key {
+			return false
+		}
+
+		var deps [][2]string // 依赖的属性视图 ID 和列 ID
+		switch key.Type {
+		case av.KeyTypeFormula:
+			formula, parseErr := av.ParseFormula(key.Formula)
+			if nil != parseErr {
+				break
+			}
+			var keys []*av.Key
+			for _, kv := range a.KeyValues {
+				keys = append(keys, kv.Key)
+			}
+			for _, ref := range formula.Refs() {
+				if refKey := av.GetFormulaRefKey(keys, ref); nil != refKey {
+					deps = append(deps, [2]string{avID, refKey.ID})
+				}
+			}
+		case av.KeyTypeRollup:
+			if nil == key.Rollup {
+				break
+			}
+			relKey, _ := a.GetKey(key.Rollup.RelationKeyID)
+			if nil == relKey || nil == relKey.Relation {
+				break
+			}
+			deps = append(deps, [2]string{relKey.Relation.AvID, key.Rollup.KeyID})
+			if "" != key.Rollup.AltKeyID {
+				deps = append(deps, [2]string{relKey.Relation.AvID, key.Rollup.AltKeyID})
+			}
+		}
+
+		visiting[node] = true
+		for _, dep := range deps {
+			if hasCycle(dep[0], dep[1]) {
+				return true
+			}
+		}
+		delete(visiting, node)
+		visited[node] = true
+		return false
+	}
+
+	if hasCycle(attrView.ID, keyID) {
+		return fmt.Errorf("key [%s] has a circular reference", keyID)
+	}
+	return nil
+}
+
+// getAttributeViewCurrentAuthor 返回当前用户名，作为行的创建者和更新者，未登录时返回空字符串。
+func getAttributeViewCurrentAuthor() string {
+	if nil == Conf {
+		return ""
+	}
+	if user := Conf.GetUser(); nil != user {
+		return user.UserName
+	}
+	return ""
+}
+
+// getAttributeViewRowAuthor 返回行的创建者或者更新者。
+func getAttributeViewRowAuthor(attrView *av.AttributeView, rowID string, keyType av.KeyType) string {
+	blockVal := attrView.GetBlockKeyValues().GetValue(rowID)
+	if nil == blockVal || nil == blockVal.Block {
+		return ""
+	}
+	if av.KeyTypeCreatedBy == keyType {
+		return blockVal.Block.CreatedBy
+	}
+	return blockVal.Block.UpdatedBy
+}
+
+// touchAttributeViewRow 更新行的更新时间。
+// 游离行没有块 IAL 可以读取，所以还需要将更新时间写入更新时间列，作为渲染时的回退值。
+func touchAttributeViewRow(attrView *av.AttributeView, rowID string) {
+	blockVal := attrView.GetBlockKeyValues().GetValue(rowID)
+	if nil == blockVal || nil == blockVal.Block {
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	blockVal.Block.Updated = now
+	blockVal.Block.UpdatedBy = getAttributeViewCurrentAuthor()
+	if !blockVal.IsDetached {
+		return
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if av.KeyTypeUpdated != keyValues.Key.Type {
+			continue
+		}
+
+		val := keyValues.GetValue(rowID)
+		if nil == val {
+			val = &av.Value{ID: ast.NewNodeID(), KeyID: keyValues.Key.ID, BlockID: rowID, Type: av.KeyTypeUpdated}
+			keyValues.Values = append(keyValues.Values, val)
+		}
+		val.Updated = av.NewFormattedValueUpdated(now, 0, av.UpdatedFormatNone)
+		val.Updated.IsNotEmpty = true
+	}
+}
+
+func unbindBlockAv(tx *Transaction, avID, blockID string) {
+	node, tree, err := getNodeByBlockID(tx, blockID)
+	if nil != err {
+		return
+	}
+
+	attrs := parse.IAL2Map(node.KramdownIAL)
+	if "" == attrs[av.NodeAttrNameAvs] {
+		return
+	}
+
+	avIDs := strings.Split(attrs[av.NodeAttrNameAvs], ",")
+	avIDs = gulu.Str.RemoveElem(avIDs, avID)
+	if 0 == len(avIDs) {
+		delete(attrs, av.NodeAttrNameAvs)
+		node.RemoveIALAttr(av.NodeAttrNameAvs)
+	} else {
+		attrs[av.NodeAttrNameAvs] = strings.Join(avIDs, ",")
+		node.SetIALAttr(av.NodeAttrNameAvs, strings.Join(avIDs, ","))
+	}
+
+	if nil != tx {
+		err = setNodeAttrsWithTx(tx, node, tree, attrs)
+	} else {
+		err = setNodeAttrs(node, tree, attrs)
+	}
+	if nil != err {
+		logging.LogWarnf("set node [%s] attrs failed: %s", blockID, err)
+		return
+	}
+	return
+}
+
+func bindBlockAv(tx *Transaction, avID, blockID string) (err error) {
+	node, tree, err := getNodeByBlockID(tx, blockID)
+	if nil != err {
+		return
+	}
+	if nil == node {
+		err = fmt.Errorf("block [%s] not found", blockID)
+		return
+	}
+
+	attrs := parse.IAL2Map(node.KramdownIAL)
+	if "" == attrs[av.NodeAttrNameAvs] {
+		attrs[av.NodeAttrNameAvs] = avID
+	} else {
+		avIDs := strings.Split(attrs[av.NodeAttrNameAvs], ",")
+		avIDs = append(avIDs, avID)
+		avIDs = gulu.Str.RemoveDuplicatedElem(avIDs)
+		attrs[av.NodeAttrNameAvs] = strings.Join(avIDs, ",")
+	}
+
+	if nil != tx {
+		err = setNodeAttrsWithTx(tx, node, tree, attrs)
+	} else {
+		err = setNodeAttrs(node, tree, attrs)
+	}
+	if nil != err {
+		logging.LogWarnf("set node [%s] attrs failed: %s", blockID, err)
+		return
+	}
+	return
+}
+
+func getNodeByBlockID(tx *Transaction, blockID string) (node *ast.Node, tree *parse.Tree, err error) {
+	if nil != tx {
+		tree, err = tx.loadTree(blockID)
+	} else {
+		tree, err = loadTreeByBlockID(blockID)
+	}
+	if nil != err {
+		return
+	}
+	node = treenode.GetNodeInTree(tree, blockID)
+	if nil == node {
+		logging.LogWarnf("node [%s] not found in tree [%s]", blockID, tree.ID)
+		return
+	}
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColOptions(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColumnOptions(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColumnOptions(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	jsonData, err := gulu.JSON.MarshalJSON(operation.Data)
+	if nil != err {
+		return
+	}
+
+	options := []*av.SelectOption{}
+	if err = gulu.JSON.UnmarshalJSON(jsonData, &options); nil != err {
+		return
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if keyValues.Key.ID == operation.ID {
+			keyValues.Key.Options = options
+			err = av.SaveAttributeView(attrView)
+			return
+		}
+	}
+	return
+}
+
+func (tx *Transaction) doSortAttrViewColOptionsByUsage(operation *Operation) (ret *TxErr) {
+	err := sortAttributeViewColumnOptionsByUsage(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func sortAttributeViewColumnOptionsByUsage(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	keyValues, err := attrView.GetKeyValues(operation.ID)
+	if nil != err {
+		return
+	}
+
+	sortSelectOptionsByUsage(keyValues)
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// sortSelectOptionsByUsage 按照使用选项的行数降序排列单选或多选列的选项，使用行数相同时保持原有顺序。
+func sortSelectOptionsByUsage(keyValues *av.KeyValues) {
+	if av.KeyTypeSelect != keyValues.Key.Type && av.KeyTypeMSelect != keyValues.Key.Type {
+		return
+	}
+
+	counts := map[string]int{}
+	for _, value := range keyValues.Values {
+		used := map[string]bool{}
+		for _, opt := range value.MSelect {
+			if used[opt.Content] {
+				continue
+			}
+			used[opt.Content] = true
+			counts[opt.Content]++
+		}
+	}
+
+	sort.SliceStable(keyValues.Key.Options, func(i, j int) bool {
+		return counts[keyValues.Key.Options[i].Name] > counts[keyValues.Key.Options[j].Name]
+	})
+}
+
+func (tx *Transaction) doRemoveAttrViewColOption(operation *Operation) (ret *TxErr) {
+	err := removeAttributeViewColumnOption(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func removeAttributeViewColumnOption(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	optName := operation.Data.(string)
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+
+	for i, opt := range key.Options {
+		if optName == opt.Name {
+			key.Options = append(key.Options[:i], key.Options[i+1:]...)
+			break
+		}
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if keyValues.Key.ID != operation.ID {
+			continue
+		}
+
+		for _, value := range keyValues.Values {
+			if nil == value || nil == value.MSelect {
+				continue
+			}
+
+			for i, opt := range value.MSelect {
+				if optName == opt.Content {
+					value.MSelect = append(value.MSelect[:i], value.MSelect[i+1:]...)
+					break
+				}
+			}
+		}
+		break
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColOption(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColumnOption(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColumnOption(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if nil != err {
+		return
+	}
+
+	data := operation.Data.(map[string]interface{})
+
+	oldName := data["oldName"].(string)
+	newName := data["newName"].(string)
+	newColor := data["newColor"].(string)
+
+	for i, opt := range key.Options {
+		if oldName == opt.Name {
+			key.Options[i].Name = newName
+			key.Options[i].Color = newColor
+			break
+		}
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if keyValues.Key.ID != operation.ID {
+			continue
+		}
+
+		for _, value := range keyValues.Values {
+			if nil == value || nil == value.MSelect {
+				continue
+			}
+
+			for i, opt := range value.MSelect {
+				if oldName == opt.Content {
+					value.MSelect[i].Content = newName
+					value.MSelect[i].Color = newColor
+					break
+				}
+			}
+		}
+		break
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSortAttrViewCellOption(operation *Operation) (ret *TxErr) {
+	err := sortAttributeViewCellOption(operation)
+	if nil != err {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func sortAttributeViewCellOption(operation *Operation) (err error) {
+	// operation.Data 为单元格中选项名称的新顺序，未给出的选项按原顺序排在后面
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if nil != err {
+		return
+	}
+
+	val := attrView.GetValue(operation.KeyID, operation.RowID)
+	if nil == val || 2 > len(val.MSelect) {
+		return
+	}
+	if err = checkAttributeViewCellLocked(attrView, operation.KeyID, operation.RowID); nil != err {
+		return
+	}
+
+	order := map[string]int{}
+	if names, ok := operation.Data.([]interface{}); ok {
+		for i, name := range names {
+			if n, ok := name.(string); ok {
+				if _, exist := order[n]; !exist {
+					order[n] = i
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(val.MSelect, func(i, j int) bool {
+		io, iok := order[val.MSelect[i].Content]
+		jo, jok := order[val.MSelect[j].Content]
+		if iok && jok {
+			return io < jo
+		}
+		return iok && !jok
+	})
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+// ParseTextColumnToDate 将文本列 textKeyID 中的值按 layout 解析后写入日期列 dateKeyID，用于清理从 CSV 导入的数据。
+// 无法解析的值和被锁定的日期单元格会被跳过，返回成功转换的数量。
+func ParseTextColumnToDate(avID, textKeyID, dateKeyID, layout string) (converted int, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	textKeyValues, err := attrView.GetKeyValues(textKeyID)
+	if nil != err {
+		return
+	}
+	dateKeyValues, err := attrView.GetKeyValues(dateKeyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeText != textKeyValues.Key.Type || av.KeyTypeDate != dateKeyValues.Key.Type {
+		err = fmt.Errorf("invalid key types [%s, %s]", textKeyValues.Key.Type, dateKeyValues.Key.Type)
+		return
+	}
+
+	skipped := 0
+	for _, textVal := range textKeyValues.Values {
+		if nil == textVal.Text || "" == strings.TrimSpace(textVal.Text.Content) {
+			continue
+		}
+
+		t, parseErr := time.ParseInLocation(layout, strings.TrimSpace(textVal.Text.Content), time.Local)
+		if nil != parseErr {
+			skipped++
+			continue
+		}
+		if nil != checkAttributeViewCellLocked(attrView, dateKeyID, textVal.BlockID) {
+			skipped++
+			continue
+		}
+
+		isNotTime := 0 == t.Hour() && 0 == t.Minute() && 0 == t.Second()
+		dateVal := dateKeyValues.GetValue(textVal.BlockID)
+		if nil == dateVal {
+			dateVal = &av.Value{ID: ast.NewNodeID(), KeyID: dateKeyID, BlockID: textVal.BlockID, Type: av.KeyTypeDate}
+			dateKeyValues.Values = append(dateKeyValues.Values, dateVal)
+		}
+		dateVal.Date = av.NewFormattedValueDate(t.UnixMilli(), 0, av.DateFormatNone, isNotTime)
+		dateVal.Date.IsNotEmpty = true
+		converted++
+	}
+	if 0 < skipped {
+		logging.LogWarnf("skipped [%d] unparseable or locked values when converting key [%s] to date key [%s] of attribute view [%s]", skipped, textKeyID, dateKeyID, avID)
+	}
+
+	if 0 < converted {
+		err = av.SaveAttributeView(attrView)
+	}
+	return
+}
+
+// ImportAttributeViewFromMarkdownTable 将文档中的 Markdown 表格 tableBlockID 转换为属性视图，返回新建的属性视图 ID。
+// 表头作为列名，第一列作为主键列，其他列按照单元格内容推断为数字、日期或者文本列，表格行转换为游离行。
+// replace 为 true 时使用相同的块 ID 将表格块替换为数据库块。
+func ImportAttributeViewFromMarkdownTable(tableBlockID string, replace bool) (avID string, err error) {
+	tree, err := loadTreeByBlockID(tableBlockID)
+	if nil != err {
+		return
+	}
+
+	node := treenode.GetNodeInTree(tree, tableBlockID)
+	if nil == node || ast.NodeTable != node.Type {
+		err = fmt.Errorf("block [%s] is not a table", tableBlockID)
+		return
+	}
+
+	var header []string
+	var rows [][]string
+	for row := node.FirstChild; nil != row; row = row.Next {
+		switch row.Type {
+		case ast.NodeTableHead:
+			if nil != row.FirstChild {
+				header = markdownTableRowContents(row.FirstChild)
+			}
+		case ast.NodeTableRow:
+			rows = append(rows, markdownTableRowContents(row))
+		}
+	}
+	if 1 > len(header) {
+		err = fmt.Errorf("table [%s] has no header", tableBlockID)
+		return
+	}
+
+	attrView := av.NewAttributeView(ast.NewNodeID())
+	avID = attrView.ID
+	blockKeyValues := attrView.GetBlockKeyValues()
+	blockKeyValues.Key.Name = header[0]
+	view := attrView.Views[0]
+	keyValuesList := []*av.KeyValues{blockKeyValues}
+	for i := 1; i < len(header); i++ {
+		var colContents []string
+		for _, row := range rows {
+			if i < len(row) {
+				colContents = append(colContents, row[i])
+			}
+		}
+
+		key := av.NewKey(ast.NewNodeID(), header[i], "", inferAttributeViewKeyType(colContents))
+		keyValues := &av.KeyValues{Key: key}
+		attrView.KeyValues = append(attrView.KeyValues, keyValues)
+		view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: key.ID})
+		keyValuesList = append(keyValuesList, keyValues)
+	}
+
+	now := time.Now().UnixMilli()
+	author := getAttributeViewCurrentAuthor()
+	for _, row := range rows {
+		rowID := ast.NewNodeID()
+		for i, keyValues := range keyValuesList {
+			var content string
+			if i < len(row) {
+				content = row[i]
+			}
+			if av.KeyTypeBlock == keyValues.Key.Type {
+				keyValues.Values = append(keyValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: keyValues.Key.ID, BlockID: rowID, Type: av.KeyTypeBlock, IsDetached: true, Block: &av.ValueBlock{ID: rowID, Content: content, Created: now, Updated: now, CreatedBy: author, UpdatedBy: author}})
+				continue
+			}
+
+			if val := newAttributeViewValueFromText(keyValues.Key, rowID, content); nil != val {
+				keyValues.Values = append(keyValues.Values, val)
+			}
+		}
+		view.Table.RowIDs = append(view.Table.RowIDs, rowID)
+	}
+
+	if err = av.SaveAttributeView(attrView); nil != err {
+		return
+	}
+
+	if !replace {
+		return
+	}
+
+	avNode := &ast.Node{Type: ast.NodeAttributeView, ID: node.ID, AttributeViewID: avID, AttributeViewType: string(av.LayoutTypeTable)}
+	avNode.SetIALAttr("id", node.ID)
+	avNode.SetIALAttr("updated", util.CurrentTimeSecondsStr())
+	node.InsertBefore(avNode)
+	node.Unlink()
+	av.UpsertBlockRel(avID, avNode.ID)
+	err = indexWriteJSONQueue(tree)
+	return
+}
+
+// markdownTableRowContents 返回 Markdown 表格行中每个单元格的文本。
+func markdownTableRowContents(row *ast.Node) (ret []string) {
+	for cell := row.FirstChild; nil != cell; cell = cell.Next {
+		if ast.NodeTableCell == cell.Type {
+			ret = append(ret, strings.TrimSpace(cell.Content()))
+		}
+	}
+	return
+}
+
+// attributeViewTextDateLayouts 是从文本推断日期列时支持的日期格式。
+var attributeViewTextDateLayouts = []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", "2006/01/02", "2006/01/02 15:04"}
+
+// inferAttributeViewKeyType 根据列中的文本推断列类型：非空文本都是数字时为数字列，都是日期时为日期列，否则为文本列。
+func inferAttributeViewKeyType(contents []string) av.KeyType {
+	isNumber, isDate, hasContent := true, true, false
+	for _, content := range contents {
+		if "" == content {
+			continue
+		}
+
+		hasContent = true
+		if _, parseErr := strconv.ParseFloat(content, 64); nil != parseErr {
+			isNumber = false
+		}
+		if _, ok := parseAttributeViewTextDate(content); !ok {
+			isDate = false
+		}
+	}
+
+	switch {
+	case !hasContent:
+		return av.KeyTypeText
+	case isNumber:
+		return av.KeyTypeNumber
+	case isDate:
+		return av.KeyTypeDate
+	}
+	return av.KeyTypeText
+}
+
+func parseAttributeViewTextDate(content string) (ret time.Time, ok bool) {
+	for _, layout := range attributeViewTextDateLayouts {
+		if t, parseErr := time.ParseInLocation(layout, content, time.Local); nil == parseErr {
+			return t, true
+		}
+	}
+	return
+}
+
+// newAttributeViewValueFromText 按照列类型将文本转换为值，文本为空时返回 nil。
+func newAttributeViewValueFromText(key *av.Key, rowID, content string) (ret *av.Value) {
+	if "" == content {
+		return
+	}
+
+	ret = &av.Value{ID: ast.NewNodeID(), KeyID: key.ID, BlockID: rowID, Type: key.Type}
+	switch key.Type {
+	case av.KeyTypeNumber:
+		number, _ := strconv.ParseFloat(content, 64)
+		ret.Number = av.NewFormattedValueNumber(number, av.NumberFormatNone)
+		ret.Number.Decimals = av.InferNumberDecimals(content)
+	case av.KeyTypeDate:
+		t, _ := parseAttributeViewTextDate(content)
+		isNotTime := 0 == t.Hour() && 0 == t.Minute() && 0 == t.Second()
+		ret.Date = av.NewFormattedValueDate(t.UnixMilli(), 0, av.DateFormatNone, isNotTime)
+		ret.Date.IsNotEmpty = true
+	default:
+		ret.Text = &av.ValueText{Content: content}
+	}
+	return
+}
+
+// ConvertMSelectToRelation 将多选列 msKeyID 转换为关联到 destAvID 的双向关联列。
+// 每个选项对应目标属性视图中的一行（按主键内容匹配，不存在时创建游离行），转换后的关联列 ID 与原多选列相同。
+func ConvertMSelectToRelation(avID, msKeyID, destAvID string) (relKeyID string, err error) {
+	srcAv, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	destAv, err := av.ParseAttributeView(destAvID)
+	if nil != err {
+		return
+	}
+
+	isSameAv := srcAv.ID == destAv.ID
+	if isSameAv {
+		destAv = srcAv
+	}
+
+	msKeyValues, err := srcAv.GetKeyValues(msKeyID)
+	if nil != err {
+		return
+	}
+	if av.KeyTypeMSelect != msKeyValues.Key.Type && av.KeyTypeSelect != msKeyValues.Key.Type {
+		err = fmt.Errorf("key [%s] is not a select key", msKeyID)
+		return
+	}
+	if err = checkAttributeViewKeyTypeAllowed(av.KeyTypeRelation); nil != err {
+		return
+	}
+
+	// 按选项名称找到或者创建目标行
+	destBlockValues := destAv.GetBlockKeyValues()
+	optionBlockIDs := map[string]string{}
+	for _, v := range destBlockValues.Values {
+		if nil != v.Block {
+			if _, ok := optionBlockIDs[v.Block.Content]; !ok {
+				optionBlockIDs[v.Block.Content] = v.BlockID
+			}
+		}
+	}
+
+	var optionNames []string
+	for _, opt := range msKeyValues.Key.Options {
+		optionNames = append(optionNames, opt.Name)
+	}
+	for _, v := range msKeyValues.Values {
+		for _, opt := range v.MSelect {
+			optionNames = append(optionNames, opt.Content)
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	author := getAttributeViewCurrentAuthor()
+	for _, name := range gulu.Str.RemoveDuplicatedElem(optionNames) {
+		if "" == name {
+			continue
+		}
+		if _, ok := optionBlockIDs[name]; ok {
+			continue
+		}
+
+		blockID := ast.NewNodeID()
+		destBlockValues.Values = append(destBlockValues.Values, &av.Value{
+			ID: ast.NewNodeID(), KeyID: destBlockValues.Key.ID, BlockID: blockID, Type: av.KeyTypeBlock, IsDetached: true,
+			Block: &av.ValueBlock{ID: blockID, Content: name, Created: now, Updated: now, CreatedBy: author, UpdatedBy: author},
+		})
+		optionBlockIDs[name] = blockID
+		for _, view := range destAv.Views {
+			if nil != view.Table && 0 < len(view.Table.RowIDs) {
+				view.Table.RowIDs = append(view.Table.RowIDs, blockID)
+			}
+		}
+	}
+
+	// 将多选列替换为关联列
+	backKey := &av.Key{ID: ast.NewNodeID(), Name: strings.TrimSpace(srcAv.Name + " " + msKeyValues.Key.Name), Type: av.KeyTypeRelation}
+	relKey := msKeyValues.Key
+	relKey.Type = av.KeyTypeRelation
+	relKey.Options = nil
+	relKey.Relation = &av.Relation{AvID: destAv.ID, IsTwoWay: true, BackKeyID: backKey.ID}
+	backKey.Relation = &av.Relation{AvID: srcAv.ID, IsTwoWay: true, BackKeyID: relKey.ID}
+	backKeyValues := &av.KeyValues{Key: backKey}
+	destAv.KeyValues = append(destAv.KeyValues, backKeyValues)
+	for _, view := range destAv.Views {
+		switch view.LayoutType {
 		case av.LayoutTypeTable:
-			for i, rowID := range v.Table.RowIDs {
-				if rowID == operation.PreviousID {
-					v.Table.RowIDs[i] = operation.NextID
-					replacedRowID = true
-					break
-				}
+			view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: backKey.ID})
+		}
+	}
+
+	for _, v := range msKeyValues.Values {
+		var blockIDs []string
+		for _, opt := range v.MSelect {
+			if blockID := optionBlockIDs[opt.Content]; "" != blockID {
+				blockIDs = append(blockIDs, blockID)
 			}
+		}
+		blockIDs = gulu.Str.RemoveDuplicatedElem(blockIDs)
+		v.Type = av.KeyTypeRelation
+		v.MSelect = nil
+		v.Relation = &av.ValueRelation{BlockIDs: blockIDs}
+
+		for _, blockID := range blockIDs {
+			backVal := backKeyValues.GetValue(blockID)
+			if nil == backVal {
+				backVal = &av.Value{ID: ast.NewNodeID(), KeyID: backKey.ID, BlockID: blockID, Type: av.KeyTypeRelation, Relation: &av.ValueRelation{}}
+				backKeyValues.Values = append(backKeyValues.Values, backVal)
+			}
+			backVal.Relation.BlockIDs = append(backVal.Relation.BlockIDs, v.BlockID)
+		}
+	}
 
-			if !replacedRowID {
-				v.Table.RowIDs = append(v.Table.RowIDs, operation.NextID)
+	for _, view := range srcAv.Views {
+		if nil == view.Table {
+			continue
+		}
+
+		// 多选列的过滤、排序规则和计算不再适用
+		filters := []*av.ViewFilter{}
+		for _, f := range view.Table.Filters {
+			if f.Column != relKey.ID {
+				filters = append(filters, f)
+			}
+		}
+		view.Table.Filters = filters
+
+		sorts := []*av.ViewSort{}
+		for _, s := range view.Table.Sorts {
+			if s.Column != relKey.ID {
+				sorts = append(sorts, s)
+			}
+		}
+		view.Table.Sorts = sorts
+
+		for _, column := range view.Table.Columns {
+			if column.ID == relKey.ID {
+				column.Calc = nil
 			}
 		}
 	}
 
-	err = av.SaveAttributeView(attrView)
+	if err = av.SaveAttributeView(srcAv); nil != err {
+		return
+	}
+	if !isSameAv {
+		if err = av.SaveAttributeView(destAv); nil != err {
+			return
+		}
+		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": destAv.ID})
+	}
+
+	av.UpsertAvBackRel(srcAv.ID, destAv.ID)
+	av.UpsertAvBackRel(destAv.ID, srcAv.ID)
+	relKeyID = relKey.ID
 	return
 }
 
-func (tx *Transaction) doUpdateAttrViewCell(operation *Operation) (ret *TxErr) {
-	err := updateAttributeViewCell(operation, tx)
+// FindAttributeViewDuplicateRows 查找属性视图中列 keyID 值相同的重复行，返回除每组第一行以外的行 ID。
+// 空值不视为重复，该函数不会修改属性视图，可用于去重前的预览。
+func FindAttributeViewDuplicateRows(avID, keyID string) (duplicates []string, err error) {
+	attrView, err := av.ParseAttributeView(avID)
 	if nil != err {
-		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+		return
+	}
+
+	keyValues, err := attrView.GetKeyValues(keyID)
+	if nil != err {
+		return
+	}
+
+	duplicates = []string{}
+	seen := map[string]bool{}
+	for _, value := range keyValues.Values {
+		content := strings.TrimSpace(value.String())
+		if "" == content {
+			continue
+		}
+
+		if seen[content] {
+			duplicates = append(duplicates, value.BlockID)
+			continue
+		}
+		seen[content] = true
 	}
 	return
 }
 
-func updateAttributeViewCell(operation *Operation, tx *Transaction) (err error) {
-	err = UpdateAttributeViewCell(tx, operation.AvID, operation.KeyID, operation.RowID, operation.ID, operation.Data)
+// DeduplicateAttributeViewRows 移除属性视图中列 keyID 值相同的重复行，每组仅保留第一行，返回移除的行数。
+func DeduplicateAttributeViewRows(avID, keyID string) (removed int, err error) {
+	duplicates, err := FindAttributeViewDuplicateRows(avID, keyID)
+	if nil != err || 1 > len(duplicates) {
+		return
+	}
+
+	if err = removeAttributeViewBlock(nil, &Operation{AvID: avID, SrcIDs: duplicates}); nil != err {
+		logging.LogErrorf("remove duplicate rows from attribute view [%s] failed: %s", avID, err)
+		return
+	}
+
+	removed = len(duplicates)
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
 	return
 }
 
-func UpdateAttributeViewCell(tx *Transaction, avID, keyID, rowID, cellID string, valueData interface{}) (err error) {
+// RecomputeAttributeView 订正属性视图 avID 的数据并重新计算计算列，用于批量修改或者数据迁移之后的修复，多次调用的结果一致：
+//   - 移除没有对应行的单元格
+//   - 移除关联列中目标属性视图已经不存在的块，并刷新关联内容快照
+//   - 移除视图中已经不存在的行 ID（自定义排序、置顶行和行颜色）
+//
+// 订正后只保存一次，开启了 CacheComputedCols 时会重新渲染所有视图刷新计算列缓存。
+func RecomputeAttributeView(avID string) (err error) {
 	attrView, err := av.ParseAttributeView(avID)
 	if nil != err {
 		return
 	}
 
-	var blockVal *av.Value
-	for _, kv := range attrView.KeyValues {
-		if av.KeyTypeBlock == kv.Key.Type {
-			for _, v := range kv.Values {
-				if rowID == v.Block.ID {
-					blockVal = v
-					break
-				}
-			}
-			break
+	rowIDs := map[string]bool{}
+	if blockKeyValues := attrView.GetBlockKeyValues(); nil != blockKeyValues {
+		for _, value := range blockKeyValues.Values {
+			rowIDs[value.BlockID] = true
 		}
 	}
 
-	var val *av.Value
-	oldIsDetached := true
-	if nil != blockVal {
-		oldIsDetached = blockVal.IsDetached
-	}
+	destAvs := map[string]*av.AttributeView{attrView.ID: attrView}
 	for _, keyValues := range attrView.KeyValues {
-		if keyID != keyValues.Key.ID {
+		if av.KeyTypeBlock == keyValues.Key.Type {
 			continue
 		}
 
+		tmp := keyValues.Values[:0]
 		for _, value := range keyValues.Values {
-			if cellID == value.ID {
-				val = value
-				val.Type = keyValues.Key.Type
-				break
+			if rowIDs[value.BlockID] {
+				tmp = append(tmp, value)
 			}
 		}
+		keyValues.Values = tmp
 
-		if nil == val {
-			val = &av.Value{ID: cellID, KeyID: keyValues.Key.ID, BlockID: rowID, Type: keyValues.Key.Type}
-			keyValues.Values = append(keyValues.Values, val)
+		if av.KeyTypeRelation != keyValues.Key.Type || nil == keyValues.Key.Relation {
+			continue
 		}
-		break
-	}
 
-	isUpdatingBlockKey := av.KeyTypeBlock == val.Type
-	oldBoundBlockID := val.BlockID
-	var oldRelationBlockIDs []string
-	if av.KeyTypeRelation == val.Type {
-		if nil != val.Relation {
-			for _, bID := range val.Relation.BlockIDs {
-				oldRelationBlockIDs = append(oldRelationBlockIDs, bID)
-			}
+		destAvID := keyValues.Key.Relation.AvID
+		destAv, ok := destAvs[destAvID]
+		if !ok {
+			destAv, _ = av.ParseAttributeView(destAvID)
+			destAvs[destAvID] = destAv
+		}
+		if nil == destAv {
+			// 目标属性视图不存在时保留关联列的值，避免误删
+			continue
 		}
-	}
-	data, err := gulu.JSON.MarshalJSON(valueData)
-	if nil != err {
-		return
-	}
-	if err = gulu.JSON.UnmarshalJSON(data, &val); nil != err {
-		return
-	}
-	relationChangeMode := 0 // 0：不变（仅排序），1：增加，2：减少
-	if av.KeyTypeRelation == val.Type {
-		// 关联列得 content 是自动渲染的，所以不需要保存
-		val.Relation.Contents = nil
 
-		// 计算关联变更模式
-		if len(oldRelationBlockIDs) == len(val.Relation.BlockIDs) {
-			relationChangeMode = 0
-		} else {
-			if len(oldRelationBlockIDs) > len(val.Relation.BlockIDs) {
-				relationChangeMode = 2
-			} else {
-				relationChangeMode = 1
+		destBlockKeyValues := destAv.GetBlockKeyValues()
+		if nil == destBlockKeyValues {
+			continue
+		}
+		for _, value := range keyValues.Values {
+			if nil == value.Relation {
+				continue
+			}
+
+			blockIDs, contents := []string{}, []string{}
+			for _, blockID := range value.Relation.BlockIDs {
+				destVal := destBlockKeyValues.GetValue(blockID)
+				if nil == destVal {
+					continue
+				}
+				blockIDs = append(blockIDs, blockID)
+				contents = append(contents, destVal.String())
 			}
+			value.Relation.BlockIDs, value.Relation.Contents = blockIDs, contents
 		}
 	}
 
-	// val.IsDetached 只有更新主键的时候才会传入，所以下面需要结合 isUpdatingBlockKey 来判断
-
-	if oldIsDetached { // 之前是游离行
-		if !val.IsDetached { // 现在绑定了块
-			// 将游离行绑定到新建的块上
-			bindBlockAv(tx, avID, rowID)
+	for _, view := range attrView.Views {
+		if nil == view.Table {
+			continue
 		}
-	} else { // 之前绑定了块
-		if isUpdatingBlockKey { // 正在更新主键
-			if val.IsDetached { // 现在是游离行
-				// 将绑定的块从属性视图中移除
-				unbindBlockAv(tx, avID, rowID)
-			} else { // 现在绑定了块
-				if oldBoundBlockID != val.BlockID { // 之前绑定的块和现在绑定的块不一样
-					// 换绑块
-					unbindBlockAv(tx, avID, oldBoundBlockID)
-					bindBlockAv(tx, avID, val.BlockID)
-				} else { // 之前绑定的块和现在绑定的块一样
-					// 直接返回，因为锚文本不允许更改
-					return
-				}
+
+		view.Table.RowIDs = existingAttributeViewRowIDs(view.Table.RowIDs, rowIDs)
+		view.Table.TopRowIDs = existingAttributeViewRowIDs(view.Table.TopRowIDs, rowIDs)
+		for rowID := range view.Table.RowColors {
+			if !rowIDs[rowID] {
+				delete(view.Table.RowColors, rowID)
 			}
 		}
 	}
 
-	if nil != blockVal {
-		blockVal.Block.Updated = time.Now().UnixMilli()
-		if isUpdatingBlockKey {
-			blockVal.IsDetached = val.IsDetached
-		}
+	if err = av.SaveAttributeView(attrView); nil != err {
+		return
 	}
 
-	key, _ := attrView.GetKey(val.KeyID)
-	if nil != key && av.KeyTypeRelation == key.Type && nil != key.Relation {
-		destAv, _ := av.ParseAttributeView(key.Relation.AvID)
-		if nil != destAv {
-			if key.Relation.IsTwoWay {
-				// relationChangeMode
-				// 0：关联列值不变（仅排序），不影响目标值
-				// 1：关联列值增加，增加目标值
-				// 2：关联列值减少，减少目标值
+	if attrView.CacheComputedCols {
+		for _, view := range attrView.Views {
+			if av.LayoutTypeTable != view.LayoutType {
+				continue
+			}
 
-				if 1 == relationChangeMode {
-					addBlockIDs := val.Relation.BlockIDs
-					for _, bID := range oldRelationBlockIDs {
-						addBlockIDs = gulu.Str.RemoveElem(addBlockIDs, bID)
-					}
+			table, renderErr := renderAttributeViewTable(attrView, view, nil)
+			if nil != renderErr {
+				logging.LogErrorf("render attribute view [%s] view [%s] failed: %s", avID, view.ID, renderErr)
+				continue
+			}
+			table.FilterRows(attrView)
+			table.SortRows()
+			renderAttributeViewOrderedCols(attrView, table)
+		}
+	}
 
-					for _, blockID := range addBlockIDs {
-						for _, keyValues := range destAv.KeyValues {
-							if keyValues.Key.ID != key.Relation.BackKeyID {
-								continue
-							}
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+	return
+}
 
-							destVal := keyValues.GetValue(blockID)
-							if nil == destVal {
-								destVal = &av.Value{ID: ast.NewNodeID(), KeyID: keyValues.Key.ID, BlockID: blockID, Type: keyValues.Key.Type, Relation: &av.ValueRelation{}}
-								keyValues.Values = append(keyValues.Values, destVal)
-							}
+// existingAttributeViewRowIDs 返回 ids 中在 rowIDs 中存在的行 ID，并移除重复的行 ID。
+func existingAttributeViewRowIDs(ids []string, rowIDs map[string]bool) (ret []string) {
+	if nil == ids {
+		return
+	}
 
-							destVal.Relation.BlockIDs = append(destVal.Relation.BlockIDs, rowID)
-							destVal.Relation.BlockIDs = gulu.Str.RemoveDuplicatedElem(destVal.Relation.BlockIDs)
-							break
-						}
-					}
-				} else if 2 == relationChangeMode {
-					removeBlockIDs := oldRelationBlockIDs
-					for _, bID := range val.Relation.BlockIDs {
-						removeBlockIDs = gulu.Str.RemoveElem(removeBlockIDs, bID)
-					}
+	ret = []string{}
+	for _, id := range ids {
+		if rowIDs[id] && !gulu.Str.Contains(id, ret) {
+			ret = append(ret, id)
+		}
+	}
+	return
+}
 
-					for _, blockID := range removeBlockIDs {
-						for _, keyValues := range destAv.KeyValues {
-							if keyValues.Key.ID != key.Relation.BackKeyID {
-								continue
-							}
+// ApplyFilterAsDeletion 将视图 viewID 的过滤条件作为保留条件，永久删除整个属性视图中不满足过滤条件的行，返回保留和删除的行数。
+// dryRun 为 true 时仅统计行数不删除，删除操作不可撤销，调用方应该先使用 dryRun 向用户确认。
+func ApplyFilterAsDeletion(avID, viewID string, dryRun bool) (kept, removed int, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
 
-							for _, value := range keyValues.Values {
-								if value.BlockID == blockID {
-									value.Relation.BlockIDs = gulu.Str.RemoveElem(value.Relation.BlockIDs, rowID)
-									break
-								}
-							}
-						}
-					}
-				}
+	view := attrView.GetView(viewID)
+	if nil == view {
+		err = av.ErrViewNotFound
+		return
+	}
+	if av.LayoutTypeTable != view.LayoutType || nil == view.Table {
+		err = fmt.Errorf("view [%s] is not a table view", viewID)
+		return
+	}
 
-				av.SaveAttributeView(destAv)
-			}
+	table, err := renderAttributeViewTable(attrView, view, nil)
+	if nil != err {
+		return
+	}
+	table.FilterRows(attrView)
+
+	keepRowIDs := map[string]bool{}
+	for _, row := range table.Rows {
+		keepRowIDs[row.ID] = true
+	}
+
+	var removeRowIDs []string
+	for _, blockValue := range attrView.GetBlockKeyValues().Values {
+		if !keepRowIDs[blockValue.BlockID] {
+			removeRowIDs = append(removeRowIDs, blockValue.BlockID)
 		}
 	}
 
-	relatedAvIDs := av.GetSrcAvIDs(avID)
-	for _, relatedAvID := range relatedAvIDs {
-		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": relatedAvID})
+	kept, removed = len(attrView.GetBlockKeyValues().Values)-len(removeRowIDs), len(removeRowIDs)
+	if dryRun || 1 > removed {
+		return
 	}
 
-	if err = av.SaveAttributeView(attrView); nil != err {
+	if err = removeAttributeViewBlock(nil, &Operation{AvID: avID, SrcIDs: removeRowIDs}); nil != err {
+		logging.LogErrorf("remove filtered rows from attribute view [%s] failed: %s", avID, err)
 		return
 	}
+
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
 	return
 }
 
-func unbindBlockAv(tx *Transaction, avID, blockID string) {
-	node, tree, err := getNodeByBlockID(tx, blockID)
+// ColumnStats 描述了属性视图某一列在视图过滤后的统计信息。
+type ColumnStats struct {
+	Count         int `json:"count"`         // 行数
+	EmptyCount    int `json:"emptyCount"`    // 空值行数
+	DistinctCount int `json:"distinctCount"` // 不同非空值的数量
+
+	// 以下仅用于数字列和日期列，日期列使用毫秒时间戳，没有非空值时为 nil
+	Min *float64 `json:"min,omitempty"` // 最小值
+	Max *float64 `json:"max,omitempty"` // 最大值
+	Avg *float64 `json:"avg,omitempty"` // 平均值
+
+	TopOptions []*OptionFrequency `json:"topOptions,omitempty"` // 出现次数最多的选项，仅用于单选列和多选列
+}
+
+// OptionFrequency 描述了选项在列中出现的次数。
+type OptionFrequency struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Count int    `json:"count"`
+}
+
+const columnStatsTopOptionsLimit = 5
+
+// GetAttributeViewColumnStats 统计视图 viewID 过滤后列 keyID 的行数、空值数、不同值数量、数字和日期的最小值/最大值/平均值以及选项频次。
+func GetAttributeViewColumnStats(avID, viewID, keyID string) (ret *ColumnStats, err error) {
+	attrView, err := av.ParseAttributeView(avID)
 	if nil != err {
 		return
 	}
 
-	attrs := parse.IAL2Map(node.KramdownIAL)
-	if "" == attrs[av.NodeAttrNameAvs] {
+	view := attrView.GetView(viewID)
+	if nil == view {
+		err = av.ErrViewNotFound
+		return
+	}
+	if av.LayoutTypeTable != view.LayoutType || nil == view.Table {
+		err = fmt.Errorf("view [%s] is not a table view", viewID)
 		return
 	}
 
-	avIDs := strings.Split(attrs[av.NodeAttrNameAvs], ",")
-	avIDs = gulu.Str.RemoveElem(avIDs, avID)
-	if 0 == len(avIDs) {
-		delete(attrs, av.NodeAttrNameAvs)
-		node.RemoveIALAttr(av.NodeAttrNameAvs)
-	} else {
-		attrs[av.NodeAttrNameAvs] = strings.Join(avIDs, ",")
-		node.SetIALAttr(av.NodeAttrNameAvs, strings.Join(avIDs, ","))
+	table, err := renderAttributeViewTable(attrView, view, nil)
+	if nil != err {
+		return
 	}
+	table.FilterRows(attrView)
+	table.FilterRowsByBindingState()
+	renderAttributeViewOrderedCols(attrView, table)
 
-	if nil != tx {
-		err = setNodeAttrsWithTx(tx, node, tree, attrs)
-	} else {
-		err = setNodeAttrs(node, tree, attrs)
+	colIndex := -1
+	for i, col := range table.Columns {
+		if col.ID == keyID {
+			colIndex = i
+			break
+		}
 	}
-	if nil != err {
-		logging.LogWarnf("set node [%s] attrs failed: %s", blockID, err)
+	if 0 > colIndex {
+		err = av.ErrKeyNotFound
 		return
 	}
+
+	col := table.Columns[colIndex]
+	ret = &ColumnStats{Count: len(table.Rows)}
+	distinct := map[string]bool{}
+	var numbers []float64
+	options := map[string]*OptionFrequency{}
+	for _, row := range table.Rows {
+		val := row.Cells[colIndex].Value
+		if nil == val {
+			ret.EmptyCount++
+			continue
+		}
+
+		switch col.Type.BaseType() {
+		case av.KeyTypeNumber:
+			if nil != val.Number && val.Number.IsNotEmpty {
+				numbers = append(numbers, val.Number.Content)
+			}
+		case av.KeyTypeDate:
+			if nil != val.Date && val.Date.IsNotEmpty {
+				numbers = append(numbers, float64(val.Date.Content))
+			}
+		case av.KeyTypeCreated:
+			if nil != val.Created && val.Created.IsNotEmpty {
+				numbers = append(numbers, float64(val.Created.Content))
+			}
+		case av.KeyTypeUpdated:
+			if nil != val.Updated && val.Updated.IsNotEmpty {
+				numbers = append(numbers, float64(val.Updated.Content))
+			}
+		case av.KeyTypeSelect, av.KeyTypeMSelect:
+			for _, opt := range val.MSelect {
+				if "" == opt.Content {
+					continue
+				}
+				if nil == options[opt.Content] {
+					options[opt.Content] = &OptionFrequency{Name: opt.Content, Color: opt.Color}
+				}
+				options[opt.Content].Count++
+			}
+		}
+
+		content := val.String()
+		if "" == content {
+			ret.EmptyCount++
+			continue
+		}
+		distinct[content] = true
+	}
+	ret.DistinctCount = len(distinct)
+
+	if 0 < len(numbers) {
+		min, max, sum := numbers[0], numbers[0], 0.0
+		for _, n := range numbers {
+			min, max, sum = math.Min(min, n), math.Max(max, n), sum+n
+		}
+		avg := sum / float64(len(numbers))
+		ret.Min, ret.Max, ret.Avg = &min, &max, &avg
+	}
+
+	for _, opt := range options {
+		ret.TopOptions = append(ret.TopOptions, opt)
+	}
+	sort.Slice(ret.TopOptions, func(i, j int) bool {
+		if ret.TopOptions[i].Count != ret.TopOptions[j].Count {
+			return ret.TopOptions[i].Count > ret.TopOptions[j].Count
+		}
+		return ret.TopOptions[i].Name < ret.TopOptions[j].Name
+	})
+	if columnStatsTopOptionsLimit < len(ret.TopOptions) {
+		ret.TopOptions = ret.TopOptions[:columnStatsTopOptionsLimit]
+	}
 	return
 }
 
-func bindBlockAv(tx *Transaction, avID, blockID string) {
-	node, tree, err := getNodeByBlockID(tx, blockID)
+// GetAttributeViewDistinctValues 返回视图 viewID 过滤后列 keyID 中出现过的不同值（渲染后的文本，已排序），用于构建过滤条件选择器。
+// 关联列返回关联块的内容，单选和多选列返回选项名，空值会被忽略。
+func GetAttributeViewDistinctValues(avID, viewID, keyID string) (ret []string, err error) {
+	attrView, err := av.ParseAttributeView(avID)
 	if nil != err {
 		return
 	}
 
-	attrs := parse.IAL2Map(node.KramdownIAL)
-	if "" == attrs[av.NodeAttrNameAvs] {
-		attrs[av.NodeAttrNameAvs] = avID
-	} else {
-		avIDs := strings.Split(attrs[av.NodeAttrNameAvs], ",")
-		avIDs = append(avIDs, avID)
-		avIDs = gulu.Str.RemoveDuplicatedElem(avIDs)
-		attrs[av.NodeAttrNameAvs] = strings.Join(avIDs, ",")
+	view := attrView.GetView(viewID)
+	if nil == view {
+		err = av.ErrViewNotFound
+		return
 	}
-
-	if nil != tx {
-		err = setNodeAttrsWithTx(tx, node, tree, attrs)
-	} else {
-		err = setNodeAttrs(node, tree, attrs)
+	if av.LayoutTypeTable != view.LayoutType || nil == view.Table {
+		err = fmt.Errorf("view [%s] is not a table view", viewID)
+		return
 	}
+
+	table, err := renderAttributeViewTable(attrView, view, nil)
 	if nil != err {
-		logging.LogWarnf("set node [%s] attrs failed: %s", blockID, err)
 		return
 	}
-	return
-}
+	table.FilterRows(attrView)
+	table.FilterRowsByBindingState()
+	renderAttributeViewOrderedCols(attrView, table)
 
-func getNodeByBlockID(tx *Transaction, blockID string) (node *ast.Node, tree *parse.Tree, err error) {
-	if nil != tx {
-		tree, err = tx.loadTree(blockID)
-	} else {
-		tree, err = loadTreeByBlockID(blockID)
+	colIndex := -1
+	for i, col := range table.Columns {
+		if col.ID == keyID {
+			colIndex = i
+			break
+		}
 	}
-	if nil != err {
+	if 0 > colIndex {
+		err = av.ErrKeyNotFound
 		return
 	}
-	node = treenode.GetNodeInTree(tree, blockID)
-	if nil == node {
-		logging.LogWarnf("node [%s] not found in tree [%s]", blockID, tree.ID)
-		return
+
+	distinct := map[string]bool{}
+	for _, row := range table.Rows {
+		val := row.Cells[colIndex].Value
+		if nil == val {
+			continue
+		}
+
+		var contents []string
+		switch table.Columns[colIndex].Type {
+		case av.KeyTypeRelation:
+			if nil != val.Relation {
+				contents = val.Relation.Contents
+			}
+		case av.KeyTypeSelect, av.KeyTypeMSelect:
+			contents = selectContents(val.MSelect)
+		default:
+			contents = []string{val.String()}
+		}
+
+		for _, content := range contents {
+			if content = strings.TrimSpace(content); "" != content {
+				distinct[content] = true
+			}
+		}
 	}
-	return
-}
 
-func (tx *Transaction) doUpdateAttrViewColOptions(operation *Operation) (ret *TxErr) {
-	err := updateAttributeViewColumnOptions(operation)
-	if nil != err {
-		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	ret = []string{}
+	for content := range distinct {
+		ret = append(ret, content)
 	}
+	sort.Strings(ret)
 	return
 }
 
-func updateAttributeViewColumnOptions(operation *Operation) (err error) {
-	attrView, err := av.ParseAttributeView(operation.AvID)
+// PreviewAttributeViewTemplate 使用行 rowID 的值渲染模板 tplContent，不会保存属性视图，用于编辑模板列时实时预览。
+func PreviewAttributeViewTemplate(avID, rowID, tplContent string) (rendered string, err error) {
+	attrView, err := av.ParseAttributeView(avID)
 	if nil != err {
 		return
 	}
 
-	jsonData, err := gulu.JSON.MarshalJSON(operation.Data)
-	if nil != err {
+	rowValues := getAttributeViewRowValues(attrView, rowID)
+	block := getRowBlockValue(rowValues)
+	if nil == block {
+		err = fmt.Errorf("row [%s] not found in attribute view [%s]", rowID, avID)
 		return
 	}
 
-	options := []*av.SelectOption{}
-	if err = gulu.JSON.UnmarshalJSON(jsonData, &options); nil != err {
-		return
+	ial := map[string]string{}
+	if !block.IsDetached {
+		ial = GetBlockAttrsWithoutWaitWriting(rowID)
 	}
+	rendered, err = renderTemplateCol0(ial, tplContent, rowValues, 0, 0)
+	return
+}
 
+// getAttributeViewRowValues 返回行 rowID 在各列中的值，没有值的列会被跳过。
+func getAttributeViewRowValues(attrView *av.AttributeView, rowID string) (ret []*av.KeyValues) {
 	for _, keyValues := range attrView.KeyValues {
-		if keyValues.Key.ID == operation.ID {
-			keyValues.Key.Options = options
-			err = av.SaveAttributeView(attrView)
-			return
+		if val := keyValues.GetValue(rowID); nil != val {
+			ret = append(ret, &av.KeyValues{Key: keyValues.Key, Values: []*av.Value{val}})
 		}
 	}
 	return
 }
 
-func (tx *Transaction) doRemoveAttrViewColOption(operation *Operation) (ret *TxErr) {
-	err := removeAttributeViewColumnOption(operation)
+// LinkRelationsByText 按文本匹配建立关联：对源属性视图的每一行，将文本列 textKeyID 的内容与目标属性视图 destAvID 的主键内容精确匹配，
+// 并将匹配到的所有目标块追加到关联列 relKeyID 中。关联列尚未设置目标或者是指向 destAvID 的单向关联时会被设置为双向关联。
+// 返回建立了关联的行数和没有匹配到目标块的行数，空文本不参与匹配。
+func LinkRelationsByText(srcAvID, textKeyID, relKeyID, destAvID string) (linked, unmatched int, err error) {
+	srcAv, err := av.ParseAttributeView(srcAvID)
 	if nil != err {
-		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+		return
 	}
-	return
-}
 
-func removeAttributeViewColumnOption(operation *Operation) (err error) {
-	attrView, err := av.ParseAttributeView(operation.AvID)
+	destAv := srcAv
+	isSameAv := srcAvID == destAvID
+	if !isSameAv {
+		if destAv, err = av.ParseAttributeView(destAvID); nil != err {
+			return
+		}
+	}
+
+	textKeyValues, err := srcAv.GetKeyValues(textKeyID)
 	if nil != err {
 		return
 	}
+	if av.KeyTypeText != textKeyValues.Key.Type {
+		err = fmt.Errorf("key [%s] is not a text key", textKeyID)
+		return
+	}
 
-	optName := operation.Data.(string)
-
-	key, err := attrView.GetKey(operation.ID)
+	relKeyValues, err := srcAv.GetKeyValues(relKeyID)
 	if nil != err {
 		return
 	}
-
-	for i, opt := range key.Options {
-		if optName == opt.Name {
-			key.Options = append(key.Options[:i], key.Options[i+1:]...)
-			break
-		}
+	relKey := relKeyValues.Key
+	if av.KeyTypeRelation != relKey.Type {
+		err = fmt.Errorf("key [%s] is not a relation key", relKeyID)
+		return
+	}
+	if nil != relKey.Relation && "" != relKey.Relation.AvID && destAvID != relKey.Relation.AvID {
+		err = fmt.Errorf("relation key [%s] is related to attribute view [%s]", relKeyID, relKey.Relation.AvID)
+		return
 	}
 
-	for _, keyValues := range attrView.KeyValues {
-		if keyValues.Key.ID != operation.ID {
-			continue
+	if nil == relKey.Relation || !relKey.Relation.IsTwoWay {
+		// 创建回链关联列，并补全已有关联的回链
+		if err = checkAttributeViewKeyTypeAllowed(av.KeyTypeRelation); nil != err {
+			return
+		}
+		backKey := &av.Key{ID: ast.NewNodeID(), Name: strings.TrimSpace(srcAv.Name + " " + relKey.Name), Type: av.KeyTypeRelation}
+		relKey.Relation = &av.Relation{AvID: destAvID, IsTwoWay: true, BackKeyID: backKey.ID}
+		backKey.Relation = &av.Relation{AvID: srcAvID, IsTwoWay: true, BackKeyID: relKey.ID}
+		backKeyValues := &av.KeyValues{Key: backKey}
+		destAv.KeyValues = append(destAv.KeyValues, backKeyValues)
+		for _, view := range destAv.Views {
+			switch view.LayoutType {
+			case av.LayoutTypeTable:
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: backKey.ID})
+			}
 		}
 
-		for _, value := range keyValues.Values {
-			if nil == value || nil == value.MSelect {
+		for _, v := range relKeyValues.Values {
+			if nil == v.Relation {
 				continue
 			}
 
-			for i, opt := range value.MSelect {
-				if optName == opt.Content {
-					value.MSelect = append(value.MSelect[:i], value.MSelect[i+1:]...)
-					break
+			for _, blockID := range v.Relation.BlockIDs {
+				backVal := backKeyValues.GetValue(blockID)
+				if nil == backVal {
+					backVal = &av.Value{ID: ast.NewNodeID(), KeyID: backKey.ID, BlockID: blockID, Type: av.KeyTypeRelation, Relation: &av.ValueRelation{}}
+					backKeyValues.Values = append(backKeyValues.Values, backVal)
 				}
+				backVal.Relation.BlockIDs = gulu.Str.RemoveDuplicatedElem(append(backVal.Relation.BlockIDs, v.BlockID))
 			}
 		}
-		break
+
+		if err = av.SaveAttributeView(srcAv); nil != err {
+			return
+		}
+		if !isSameAv {
+			if err = av.SaveAttributeView(destAv); nil != err {
+				return
+			}
+		}
+		av.UpsertAvBackRel(srcAv.ID, destAv.ID)
+		av.UpsertAvBackRel(destAv.ID, srcAv.ID)
 	}
 
-	err = av.SaveAttributeView(attrView)
-	return
-}
+	// 主键内容 -> 目标块 ID 列表
+	destBlockIDs := map[string][]string{}
+	for _, v := range destAv.GetBlockKeyValues().Values {
+		if nil != v.Block && "" != v.Block.Content {
+			destBlockIDs[v.Block.Content] = append(destBlockIDs[v.Block.Content], v.BlockID)
+		}
+	}
 
-func (tx *Transaction) doUpdateAttrViewColOption(operation *Operation) (ret *TxErr) {
-	err := updateAttributeViewColumnOption(operation)
-	if nil != err {
-		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	srcRows := srcAv.GetBlockKeyValues()
+	links := map[string][]string{}
+	var unmatchedTexts []string
+	for _, v := range textKeyValues.Values {
+		if nil == v.Text || "" == strings.TrimSpace(v.Text.Content) || nil == srcRows.GetValue(v.BlockID) {
+			continue
+		}
+
+		blockIDs := destBlockIDs[v.Text.Content]
+		if 1 > len(blockIDs) {
+			unmatched++
+			unmatchedTexts = append(unmatchedTexts, v.Text.Content)
+			continue
+		}
+		links[v.BlockID] = blockIDs
+		linked++
 	}
+	if 0 < unmatched {
+		logging.LogWarnf("unmatched [%d] texts when linking attribute view [%s] key [%s] to [%s]: %s", unmatched, srcAvID, textKeyID, destAvID, strings.Join(unmatchedTexts, ", "))
+	}
+
+	err = LinkAttributeViewRelations(srcAvID, relKeyID, links)
 	return
 }
 
-func updateAttributeViewColumnOption(operation *Operation) (err error) {
-	attrView, err := av.ParseAttributeView(operation.AvID)
+// LinkAttributeViewRelations 批量建立关联，links 为源行 ID 到目标块 ID 列表的映射，新的关联会追加到已有关联之后。
+// 不存在的源行和目标块会被跳过，超出关联列 MaxEntries 限制的关联以及涉及被锁定单元格的关联也会被跳过。双向关联时同时更新目标属性视图的回链关联列。
+func LinkAttributeViewRelations(srcAvID, relKeyID string, links map[string][]string) (err error) {
+	srcAv, err := av.ParseAttributeView(srcAvID)
 	if nil != err {
 		return
 	}
 
-	key, err := attrView.GetKey(operation.ID)
+	relKeyValues, err := srcAv.GetKeyValues(relKeyID)
 	if nil != err {
 		return
 	}
 
-	data := operation.Data.(map[string]interface{})
+	relKey := relKeyValues.Key
+	if av.KeyTypeRelation != relKey.Type || nil == relKey.Relation {
+		err = fmt.Errorf("key [%s] is not a relation key", relKeyID)
+		return
+	}
 
-	oldName := data["oldName"].(string)
-	newName := data["newName"].(string)
-	newColor := data["newColor"].(string)
+	destAv := srcAv
+	isSameAv := srcAv.ID == relKey.Relation.AvID
+	if !isSameAv {
+		if destAv, err = av.ParseAttributeView(relKey.Relation.AvID); nil != err {
+			return
+		}
+	}
 
-	for i, opt := range key.Options {
-		if oldName == opt.Name {
-			key.Options[i].Name = newName
-			key.Options[i].Color = newColor
-			break
+	var backKeyValues *av.KeyValues
+	if relKey.Relation.IsTwoWay {
+		if backKeyValues, err = destAv.GetKeyValues(relKey.Relation.BackKeyID); nil != err {
+			return
 		}
 	}
 
-	for _, keyValues := range attrView.KeyValues {
-		if keyValues.Key.ID != operation.ID {
+	srcRows := srcAv.GetBlockKeyValues()
+	destRows := destAv.GetBlockKeyValues()
+	var rowIDs []string
+	for rowID := range links {
+		rowIDs = append(rowIDs, rowID)
+	}
+	sort.Strings(rowIDs)
+
+	skipped := 0
+	for _, rowID := range rowIDs {
+		if nil == srcRows.GetValue(rowID) || nil != checkAttributeViewCellLocked(srcAv, relKey.ID, rowID) {
+			skipped += len(links[rowID])
 			continue
 		}
 
-		for _, value := range keyValues.Values {
-			if nil == value || nil == value.MSelect {
+		val := relKeyValues.GetValue(rowID)
+		if nil == val {
+			val = &av.Value{ID: ast.NewNodeID(), KeyID: relKey.ID, BlockID: rowID, Type: av.KeyTypeRelation, Relation: &av.ValueRelation{}}
+			relKeyValues.Values = append(relKeyValues.Values, val)
+		}
+		if nil == val.Relation {
+			val.Relation = &av.ValueRelation{}
+		}
+
+		changed := false
+		for _, destBlockID := range links[rowID] {
+			if nil == destRows.GetValue(destBlockID) {
+				skipped++
+				continue
+			}
+			if gulu.Str.Contains(destBlockID, val.Relation.BlockIDs) {
+				continue
+			}
+			if 0 < relKey.Relation.MaxEntries && relKey.Relation.MaxEntries <= len(val.Relation.BlockIDs) {
+				skipped++
+				continue
+			}
+			if nil != backKeyValues && nil != checkAttributeViewCellLocked(destAv, backKeyValues.Key.ID, destBlockID) {
+				skipped++
 				continue
 			}
 
-			for i, opt := range value.MSelect {
-				if oldName == opt.Content {
-					value.MSelect[i].Content = newName
-					value.MSelect[i].Color = newColor
-					break
+			val.Relation.BlockIDs = append(val.Relation.BlockIDs, destBlockID)
+			changed = true
+
+			if nil != backKeyValues {
+				backVal := backKeyValues.GetValue(destBlockID)
+				if nil == backVal {
+					backVal = &av.Value{ID: ast.NewNodeID(), KeyID: backKeyValues.Key.ID, BlockID: destBlockID, Type: av.KeyTypeRelation, Relation: &av.ValueRelation{}}
+					backKeyValues.Values = append(backKeyValues.Values, backVal)
+				}
+				if nil == backVal.Relation {
+					backVal.Relation = &av.ValueRelation{}
 				}
+				backVal.Relation.BlockIDs = gulu.Str.RemoveDuplicatedElem(append(backVal.Relation.BlockIDs, rowID))
+				touchAttributeViewRow(destAv, destBlockID)
 			}
 		}
-		break
+		if changed {
+			touchAttributeViewRow(srcAv, rowID)
+		}
+	}
+	if 0 < skipped {
+		logging.LogWarnf("skipped [%d] relation links to attribute view [%s] key [%s]", skipped, srcAvID, relKeyID)
 	}
 
-	err = av.SaveAttributeView(attrView)
+	if err = av.SaveAttributeView(srcAv); nil != err {
+		return
+	}
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": srcAv.ID})
+	if !isSameAv && nil != backKeyValues {
+		if err = av.SaveAttributeView(destAv); nil != err {
+			return
+		}
+		util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": destAv.ID})
+	}
 	return
 }
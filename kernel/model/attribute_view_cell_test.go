@@ -0,0 +1,486 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+func TestFillDownAttributeViewCell(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	srcRowID := addTestAttributeViewRow(attrView, "src")
+	var rowIDs []string
+	for i := 0; i < 10; i++ {
+		rowIDs = append(rowIDs, addTestAttributeViewRow(attrView, "row"))
+	}
+	textKeyID := attrView.KeyValues[1].Key.ID
+	setTestAttributeViewValue(attrView, textKeyID, srcRowID, &av.Value{Text: &av.ValueText{Content: "foo"}})
+	setTestAttributeViewValue(attrView, textKeyID, rowIDs[0], &av.Value{Text: &av.ValueText{Content: "bar"}})
+	saveTestAttributeViews(t, attrView)
+	oldCellID := attrView.GetValue(textKeyID, rowIDs[0]).ID
+
+	if err := FillDownAttributeViewCell(nil, attrView.ID, textKeyID, srcRowID, rowIDs); nil != err {
+		t.Fatalf("fill down failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	for _, rowID := range rowIDs {
+		val := attrView.GetValue(textKeyID, rowID)
+		if nil == val || nil == val.Text || "foo" != val.Text.Content || rowID != val.BlockID {
+			t.Fatalf("row [%s] was not filled", rowID)
+		}
+	}
+	if oldCellID != attrView.GetValue(textKeyID, rowIDs[0]).ID {
+		t.Fatalf("existing cell ID should be kept")
+	}
+	if 11 != len(attrView.KeyValues[1].Values) {
+		t.Fatalf("expected 11 text values, got %d", len(attrView.KeyValues[1].Values))
+	}
+}
+
+func TestFillDownAttributeViewCellTwoWayRelation(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	d1 := addTestAttributeViewRow(destAv, "d1")
+
+	attrView := newTestAttributeView(t)
+	target := addTestAttributeViewRow(attrView, "target")
+	srcRowID := addTestAttributeViewRow(attrView, "src")
+	var rowIDs []string
+	for i := 0; i < 3; i++ {
+		rowIDs = append(rowIDs, addTestAttributeViewRow(attrView, "row"))
+	}
+
+	// 自关联：回链写入的是同一个属性视图
+	selfKey := addTestAttributeViewKey(attrView, "Self", av.KeyTypeRelation)
+	selfBackKey := addTestAttributeViewKey(attrView, "Self back", av.KeyTypeRelation)
+	selfKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: selfBackKey.ID}
+	selfBackKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: selfKey.ID}
+	setTestAttributeViewValue(attrView, selfKey.ID, srcRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{target}}})
+	setTestAttributeViewValue(attrView, selfBackKey.ID, target, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{srcRowID}}})
+
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	backKey := addTestAttributeViewKey(destAv, "Back", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID, IsTwoWay: true, BackKeyID: backKey.ID}
+	backKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: relKey.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, srcRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{d1}}})
+	setTestAttributeViewValue(destAv, backKey.ID, d1, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{srcRowID}}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	for _, keyID := range []string{selfKey.ID, relKey.ID} {
+		if err := FillDownAttributeViewCell(nil, attrView.ID, keyID, srcRowID, rowIDs); nil != err {
+			t.Fatalf("fill down failed: %s", err)
+		}
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	if got := attrView.GetValue(selfBackKey.ID, target).Relation.BlockIDs; 4 != len(got) {
+		t.Fatalf("expected self back relation to keep all filled rows, got %v", got)
+	}
+	if got := destAv.GetValue(backKey.ID, d1).Relation.BlockIDs; 4 != len(got) {
+		t.Fatalf("expected back relation to keep all filled rows, got %v", got)
+	}
+}
+
+func TestUpdateAttributeViewCellTouchesDetachedRow(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	updatedKey := addTestAttributeViewKey(attrView, "Updated", av.KeyTypeUpdated)
+	old := time.Now().Add(-time.Hour).UnixMilli()
+	attrView.GetBlockKeyValues().GetValue(rowID).Block.Updated = old
+	saveTestAttributeViews(t, attrView)
+
+	textKeyID := attrView.KeyValues[1].Key.ID
+	if err := UpdateAttributeViewCell(nil, attrView.ID, textKeyID, rowID, ast.NewNodeID(), map[string]interface{}{"text": map[string]interface{}{"content": "bar"}}, false); nil != err {
+		t.Fatalf("update cell failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	storedVal := attrView.GetValue(updatedKey.ID, rowID)
+	if nil == storedVal || nil == storedVal.Updated || storedVal.Updated.Content <= old {
+		t.Fatalf("expected stored updated value to be bumped")
+	}
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	cell := table.Rows[0].Cells[2]
+	if cell.Value.Updated.Content != storedVal.Updated.Content {
+		t.Fatalf("expected rendered updated [%d], got [%d]", storedVal.Updated.Content, cell.Value.Updated.Content)
+	}
+}
+
+func TestSetAttributeViewCellLocked(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	srcID := addTestAttributeViewRow(attrView, "src")
+	setTestAttributeViewValue(attrView, textKeyID, srcID, &av.Value{Text: &av.ValueText{Content: "source"}})
+	rowID := addTestAttributeViewRow(attrView, "a")
+	saveTestAttributeViews(t, attrView)
+
+	if err := setAttributeViewCellLocked(&Operation{AvID: attrView.ID, KeyID: textKeyID, RowID: rowID, Data: true}); nil != err {
+		t.Fatalf("lock cell failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	cellID := attrView.GetValue(textKeyID, rowID).ID
+	textData := func(content string) map[string]interface{} {
+		return map[string]interface{}{"text": map[string]interface{}{"content": content}}
+	}
+
+	if err := UpdateAttributeViewCell(nil, attrView.ID, textKeyID, rowID, cellID, textData("edited"), false); av.ErrCellLocked != err {
+		t.Fatalf("expected locked cell error, got %v", err)
+	}
+	if err := FillDownAttributeViewCell(nil, attrView.ID, textKeyID, srcID, []string{rowID}); av.ErrCellLocked != err {
+		t.Fatalf("expected locked cell error on fill down, got %v", err)
+	}
+	if tx := (&Transaction{}).doUpdateAttrViewCell(&Operation{AvID: attrView.ID, KeyID: textKeyID, RowID: rowID, ID: cellID, Data: textData("edited")}); nil == tx {
+		t.Fatalf("expected transaction error for locked cell")
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if val := attrView.GetValue(textKeyID, rowID); !val.Locked || (nil != val.Text && "" != val.Text.Content) {
+		t.Fatalf("locked cell should not be changed")
+	}
+
+	if err := UpdateAttributeViewCell(nil, attrView.ID, textKeyID, rowID, cellID, textData("approved"), true); nil != err {
+		t.Fatalf("override lock failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if val := attrView.GetValue(textKeyID, rowID); !val.Locked || "approved" != val.Text.Content {
+		t.Fatalf("expected overridden value kept locked")
+	}
+
+	if err := setAttributeViewCellLocked(&Operation{AvID: attrView.ID, KeyID: textKeyID, RowID: rowID, Data: false}); nil != err {
+		t.Fatalf("unlock cell failed: %s", err)
+	}
+	if err := UpdateAttributeViewCell(nil, attrView.ID, textKeyID, rowID, cellID, textData("edited"), false); nil != err {
+		t.Fatalf("update unlocked cell failed: %s", err)
+	}
+}
+
+func TestAttributeViewCellLockedWritePaths(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	destID := addTestAttributeViewRow(destAv, "dest")
+
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	checkKey := addTestAttributeViewKey(attrView, "Done", av.KeyTypeCheckbox)
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	backKey := addTestAttributeViewKey(destAv, "Back", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID, IsTwoWay: true, BackKeyID: backKey.ID}
+	backKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: relKey.ID}
+	rowID := addTestAttributeViewRow(attrView, "a")
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "x"}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	lock := func(avID, keyID, rowID string) {
+		if err := setAttributeViewCellLocked(&Operation{AvID: avID, KeyID: keyID, RowID: rowID, Data: true}); nil != err {
+			t.Fatalf("lock cell failed: %s", err)
+		}
+	}
+	lock(attrView.ID, checkKey.ID, rowID)
+	lock(attrView.ID, textKeyID, rowID)
+	lock(destAv.ID, backKey.ID, destID)
+
+	if _, err := ToggleAttributeViewCheckbox(attrView.ID, checkKey.ID, rowID, ""); av.ErrCellLocked != err {
+		t.Fatalf("expected locked cell error on toggle, got %v", err)
+	}
+	if _, err := ConvertTextToSelect(attrView.ID, textKeyID, false); av.ErrCellLocked != err {
+		t.Fatalf("expected locked cell error on text to select, got %v", err)
+	}
+
+	// 回链单元格被锁定时不能修改双向关联
+	relData := map[string]interface{}{"relation": map[string]interface{}{"blockIDs": []interface{}{destID}}}
+	if err := UpdateAttributeViewCell(nil, attrView.ID, relKey.ID, rowID, ast.NewNodeID(), relData, false); av.ErrCellLocked != err {
+		t.Fatalf("expected locked back relation error, got %v", err)
+	}
+	if err := LinkAttributeViewRelations(attrView.ID, relKey.ID, map[string][]string{rowID: {destID}}); nil != err {
+		t.Fatalf("link relations failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if val := attrView.GetValue(relKey.ID, rowID); nil != val && nil != val.Relation && 0 < len(val.Relation.BlockIDs) {
+		t.Fatalf("expected locked back relation to be skipped")
+	}
+
+	// 客户端传入的 locked 不能解锁单元格
+	textData := map[string]interface{}{"locked": false, "text": map[string]interface{}{"content": "y"}}
+	cellID := attrView.GetValue(textKeyID, rowID).ID
+	if err := UpdateAttributeViewCell(nil, attrView.ID, textKeyID, rowID, cellID, textData, true); nil != err {
+		t.Fatalf("override lock failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if val := attrView.GetValue(textKeyID, rowID); !val.Locked || "y" != val.Text.Content {
+		t.Fatalf("expected cell kept locked")
+	}
+}
+
+func TestToggleAttributeViewCheckbox(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	doneKey := addTestAttributeViewKey(attrView, "Done", av.KeyTypeCheckbox)
+	rowID := addTestAttributeViewRow(attrView, "a")
+	attrView.GetBlockKeyValues().GetValue(rowID).Block.Updated = 1
+	saveTestAttributeViews(t, attrView)
+
+	for _, expected := range []bool{true, false} {
+		checked, err := ToggleAttributeViewCheckbox(attrView.ID, doneKey.ID, rowID, "")
+		if nil != err {
+			t.Fatalf("toggle checkbox failed: %s", err)
+		}
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		val := attrView.GetValue(doneKey.ID, rowID)
+		if expected != checked || nil == val || nil == val.Checkbox || expected != val.Checkbox.Checked {
+			t.Fatalf("expected checked [%v]", expected)
+		}
+		if 1 == attrView.GetBlockKeyValues().GetValue(rowID).Block.Updated {
+			t.Fatalf("expected row updated time bumped")
+		}
+	}
+	if 1 != len(attrView.KeyValues[2].Values) {
+		t.Fatalf("expected a single checkbox value, got %d", len(attrView.KeyValues[2].Values))
+	}
+
+	if _, err := ToggleAttributeViewCheckbox(attrView.ID, attrView.KeyValues[1].Key.ID, rowID, ""); nil == err {
+		t.Fatalf("expected error toggling a non-checkbox key")
+	}
+}
+
+func TestSanitizeAttributeViewNumbers(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	attrView.Views[0].Table.Columns[2].Calc = &av.ColumnCalc{Operator: av.CalcOperatorSum}
+	validID := addTestAttributeViewRow(attrView, "valid")
+	setTestAttributeViewValue(attrView, numKey.ID, validID, &av.Value{Number: &av.ValueNumber{Content: 3, IsNotEmpty: true, FormattedContent: "garbage"}})
+	// 文本列改为数字列后导入的值没有数字
+	garbageID := addTestAttributeViewRow(attrView, "garbage")
+	setTestAttributeViewValue(attrView, numKey.ID, garbageID, &av.Value{Text: &av.ValueText{Content: "12abc"}})
+	saveTestAttributeViews(t, attrView)
+
+	fixed, err := SanitizeAttributeViewNumbers(attrView.ID, numKey.ID)
+	if nil != err || 1 != fixed {
+		t.Fatalf("expected 1 fixed cell, got %d, %v", fixed, err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if val := attrView.GetValue(numKey.ID, garbageID); nil == val.Number || val.Number.IsNotEmpty {
+		t.Fatalf("expected garbage number cleared")
+	}
+	if val := attrView.GetValue(numKey.ID, validID); "3" != val.Number.FormattedContent {
+		t.Fatalf("expected valid number reformatted, got [%s]", val.Number.FormattedContent)
+	}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	if result := table.Columns[2].Calc.Result; nil == result || 3 != result.Number.Content {
+		t.Fatalf("expected sum 3 after sanitizing, got %v", result)
+	}
+
+	// NaN 无法保存，只会出现在还没有保存的属性视图中
+	setTestAttributeViewValue(attrView, numKey.ID, validID, &av.Value{Number: &av.ValueNumber{Content: math.NaN(), IsNotEmpty: true}})
+	if fixed, _ = sanitizeAttributeViewNumbers(attrView, numKey.ID); 1 != fixed {
+		t.Fatalf("expected NaN cleared, got %d", fixed)
+	}
+	if err = av.SaveAttributeView(attrView); nil != err {
+		t.Fatalf("save sanitized attribute view failed: %s", err)
+	}
+}
+
+func TestUpdateAttributeViewCellMask(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "row")
+	rowID := attrView.GetBlockKeyValues().Values[0].BlockID
+	skuKeyID := attrView.KeyValues[1].Key.ID
+	saveTestAttributeViews(t, attrView)
+
+	setMask := func(strict bool) {
+		if err := setAttributeViewColMask(&Operation{AvID: attrView.ID, ID: skuKeyID, Data: map[string]interface{}{"mask": "AAA-000", "strict": strict}}); nil != err {
+			t.Fatalf("set mask failed: %s", err)
+		}
+	}
+	update := func(content string) *TxErr {
+		cellID := ast.NewNodeID()
+		if val := GetAttributeView(attrView.ID).GetValue(skuKeyID, rowID); nil != val {
+			cellID = val.ID
+		}
+		return (&Transaction{}).doUpdateAttrViewCell(&Operation{AvID: attrView.ID, KeyID: skuKeyID, RowID: rowID, ID: cellID, Data: map[string]interface{}{"text": map[string]interface{}{"content": content}}})
+	}
+	stored := func() string {
+		return GetAttributeView(attrView.ID).GetValue(skuKeyID, rowID).Text.Content
+	}
+
+	setMask(true)
+	if txErr := update("SKU-123"); nil != txErr {
+		t.Fatalf("expected conforming value accepted: %s", txErr.msg)
+	}
+	if txErr := update("SKU-12X"); nil == txErr || TxErrWriteAttributeView != txErr.code {
+		t.Fatalf("expected non-conforming value rejected")
+	}
+	if "SKU-123" != stored() {
+		t.Fatalf("expected rejected value not stored, got [%s]", stored())
+	}
+
+	// 非严格模式下不符合掩码的值原样保存
+	setMask(false)
+	if txErr := update("sku 12"); nil != txErr {
+		t.Fatalf("expected non-conforming value stored in non-strict mode: %s", txErr.msg)
+	}
+	if "sku 12" != stored() {
+		t.Fatalf("unexpected stored value [%s]", stored())
+	}
+
+	table := renderTestAttributeViewTable(t, GetAttributeView(attrView.ID), nil)
+	if "AAA-000" != table.Columns[1].Mask {
+		t.Fatalf("expected mask exposed on rendered column")
+	}
+}
+
+func TestAddAttributeViewMSelectOptionToRows(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	tagKey := addTestAttributeViewKey(attrView, "Tags", av.KeyTypeMSelect)
+	tagKey.Options = []*av.SelectOption{{Name: "urgent", Color: "1"}, {Name: "later", Color: "2"}}
+	row1 := addTestAttributeViewRow(attrView, "foo")
+	row2 := addTestAttributeViewRow(attrView, "bar")
+	row3 := addTestAttributeViewRow(attrView, "baz")
+	setTestAttributeViewValue(attrView, tagKey.ID, row1, &av.Value{MSelect: []*av.ValueSelect{{Content: "later", Color: "2"}}})
+	setTestAttributeViewValue(attrView, tagKey.ID, row2, &av.Value{MSelect: []*av.ValueSelect{{Content: "urgent", Color: "1"}}})
+	saveTestAttributeViews(t, attrView)
+
+	rowIDs := []string{row1, row2, row3, row1}
+	if err := AddAttributeViewMSelectOptionToRows(nil, attrView.ID, tagKey.ID, "urgent", rowIDs); nil != err {
+		t.Fatalf("add option to rows failed: %s", err)
+	}
+	// 再次执行不应该产生重复选项
+	if err := AddAttributeViewMSelectOptionToRows(nil, attrView.ID, tagKey.ID, "urgent", rowIDs); nil != err {
+		t.Fatalf("add option to rows again failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	expected := map[string]string{row1: "later,urgent", row2: "urgent", row3: "urgent"}
+	for rowID, want := range expected {
+		val := attrView.GetValue(tagKey.ID, rowID)
+		if nil == val {
+			t.Fatalf("expected row [%s] to have a value", rowID)
+		}
+		if got := strings.Join(selectContents(val.MSelect), ","); want != got {
+			t.Fatalf("row [%s] expected [%s], got [%s]", rowID, want, got)
+		}
+	}
+	if key, _ := attrView.GetKey(tagKey.ID); 2 != len(key.Options) {
+		t.Fatalf("expected existing option to be reused, got %d options", len(key.Options))
+	}
+
+	textKeyID := attrView.KeyValues[1].Key.ID
+	if err := AddAttributeViewMSelectOptionToRows(nil, attrView.ID, textKeyID, "urgent", rowIDs); nil == err {
+		t.Fatalf("expected non multi-select key to be rejected")
+	}
+}
+
+func TestRenderAttributeViewNumberInputDecimals(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Price", av.KeyTypeNumber)
+	attrView.Views[0].Table.Columns[2].Calc = &av.ColumnCalc{Operator: av.CalcOperatorSum}
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	otherID := addTestAttributeViewRow(attrView, "bar")
+	saveTestAttributeViews(t, attrView)
+
+	for id, input := range map[string]string{rowID: "3.50", otherID: "1.50"} {
+		content, _ := strconv.ParseFloat(input, 64)
+		data := map[string]interface{}{"number": map[string]interface{}{"content": content, "isNotEmpty": true, "input": input}}
+		if err := UpdateAttributeViewCell(nil, attrView.ID, numKey.ID, id, ast.NewNodeID(), data, false); nil != err {
+			t.Fatalf("update cell failed: %s", err)
+		}
+	}
+
+	render := func() *av.Table {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		return table
+	}
+	cellContent := func(table *av.Table, id string) string {
+		for _, row := range table.Rows {
+			if row.ID == id {
+				return row.Cells[2].Value.String()
+			}
+		}
+		return ""
+	}
+
+	table := render()
+	if got := cellContent(table, rowID); "3.50" != got {
+		t.Fatalf("expected input decimals preserved [3.50], got [%s]", got)
+	}
+	if data, _ := os.ReadFile(av.GetAttributeViewDataPath(attrView.ID)); bytes.Contains(data, []byte(`"input"`)) {
+		t.Fatalf("expected raw number input not saved")
+	}
+	if got := table.Columns[2].Calc.Result.Number.FormattedContent; "5" != got {
+		t.Fatalf("expected calc result in column format [5], got [%s]", got)
+	}
+
+	// 修改数字时没有传入输入文本则不再保留小数位数
+	data := map[string]interface{}{"number": map[string]interface{}{"content": 4, "isNotEmpty": true}}
+	if err := UpdateAttributeViewCell(nil, attrView.ID, numKey.ID, rowID, attrView.GetValue(numKey.ID, rowID).ID, data, false); nil != err {
+		t.Fatalf("update cell failed: %s", err)
+	}
+	if got := cellContent(render(), rowID); "4" != got {
+		t.Fatalf("expected [4], got [%s]", got)
+	}
+
+	// 列设置了数字格式时使用列格式
+	attrView.KeyValues[2].Key.NumberFormat = av.NumberFormatUSDollar
+	saveTestAttributeViews(t, attrView)
+	if got := cellContent(render(), otherID); "$1.50" != got {
+		t.Fatalf("expected column format [$1.50], got [%s]", got)
+	}
+}
+
+func TestAttributeViewComputedKeysReadOnly(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	srcID := addTestAttributeViewRow(attrView, "src")
+	rowID := addTestAttributeViewRow(attrView, "a")
+	var keys []*av.Key
+	for _, keyType := range []av.KeyType{av.KeyTypeRunningTotal, av.KeyTypeBlockAttr, av.KeyTypeDateDelta, av.KeyTypePercentOfColumn,
+		av.KeyTypeBacklinkCount, av.KeyTypeAge, av.KeyTypeTextLength, av.KeyTypeRowDelta} {
+		keys = append(keys, addTestAttributeViewKey(attrView, string(keyType), keyType))
+	}
+	saveTestAttributeViews(t, attrView)
+
+	numberData := map[string]interface{}{"number": map[string]interface{}{"content": 1, "isNotEmpty": true}}
+	for _, key := range keys {
+		if !key.Type.IsComputed() {
+			t.Fatalf("expected key type [%s] to be computed", key.Type)
+		}
+		if err := UpdateAttributeViewCell(nil, attrView.ID, key.ID, rowID, ast.NewNodeID(), numberData, false); nil == err {
+			t.Fatalf("expected write to computed key type [%s] to be rejected", key.Type)
+		}
+		if err := FillDownAttributeViewCell(nil, attrView.ID, key.ID, srcID, []string{rowID}); nil == err {
+			t.Fatalf("expected fill down of computed key type [%s] to be rejected", key.Type)
+		}
+	}
+}
@@ -0,0 +1,755 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestSortAttributeViewCellOption(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	key := addTestAttributeViewKey(attrView, "Tags", av.KeyTypeMSelect)
+	key.Options = []*av.SelectOption{{Name: "a", Color: "1"}, {Name: "b", Color: "2"}, {Name: "c", Color: "3"}, {Name: "d", Color: "4"}}
+	setTestAttributeViewValue(attrView, key.ID, rowID, &av.Value{MSelect: []*av.ValueSelect{{Content: "a", Color: "1"}, {Content: "b", Color: "2"}, {Content: "c", Color: "3"}, {Content: "d", Color: "4"}}})
+	saveTestAttributeViews(t, attrView)
+
+	cellOptions := func() (ret string) {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		for _, opt := range attrView.GetValue(key.ID, rowID).MSelect {
+			ret += opt.Content
+		}
+		return
+	}
+
+	if err := sortAttributeViewCellOption(&Operation{AvID: attrView.ID, KeyID: key.ID, RowID: rowID, Data: []interface{}{"c", "a"}}); nil != err {
+		t.Fatalf("sort cell options failed: %s", err)
+	}
+	if "cabd" != cellOptions() {
+		t.Fatalf("unexpected cell options order [%s]", cellOptions())
+	}
+
+	if err := updateAttributeViewColumnOption(&Operation{AvID: attrView.ID, ID: key.ID, Data: map[string]interface{}{"oldName": "a", "newName": "e", "newColor": "5"}}); nil != err {
+		t.Fatalf("update option failed: %s", err)
+	}
+	if err := removeAttributeViewColumnOption(&Operation{AvID: attrView.ID, ID: key.ID, Data: "b"}); nil != err {
+		t.Fatalf("remove option failed: %s", err)
+	}
+	if "ced" != cellOptions() {
+		t.Fatalf("unexpected cell options order [%s]", cellOptions())
+	}
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rendered := ""
+	for _, opt := range table.Rows[0].Cells[2].Value.MSelect {
+		rendered += opt.Content
+	}
+	if "ced" != rendered {
+		t.Fatalf("unexpected rendered options order [%s]", rendered)
+	}
+}
+
+func TestParseTextColumnToDate(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	dateKey := addTestAttributeViewKey(attrView, "Date", av.KeyTypeDate)
+	var rowIDs []string
+	for _, content := range []string{"2024/01/02", "not a date", "2023/12/31"} {
+		rowID := addTestAttributeViewRow(attrView, "row")
+		setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: content}})
+		rowIDs = append(rowIDs, rowID)
+	}
+	saveTestAttributeViews(t, attrView)
+
+	converted, err := ParseTextColumnToDate(attrView.ID, textKeyID, dateKey.ID, "2006/01/02")
+	if nil != err {
+		t.Fatalf("parse text column to date failed: %s", err)
+	}
+	if 2 != converted {
+		t.Fatalf("expected 2 converted values, got %d", converted)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	dateVal := attrView.GetValue(dateKey.ID, rowIDs[0])
+	expected := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local).UnixMilli()
+	if nil == dateVal || expected != dateVal.Date.Content || !dateVal.Date.IsNotEmpty || !dateVal.Date.IsNotTime {
+		t.Fatalf("unexpected date value")
+	}
+	if nil != attrView.GetValue(dateKey.ID, rowIDs[1]) {
+		t.Fatalf("unparseable value should be skipped")
+	}
+}
+
+func TestRenderAttributeViewRunningTotal(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	totalKey := addTestAttributeViewKey(attrView, "Total", av.KeyTypeRunningTotal)
+	for i, amount := range []float64{1, 2, 3, 4} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: amount, IsNotEmpty: true}})
+	}
+	saveTestAttributeViews(t, attrView)
+	if err := updateAttributeViewColSourceKey(&Operation{AvID: attrView.ID, ID: totalKey.ID, KeyID: numKey.ID}); nil != err {
+		t.Fatalf("update source key failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+
+	totals := func() (ret []float64) {
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		for _, row := range table.Rows {
+			ret = append(ret, row.Cells[3].Value.Number.Content)
+		}
+		return
+	}
+
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: numKey.ID, Order: av.SortOrderAsc}}
+	if got := totals(); 4 != len(got) || 1 != got[0] || 3 != got[1] || 6 != got[2] || 10 != got[3] {
+		t.Fatalf("unexpected running totals %v", got)
+	}
+
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: numKey.ID, Order: av.SortOrderDesc}}
+	if got := totals(); 4 != len(got) || 4 != got[0] || 7 != got[1] || 9 != got[2] || 10 != got[3] {
+		t.Fatalf("unexpected running totals %v", got)
+	}
+
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: numKey.ID, Operator: av.FilterOperatorIsGreater, Value: &av.Value{Number: &av.ValueNumber{Content: 2}}}}
+	if got := totals(); 2 != len(got) || 4 != got[0] || 7 != got[1] {
+		t.Fatalf("unexpected running totals %v", got)
+	}
+}
+
+func TestRenderAttributeViewPercentOfColumn(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Budget", av.KeyTypeNumber)
+	percentKey := addTestAttributeViewKey(attrView, "Share", av.KeyTypePercentOfColumn)
+	for i, amount := range []float64{10, 30, 60, 100} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: amount, IsNotEmpty: true}})
+	}
+	saveTestAttributeViews(t, attrView)
+	if err := updateAttributeViewColSourceKey(&Operation{AvID: attrView.ID, ID: percentKey.ID, KeyID: numKey.ID}); nil != err {
+		t.Fatalf("update source key failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+
+	// 过滤掉 100 后剩余行的占比按 100 总和重新计算
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: numKey.ID, Operator: av.FilterOperatorIsLess, Value: &av.Value{Number: &av.ValueNumber{Content: 100}}}}
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: numKey.ID, Order: av.SortOrderAsc}}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rows := table.Rows
+	sum := 0.0
+	for _, row := range rows {
+		sum += row.Cells[3].Value.Number.Content
+	}
+	if 3 != len(rows) || 100 != sum || 10 != rows[0].Cells[3].Value.Number.Content || "60%" != rows[2].Cells[3].Value.Number.FormattedContent {
+		t.Fatalf("unexpected percentages, sum [%v]", sum)
+	}
+
+	// 总和为 0 时占比为空
+	for _, keyValues := range attrView.KeyValues {
+		if numKey.ID == keyValues.Key.ID {
+			keyValues.Values[0].Number.Content = 0
+		}
+	}
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: numKey.ID, Operator: av.FilterOperatorIsLess, Value: &av.Value{Number: &av.ValueNumber{Content: 5}}}}
+	table = renderTestAttributeViewTable(t, attrView, nil)
+	if rows = table.Rows; 1 != len(rows) || rows[0].Cells[3].Value.Number.IsNotEmpty {
+		t.Fatalf("expected empty percentage for zero total")
+	}
+}
+
+func TestGetAttributeViewColumnStats(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	numKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	statusKey := addTestAttributeViewKey(attrView, "Status", av.KeyTypeSelect)
+	for i, row := range []struct {
+		text   string
+		amount float64
+		status string
+	}{{"a", 1, "Todo"}, {"a", 2, "Done"}, {"a", 2, "Todo"}, {"a", 0, ""}, {"hidden", 100, "Done"}} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: row.text}})
+		if 0 != row.amount {
+			setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: row.amount, IsNotEmpty: true}})
+		}
+		if "" != row.status {
+			setTestAttributeViewValue(attrView, statusKey.ID, rowID, &av.Value{MSelect: []*av.ValueSelect{{Content: row.status}}})
+		}
+	}
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: textKeyID, Operator: av.FilterOperatorIsEqual, Value: &av.Value{Text: &av.ValueText{Content: "a"}}}}
+	saveTestAttributeViews(t, attrView)
+
+	stats, err := GetAttributeViewColumnStats(attrView.ID, attrView.ViewID, numKey.ID)
+	if nil != err {
+		t.Fatalf("get column stats failed: %s", err)
+	}
+	if 4 != stats.Count || 1 != stats.EmptyCount || 2 != stats.DistinctCount {
+		t.Fatalf("unexpected numeric counts [%d, %d, %d]", stats.Count, stats.EmptyCount, stats.DistinctCount)
+	}
+	if nil == stats.Min || 1 != *stats.Min || 2 != *stats.Max || 5.0/3 != *stats.Avg {
+		t.Fatalf("unexpected numeric min/max/avg")
+	}
+
+	stats, err = GetAttributeViewColumnStats(attrView.ID, attrView.ViewID, statusKey.ID)
+	if nil != err {
+		t.Fatalf("get column stats failed: %s", err)
+	}
+	if 4 != stats.Count || 1 != stats.EmptyCount || 2 != stats.DistinctCount || nil != stats.Min {
+		t.Fatalf("unexpected select counts [%d, %d, %d]", stats.Count, stats.EmptyCount, stats.DistinctCount)
+	}
+	if 2 != len(stats.TopOptions) || "Todo" != stats.TopOptions[0].Name || 2 != stats.TopOptions[0].Count || 1 != stats.TopOptions[1].Count {
+		t.Fatalf("unexpected top options")
+	}
+}
+
+func TestAddAttributeViewColumnWithConfig(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+
+	selectKeyID := ast.NewNodeID()
+	err := addAttributeViewColumnWithConfig(&Operation{AvID: attrView.ID, ID: selectKeyID, PreviousID: attrView.KeyValues[0].Key.ID, Data: map[string]interface{}{
+		"name": "Status",
+		"type": "select",
+		"options": []interface{}{
+			map[string]interface{}{"name": "Todo", "color": "1"},
+			map[string]interface{}{"name": "Done", "color": "2"},
+			map[string]interface{}{"name": "Todo", "color": "3"},
+		},
+		"numberFormat": "percent",
+	}})
+	if nil != err {
+		t.Fatalf("add select column failed: %s", err)
+	}
+
+	relKeyID := ast.NewNodeID()
+	err = addAttributeViewColumnWithConfig(&Operation{AvID: attrView.ID, ID: relKeyID, PreviousID: textKeyID, Data: map[string]interface{}{
+		"name":     "Projects",
+		"type":     "relation",
+		"relation": map[string]interface{}{"avID": destAv.ID, "isTwoWay": true, "maxEntries": 3},
+	}})
+	if nil != err {
+		t.Fatalf("add relation column failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	selectKey, _ := attrView.GetKey(selectKeyID)
+	if nil == selectKey || 2 != len(selectKey.Options) || "Done" != selectKey.Options[1].Name || av.NumberFormatNone != selectKey.NumberFormat {
+		t.Fatalf("unexpected select key config")
+	}
+	relKey, _ := attrView.GetKey(relKeyID)
+	if nil == relKey || nil == relKey.Relation || destAv.ID != relKey.Relation.AvID || 3 != relKey.Relation.MaxEntries || "" == relKey.Relation.BackKeyID {
+		t.Fatalf("unexpected relation key config")
+	}
+	columns := attrView.Views[0].Table.Columns
+	if 4 != len(columns) || selectKeyID != columns[1].ID || relKeyID != columns[3].ID {
+		t.Fatalf("unexpected column positions")
+	}
+
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	backKey, _ := destAv.GetKey(relKey.Relation.BackKeyID)
+	if nil == backKey || nil == backKey.Relation || attrView.ID != backKey.Relation.AvID || relKeyID != backKey.Relation.BackKeyID {
+		t.Fatalf("expected back relation key in destination attribute view")
+	}
+
+	if err = addAttributeViewColumnWithConfig(&Operation{AvID: attrView.ID, ID: ast.NewNodeID(), Data: map[string]interface{}{"name": "Share", "type": "percentOfColumn", "sourceKeyID": textKeyID}}); nil == err {
+		t.Fatalf("expected invalid source key error")
+	}
+}
+
+func TestSetAttributeViewColEmptyPlaceholder(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo")
+	textKey := attrView.KeyValues[1].Key
+	if err := setAttributeViewColEmptyPlaceholder(&Operation{AvID: attrView.ID, ID: textKey.ID, Data: "N/A"}); nil != err {
+		t.Fatalf("set empty placeholder failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	if "N/A" != table.Columns[1].EmptyPlaceholder || "" != table.Columns[0].EmptyPlaceholder {
+		t.Fatalf("unexpected column placeholders [%s, %s]", table.Columns[0].EmptyPlaceholder, table.Columns[1].EmptyPlaceholder)
+	}
+	if cell := table.Rows[0].Cells[1]; nil != cell.Value && nil != cell.Value.Text && "" != cell.Value.Text.Content {
+		t.Fatalf("expected empty cell value, got [%s]", cell.Value.Text.Content)
+	}
+}
+
+func TestRenderAttributeViewBlockAttr(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	detachedID := addTestAttributeViewRow(attrView, "detached")
+	boundID := ast.NewNodeID()
+	addTestAttributeViewRowWithID(attrView, boundID, "bound")
+	attrView.GetBlockKeyValues().GetValue(boundID).IsDetached = false
+
+	// 绑定块需要在块树中存在，块属性从文档中读取
+	tree := treenode.NewTree("box", "/"+boundID+".sy", "/bound", "bound")
+	tree.Root.SetIALAttr("custom-priority", "high")
+	if err := filesys.WriteTree(tree); nil != err {
+		t.Fatalf("write tree failed: %s", err)
+	}
+	treenode.IndexBlockTree(tree)
+
+	attrKey := addTestAttributeViewKey(attrView, "Priority", av.KeyTypeBlockAttr)
+	saveTestAttributeViews(t, attrView)
+	if err := updateAttributeViewColAttrName(&Operation{AvID: attrView.ID, ID: attrKey.ID, Data: "custom-priority"}); nil != err {
+		t.Fatalf("update attr name failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: attrKey.ID, Operator: av.FilterOperatorIsEqual, Value: &av.Value{Text: &av.ValueText{Content: "high"}}}}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	if 1 != len(table.Rows) || boundID != table.Rows[0].ID || "high" != table.Rows[0].Cells[2].Value.String() {
+		t.Fatalf("expected only the bound row with its block attribute")
+	}
+
+	cellID := ast.NewNodeID()
+	if _, err := updateAttributeViewValue(nil, attrView, nil, attrKey.ID, detachedID, cellID, map[string]interface{}{"text": map[string]interface{}{"content": "low"}}); nil == err {
+		t.Fatalf("expected read-only block attribute error")
+	}
+}
+
+func TestSetAttributeViewColWidthAllViews(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "a")
+	textKeyID := attrView.KeyValues[1].Key.ID
+	for _, columns := range [][]*av.ViewTableColumn{{{ID: attrView.KeyValues[0].Key.ID}, {ID: textKeyID}}, {{ID: attrView.KeyValues[0].Key.ID}}} {
+		view := &av.View{ID: ast.NewNodeID(), Name: "Table", LayoutType: av.LayoutTypeTable, Table: &av.LayoutTable{ID: ast.NewNodeID(), Columns: columns, Filters: []*av.ViewFilter{}, Sorts: []*av.ViewSort{}}}
+		attrView.Views = append(attrView.Views, view)
+	}
+	saveTestAttributeViews(t, attrView)
+
+	if err := setAttributeViewColWidthAllViews(&Operation{AvID: attrView.ID, ID: textKeyID, Data: "320px"}); nil != err {
+		t.Fatalf("set column width failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	for _, view := range attrView.Views[:2] {
+		if "320px" != view.Table.Columns[1].Width {
+			t.Fatalf("expected width propagated to view [%s]", view.ID)
+		}
+	}
+	if 1 != len(attrView.Views[2].Table.Columns) || "" != attrView.Views[2].Table.Columns[0].Width {
+		t.Fatalf("view without the column should be skipped")
+	}
+}
+
+func TestRenderAttributeViewDateDelta(t *testing.T) {
+	setTestDataDir(t)
+	oldLangs := util.AttrViewLangs
+	util.AttrViewLangs = map[string]map[string]interface{}{util.Lang: {
+		"dateDeltaToday": "today", "dateDeltaIn1Day": "in 1 day", "dateDeltaInXDays": "in %d days",
+		"dateDelta1DayAgo": "1 day ago", "dateDeltaXDaysAgo": "%d days ago",
+	}}
+	defer func() { util.AttrViewLangs = oldLangs }()
+	attrView := newTestAttributeView(t)
+	dateKey := addTestAttributeViewKey(attrView, "Deadline", av.KeyTypeDate)
+	deltaKey := addTestAttributeViewKey(attrView, "Due", av.KeyTypeDateDelta)
+	now := time.Now()
+	for _, days := range []int{3, -5, 0} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(days))
+		setTestAttributeViewValue(attrView, dateKey.ID, rowID, &av.Value{Date: &av.ValueDate{Content: now.AddDate(0, 0, days).UnixMilli(), IsNotEmpty: true}})
+	}
+	addTestAttributeViewRow(attrView, "empty")
+	saveTestAttributeViews(t, attrView)
+	if err := updateAttributeViewColSourceKey(&Operation{AvID: attrView.ID, ID: deltaKey.ID, KeyID: attrView.KeyValues[1].Key.ID}); nil == err {
+		t.Fatalf("expected invalid source key type error")
+	}
+	if err := updateAttributeViewColSourceKey(&Operation{AvID: attrView.ID, ID: deltaKey.ID, KeyID: dateKey.ID}); nil != err {
+		t.Fatalf("update source key failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: deltaKey.ID, Order: av.SortOrderDesc}}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+
+	// 空值按 0 参与排序，这里只检查非空值的顺序
+	var got []string
+	for _, row := range table.Rows {
+		if "empty" == row.GetBlockValue().Block.Content {
+			if "" != row.Cells[3].Value.String() {
+				t.Fatalf("expected empty date delta, got [%s]", row.Cells[3].Value.String())
+			}
+			continue
+		}
+		got = append(got, row.GetBlockValue().Block.Content+":"+row.Cells[3].Value.String())
+	}
+	if 3 != len(got) || "3:in 3 days" != got[0] || "0:today" != got[1] || "-5:5 days ago" != got[2] {
+		t.Fatalf("unexpected date deltas %v", got)
+	}
+}
+
+func TestConvertTextToSelect(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	tagsKeyID := attrView.KeyValues[1].Key.ID
+	var rowIDs []string
+	for _, tags := range []string{"go, rust", "rust，go,go", "", " , "} {
+		rowID := addTestAttributeViewRow(attrView, "a")
+		setTestAttributeViewValue(attrView, tagsKeyID, rowID, &av.Value{Text: &av.ValueText{Content: tags}})
+		rowIDs = append(rowIDs, rowID)
+	}
+	saveTestAttributeViews(t, attrView)
+
+	keyID, err := ConvertTextToSelect(attrView.ID, tagsKeyID, true)
+	if nil != err || tagsKeyID != keyID {
+		t.Fatalf("convert text to select failed: %v", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	key, _ := attrView.GetKey(keyID)
+	if av.KeyTypeMSelect != key.Type || 2 != len(key.Options) || "go" != key.Options[0].Name || "1" != key.Options[0].Color || "rust" != key.Options[1].Name || "2" != key.Options[1].Color {
+		t.Fatalf("unexpected options %+v", key.Options)
+	}
+	for i, expected := range [][]string{{"go", "rust"}, {"rust", "go"}, {}, {}} {
+		val := attrView.GetValue(keyID, rowIDs[i])
+		if av.KeyTypeMSelect != val.Type || nil != val.Text || len(expected) != len(val.MSelect) {
+			t.Fatalf("unexpected value of row [%d]: %+v", i, val)
+		}
+		for j, content := range expected {
+			if content != val.MSelect[j].Content {
+				t.Fatalf("expected option [%s] in row [%d], got [%s]", content, i, val.MSelect[j].Content)
+			}
+		}
+	}
+
+	if _, err = ConvertTextToSelect(attrView.ID, keyID, false); nil == err {
+		t.Fatalf("expected error converting a non-text key")
+	}
+}
+
+func TestSortAttributeViewColumnOptionsByUsage(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	tagsKey := addTestAttributeViewKey(attrView, "Tags", av.KeyTypeMSelect)
+	for _, name := range []string{"rare", "common", "unused", "medium", "tie"} {
+		tagsKey.Options = append(tagsKey.Options, &av.SelectOption{Name: name, Color: "1"})
+	}
+	for _, tags := range [][]string{{"common", "medium"}, {"common", "rare", "tie"}, {"common", "medium", "common"}} {
+		rowID := addTestAttributeViewRow(attrView, "row")
+		var mSelect []*av.ValueSelect
+		for _, tag := range tags {
+			mSelect = append(mSelect, &av.ValueSelect{Content: tag})
+		}
+		setTestAttributeViewValue(attrView, tagsKey.ID, rowID, &av.Value{MSelect: mSelect})
+	}
+	saveTestAttributeViews(t, attrView)
+
+	if err := sortAttributeViewColumnOptionsByUsage(&Operation{AvID: attrView.ID, ID: tagsKey.ID}); nil != err {
+		t.Fatalf("sort options by usage failed: %s", err)
+	}
+
+	attrView, err := av.ParseAttributeView(attrView.ID)
+	if nil != err {
+		t.Fatalf("parse attribute view failed: %s", err)
+	}
+	key, _ := attrView.GetKey(tagsKey.ID)
+	var names []string
+	for _, opt := range key.Options {
+		names = append(names, opt.Name)
+	}
+	// common 3 行，medium 2 行，rare 和 tie 各 1 行保持原有顺序，unused 未使用
+	if "common,medium,rare,tie,unused" != strings.Join(names, ",") {
+		t.Fatalf("unexpected option order %v", names)
+	}
+}
+
+func TestRenderAttributeViewAge(t *testing.T) {
+	setTestDataDir(t)
+	oldLangs := util.TimeLangs
+	util.TimeLangs = map[string]map[string]interface{}{util.Lang: {
+		"now": "now", "1m": "1 minute %s", "xm": "%d minutes %s", "1h": "1 hour %s", "xh": "%d hours %s",
+		"1d": "1 day %s", "xd": "%d days %s", "1w": "1 week %s", "xw": "%d weeks %s",
+		"1M": "1 month %s", "xM": "%d months %s", "1y": "1 year %s", "xy": "%d years %s",
+	}}
+	defer func() { util.TimeLangs = oldLangs }()
+	attrView := newTestAttributeView(t)
+	ageKey := addTestAttributeViewKey(attrView, "Age", av.KeyTypeAge)
+	now := time.Now()
+	for _, days := range []int{2, 90} {
+		// 行 ID 的时间戳部分决定了创建时间
+		rowID := now.AddDate(0, 0, -days).Format("20060102150405") + ast.NewNodeID()[len("20060102150405"):]
+		addTestAttributeViewRowWithID(attrView, rowID, strconv.Itoa(days))
+		attrView.GetBlockKeyValues().GetValue(rowID).Block.Created = 0
+	}
+	// 游离行使用保存的块创建时间
+	oldRowID := addTestAttributeViewRow(attrView, "400")
+	attrView.GetBlockKeyValues().GetValue(oldRowID).Block.Created = now.AddDate(0, 0, -400).UnixMilli()
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: ageKey.ID, Order: av.SortOrderAsc}}
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	var got []string
+	var lastAge float64
+	for _, row := range table.Rows {
+		age := row.Cells[2].Value.Number.Content
+		if age <= lastAge {
+			t.Fatalf("expected age to increase with older creation times")
+		}
+		lastAge = age
+		got = append(got, row.GetBlockValue().Block.Content+":"+row.Cells[2].Value.String())
+	}
+	if 3 != len(got) || "2:2 days" != got[0] || "90:3 months" != got[1] || "400:1 year" != got[2] {
+		t.Fatalf("unexpected ages %v", got)
+	}
+
+	if _, err := updateAttributeViewValue(nil, attrView, nil, ageKey.ID, oldRowID, ast.NewNodeID(), map[string]interface{}{"number": map[string]interface{}{"content": 1}}); nil == err {
+		t.Fatalf("expected read-only age error")
+	}
+}
+
+func TestRenderAttributeViewTextLength(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	charsKey := addTestAttributeViewKey(attrView, "Chars", av.KeyTypeTextLength)
+	wordsKey := addTestAttributeViewKey(attrView, "Words", av.KeyTypeTextLength)
+	blockCharsKey := addTestAttributeViewKey(attrView, "Block chars", av.KeyTypeTextLength)
+	rowID := addTestAttributeViewRow(attrView, "思源笔记")
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: " Hello  wide world "}})
+	saveTestAttributeViews(t, attrView)
+
+	for _, keyID := range []string{charsKey.ID, wordsKey.ID} {
+		if err := updateAttributeViewColSourceKey(&Operation{AvID: attrView.ID, ID: keyID, KeyID: textKeyID}); nil != err {
+			t.Fatalf("update source key failed: %s", err)
+		}
+	}
+	if err := updateAttributeViewColCountMode(&Operation{AvID: attrView.ID, ID: wordsKey.ID, Data: string(av.TextLengthCountModeWords)}); nil != err {
+		t.Fatalf("update count mode failed: %s", err)
+	}
+	if err := updateAttributeViewColCountMode(&Operation{AvID: attrView.ID, ID: wordsKey.ID, Data: "lines"}); nil == err {
+		t.Fatalf("expected invalid count mode to be rejected")
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	expected := map[string]float64{charsKey.ID: 17, wordsKey.ID: 3, blockCharsKey.ID: 4}
+	for _, cell := range table.Rows[0].Cells {
+		if want, ok := expected[cell.Value.KeyID]; ok {
+			if got := cell.Value.Number.Content; want != got {
+				t.Fatalf("expected key [%s] length [%v], got [%v]", cell.Value.KeyID, want, got)
+			}
+			delete(expected, cell.Value.KeyID)
+		}
+	}
+	if 0 < len(expected) {
+		t.Fatalf("missing text length cells %v", expected)
+	}
+}
+
+func TestRenderAttributeViewRowDelta(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Value", av.KeyTypeNumber)
+	deltaKey := addTestAttributeViewKey(attrView, "Delta", av.KeyTypeRowDelta)
+	deltaKey.SourceKeyID = numKey.ID
+	for _, n := range []float64{13, 10, 20} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(int(n)))
+		setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: av.NewFormattedValueNumber(n, av.NumberFormatNone)})
+	}
+
+	deltas := func(order av.SortOrder) string {
+		attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: numKey.ID, Order: order}}
+		saveTestAttributeViews(t, attrView)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		var ret []string
+		for _, row := range table.Rows {
+			ret = append(ret, row.Cells[3].Value.String())
+		}
+		return strings.Join(ret, ",")
+	}
+	if got := deltas(av.SortOrderAsc); ",3,7" != got {
+		t.Fatalf("expected ascending deltas [,3,7], got [%s]", got)
+	}
+	if got := deltas(av.SortOrderDesc); ",-7,-3" != got {
+		t.Fatalf("expected descending deltas [,-7,-3], got [%s]", got)
+	}
+}
+
+func TestAddAttributeViewColumnDisallowedType(t *testing.T) {
+	setTestDataDir(t)
+	setTestConf(t, &AppConf{Editor: conf.NewEditor()})
+	Conf.Editor.AllowedAttrViewKeyTypes = []string{string(av.KeyTypeText), string(av.KeyTypeNumber)}
+
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	saveTestAttributeViews(t, attrView)
+
+	tx := &Transaction{}
+	tplKeyID := ast.NewNodeID()
+	if txErr := tx.doAddAttrViewColumn(&Operation{AvID: attrView.ID, ID: tplKeyID, Name: "Template", Typ: string(av.KeyTypeTemplate)}); nil == txErr {
+		t.Fatalf("expected template column to be rejected")
+	}
+	if err := updateAttributeViewColumn(&Operation{AvID: attrView.ID, ID: textKeyID, Name: "Text", Typ: string(av.KeyTypeTemplate)}); nil == err {
+		t.Fatalf("expected changing to template column to be rejected")
+	}
+	if err := addAttributeViewColumnWithConfig(&Operation{AvID: attrView.ID, ID: tplKeyID, Data: map[string]interface{}{"name": "Template", "type": string(av.KeyTypeTemplate)}}); nil == err {
+		t.Fatalf("expected template column with config to be rejected")
+	}
+	if err := addAttributeViewColumn(&Operation{AvID: attrView.ID, ID: ast.NewNodeID(), Name: "Score", Typ: string(av.KeyTypeNumber)}); nil != err {
+		t.Fatalf("add number column failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if _, err := attrView.GetKey(tplKeyID); nil == err {
+		t.Fatalf("template column should not be added")
+	}
+	if key, _ := attrView.GetKey(textKeyID); av.KeyTypeText != key.Type {
+		t.Fatalf("expected text column unchanged, got [%s]", key.Type)
+	}
+	if 3 != len(attrView.KeyValues) {
+		t.Fatalf("expected 3 columns, got %d", len(attrView.KeyValues))
+	}
+
+	tagsKey := addTestAttributeViewKey(attrView, "Tags", av.KeyTypeMSelect)
+	relKey := addTestAttributeViewKey(attrView, "Rel", av.KeyTypeRelation)
+	saveTestAttributeViews(t, attrView)
+	if _, err := ConvertMSelectToRelation(attrView.ID, tagsKey.ID, attrView.ID); nil == err {
+		t.Fatalf("expected converting to relation column to be rejected")
+	}
+	if _, _, err := LinkRelationsByText(attrView.ID, textKeyID, relKey.ID, attrView.ID); nil == err {
+		t.Fatalf("expected creating relation back column to be rejected")
+	}
+	if _, err := DuplicateAttributeView(attrView.ID, "Copy"); nil == err {
+		t.Fatalf("expected duplicating disallowed columns to be rejected")
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if key, _ := attrView.GetKey(tagsKey.ID); av.KeyTypeMSelect != key.Type {
+		t.Fatalf("expected select column unchanged, got [%s]", key.Type)
+	}
+	if 5 != len(attrView.KeyValues) {
+		t.Fatalf("expected 5 columns, got %d", len(attrView.KeyValues))
+	}
+}
+
+func TestGetAttributeViewDistinctValues(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	d1 := addTestAttributeViewRow(destAv, "Alpha")
+	d2 := addTestAttributeViewRow(destAv, "Beta")
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	tagKey := addTestAttributeViewKey(attrView, "Tags", av.KeyTypeMSelect)
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	for i, row := range []struct {
+		text   string
+		tags   []string
+		relIDs []string
+	}{{"b", []string{"x", "y"}, []string{d2}}, {"a", []string{"y"}, []string{d1, d2}}, {"b", nil, nil}, {"hidden", []string{"z"}, []string{d1}}} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: row.text}})
+		var tags []*av.ValueSelect
+		for _, tag := range row.tags {
+			tags = append(tags, &av.ValueSelect{Content: tag})
+		}
+		setTestAttributeViewValue(attrView, tagKey.ID, rowID, &av.Value{MSelect: tags})
+		setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: row.relIDs}})
+	}
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: textKeyID, Operator: av.FilterOperatorIsNotEqual, Value: &av.Value{Text: &av.ValueText{Content: "hidden"}}}}
+	saveTestAttributeViews(t, attrView)
+
+	for keyID, expected := range map[string]string{textKeyID: "a,b", tagKey.ID: "x,y", relKey.ID: "Alpha,Beta"} {
+		values, err := GetAttributeViewDistinctValues(attrView.ID, attrView.ViewID, keyID)
+		if nil != err {
+			t.Fatalf("get distinct values failed: %s", err)
+		}
+		if got := strings.Join(values, ","); expected != got {
+			t.Fatalf("key [%s] expected distinct values [%s], got [%s]", keyID, expected, got)
+		}
+	}
+
+	if _, err := GetAttributeViewDistinctValues(attrView.ID, attrView.ViewID, "missing"); nil == err {
+		t.Fatalf("expected missing key to be rejected")
+	}
+}
+
+func TestRenderAttributeViewFormulaReferencesRollup(t *testing.T) {
+	setTestDataDir(t)
+	attrView, _, _ := newTestRollupAttributeView(t, 3)
+	qtyKey := addTestAttributeViewKey(attrView, "Qty", av.KeyTypeNumber)
+	totalKey := addTestAttributeViewKey(attrView, "Line total", av.KeyTypeFormula)
+	countKey := addTestAttributeViewKey(attrView, "Relation count", av.KeyTypeFormula)
+	for _, v := range attrView.GetBlockKeyValues().Values {
+		setTestAttributeViewValue(attrView, qtyKey.ID, v.BlockID, &av.Value{Number: &av.ValueNumber{Content: 3, IsNotEmpty: true}})
+	}
+	saveTestAttributeViews(t, attrView)
+	for key, formula := range map[*av.Key]string{totalKey: "Total * Qty", countKey: "Relation * 10"} {
+		if err := updateAttributeViewColFormula(&Operation{AvID: attrView.ID, ID: key.ID, Data: formula}); nil != err {
+			t.Fatalf("update formula failed: %s", err)
+		}
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	for _, row := range table.Rows {
+		i, _ := strconv.Atoi(row.GetBlockValue().Block.Content)
+		for _, cell := range row.Cells {
+			switch cell.Value.KeyID {
+			case totalKey.ID:
+				if float64(i*3) != cell.Value.Number.Content {
+					t.Fatalf("expected line total [%d], got [%v]", i*3, cell.Value.Number.Content)
+				}
+			case countKey.ID:
+				if 10 != cell.Value.Number.Content {
+					t.Fatalf("expected relation count formula [10], got [%v]", cell.Value.Number.Content)
+				}
+			}
+		}
+	}
+}
+
+func TestUpdateAttributeViewColFormulaRejectsCycle(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo")
+	relKey := addTestAttributeViewKey(attrView, "Parent", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: attrView.ID}
+	formulaKey := addTestAttributeViewKey(attrView, "Double", av.KeyTypeFormula)
+	rollupKey := addTestAttributeViewKey(attrView, "Parent double", av.KeyTypeRollup)
+	rollupKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: formulaKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorSum}}
+	saveTestAttributeViews(t, attrView)
+
+	// 公式 -> 汇总 -> 公式
+	if err := updateAttributeViewColFormula(&Operation{AvID: attrView.ID, ID: formulaKey.ID, Data: "prop(\"Parent double\") * 2"}); nil == err {
+		t.Fatalf("expected circular reference rejected")
+	}
+	if err := updateAttributeViewColFormula(&Operation{AvID: attrView.ID, ID: formulaKey.ID, Data: "Unknown * 2"}); nil == err {
+		t.Fatalf("expected unknown key rejected")
+	}
+	if err := updateAttributeViewColFormula(&Operation{AvID: attrView.ID, ID: formulaKey.ID, Data: "Parent * 2"}); nil != err {
+		t.Fatalf("update formula failed: %s", err)
+	}
+}
@@ -0,0 +1,328 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestRenderAttributeViewFilterCreatedRelativeToToday(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	addTestAttributeViewRow(attrView, "today")
+	oldRowID := time.Now().AddDate(0, 0, -3).Format("20060102150405") + "-abcdefg"
+	addTestAttributeViewRowWithID(attrView, oldRowID, "old")
+	createdKey := addTestAttributeViewKey(attrView, "Created", av.KeyTypeCreated)
+
+	// 最近一天（昨天和今天）创建的行
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{
+		Column:       createdKey.ID,
+		Operator:     av.FilterOperatorIsRelativeToToday,
+		Value:        &av.Value{Type: av.KeyTypeCreated, Created: &av.ValueCreated{}},
+		RelativeDate: &av.RelativeDate{Count: 1, Unit: av.RelativeDateUnitDay, Direction: av.RelativeDateDirectionBefore},
+	}}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	if 1 != len(table.Rows) || "today" != table.Rows[0].GetBlockValue().Block.Content {
+		t.Fatalf("expected only the row created today, got %d rows", len(table.Rows))
+	}
+
+	attrView.Views[0].Table.Filters[0].RelativeDate.Count = 7
+	table = renderTestAttributeViewTable(t, attrView, nil)
+	if 2 != len(table.Rows) {
+		t.Fatalf("expected 2 rows created in the last 7 days")
+	}
+}
+
+func TestRenderAttributeViewRelationMatchesContext(t *testing.T) {
+	setTestDataDir(t)
+	masterAv := newTestAttributeView(t)
+	master1 := addTestAttributeViewRow(masterAv, "m1")
+	master2 := addTestAttributeViewRow(masterAv, "m2")
+	saveTestAttributeViews(t, masterAv)
+
+	detailAv := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(detailAv, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: masterAv.ID}
+	for i, masterID := range []string{master1, master1, master2} {
+		rowID := addTestAttributeViewRow(detailAv, "d"+strconv.Itoa(i))
+		setTestAttributeViewValue(detailAv, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{masterID}}})
+	}
+	addTestAttributeViewRow(detailAv, "unlinked")
+	detailAv.Views[0].Table.Filters = []*av.ViewFilter{{Column: relKey.ID, Operator: av.FilterOperatorRelationMatchesContext}}
+
+	table := renderTestAttributeViewTable(t, detailAv, &RenderAttributeViewOptions{RelationContextBlockID: master1})
+	if 2 != len(table.Rows) {
+		t.Fatalf("expected 2 rows linked to context block, got %d", len(table.Rows))
+	}
+
+	table = renderTestAttributeViewTable(t, detailAv, &RenderAttributeViewOptions{RelationContextBlockID: master2})
+	if 1 != len(table.Rows) {
+		t.Fatalf("expected 1 row linked to context block, got %d", len(table.Rows))
+	}
+
+	table = renderTestAttributeViewTable(t, detailAv, nil)
+	if 4 != len(table.Rows) {
+		t.Fatalf("expected all rows without context, got %d", len(table.Rows))
+	}
+}
+
+func TestApplyFilterAsDeletion(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	statusKey := addTestAttributeViewKey(attrView, "Status", av.KeyTypeSelect)
+	var activeRowIDs []string
+	for i, status := range []string{"Active", "Archived", "Active", ""} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		attrView.Views[0].Table.RowIDs = append(attrView.Views[0].Table.RowIDs, rowID)
+		if "" != status {
+			setTestAttributeViewValue(attrView, statusKey.ID, rowID, &av.Value{MSelect: []*av.ValueSelect{{Content: status}}})
+		}
+		if "Active" == status {
+			activeRowIDs = append(activeRowIDs, rowID)
+		}
+	}
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: statusKey.ID, Operator: av.FilterOperatorIsEqual, Value: &av.Value{MSelect: []*av.ValueSelect{{Content: "Active"}}}}}
+	saveTestAttributeViews(t, attrView)
+
+	kept, removed, err := ApplyFilterAsDeletion(attrView.ID, attrView.ViewID, true)
+	if nil != err {
+		t.Fatalf("dry run failed: %s", err)
+	}
+	if 2 != kept || 2 != removed {
+		t.Fatalf("unexpected dry run counts [%d, %d]", kept, removed)
+	}
+	if attrView, _ = av.ParseAttributeView(attrView.ID); 4 != len(attrView.GetBlockKeyValues().Values) {
+		t.Fatalf("dry run should not remove rows")
+	}
+
+	if _, removed, err = ApplyFilterAsDeletion(attrView.ID, attrView.ViewID, false); nil != err || 2 != removed {
+		t.Fatalf("apply filter as deletion failed: %v", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	blockValues := attrView.GetBlockKeyValues().Values
+	if 2 != len(blockValues) || !gulu.Str.Contains(blockValues[0].BlockID, activeRowIDs) || !gulu.Str.Contains(blockValues[1].BlockID, activeRowIDs) {
+		t.Fatalf("expected only active rows kept")
+	}
+	if 2 != len(attrView.Views[0].Table.RowIDs) {
+		t.Fatalf("expected removed rows pruned from view, got %v", attrView.Views[0].Table.RowIDs)
+	}
+}
+
+func TestFilterRowsValueChangedWithin(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	statusKey := addTestAttributeViewKey(attrView, "Status", av.KeyTypeText)
+	changedID := addTestAttributeViewRow(attrView, "changed")
+	staleID := addTestAttributeViewRow(attrView, "stale")
+	untouchedID := addTestAttributeViewRow(attrView, "untouched")
+	setTestAttributeViewValue(attrView, statusKey.ID, staleID, &av.Value{Text: &av.ValueText{Content: "old"}, UpdatedAt: time.Now().AddDate(0, 0, -3).UnixMilli()})
+	setTestAttributeViewValue(attrView, statusKey.ID, untouchedID, &av.Value{Text: &av.ValueText{Content: "legacy"}})
+
+	if _, err := updateAttributeViewValue(nil, attrView, nil, statusKey.ID, changedID, ast.NewNodeID(), map[string]interface{}{"text": map[string]interface{}{"content": "new"}}); nil != err {
+		t.Fatalf("update value failed: %s", err)
+	}
+	if 0 == attrView.GetValue(statusKey.ID, changedID).UpdatedAt {
+		t.Fatalf("expected value updated timestamp")
+	}
+
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: statusKey.ID, Operator: av.FilterOperatorValueChangedWithin, Days: 1}}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	if 1 != len(table.Rows) || changedID != table.Rows[0].ID {
+		t.Fatalf("expected only the recently changed row, got %d rows", len(table.Rows))
+	}
+
+	attrView.Views[0].Table.Filters[0].Days = 7
+	table = renderTestAttributeViewTable(t, attrView, nil)
+	if 2 != len(table.Rows) {
+		t.Fatalf("expected changed and stale rows within 7 days")
+	}
+}
+
+func TestFilterAttributeViewRelationAsymmetric(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	d1 := addTestAttributeViewRow(destAv, "d1")
+	d2 := addTestAttributeViewRow(destAv, "d2")
+	attrView := newTestAttributeView(t)
+	symmetricRowID := addTestAttributeViewRow(attrView, "symmetric")
+	asymmetricRowID := addTestAttributeViewRow(attrView, "asymmetric")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	backKey := addTestAttributeViewKey(destAv, "Back", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID, IsTwoWay: true, BackKeyID: backKey.ID}
+	backKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: relKey.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, symmetricRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{d1}}})
+	setTestAttributeViewValue(attrView, relKey.ID, asymmetricRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{d2}}})
+	setTestAttributeViewValue(destAv, backKey.ID, d1, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{symmetricRowID}}})
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: relKey.ID, Operator: av.FilterOperatorRelationAsymmetric}}
+	saveTestAttributeViews(t, destAv, attrView)
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rows := table.Rows
+	if 1 != len(rows) || asymmetricRowID != rows[0].ID {
+		t.Fatalf("expected only the asymmetric row, got %d rows", len(rows))
+	}
+}
+
+func TestRenderAttributeViewRowBindingFilter(t *testing.T) {
+	setTestDataDir(t)
+	tree := treenode.NewTree("box", "/"+ast.NewNodeID()+".sy", "/doc", "doc")
+	treenode.IndexBlockTree(tree)
+	boundID := tree.Root.FirstChild.ID
+
+	attrView := newTestAttributeView(t)
+	blockValues := attrView.GetBlockKeyValues()
+	blockValues.Values = append(blockValues.Values, &av.Value{
+		ID: ast.NewNodeID(), KeyID: blockValues.Key.ID, BlockID: boundID, Type: av.KeyTypeBlock,
+		Block: &av.ValueBlock{ID: boundID, Content: "bound"},
+	})
+	addTestAttributeViewRow(attrView, "detached1")
+	addTestAttributeViewRow(attrView, "detached2")
+	saveTestAttributeViews(t, attrView)
+
+	render := func(state av.RowBindingState) (ret []string) {
+		if err := setAttributeViewRowBindingFilter(&Operation{AvID: attrView.ID, Data: string(state)}); nil != err {
+			t.Fatalf("set row binding filter failed: %s", err)
+		}
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		for _, row := range table.Rows {
+			ret = append(ret, row.Cells[0].Value.String())
+		}
+		sort.Strings(ret)
+		return
+	}
+
+	if got := strings.Join(render(av.RowBindingStateDetached), ","); "detached1,detached2" != got {
+		t.Fatalf("expected only detached rows, got [%s]", got)
+	}
+	if got := strings.Join(render(av.RowBindingStateBound), ","); "bound" != got {
+		t.Fatalf("expected only bound rows, got [%s]", got)
+	}
+	if got := strings.Join(render(av.RowBindingStateAny), ","); "bound,detached1,detached2" != got {
+		t.Fatalf("expected all rows, got [%s]", got)
+	}
+	if err := setAttributeViewRowBindingFilter(&Operation{AvID: attrView.ID, Data: "unknown"}); nil == err {
+		t.Fatalf("expected invalid row binding state to be rejected")
+	}
+
+	// 按行是否绑定块过滤不是列过滤规则，按过滤规则删除行时不应删除被隐藏的行
+	render(av.RowBindingStateBound)
+	if kept, removed, err := ApplyFilterAsDeletion(attrView.ID, attrView.ViewID, true); nil != err || 3 != kept || 0 != removed {
+		t.Fatalf("expected hidden rows not to be removed, got [%d, %d, %v]", kept, removed, err)
+	}
+}
+
+func TestFilterRowsAuthorIsMe(t *testing.T) {
+	setTestDataDir(t)
+	setTestConf(t, &AppConf{Editor: conf.NewEditor()})
+
+	attrView := newTestAttributeView(t, "mine", "theirs")
+	blockValues := attrView.GetBlockKeyValues()
+	blockValues.Values[0].Block.CreatedBy = "alice"
+	blockValues.Values[1].Block.CreatedBy = "bob"
+	createdByKey := addTestAttributeViewKey(attrView, "Created by", av.KeyTypeCreatedBy)
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: createdByKey.ID, Operator: av.FilterOperatorAuthorIsMe}}
+	saveTestAttributeViews(t, attrView)
+
+	for _, c := range []struct {
+		user     *conf.User
+		expected []string
+	}{
+		{&conf.User{UserName: "alice"}, []string{"mine"}},
+		{&conf.User{UserName: "bob"}, []string{"theirs"}},
+		{nil, nil},
+	} {
+		Conf.SetUser(c.user)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		var contents []string
+		for _, row := range table.Rows {
+			contents = append(contents, row.GetBlockValue().Block.Content)
+		}
+		if strings.Join(c.expected, ",") != strings.Join(contents, ",") {
+			t.Fatalf("expected rows %v, got %v", c.expected, contents)
+		}
+	}
+
+	// 过滤条件中不保存当前用户，文件中只有行的创建者
+	data, err := os.ReadFile(filepath.Join(util.DataDir, "storage", "av", attrView.ID+".json"))
+	if nil != err {
+		t.Fatalf("read attribute view failed: %s", err)
+	}
+	if 1 != bytes.Count(data, []byte("alice")) {
+		t.Fatalf("expected the current user not stored in the filter")
+	}
+}
+
+func TestRenderAttributeViewVisibilityFormula(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	budgetKey := addTestAttributeViewKey(attrView, "Budget", av.KeyTypeNumber)
+	spentKey := addTestAttributeViewKey(attrView, "Spent", av.KeyTypeNumber)
+	for _, c := range []struct {
+		name          string
+		budget, spent float64
+	}{{"within", 100, 80}, {"over", 100, 120}, {"exact", 50, 50}} {
+		rowID := addTestAttributeViewRow(attrView, c.name)
+		setTestAttributeViewValue(attrView, budgetKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: c.budget, IsNotEmpty: true}})
+		setTestAttributeViewValue(attrView, spentKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: c.spent, IsNotEmpty: true}})
+	}
+	saveTestAttributeViews(t, attrView)
+
+	render := func() (ret []string) {
+		a, _ := av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, a, nil)
+		for _, row := range table.Rows {
+			ret = append(ret, row.GetBlockValue().Block.Content)
+		}
+		sort.Strings(ret)
+		return
+	}
+
+	// 隐藏超出预算的行
+	if err := setAttributeViewVisibilityFormula(&Operation{AvID: attrView.ID, Data: "not (Spent > Budget)"}); nil != err {
+		t.Fatalf("set visibility formula failed: %s", err)
+	}
+	if got := strings.Join(render(), ","); "exact,within" != got {
+		t.Fatalf("expected over-budget row hidden, got [%s]", got)
+	}
+
+	// 引用的列不存在时显示所有行
+	if err := setAttributeViewVisibilityFormula(&Operation{AvID: attrView.ID, Data: "Missing > Budget"}); nil != err {
+		t.Fatalf("set visibility formula failed: %s", err)
+	}
+	if got := strings.Join(render(), ","); "exact,over,within" != got {
+		t.Fatalf("expected all rows for an invalid formula, got [%s]", got)
+	}
+
+	if err := setAttributeViewVisibilityFormula(&Operation{AvID: attrView.ID, Data: "Spent >"}); nil == err {
+		t.Fatalf("expected unparseable formula rejected")
+	}
+}
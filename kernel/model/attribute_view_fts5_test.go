@@ -0,0 +1,79 @@
+//go:build fts5
+
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// 数据库使用了 FTS5 全文检索，所以需要使用 fts5 构建标签运行：go test -tags fts5
+
+func TestRenderAttributeViewBacklinkCount(t *testing.T) {
+	setTestDataDir(t)
+	util.DBPath = filepath.Join(t.TempDir(), "siyuan.db")
+	if err := sql.InitDatabase(true); nil != err {
+		t.Fatalf("init database failed: %s", err)
+	}
+	setTestConf(t, &AppConf{Lang: "en_US"}) // 写入数据库时会推送索引状态消息
+
+	attrView := newTestAttributeView(t)
+	detachedID := addTestAttributeViewRow(attrView, "detached")
+	boundID := ast.NewNodeID()
+	addTestAttributeViewRowWithID(attrView, boundID, "bound")
+	attrView.GetBlockKeyValues().GetValue(boundID).IsDetached = false
+	boundTree := treenode.NewTree("box", "/"+boundID+".sy", "/bound", "bound")
+	if err := filesys.WriteTree(boundTree); nil != err {
+		t.Fatalf("write tree failed: %s", err)
+	}
+	treenode.IndexBlockTree(boundTree)
+	countKey := addTestAttributeViewKey(attrView, "Backlinks", av.KeyTypeBacklinkCount)
+	saveTestAttributeViews(t, attrView)
+
+	// 在另一个文档中引用绑定块两次
+	refTreeID := ast.NewNodeID()
+	refTree := treenode.NewTree("box", "/"+refTreeID+".sy", "/ref", "ref")
+	for _, text := range []string{"a", "b"} {
+		para := &ast.Node{Type: ast.NodeParagraph, ID: ast.NewNodeID(), Box: refTree.Box, Path: refTree.Path}
+		para.SetIALAttr("id", para.ID)
+		para.AppendChild(&ast.Node{Type: ast.NodeTextMark, TextMarkType: "block-ref", TextMarkBlockRefID: boundID, TextMarkBlockRefSubtype: "s", TextMarkTextContent: text})
+		refTree.Root.AppendChild(para)
+	}
+	sql.UpsertTreeQueue(refTree)
+	sql.FlushQueue()
+
+	counts := map[string]float64{}
+	for _, row := range renderTestAttributeViewTable(t, attrView, nil).Rows {
+		counts[row.ID] = row.Cells[2].Value.Number.Content
+	}
+	if 2 != counts[boundID] || 0 != counts[detachedID] {
+		t.Fatalf("unexpected backlink counts %v", counts)
+	}
+
+	if _, err := updateAttributeViewValue(nil, attrView, nil, countKey.ID, boundID, ast.NewNodeID(), map[string]interface{}{"number": map[string]interface{}{"content": 1}}); nil == err {
+		t.Fatalf("expected read-only backlink count error")
+	}
+}
@@ -0,0 +1,220 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// saveTestHistoryAttributeView 将属性视图写入 created 对应的历史目录中。
+func saveTestHistoryAttributeView(t *testing.T, attrView *av.AttributeView, created time.Time) {
+	data, err := gulu.JSON.MarshalJSON(attrView)
+	if nil != err {
+		t.Fatalf("marshal attribute view failed: %s", err)
+	}
+	avDir := filepath.Join(util.HistoryDir, created.Format("2006-01-02-150405")+"-update", "storage", "av")
+	if err = os.MkdirAll(avDir, 0755); nil != err {
+		t.Fatalf("mkdir failed: %s", err)
+	}
+	if err = os.WriteFile(filepath.Join(avDir, attrView.ID+".json"), data, 0644); nil != err {
+		t.Fatalf("write history failed: %s", err)
+	}
+}
+
+func TestDiffAttributeViews(t *testing.T) {
+	setTestDataDir(t)
+	util.HistoryDir = t.TempDir()
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	textKeyID := attrView.KeyValues[1].Key.ID
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "old"}})
+	createdA := time.Now().Add(-time.Hour)
+	saveTestHistoryAttributeView(t, attrView, createdA)
+
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "new"}})
+	addedRowID := addTestAttributeViewRow(attrView, "bar")
+	numKey := addTestAttributeViewKey(attrView, "Number", av.KeyTypeNumber)
+	createdB := time.Now()
+	saveTestHistoryAttributeView(t, attrView, createdB)
+
+	diff, err := DiffAttributeViews(attrView.ID, strconv.FormatInt(createdA.Unix(), 10), strconv.FormatInt(createdB.Unix(), 10))
+	if nil != err {
+		t.Fatalf("diff attribute views failed: %s", err)
+	}
+	if 1 != len(diff.AddedRows) || addedRowID != diff.AddedRows[0] || 0 != len(diff.RemovedRows) {
+		t.Fatalf("unexpected row diff [%v, %v]", diff.AddedRows, diff.RemovedRows)
+	}
+	if 1 != len(diff.ChangedCells) || rowID != diff.ChangedCells[0].RowID || textKeyID != diff.ChangedCells[0].KeyID {
+		t.Fatalf("unexpected cell diff, got %d changed cells", len(diff.ChangedCells))
+	}
+	if "old" != diff.ChangedCells[0].OldValue.Text.Content || "new" != diff.ChangedCells[0].NewValue.Text.Content {
+		t.Fatalf("unexpected changed cell values")
+	}
+	if 1 != len(diff.AddedKeys) || numKey.ID != diff.AddedKeys[0].ID || 0 != len(diff.RemovedKeys) {
+		t.Fatalf("unexpected key diff")
+	}
+}
+
+func TestRestoreAttributeViewRow(t *testing.T) {
+	setTestDataDir(t)
+	util.HistoryDir = t.TempDir()
+	destAv := newTestAttributeView(t)
+	d1 := addTestAttributeViewRow(destAv, "d1")
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	otherRowID := addTestAttributeViewRow(attrView, "bar")
+	textKeyID := attrView.KeyValues[1].Key.ID
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	backKey := addTestAttributeViewKey(destAv, "Back", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID, IsTwoWay: true, BackKeyID: backKey.ID}
+	backKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: relKey.ID}
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "old"}})
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{d1}}})
+	setTestAttributeViewValue(destAv, backKey.ID, d1, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{rowID}}})
+	created := time.Now().Add(-time.Hour)
+	saveTestHistoryAttributeView(t, attrView, created)
+
+	// 快照之后修改单元格并解除关联
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "new"}})
+	setTestAttributeViewValue(attrView, textKeyID, otherRowID, &av.Value{Text: &av.ValueText{Content: "keep"}})
+	attrView.GetValue(relKey.ID, rowID).Relation.BlockIDs = nil
+	destAv.GetValue(backKey.ID, d1).Relation.BlockIDs = nil
+	saveTestAttributeViews(t, destAv, attrView)
+
+	if err := RestoreAttributeViewRow(attrView.ID, strconv.FormatInt(created.Unix(), 10), rowID); nil != err {
+		t.Fatalf("restore row failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	if got := attrView.GetValue(textKeyID, rowID).Text.Content; "old" != got {
+		t.Fatalf("expected restored [old], got [%s]", got)
+	}
+	if got := attrView.GetValue(textKeyID, otherRowID).Text.Content; "keep" != got {
+		t.Fatalf("expected other row untouched, got [%s]", got)
+	}
+	if got := attrView.GetValue(relKey.ID, rowID).Relation.BlockIDs; 1 != len(got) || d1 != got[0] {
+		t.Fatalf("unexpected restored relation %v", got)
+	}
+	if got := destAv.GetValue(backKey.ID, d1).Relation.BlockIDs; 1 != len(got) || rowID != got[0] {
+		t.Fatalf("unexpected restored back relation %v", got)
+	}
+}
+
+func TestMergeConflictedAttributeView(t *testing.T) {
+	setTestDataDir(t)
+	base := newTestAttributeView(t)
+	textKeyID := base.KeyValues[1].Key.ID
+	numKey := addTestAttributeViewKey(base, "Score", av.KeyTypeNumber)
+	rowID := addTestAttributeViewRow(base, "foo")
+	setTestAttributeViewValue(base, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "base"}, UpdatedAt: 100})
+	setTestAttributeViewValue(base, numKey.ID, rowID, &av.Value{Number: av.NewFormattedValueNumber(1, av.NumberFormatNone), UpdatedAt: 100})
+	data, _ := gulu.JSON.MarshalJSON(base)
+
+	// 本地修改文本并新增一行，远端修改数字
+	local, remote := &av.AttributeView{}, &av.AttributeView{}
+	gulu.JSON.UnmarshalJSON(data, local)
+	gulu.JSON.UnmarshalJSON(data, remote)
+	local.GetValue(textKeyID, rowID).Text.Content = "local"
+	local.GetValue(textKeyID, rowID).UpdatedAt = 200
+	localRowID := addTestAttributeViewRow(local, "bar")
+	local.Views[0].Table.RowIDs = append(local.Views[0].Table.RowIDs, localRowID)
+	remote.GetValue(numKey.ID, rowID).Number = av.NewFormattedValueNumber(2, av.NumberFormatNone)
+	remote.GetValue(numKey.ID, rowID).UpdatedAt = 300
+	saveTestAttributeViews(t, remote)
+	conflictPath := filepath.Join(t.TempDir(), base.ID+".json")
+	data, _ = gulu.JSON.MarshalJSON(local)
+	if err := os.WriteFile(conflictPath, data, 0644); nil != err {
+		t.Fatalf("write conflicted attribute view failed: %s", err)
+	}
+
+	if err := mergeConflictedAttributeView(base.ID, conflictPath); nil != err {
+		t.Fatalf("merge attribute view failed: %s", err)
+	}
+
+	merged, _ := av.ParseAttributeView(base.ID)
+	if got := merged.GetValue(textKeyID, rowID).Text.Content; "local" != got {
+		t.Fatalf("expected text [local], got [%s]", got)
+	}
+	if got := merged.GetValue(numKey.ID, rowID).Number.Content; 2 != got {
+		t.Fatalf("expected number [2], got [%v]", got)
+	}
+	if nil == merged.GetValue(merged.GetBlockKeyValues().Key.ID, localRowID) {
+		t.Fatalf("expected local row to be merged")
+	}
+	if !gulu.Str.Contains(localRowID, merged.Views[0].Table.RowIDs) {
+		t.Fatalf("expected local row id in view")
+	}
+	if 2 != len(merged.GetBlockKeyValues().Values) {
+		t.Fatalf("expected 2 rows, got %d", len(merged.GetBlockKeyValues().Values))
+	}
+}
+
+func TestGetAttributeViewValueTimeline(t *testing.T) {
+	setTestDataDir(t)
+	util.HistoryDir = t.TempDir()
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	textKeyID := attrView.KeyValues[1].Key.ID
+
+	now := time.Now()
+	todoSnapshot := now.Add(-3 * time.Hour)
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "todo"}})
+	saveTestHistoryAttributeView(t, attrView, todoSnapshot)
+
+	doingAt := now.Add(-150 * time.Minute).UnixMilli()
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "doing"}, UpdatedAt: doingAt})
+	saveTestHistoryAttributeView(t, attrView, now.Add(-2*time.Hour))
+	saveTestHistoryAttributeView(t, attrView, now.Add(-time.Hour)) // 值未变化的历史版本不产生新的条目
+
+	doneAt := now.Add(-30 * time.Minute).UnixMilli()
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "done"}, UpdatedAt: doneAt})
+	saveTestAttributeViews(t, attrView)
+
+	timeline, err := GetAttributeViewValueTimeline(attrView.ID, textKeyID, rowID)
+	if nil != err {
+		t.Fatalf("get value timeline failed: %s", err)
+	}
+	var contents []string
+	for _, entry := range timeline {
+		contents = append(contents, entry.Content)
+	}
+	if "todo,doing,done" != strings.Join(contents, ",") {
+		t.Fatalf("unexpected timeline [%s]", strings.Join(contents, ","))
+	}
+
+	// 历史目录名只精确到秒
+	todoStart := todoSnapshot.Truncate(time.Second).UnixMilli()
+	if todoStart != timeline[0].Start || doingAt-todoStart != timeline[0].Duration {
+		t.Fatalf("unexpected todo entry [start=%d, duration=%d]", timeline[0].Start, timeline[0].Duration)
+	}
+	if doingAt != timeline[1].Start || (2*time.Hour).Milliseconds() != timeline[1].Duration {
+		t.Fatalf("unexpected doing entry [start=%d, duration=%d]", timeline[1].Start, timeline[1].Duration)
+	}
+	if doneAt != timeline[2].Start || !timeline[2].Current || (30*time.Minute).Milliseconds() > timeline[2].Duration {
+		t.Fatalf("unexpected done entry [start=%d, duration=%d]", timeline[2].Start, timeline[2].Duration)
+	}
+}
@@ -0,0 +1,1110 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestRenderAttributeViewRelationCount(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	dest1 := addTestAttributeViewRow(destAv, "d1")
+	dest2 := addTestAttributeViewRow(destAv, "d2")
+	dest3 := addTestAttributeViewRow(destAv, "d3")
+
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{dest1, dest2, dest3}}})
+
+	// 删除目标属性视图中的一行，关联数量不应该包含这个失效的引用
+	blockValues := destAv.GetBlockKeyValues()
+	blockValues.Values = blockValues.Values[:2]
+	saveTestAttributeViews(t, destAv)
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	relVal := table.Rows[0].Cells[2].Value.Relation
+	if 2 != relVal.Count || 3 != len(relVal.BlockIDs) {
+		t.Fatalf("expected 2 linked blocks, got %d", relVal.Count)
+	}
+
+	// 关联块数量只在渲染时计算，保存时不修改调用方的属性视图
+	attrView.GetValue(relKey.ID, rowID).Relation.Count = 2
+	saveTestAttributeViews(t, attrView)
+	if 2 != attrView.GetValue(relKey.ID, rowID).Relation.Count {
+		t.Fatalf("expected relation count of the caller's attribute view to be kept")
+	}
+	data, err := os.ReadFile(av.GetAttributeViewDataPath(attrView.ID))
+	if nil != err {
+		t.Fatalf("read attribute view failed: %s", err)
+	}
+	if bytes.Contains(data, []byte(`"count":2`)) {
+		t.Fatalf("expected relation count not saved")
+	}
+
+	// 只关联了失效块时渲染结果中的数量为 0
+	deadRowID := addTestAttributeViewRow(attrView, "bar")
+	setTestAttributeViewValue(attrView, relKey.ID, deadRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{dest3}}})
+	table = renderTestAttributeViewTable(t, attrView, nil)
+	for _, row := range table.Rows {
+		if deadRowID != row.ID {
+			continue
+		}
+		cellData, _ := gulu.JSON.MarshalJSON(row.Cells[2].Value)
+		if !bytes.Contains(cellData, []byte(`"count":0`)) {
+			t.Fatalf("expected rendered relation count 0, got %s", cellData)
+		}
+	}
+
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: relKey.ID, Operator: av.FilterOperatorIsGreater, Value: &av.Value{Number: &av.ValueNumber{Content: 2}}}}
+	table = renderTestAttributeViewTable(t, attrView, nil)
+	if 0 != len(table.Rows) {
+		t.Fatalf("expected no rows with more than 2 linked blocks")
+	}
+}
+
+func TestRenderAttributeViewExpandRollups(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(destAv, "Number", av.KeyTypeNumber)
+	var destIDs []string
+	for i := 1; i <= 3; i++ {
+		destID := addTestAttributeViewRow(destAv, "d")
+		setTestAttributeViewValue(destAv, numKey.ID, destID, &av.Value{Number: &av.ValueNumber{Content: float64(i), IsNotEmpty: true}})
+		destIDs = append(destIDs, destID)
+	}
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: destIDs}})
+	rollupKey := addTestAttributeViewKey(attrView, "Rollup", av.KeyTypeRollup)
+	rollupKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: numKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorSum}}
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rollup := table.Rows[0].Cells[3].Value.Rollup
+	if 1 != len(rollup.Contents) || 6 != rollup.Contents[0].Number.Content || nil != rollup.Details {
+		t.Fatalf("unexpected rollup contents without details")
+	}
+
+	table = renderTestAttributeViewTable(t, attrView, &RenderAttributeViewOptions{ExpandRollups: true})
+	rollup = table.Rows[0].Cells[3].Value.Rollup
+	if 1 != len(rollup.Contents) || 6 != rollup.Contents[0].Number.Content {
+		t.Fatalf("unexpected rollup contents with details")
+	}
+	if 3 != len(rollup.Details) {
+		t.Fatalf("expected 3 rollup details, got %d", len(rollup.Details))
+	}
+	for i, detail := range rollup.Details {
+		if destIDs[i] != detail.BlockID || float64(i+1) != detail.Number.Content {
+			t.Fatalf("unexpected rollup detail [%s: %v]", detail.BlockID, detail.Number.Content)
+		}
+	}
+}
+
+func TestConvertMSelectToRelation(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	existingTagID := addTestAttributeViewRow(destAv, "go")
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	tagsKey := addTestAttributeViewKey(attrView, "Tags", av.KeyTypeMSelect)
+	tagsKey.Options = []*av.SelectOption{{Name: "go", Color: "1"}, {Name: "rust", Color: "2"}, {Name: "unused", Color: "3"}}
+	row1 := addTestAttributeViewRow(attrView, "r1")
+	row2 := addTestAttributeViewRow(attrView, "r2")
+	setTestAttributeViewValue(attrView, tagsKey.ID, row1, &av.Value{MSelect: []*av.ValueSelect{{Content: "go"}, {Content: "rust"}}})
+	setTestAttributeViewValue(attrView, tagsKey.ID, row2, &av.Value{MSelect: []*av.ValueSelect{{Content: "rust"}}})
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: tagsKey.ID, Order: av.SortOrderAsc}}
+	for _, column := range attrView.Views[0].Table.Columns {
+		if column.ID == tagsKey.ID {
+			column.Calc = &av.ColumnCalc{Operator: av.CalcOperatorCountUniqueValues}
+		}
+	}
+	saveTestAttributeViews(t, attrView)
+
+	relKeyID, err := ConvertMSelectToRelation(attrView.ID, tagsKey.ID, destAv.ID)
+	if nil != err {
+		t.Fatalf("convert failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if 0 < len(attrView.Views[0].Table.Sorts) {
+		t.Fatalf("expected sorts on converted column removed")
+	}
+	for _, column := range attrView.Views[0].Table.Columns {
+		if column.ID == relKeyID && nil != column.Calc {
+			t.Fatalf("expected calc on converted column reset")
+		}
+	}
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	relKey, _ := attrView.GetKey(relKeyID)
+	if nil == relKey || av.KeyTypeRelation != relKey.Type || destAv.ID != relKey.Relation.AvID || !relKey.Relation.IsTwoWay {
+		t.Fatalf("expected two-way relation key")
+	}
+	if 3 != len(destAv.GetBlockKeyValues().Values) {
+		t.Fatalf("expected 3 destination rows, got %d", len(destAv.GetBlockKeyValues().Values))
+	}
+
+	var rustTagID string
+	for _, v := range destAv.GetBlockKeyValues().Values {
+		if "rust" == v.Block.Content {
+			rustTagID = v.BlockID
+		}
+	}
+
+	rel1 := attrView.GetValue(relKeyID, row1).Relation.BlockIDs
+	if 2 != len(rel1) || existingTagID != rel1[0] || rustTagID != rel1[1] {
+		t.Fatalf("unexpected links %v", rel1)
+	}
+
+	backRel := destAv.GetValue(relKey.Relation.BackKeyID, rustTagID)
+	if nil == backRel || 2 != len(backRel.Relation.BlockIDs) || row1 != backRel.Relation.BlockIDs[0] || row2 != backRel.Relation.BlockIDs[1] {
+		t.Fatalf("unexpected back relation")
+	}
+	if !gulu.Str.Contains(destAv.ID, av.GetSrcAvIDs(attrView.ID)) || !gulu.Str.Contains(attrView.ID, av.GetSrcAvIDs(destAv.ID)) {
+		t.Fatalf("expected relations to be registered")
+	}
+}
+
+func TestRenderAttributeViewRelationHasOrphan(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	dest1 := addTestAttributeViewRow(destAv, "d1")
+	dest2 := addTestAttributeViewRow(destAv, "d2")
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	healthyRowID := addTestAttributeViewRow(attrView, "healthy")
+	setTestAttributeViewValue(attrView, relKey.ID, healthyRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{dest1}}})
+	orphanRowID := addTestAttributeViewRow(attrView, "orphan")
+	setTestAttributeViewValue(attrView, relKey.ID, orphanRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{dest1, dest2}}})
+	addTestAttributeViewRow(attrView, "empty")
+
+	// 删除目标属性视图中的 d2
+	blockValues := destAv.GetBlockKeyValues()
+	blockValues.Values = blockValues.Values[:1]
+	saveTestAttributeViews(t, destAv)
+
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: relKey.ID, Operator: av.FilterOperatorRelationHasOrphan}}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rows := table.Rows
+	if 1 != len(rows) || orphanRowID != rows[0].ID {
+		t.Fatalf("expected only the row with an orphaned link, got %d rows", len(rows))
+	}
+}
+
+func TestClearAttributeViewColRelationRollup(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	d1 := addTestAttributeViewRow(destAv, "d1")
+	attrView := newTestAttributeView(t, "foo")
+	rowID := attrView.GetBlockKeyValues().Values[0].BlockID
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	addTestAttributeViewKey(attrView, "Number", av.KeyTypeNumber)
+	backKey := addTestAttributeViewKey(destAv, "Back", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID, IsTwoWay: true, BackKeyID: backKey.ID}
+	backKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: relKey.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{d1}}})
+	setTestAttributeViewValue(destAv, backKey.ID, d1, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{rowID}}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	if err := clearAttributeViewColRelationRollup(&Operation{AvID: attrView.ID, ID: relKey.ID}); nil != err {
+		t.Fatalf("clear relation failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	keyValues, _ := attrView.GetKeyValues(relKey.ID)
+	if nil == keyValues || av.KeyTypeText != keyValues.Key.Type || nil != keyValues.Key.Relation || 0 != len(keyValues.Values) {
+		t.Fatalf("expected a blank text key")
+	}
+	if relKey.ID != attrView.Views[0].Table.Columns[2].ID {
+		t.Fatalf("expected column position preserved")
+	}
+
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	if _, err := destAv.GetKey(backKey.ID); nil == err {
+		t.Fatalf("expected back key removed")
+	}
+	for _, col := range destAv.Views[0].Table.Columns {
+		if backKey.ID == col.ID {
+			t.Fatalf("expected back key column removed")
+		}
+	}
+
+	if err := clearAttributeViewColRelationRollup(&Operation{AvID: attrView.ID, ID: relKey.ID}); nil == err {
+		t.Fatalf("expected error clearing a text key")
+	}
+}
+
+func TestRenderAttributeViewRichRelationContents(t *testing.T) {
+	setTestDataDir(t)
+	setTestConf(t, &AppConf{Editor: conf.NewEditor()})
+
+	// 绑定块是一个包含加粗的段落
+	tree := treenode.NewTree("box", "/"+ast.NewNodeID()+".sy", "/doc", "doc")
+	para := tree.Root.FirstChild
+	inlineTree := parse.Parse("", []byte("**bold** text"), util.NewLute().ParseOptions)
+	for c := inlineTree.Root.FirstChild.FirstChild; nil != c; {
+		next := c.Next
+		para.AppendChild(c)
+		c = next
+	}
+	if err := filesys.WriteTree(tree); nil != err {
+		t.Fatalf("write tree failed: %s", err)
+	}
+	treenode.IndexBlockTree(tree)
+
+	destAv := newTestAttributeView(t)
+	addTestAttributeViewRowWithID(destAv, para.ID, "bold text")
+	destAv.GetBlockKeyValues().GetValue(para.ID).IsDetached = false
+	detachedID := addTestAttributeViewRow(destAv, "detached")
+	attrView := newTestAttributeView(t, "foo")
+	rowID := attrView.GetBlockKeyValues().Values[0].BlockID
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{para.ID, detachedID}}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	for _, c := range []struct {
+		opts     *RenderAttributeViewOptions
+		expected string
+	}{
+		{nil, "bold text"},
+		{&RenderAttributeViewOptions{RichRelationContents: true}, "**bold** text"},
+	} {
+		table := renderTestAttributeViewTable(t, attrView, c.opts)
+		contents := table.Rows[0].Cells[2].Value.Relation.Contents
+		if 2 != len(contents) || c.expected != contents[0] || "detached" != contents[1] {
+			t.Fatalf("expected relation contents [%s detached], got %v", c.expected, contents)
+		}
+	}
+}
+
+func TestRenderAttributeViewRelationDisplayLimit(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	var destIDs []string
+	for i := 1; i <= 15; i++ {
+		destIDs = append(destIDs, addTestAttributeViewRow(destAv, "d"+strconv.Itoa(i)))
+	}
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: destIDs}})
+	saveTestAttributeViews(t, attrView)
+
+	if err := setAttributeViewColRelationDisplayLimit(&Operation{AvID: attrView.ID, ID: relKey.ID, Data: float64(3)}); nil != err {
+		t.Fatalf("set display limit failed: %s", err)
+	}
+	if err := setAttributeViewColRelationDisplayLimit(&Operation{AvID: attrView.ID, ID: attrView.KeyValues[1].Key.ID, Data: float64(3)}); nil == err {
+		t.Fatalf("expected non-relation key error")
+	}
+
+	// 过滤仍然基于完整的关联内容
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: relKey.ID, Operator: av.FilterOperatorContains, Value: &av.Value{Relation: &av.ValueRelation{Contents: []string{"d15"}}}}}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	if 1 != len(table.Rows) {
+		t.Fatalf("expected 1 row, got %d", len(table.Rows))
+	}
+	relVal := table.Rows[0].Cells[2].Value.Relation
+	if 3 != len(relVal.Contents) || 12 != relVal.More || 15 != len(relVal.BlockIDs) || "d1" != relVal.Contents[0] {
+		t.Fatalf("unexpected relation contents %v, more %d", relVal.Contents, relVal.More)
+	}
+}
+
+func TestRenderAttributeViewRollupIgnoreZero(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(destAv, "Number", av.KeyTypeNumber)
+	var destIDs []string
+	for _, n := range []float64{0, 3, 6} {
+		destID := addTestAttributeViewRow(destAv, "d")
+		setTestAttributeViewValue(destAv, numKey.ID, destID, &av.Value{Number: &av.ValueNumber{Content: n, IsNotEmpty: true}})
+		destIDs = append(destIDs, destID)
+	}
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: destIDs}})
+	rollupKey := addTestAttributeViewKey(attrView, "Rollup", av.KeyTypeRollup)
+	saveTestAttributeViews(t, attrView)
+
+	average := func(ignoreZero bool) float64 {
+		calc := map[string]interface{}{"operator": string(av.CalcOperatorAverage), "ignoreZero": ignoreZero}
+		op := &Operation{AvID: attrView.ID, ID: rollupKey.ID, ParentID: relKey.ID, KeyID: numKey.ID, Data: map[string]interface{}{"calc": calc}}
+		if err := updateAttributeViewColRollup(op); nil != err {
+			t.Fatalf("update rollup failed: %s", err)
+		}
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		return table.Rows[0].Cells[3].Value.Rollup.Contents[0].Number.Content
+	}
+
+	if avg := average(false); 3 != avg {
+		t.Fatalf("expected average 3, got %v", avg)
+	}
+	if avg := average(true); 4.5 != avg {
+		t.Fatalf("expected average 4.5 excluding zeros, got %v", avg)
+	}
+}
+
+func TestLinkAttributeViewRelations(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	d1 := addTestAttributeViewRow(destAv, "d1")
+	d2 := addTestAttributeViewRow(destAv, "d2")
+	d3 := addTestAttributeViewRow(destAv, "d3")
+
+	attrView := newTestAttributeView(t)
+	row1 := addTestAttributeViewRow(attrView, "r1")
+	row2 := addTestAttributeViewRow(attrView, "r2")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	backKey := addTestAttributeViewKey(destAv, "Back", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID, IsTwoWay: true, BackKeyID: backKey.ID, MaxEntries: 2}
+	backKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: relKey.ID}
+	saveTestAttributeViews(t, destAv, attrView)
+
+	links := map[string][]string{
+		row1:            {d1, d2, d3},          // d3 超出 MaxEntries
+		row2:            {d2, ast.NewNodeID()}, // 未知目标块
+		ast.NewNodeID(): {d1},                  // 未知源行
+	}
+	if err := LinkAttributeViewRelations(attrView.ID, relKey.ID, links); nil != err {
+		t.Fatalf("link relations failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	if got := attrView.GetValue(relKey.ID, row1).Relation.BlockIDs; 2 != len(got) || d1 != got[0] || d2 != got[1] {
+		t.Fatalf("unexpected relation of row1 %v", got)
+	}
+	if got := attrView.GetValue(relKey.ID, row2).Relation.BlockIDs; 1 != len(got) || d2 != got[0] {
+		t.Fatalf("unexpected relation of row2 %v", got)
+	}
+	if got := destAv.GetValue(backKey.ID, d1).Relation.BlockIDs; 1 != len(got) || row1 != got[0] {
+		t.Fatalf("unexpected back relation of d1 %v", got)
+	}
+	if got := destAv.GetValue(backKey.ID, d2).Relation.BlockIDs; 2 != len(got) {
+		t.Fatalf("unexpected back relation of d2 %v", got)
+	}
+	if nil != destAv.GetValue(backKey.ID, d3) {
+		t.Fatalf("expected no back relation of d3")
+	}
+}
+
+func TestSetAttributeViewColRelationMaxEntries(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	d1 := addTestAttributeViewRow(destAv, "d1")
+	d2 := addTestAttributeViewRow(destAv, "d2")
+	d3 := addTestAttributeViewRow(destAv, "d3")
+
+	attrView := newTestAttributeView(t)
+	row := addTestAttributeViewRow(attrView, "r1")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	saveTestAttributeViews(t, destAv, attrView)
+
+	if err := setAttributeViewColRelationMaxEntries(&Operation{AvID: attrView.ID, ID: attrView.KeyValues[0].Key.ID, Data: float64(2)}); nil == err {
+		t.Fatalf("expected error when setting max entries of a non-relation key")
+	}
+	if err := setAttributeViewColRelationMaxEntries(&Operation{AvID: attrView.ID, ID: relKey.ID, Data: float64(2)}); nil != err {
+		t.Fatalf("set max entries failed: %s", err)
+	}
+	if err := LinkAttributeViewRelations(attrView.ID, relKey.ID, map[string][]string{row: {d1, d2, d3}}); nil != err {
+		t.Fatalf("link relations failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if got := attrView.GetValue(relKey.ID, row).Relation.BlockIDs; 2 != len(got) {
+		t.Fatalf("expected 2 related blocks, got %v", got)
+	}
+
+	// 已有单元格超出新的限制时拒绝设置
+	if err := setAttributeViewColRelationMaxEntries(&Operation{AvID: attrView.ID, ID: relKey.ID, Data: float64(1)}); nil == err {
+		t.Fatalf("expected error when existing cells exceed max entries")
+	}
+	if err := setAttributeViewColRelationMaxEntries(&Operation{AvID: attrView.ID, ID: relKey.ID, Data: float64(-1)}); nil != err {
+		t.Fatalf("clear max entries failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if key, _ := attrView.GetKey(relKey.ID); 0 != key.Relation.MaxEntries {
+		t.Fatalf("expected max entries to be cleared, got %d", key.Relation.MaxEntries)
+	}
+}
+
+func TestRenderAttributeViewRollupLatestValue(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	statusKey := destAv.KeyValues[1].Key
+	var destIDs []string
+	for i, status := range []string{"todo", "doing", ""} {
+		destID := addTestAttributeViewRow(destAv, "d")
+		destAv.GetBlockKeyValues().GetValue(destID).Block.Updated = int64(1000 * []int{2, 3, 1}[i])
+		setTestAttributeViewValue(destAv, statusKey.ID, destID, &av.Value{Text: &av.ValueText{Content: status}})
+		destIDs = append(destIDs, destID)
+	}
+	// 最新的关联块值为空，应该跳过
+	destAv.GetBlockKeyValues().GetValue(destIDs[2]).Block.Updated = 9000
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: destIDs}})
+	rollupKey := addTestAttributeViewKey(attrView, "Status", av.KeyTypeRollup)
+	rollupKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: statusKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorLatestValue}}
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rollup := table.Rows[0].Cells[3].Value.Rollup
+	if 1 != len(rollup.Contents) || "doing" != rollup.Contents[0].String() {
+		t.Fatalf("expected latest value [doing], got %v", rollup.Contents)
+	}
+}
+
+func TestGetRelatedRowsPreview(t *testing.T) {
+	setTestDataDir(t)
+	tasksAv := newTestAttributeView(t)
+	statusKey := addTestAttributeViewKey(tasksAv, "Status", av.KeyTypeSelect)
+	estimateKey := addTestAttributeViewKey(tasksAv, "Estimate", av.KeyTypeNumber)
+	var taskIDs []string
+	for i, status := range []string{"Doing", "Done"} {
+		taskID := addTestAttributeViewRow(tasksAv, "task"+strconv.Itoa(i))
+		setTestAttributeViewValue(tasksAv, statusKey.ID, taskID, &av.Value{MSelect: []*av.ValueSelect{{Content: status}}})
+		setTestAttributeViewValue(tasksAv, estimateKey.ID, taskID, &av.Value{Number: &av.ValueNumber{Content: float64(i + 1), IsNotEmpty: true}})
+		taskIDs = append(taskIDs, taskID)
+	}
+	saveTestAttributeViews(t, tasksAv)
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Tasks", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: tasksAv.ID}
+	rowID := addTestAttributeViewRow(attrView, "project")
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{taskIDs[1], ast.NewNodeID(), taskIDs[0]}}})
+	saveTestAttributeViews(t, attrView)
+
+	rows, err := GetRelatedRowsPreview(attrView.ID, relKey.ID, rowID, []string{statusKey.ID, estimateKey.ID})
+	if nil != err {
+		t.Fatalf("get related rows preview failed: %s", err)
+	}
+	if 2 != len(rows) {
+		t.Fatalf("expected 2 related rows, got %d", len(rows))
+	}
+	if taskIDs[1] != rows[0]["id"] || "Done" != rows[0][statusKey.ID] || "2" != rows[0][estimateKey.ID] {
+		t.Fatalf("unexpected first preview %v", rows[0])
+	}
+	if taskIDs[0] != rows[1]["id"] || "Doing" != rows[1][statusKey.ID] || "1" != rows[1][estimateKey.ID] {
+		t.Fatalf("unexpected second preview %v", rows[1])
+	}
+
+	if _, err = GetRelatedRowsPreview(attrView.ID, attrView.KeyValues[1].Key.ID, rowID, nil); nil == err {
+		t.Fatalf("expected error for non-relation key")
+	}
+}
+
+func TestRenderAttributeViewRollupFirstRelated(t *testing.T) {
+	setTestDataDir(t)
+	tasksAv := newTestAttributeView(t)
+	statusKey := addTestAttributeViewKey(tasksAv, "Status", av.KeyTypeSelect)
+	var taskIDs []string
+	for _, status := range []string{"Doing", "Done"} {
+		taskID := addTestAttributeViewRow(tasksAv, "task")
+		setTestAttributeViewValue(tasksAv, statusKey.ID, taskID, &av.Value{MSelect: []*av.ValueSelect{{Content: status}}})
+		taskIDs = append(taskIDs, taskID)
+	}
+	saveTestAttributeViews(t, tasksAv)
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Tasks", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: tasksAv.ID}
+	firstKey := addTestAttributeViewKey(attrView, "First task status", av.KeyTypeRollup)
+	firstKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: statusKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorFirstRelated}}
+	rowID := addTestAttributeViewRow(attrView, "project")
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{taskIDs[1], taskIDs[0]}}})
+	emptyRowID := addTestAttributeViewRow(attrView, "empty")
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	for _, row := range table.Rows {
+		rollup := row.Cells[3].Value.Rollup
+		switch row.ID {
+		case rowID:
+			if 1 != len(rollup.Contents) || "Done" != rollup.Contents[0].String() {
+				t.Fatalf("expected first related status [Done], got %v", rollup.Contents)
+			}
+		case emptyRowID:
+			if 0 != len(rollup.Contents) || "" != row.Cells[3].Value.String() {
+				t.Fatalf("expected empty rollup for empty relation")
+			}
+		}
+	}
+}
+
+func TestSearchRelatableAttributeViews(t *testing.T) {
+	setTestDataDir(t)
+	var avIDs []string
+	for _, name := range []string{"Project tasks", "Team tasks", "Meeting notes"} {
+		attrView := newTestAttributeView(t)
+		attrView.Name = name
+		saveTestAttributeViews(t, attrView)
+		avIDs = append(avIDs, attrView.ID)
+	}
+
+	results, err := SearchRelatableAttributeViews("TASKS")
+	if nil != err {
+		t.Fatalf("search relatable attribute views failed: %s", err)
+	}
+	var gotIDs []string
+	for _, result := range results {
+		gotIDs = append(gotIDs, result.AvID)
+	}
+	sort.Strings(gotIDs)
+	expected := []string{avIDs[0], avIDs[1]}
+	sort.Strings(expected)
+	if 2 != len(gotIDs) || expected[0] != gotIDs[0] || expected[1] != gotIDs[1] {
+		t.Fatalf("expected attribute views %v, got %v", expected, gotIDs)
+	}
+
+	results, err = SearchRelatableAttributeViews("tasks", avIDs[0])
+	if nil != err {
+		t.Fatalf("search relatable attribute views failed: %s", err)
+	}
+	if 1 != len(results) || avIDs[1] != results[0].AvID || "Team tasks" != results[0].AvName {
+		t.Fatalf("expected only the other attribute view when excluding the current one")
+	}
+}
+
+func TestGetRelationTargetInfo(t *testing.T) {
+	setTestDataDir(t)
+	setTestConf(t, &AppConf{FileTree: conf.NewFileTree()}) // 解析可读路径时需要列出笔记本
+	destAv := newTestAttributeView(t, "task")
+	destAv.Name = "Tasks"
+	saveTestAttributeViews(t, destAv)
+
+	// 目标属性视图嵌入在一个文档中
+	tree := treenode.NewTree("box", "/"+ast.NewNodeID()+".sy", "/Projects/Board", "Board")
+	avNode := &ast.Node{Type: ast.NodeAttributeView, ID: ast.NewNodeID(), AttributeViewID: destAv.ID, AttributeViewType: string(av.LayoutTypeTable)}
+	avNode.SetIALAttr("id", avNode.ID)
+	tree.Root.AppendChild(avNode)
+	if err := filesys.WriteTree(tree); nil != err {
+		t.Fatalf("write tree failed: %s", err)
+	}
+	treenode.IndexBlockTree(tree)
+	av.UpsertBlockRel(destAv.ID, avNode.ID)
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Tasks", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	missingKey := addTestAttributeViewKey(attrView, "Missing", av.KeyTypeRelation)
+	missingKey.Relation = &av.Relation{AvID: ast.NewNodeID()}
+	saveTestAttributeViews(t, attrView)
+
+	destAvID, destAvName, mirrors, err := GetRelationTargetInfo(attrView.ID, relKey.ID)
+	if nil != err {
+		t.Fatalf("get relation target info failed: %s", err)
+	}
+	if destAv.ID != destAvID || "Tasks" != destAvName {
+		t.Fatalf("unexpected relation target [%s, %s]", destAvID, destAvName)
+	}
+	if 1 != len(mirrors) || avNode.ID != mirrors[0].BlockID || "/Projects/Board" != mirrors[0].HPath {
+		t.Fatalf("unexpected relation target mirrors %v", mirrors)
+	}
+
+	destAvID, destAvName, mirrors, err = GetRelationTargetInfo(attrView.ID, missingKey.ID)
+	if nil != err || missingKey.Relation.AvID != destAvID || "" != destAvName || 0 != len(mirrors) {
+		t.Fatalf("expected missing relation target handled gracefully")
+	}
+
+	if _, _, _, err = GetRelationTargetInfo(attrView.ID, attrView.KeyValues[1].Key.ID); nil == err {
+		t.Fatalf("expected non-relation key error")
+	}
+}
+
+func TestRenderAttributeViewRollupPercentOfParent(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	budgetKey := addTestAttributeViewKey(attrView, "Budget", av.KeyTypeNumber)
+	parentKey := addTestAttributeViewKey(attrView, "Parent", av.KeyTypeRelation)
+	parentKey.Relation = &av.Relation{AvID: attrView.ID}
+	shareKey := addTestAttributeViewKey(attrView, "Share", av.KeyTypeRollup)
+	shareKey.Rollup = &av.Rollup{RelationKeyID: parentKey.ID, KeyID: budgetKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorPercentOfParent}}
+
+	parentID := addTestAttributeViewRow(attrView, "parent")
+	setTestAttributeViewValue(attrView, budgetKey.ID, parentID, &av.Value{Number: av.NewFormattedValueNumber(100, av.NumberFormatNone)})
+	for content, budget := range map[string]float64{"child1": 30, "child2": 90} {
+		childID := addTestAttributeViewRow(attrView, content)
+		setTestAttributeViewValue(attrView, budgetKey.ID, childID, &av.Value{Number: av.NewFormattedValueNumber(budget, av.NumberFormatNone)})
+		setTestAttributeViewValue(attrView, parentKey.ID, childID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{parentID}}})
+	}
+	saveTestAttributeViews(t, attrView)
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	got := map[string]string{}
+	for _, row := range table.Rows {
+		got[row.GetBlockValue().Block.Content] = row.Cells[4].Value.String()
+	}
+	// 子行占父行下所有子行之和的比例，没有父行时为 100%
+	if "100%" != got["parent"] || "25%" != got["child1"] || "75%" != got["child2"] {
+		t.Fatalf("unexpected percent of parent %v", got)
+	}
+}
+
+func TestSplitTwoWayRelation(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	d1 := addTestAttributeViewRow(destAv, "d1")
+	attrView := newTestAttributeView(t)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	backKey := addTestAttributeViewKey(destAv, "Back", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID, IsTwoWay: true, BackKeyID: backKey.ID}
+	backKey.Relation = &av.Relation{AvID: attrView.ID, IsTwoWay: true, BackKeyID: relKey.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{d1}}})
+	setTestAttributeViewValue(destAv, backKey.ID, d1, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{rowID}}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	if err := splitTwoWayRelation(&Operation{AvID: attrView.ID, KeyID: relKey.ID}); nil != err {
+		t.Fatalf("split relation failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	relKey, _ = attrView.GetKey(relKey.ID)
+	backKey, _ = destAv.GetKey(backKey.ID)
+	if relKey.Relation.IsTwoWay || "" != relKey.Relation.BackKeyID || destAv.ID != relKey.Relation.AvID {
+		t.Fatalf("unexpected source relation %+v", relKey.Relation)
+	}
+	if backKey.Relation.IsTwoWay || "" != backKey.Relation.BackKeyID || attrView.ID != backKey.Relation.AvID {
+		t.Fatalf("unexpected back relation %+v", backKey.Relation)
+	}
+	if got := attrView.GetValue(relKey.ID, rowID).Relation.BlockIDs; 1 != len(got) || d1 != got[0] {
+		t.Fatalf("unexpected source links %v", got)
+	}
+	if got := destAv.GetValue(backKey.ID, d1).Relation.BlockIDs; 1 != len(got) || rowID != got[0] {
+		t.Fatalf("unexpected back links %v", got)
+	}
+	if !gulu.Str.Contains(attrView.ID, av.GetSrcAvIDs(destAv.ID)) || !gulu.Str.Contains(destAv.ID, av.GetSrcAvIDs(attrView.ID)) {
+		t.Fatalf("unexpected relations bookkeeping")
+	}
+}
+
+func TestRenderAttributeViewRollupPercentDone(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	doneKey := addTestAttributeViewKey(destAv, "Done", av.KeyTypeCheckbox)
+	statusKey := addTestAttributeViewKey(destAv, "Status", av.KeyTypeSelect)
+	var taskIDs []string
+	for i, done := range []bool{true, false, true, false} {
+		taskID := addTestAttributeViewRow(destAv, "task"+strconv.Itoa(i))
+		setTestAttributeViewValue(destAv, doneKey.ID, taskID, &av.Value{Checkbox: &av.ValueCheckbox{Checked: done}})
+		status := "Todo"
+		if done {
+			status = "Done"
+		}
+		setTestAttributeViewValue(destAv, statusKey.ID, taskID, &av.Value{MSelect: []*av.ValueSelect{{Content: status}}})
+		taskIDs = append(taskIDs, taskID)
+	}
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Tasks", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	checkboxKey := addTestAttributeViewKey(attrView, "Progress", av.KeyTypeRollup)
+	checkboxKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: doneKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorPercentDone}}
+	selectKey := addTestAttributeViewKey(attrView, "Status progress", av.KeyTypeRollup)
+	selectKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: statusKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorPercentDone, DoneOption: "Done"}}
+	projectID := addTestAttributeViewRow(attrView, "project")
+	setTestAttributeViewValue(attrView, relKey.ID, projectID, &av.Value{Relation: &av.ValueRelation{BlockIDs: taskIDs}})
+	emptyID := addTestAttributeViewRow(attrView, "empty")
+	setTestAttributeViewValue(attrView, relKey.ID, emptyID, &av.Value{Relation: &av.ValueRelation{}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	for _, row := range table.Rows {
+		expected := "50%"
+		if row.ID == emptyID {
+			expected = ""
+		}
+		for _, cell := range row.Cells {
+			if cell.Value.KeyID != checkboxKey.ID && cell.Value.KeyID != selectKey.ID {
+				continue
+			}
+			if got := cell.Value.String(); expected != got {
+				t.Fatalf("expected row [%s] progress [%s], got [%s]", row.ID, expected, got)
+			}
+		}
+	}
+}
+
+func TestGetAttributeViewRelationGraph(t *testing.T) {
+	setTestDataDir(t)
+	projects, tasks, people := newTestAttributeView(t), newTestAttributeView(t), newTestAttributeView(t)
+	projects.Name, tasks.Name, people.Name = "Projects", "Tasks", "People"
+	projectTasks := addTestAttributeViewKey(projects, "Tasks", av.KeyTypeRelation)
+	taskProject := addTestAttributeViewKey(tasks, "Project", av.KeyTypeRelation)
+	projectTasks.Relation = &av.Relation{AvID: tasks.ID, IsTwoWay: true, BackKeyID: taskProject.ID}
+	taskProject.Relation = &av.Relation{AvID: projects.ID, IsTwoWay: true, BackKeyID: projectTasks.ID}
+	taskOwner := addTestAttributeViewKey(tasks, "Owner", av.KeyTypeRelation)
+	taskOwner.Relation = &av.Relation{AvID: people.ID}
+	personLead := addTestAttributeViewKey(people, "Leads", av.KeyTypeRelation)
+	personLead.Relation = &av.Relation{AvID: projects.ID}
+	orphan := addTestAttributeViewKey(people, "Orphan", av.KeyTypeRelation)
+	orphan.Relation = &av.Relation{AvID: ast.NewNodeID()}
+	saveTestAttributeViews(t, projects, tasks, people)
+
+	graph, err := GetAttributeViewRelationGraph()
+	if nil != err {
+		t.Fatalf("get relation graph failed: %s", err)
+	}
+	if 3 != len(graph.Nodes) {
+		t.Fatalf("expected 3 nodes, got %d", len(graph.Nodes))
+	}
+
+	var got []string
+	for _, edge := range graph.Edges {
+		if edge.IsTwoWay {
+			// 双向关联可能从任意一侧开始扫描
+			if edge.SrcKeyID == taskProject.ID {
+				edge.SrcAvID, edge.SrcKeyID, edge.DestAvID, edge.DestKeyID = edge.DestAvID, edge.DestKeyID, edge.SrcAvID, edge.SrcKeyID
+			}
+			got = append(got, edge.SrcAvID+":"+edge.SrcKeyID+"<->"+edge.DestAvID+":"+edge.DestKeyID)
+			continue
+		}
+		got = append(got, edge.SrcAvID+":"+edge.SrcKeyID+"->"+edge.DestAvID)
+	}
+	sort.Strings(got)
+	expected := []string{
+		projects.ID + ":" + projectTasks.ID + "<->" + tasks.ID + ":" + taskProject.ID,
+		tasks.ID + ":" + taskOwner.ID + "->" + people.ID,
+		people.ID + ":" + personLead.ID + "->" + projects.ID,
+	}
+	sort.Strings(expected)
+	if strings.Join(expected, ",") != strings.Join(got, ",") {
+		t.Fatalf("expected edges %v, got %v", expected, got)
+	}
+}
+
+func TestRenderAttributeViewRollupCountByOption(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	priorityKey := addTestAttributeViewKey(destAv, "Priority", av.KeyTypeSelect)
+	priorityKey.Options = []*av.SelectOption{{Name: "Low"}, {Name: "High"}}
+	var taskIDs []string
+	for i, priority := range []string{"High", "Low", "High", "", "Low", "High"} {
+		taskID := addTestAttributeViewRow(destAv, "task"+strconv.Itoa(i))
+		if "" != priority {
+			setTestAttributeViewValue(destAv, priorityKey.ID, taskID, &av.Value{MSelect: []*av.ValueSelect{{Content: priority}}})
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Tasks", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	rollupKey := addTestAttributeViewKey(attrView, "By priority", av.KeyTypeRollup)
+	rollupKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: priorityKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorCountByOption}}
+	projectID := addTestAttributeViewRow(attrView, "project")
+	setTestAttributeViewValue(attrView, relKey.ID, projectID, &av.Value{Relation: &av.ValueRelation{BlockIDs: taskIDs}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rollup := table.Rows[0].Cells[3].Value.Rollup
+	if got := rollup.Contents[0].String(); "3 High, 2 Low, 1 (none)" != got {
+		t.Fatalf("expected breakdown [3 High, 2 Low, 1 (none)], got [%s]", got)
+	}
+	if 3 != rollup.Breakdown["High"] || 2 != rollup.Breakdown["Low"] || 1 != rollup.Breakdown[av.RollupNoneOption] {
+		t.Fatalf("unexpected breakdown %v", rollup.Breakdown)
+	}
+}
+
+func TestUpdateAttributeViewBlockContentsRefreshesRelatedViews(t *testing.T) {
+	setTestDataDir(t)
+	setTestConf(t, &AppConf{Editor: conf.NewEditor()})
+
+	var refreshed []string
+	oldBroadcast := broadcastRefreshAttributeView
+	broadcastRefreshAttributeView = func(avID string) { refreshed = append(refreshed, avID) }
+	defer func() { broadcastRefreshAttributeView = oldBroadcast }()
+
+	// 目标属性视图中有一行绑定了块，来源属性视图通过关联列引用该行
+	destAv := newTestAttributeView(t)
+	tree := parse.Parse("", []byte("old title"), util.NewLute().ParseOptions)
+	para := tree.Root.FirstChild
+	para.SetIALAttr(av.NodeAttrNameAvs, destAv.ID)
+	blockValues := destAv.GetBlockKeyValues()
+	blockValues.Values = append(blockValues.Values, &av.Value{
+		ID: ast.NewNodeID(), KeyID: blockValues.Key.ID, BlockID: para.ID, Type: av.KeyTypeBlock,
+		Block: &av.ValueBlock{ID: para.ID, Content: "old title"},
+	})
+	srcAv := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(srcAv, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	srcRowID := addTestAttributeViewRow(srcAv, "src")
+	setTestAttributeViewValue(srcAv, relKey.ID, srcRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{para.ID}}})
+	unrelatedAv := newTestAttributeView(t)
+	saveTestAttributeViews(t, destAv, srcAv, unrelatedAv)
+	av.UpsertAvBackRel(srcAv.ID, destAv.ID)
+
+	// 内容没有变化时不刷新
+	updateAttributeViewBlockContents(map[string]*ast.Node{para.ID: para})
+	if 0 != len(refreshed) {
+		t.Fatalf("expected no refresh for unchanged content, got %v", refreshed)
+	}
+
+	para.FirstChild.Tokens = []byte("new title")
+	updateAttributeViewBlockContents(map[string]*ast.Node{para.ID: para})
+	sort.Strings(refreshed)
+	expected := []string{destAv.ID, srcAv.ID}
+	sort.Strings(expected)
+	if strings.Join(expected, ",") != strings.Join(refreshed, ",") {
+		t.Fatalf("expected refreshed attribute views %v, got %v", expected, refreshed)
+	}
+
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	if content := destAv.GetBlockKeyValues().GetValue(para.ID).Block.Content; "new title" != content {
+		t.Fatalf("expected primary key content [new title], got [%s]", content)
+	}
+}
+
+func TestRenderAttributeViewRollupEarliestLatestDate(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	dueKey := addTestAttributeViewKey(destAv, "Due", av.KeyTypeDate)
+	day := func(month time.Month, d int) int64 {
+		return time.Date(2024, month, d, 0, 0, 0, 0, time.Local).UnixMilli()
+	}
+	var taskIDs []string
+	for i, date := range []*av.ValueDate{
+		{Content: day(1, 10), IsNotEmpty: true, IsNotTime: true},
+		{Content: day(1, 5), IsNotEmpty: true, IsNotTime: true, HasEndDate: true, Content2: day(2, 20), IsNotEmpty2: true},
+		{Content: day(2, 1), IsNotEmpty: true, IsNotTime: true},
+		{}, // 空日期不参与计算
+	} {
+		taskID := addTestAttributeViewRow(destAv, "task"+strconv.Itoa(i))
+		setTestAttributeViewValue(destAv, dueKey.ID, taskID, &av.Value{Date: date})
+		taskIDs = append(taskIDs, taskID)
+	}
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Tasks", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	earliestKey := addTestAttributeViewKey(attrView, "Start", av.KeyTypeRollup)
+	earliestKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: dueKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorEarliest}}
+	latestKey := addTestAttributeViewKey(attrView, "End", av.KeyTypeRollup)
+	latestKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: dueKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorLatest}}
+	projectID := addTestAttributeViewRow(attrView, "project")
+	setTestAttributeViewValue(attrView, relKey.ID, projectID, &av.Value{Relation: &av.ValueRelation{BlockIDs: taskIDs}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	expected := map[string]string{earliestKey.ID: "2024-01-05", latestKey.ID: "2024-02-20"}
+	for _, cell := range table.Rows[0].Cells {
+		want, ok := expected[cell.Value.KeyID]
+		if !ok {
+			continue
+		}
+		if got := cell.Value.String(); want != got {
+			t.Fatalf("expected rollup [%s], got [%s]", want, got)
+		}
+		delete(expected, cell.Value.KeyID)
+	}
+	if 0 < len(expected) {
+		t.Fatalf("rollup cells not rendered")
+	}
+}
+
+func TestRenderAttributeViewRollupEarliestLatestCreated(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	createdKey := addTestAttributeViewKey(destAv, "Created", av.KeyTypeCreated)
+	var taskIDs []string
+	for i, created := range []time.Time{
+		time.Date(2024, 3, 2, 9, 30, 0, 0, time.Local),
+		time.Date(2024, 1, 5, 8, 0, 0, 0, time.Local),
+		time.Date(2024, 2, 1, 18, 15, 0, 0, time.Local),
+	} {
+		taskID := addTestAttributeViewRow(destAv, "task"+strconv.Itoa(i))
+		destAv.GetBlockKeyValues().GetValue(taskID).Block.Created = created.UnixMilli() // 游离行使用保存的创建时间
+		taskIDs = append(taskIDs, taskID)
+	}
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Tasks", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	earliestKey := addTestAttributeViewKey(attrView, "First", av.KeyTypeRollup)
+	earliestKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: createdKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorEarliest}}
+	latestKey := addTestAttributeViewKey(attrView, "Last", av.KeyTypeRollup)
+	latestKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: createdKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorLatest}}
+	projectID := addTestAttributeViewRow(attrView, "project")
+	setTestAttributeViewValue(attrView, relKey.ID, projectID, &av.Value{Relation: &av.ValueRelation{BlockIDs: taskIDs}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	// 按创建时间列的格式显示时间
+	expected := map[string]string{earliestKey.ID: "2024-01-05 08:00", latestKey.ID: "2024-03-02 09:30"}
+	for _, cell := range table.Rows[0].Cells {
+		want, ok := expected[cell.Value.KeyID]
+		if !ok {
+			continue
+		}
+		if got := cell.Value.Rollup.Contents; 1 != len(got) || av.KeyTypeCreated != got[0].Type || want != got[0].String() {
+			t.Fatalf("expected created rollup [%s], got %v", want, got)
+		}
+		delete(expected, cell.Value.KeyID)
+	}
+	if 0 < len(expected) {
+		t.Fatalf("rollup cells not rendered")
+	}
+}
+
+func TestRenderAttributeViewRollupConditionalTarget(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	amountKey := addTestAttributeViewKey(destAv, "Amount", av.KeyTypeNumber)
+	overrideKey := addTestAttributeViewKey(destAv, "Override", av.KeyTypeNumber)
+	manualKey := addTestAttributeViewKey(destAv, "Manual", av.KeyTypeCheckbox)
+	var itemIDs []string
+	for i, item := range []struct {
+		amount, override float64
+		manual           bool
+	}{{10, 3, true}, {5, 100, false}} {
+		itemID := addTestAttributeViewRow(destAv, "item"+strconv.Itoa(i))
+		setTestAttributeViewValue(destAv, amountKey.ID, itemID, &av.Value{Number: &av.ValueNumber{Content: item.amount, IsNotEmpty: true}})
+		setTestAttributeViewValue(destAv, overrideKey.ID, itemID, &av.Value{Number: &av.ValueNumber{Content: item.override, IsNotEmpty: true}})
+		setTestAttributeViewValue(destAv, manualKey.ID, itemID, &av.Value{Checkbox: &av.ValueCheckbox{Checked: item.manual}})
+		itemIDs = append(itemIDs, itemID)
+	}
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Items", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	rollupKey := addTestAttributeViewKey(attrView, "Total", av.KeyTypeRollup)
+	rowID := addTestAttributeViewRow(attrView, "order")
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: itemIDs}})
+	saveTestAttributeViews(t, destAv, attrView)
+
+	setRollup := func(conditionColumn string) {
+		data := map[string]interface{}{
+			"calc":     map[string]interface{}{"operator": av.CalcOperatorSum},
+			"altKeyID": overrideKey.ID,
+			"condition": map[string]interface{}{
+				"column":   conditionColumn,
+				"operator": av.FilterOperatorIsTrue,
+				"value":    map[string]interface{}{"checkbox": map[string]interface{}{"checked": true}},
+			},
+		}
+		if err := updateAttributeViewColRollup(&Operation{AvID: attrView.ID, ID: rollupKey.ID, ParentID: relKey.ID, KeyID: amountKey.ID, Data: data}); nil != err {
+			t.Fatalf("update rollup failed: %s", err)
+		}
+	}
+	rollupResult := func() string {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		for _, cell := range table.Rows[0].Cells {
+			if cell.Value.KeyID == rollupKey.ID {
+				return cell.Value.String()
+			}
+		}
+		t.Fatalf("rollup cell not rendered")
+		return ""
+	}
+
+	// 勾选了 Manual 的关联块使用 Override，其余使用 Amount
+	setRollup(manualKey.ID)
+	if got := rollupResult(); "8" != got {
+		t.Fatalf("expected conditional rollup [8], got [%s]", got)
+	}
+
+	// 条件列不存在时回退到主目标列
+	setRollup(ast.NewNodeID())
+	if got := rollupResult(); "15" != got {
+		t.Fatalf("expected fallback rollup [15], got [%s]", got)
+	}
+}
+
+func TestLinkRelationsByText(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	acme := addTestAttributeViewRow(destAv, "Acme")
+	globex := addTestAttributeViewRow(destAv, "Globex")
+
+	attrView := newTestAttributeView(t)
+	customerKey := addTestAttributeViewKey(attrView, "Customer", av.KeyTypeText)
+	relKey := addTestAttributeViewKey(attrView, "Customer link", av.KeyTypeRelation)
+	rowIDs := map[string]string{}
+	for _, text := range []string{"Acme", "Globex", "acme", "Initech", ""} {
+		rowID := addTestAttributeViewRow(attrView, "order "+text)
+		setTestAttributeViewValue(attrView, customerKey.ID, rowID, &av.Value{Text: &av.ValueText{Content: text}})
+		rowIDs[text] = rowID
+	}
+	saveTestAttributeViews(t, destAv, attrView)
+
+	linked, unmatched, err := LinkRelationsByText(attrView.ID, customerKey.ID, relKey.ID, destAv.ID)
+	if nil != err {
+		t.Fatalf("link relations by text failed: %s", err)
+	}
+	// 精确匹配，大小写不同的 acme 和不存在的 Initech 未匹配，空文本不参与匹配
+	if 2 != linked || 2 != unmatched {
+		t.Fatalf("expected 2 linked and 2 unmatched, got [%d] and [%d]", linked, unmatched)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	destAv, _ = av.ParseAttributeView(destAv.ID)
+	relKey, _ = attrView.GetKey(relKey.ID)
+	if nil == relKey.Relation || !relKey.Relation.IsTwoWay || destAv.ID != relKey.Relation.AvID {
+		t.Fatalf("expected two-way relation to dest attribute view")
+	}
+	if got := attrView.GetValue(relKey.ID, rowIDs["Acme"]).Relation.BlockIDs; 1 != len(got) || acme != got[0] {
+		t.Fatalf("unexpected relation of Acme order %v", got)
+	}
+	if got := attrView.GetValue(relKey.ID, rowIDs["Globex"]).Relation.BlockIDs; 1 != len(got) || globex != got[0] {
+		t.Fatalf("unexpected relation of Globex order %v", got)
+	}
+	if val := attrView.GetValue(relKey.ID, rowIDs["acme"]); nil != val && nil != val.Relation && 0 < len(val.Relation.BlockIDs) {
+		t.Fatalf("expected no relation of acme order")
+	}
+	if got := destAv.GetValue(relKey.Relation.BackKeyID, acme).Relation.BlockIDs; 1 != len(got) || rowIDs["Acme"] != got[0] {
+		t.Fatalf("unexpected back relation of Acme %v", got)
+	}
+}
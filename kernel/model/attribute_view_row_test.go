@@ -0,0 +1,378 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+func TestSetAttributeViewRowColor(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo", "bar")
+	rowID := attrView.KeyValues[0].Values[0].BlockID
+
+	rowColor := func() string {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		for _, row := range table.Rows {
+			if row.ID == rowID {
+				return row.Color
+			}
+		}
+		t.Fatalf("row [%s] not found", rowID)
+		return ""
+	}
+
+	if err := setAttributeViewRowColor(&Operation{AvID: attrView.ID, ID: rowID, Data: "red"}); nil != err {
+		t.Fatalf("set row color failed: %s", err)
+	}
+	if "red" != rowColor() {
+		t.Fatalf("unexpected row color [%s]", rowColor())
+	}
+
+	if err := setAttributeViewRowColor(&Operation{AvID: attrView.ID, ID: rowID, Data: ""}); nil != err {
+		t.Fatalf("clear row color failed: %s", err)
+	}
+	if "" != rowColor() || 0 != len(attrView.Views[0].Table.RowColors) {
+		t.Fatalf("expected row color cleared")
+	}
+}
+
+func TestDeduplicateAttributeViewRows(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo", "bar", "foo")
+	keyID := attrView.KeyValues[0].Key.ID
+	lastRowID := attrView.KeyValues[0].Values[2].BlockID
+
+	duplicates, err := FindAttributeViewDuplicateRows(attrView.ID, keyID)
+	if nil != err {
+		t.Fatalf("find duplicate rows failed: %s", err)
+	}
+	if 1 != len(duplicates) || lastRowID != duplicates[0] {
+		t.Fatalf("unexpected duplicates %v", duplicates)
+	}
+	if attrView, _ = av.ParseAttributeView(attrView.ID); 3 != len(attrView.KeyValues[0].Values) {
+		t.Fatalf("dry run should not remove rows")
+	}
+
+	removed, err := DeduplicateAttributeViewRows(attrView.ID, keyID)
+	if nil != err {
+		t.Fatalf("deduplicate rows failed: %s", err)
+	}
+	if 1 != removed {
+		t.Fatalf("expected 1 removed row, got %d", removed)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if 2 != len(attrView.KeyValues[0].Values) || gulu.Str.Contains(lastRowID, attrView.Views[0].Table.RowIDs) {
+		t.Fatalf("expected duplicate row removed")
+	}
+}
+
+func TestSyncDelete2AttributeViewKeepDeletedRows(t *testing.T) {
+	setTestDataDir(t)
+	deletedID := ast.NewNodeID()
+	newBoundAv := func(keepDeletedRows bool) *av.AttributeView {
+		attrView := newTestAttributeView(t, "detached")
+		textKeyID := attrView.KeyValues[1].Key.ID
+		// 绑定块的块树不存在，模拟块在属性视图外被删除
+		addTestAttributeViewRowWithID(attrView, deletedID, "deleted")
+		attrView.GetBlockKeyValues().GetValue(deletedID).IsDetached = false
+		setTestAttributeViewValue(attrView, textKeyID, deletedID, &av.Value{Text: &av.ValueText{Content: "keep me"}})
+		attrView.KeepDeletedRows = keepDeletedRows
+		saveTestAttributeViews(t, attrView)
+		return attrView
+	}
+	dropAv, keepAv := newBoundAv(false), newBoundAv(true)
+
+	// 渲染时将找不到块的行标记为游离行，但不修改存储的数据
+	var renderedRow *av.TableRow
+	for _, r := range renderTestAttributeViewTable(t, keepAv, nil).Rows {
+		if deletedID == r.ID {
+			renderedRow = r
+		}
+	}
+	if nil == renderedRow || !renderedRow.Cells[0].Value.IsDetached || !renderedRow.Cells[0].Value.IsBlockDeleted {
+		t.Fatalf("expected render to keep the row as detached")
+	}
+	if "keep me" != renderedRow.Cells[1].Value.Text.Content {
+		t.Fatalf("expected rendered row values to be kept")
+	}
+	keepAv, _ = av.ParseAttributeView(keepAv.ID)
+	if blockValue := keepAv.GetBlockKeyValues().GetValue(deletedID); blockValue.IsDetached || blockValue.IsBlockDeleted {
+		t.Fatalf("expected render not to detach the row")
+	}
+
+	node := &ast.Node{ID: deletedID, Type: ast.NodeParagraph}
+	node.SetIALAttr(av.NodeAttrNameAvs, dropAv.ID+","+keepAv.ID)
+	syncDelete2AttributeView(node)
+
+	dropAv, _ = av.ParseAttributeView(dropAv.ID)
+	if nil != dropAv.GetBlockKeyValues().GetValue(deletedID) {
+		t.Fatalf("expected deleted row to be dropped by default")
+	}
+
+	keepAv, _ = av.ParseAttributeView(keepAv.ID)
+	blockValue := keepAv.GetBlockKeyValues().GetValue(deletedID)
+	if nil == blockValue || !blockValue.IsDetached || !blockValue.IsBlockDeleted {
+		t.Fatalf("expected row to be saved as detached with block deleted marker")
+	}
+
+	table := renderTestAttributeViewTable(t, keepAv, nil)
+	var row *av.TableRow
+	for _, r := range table.Rows {
+		if deletedID == r.ID {
+			row = r
+		}
+	}
+	if nil == row {
+		t.Fatalf("expected deleted row to be kept")
+	}
+	if "keep me" != row.Cells[1].Value.Text.Content {
+		t.Fatalf("expected row values to be kept")
+	}
+}
+
+func TestSetAttributeViewRowTop(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Score", av.KeyTypeNumber)
+	rowIDs := map[float64]string{}
+	for _, score := range []float64{1, 2, 3} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(int(score)))
+		setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: av.NewFormattedValueNumber(score, av.NumberFormatNone)})
+		rowIDs[score] = rowID
+	}
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: numKey.ID, Order: av.SortOrderDesc}}
+	attrView.Views[0].Table.Columns[2].Calc = &av.ColumnCalc{Operator: av.CalcOperatorSum}
+	saveTestAttributeViews(t, attrView)
+
+	// 降序排序时 1 应该在最后，置顶后排在最前面
+	if err := setAttributeViewRowTop(&Operation{AvID: attrView.ID, ID: rowIDs[1], Data: true}); nil != err {
+		t.Fatalf("set row top failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	var got []string
+	for _, row := range table.Rows {
+		got = append(got, row.GetBlockValue().Block.Content)
+	}
+	if "1,3,2" != strings.Join(got, ",") {
+		t.Fatalf("expected pinned-top row first, got %v", got)
+	}
+	if 6 != table.Columns[2].Calc.Result.Number.Content {
+		t.Fatalf("expected pinned-top row to participate in calc, got %v", table.Columns[2].Calc.Result.Number.Content)
+	}
+
+	if err := removeAttributeViewBlock(nil, &Operation{AvID: attrView.ID, SrcIDs: []string{rowIDs[1]}}); nil != err {
+		t.Fatalf("remove row failed: %s", err)
+	}
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if 0 != len(attrView.Views[0].Table.TopRowIDs) {
+		t.Fatalf("expected deleted row pruned from top rows")
+	}
+}
+
+func TestImportAttributeViewFromMarkdownTable(t *testing.T) {
+	setTestDataDir(t)
+	setTestConf(t, &AppConf{Editor: conf.NewEditor()})
+	oldLangs := util.AttrViewLangs
+	util.AttrViewLangs = map[string]map[string]interface{}{util.Lang: {"table": "Table", "key": "Key"}}
+	defer func() { util.AttrViewLangs = oldLangs }()
+
+	tree := treenode.NewTree("box", "/"+ast.NewNodeID()+".sy", "/doc", "doc")
+	mdTree := parse.Parse("", []byte("| Name | Score | Due |\n| --- | --- | --- |\n| foo | 1.5 | 2024-01-02 |\n| bar |  | 2024-03-04 |\n| baz | 3 | soon |\n"), util.NewLute().ParseOptions)
+	mdTable := mdTree.Root.FirstChild
+	mdTable.ID = ast.NewNodeID()
+	mdTable.SetIALAttr("id", mdTable.ID)
+	tree.Root.FirstChild.InsertBefore(mdTable)
+	if err := filesys.WriteTree(tree); nil != err {
+		t.Fatalf("write tree failed: %s", err)
+	}
+	treenode.IndexBlockTree(tree)
+
+	avID, err := ImportAttributeViewFromMarkdownTable(mdTable.ID, true)
+	if nil != err {
+		t.Fatalf("import markdown table failed: %s", err)
+	}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		t.Fatalf("parse attribute view failed: %s", err)
+	}
+	var keys []string
+	for _, keyValues := range attrView.KeyValues {
+		keys = append(keys, keyValues.Key.Name+":"+string(keyValues.Key.Type))
+	}
+	if "Name:block,Score:number,Due:text" != strings.Join(keys, ",") {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	var rows []string
+	for _, row := range table.Rows {
+		if !row.GetBlockValue().IsDetached {
+			t.Fatalf("expected detached rows")
+		}
+		rows = append(rows, row.Cells[0].Value.String()+"|"+row.Cells[1].Value.String()+"|"+row.Cells[2].Value.String())
+	}
+	if "foo|1.5|2024-01-02;bar||2024-03-04;baz|3|soon" != strings.Join(rows, ";") {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	tree, err = filesys.LoadTree("box", tree.Path, util.NewLute())
+	if nil != err {
+		t.Fatalf("load tree failed: %s", err)
+	}
+	node := treenode.GetNodeInTree(tree, mdTable.ID)
+	if nil == node || ast.NodeAttributeView != node.Type || avID != node.AttributeViewID {
+		t.Fatalf("expected table block replaced with attribute view block")
+	}
+}
+
+func TestClearAttributeViewRowOrder(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	var rowIDs []string
+	for _, content := range []string{"a", "b", "c"} {
+		rowIDs = append(rowIDs, addTestAttributeViewRow(attrView, content))
+	}
+	sort.Strings(rowIDs)
+	attrView.Views[0].Table.RowIDs = []string{rowIDs[2], rowIDs[0], rowIDs[1]}
+	saveTestAttributeViews(t, attrView)
+
+	renderedRowIDs := func() string {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		var ret []string
+		for _, row := range table.Rows {
+			ret = append(ret, row.ID)
+		}
+		return strings.Join(ret, ",")
+	}
+	if expected, got := strings.Join([]string{rowIDs[2], rowIDs[0], rowIDs[1]}, ","), renderedRowIDs(); expected != got {
+		t.Fatalf("expected custom order [%s], got [%s]", expected, got)
+	}
+
+	if err := clearAttributeViewRowOrder(&Operation{AvID: attrView.ID}); nil != err {
+		t.Fatalf("clear row order failed: %s", err)
+	}
+	if expected, got := strings.Join(rowIDs, ","), renderedRowIDs(); expected != got {
+		t.Fatalf("expected default order [%s], got [%s]", expected, got)
+	}
+	if 3 != len(attrView.GetBlockKeyValues().Values) {
+		t.Fatalf("expected rows to be kept")
+	}
+}
+
+func TestMaterializeDetachedRow(t *testing.T) {
+	setTestDataDir(t)
+	setTestConf(t, &AppConf{Editor: conf.NewEditor()})
+
+	tree := treenode.NewTree("box", "/"+ast.NewNodeID()+".sy", "/doc", "doc")
+	if err := filesys.WriteTree(tree); nil != err {
+		t.Fatalf("write tree failed: %s", err)
+	}
+	treenode.IndexBlockTree(tree)
+
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	rowID := addTestAttributeViewRow(attrView, "Write report")
+	setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: "note"}})
+	attrView.Views[0].Table.RowIDs = []string{rowID}
+	srcAv := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(srcAv, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: attrView.ID}
+	srcRowID := addTestAttributeViewRow(srcAv, "src")
+	setTestAttributeViewValue(srcAv, relKey.ID, srcRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{rowID}}})
+	saveTestAttributeViews(t, attrView, srcAv)
+	av.UpsertAvBackRel(srcAv.ID, attrView.ID)
+
+	newBlockID, err := MaterializeDetachedRow(attrView.ID, rowID, tree.ID)
+	if nil != err {
+		t.Fatalf("materialize detached row failed: %s", err)
+	}
+	if _, err = MaterializeDetachedRow(attrView.ID, newBlockID, tree.ID); nil == err {
+		t.Fatalf("expected bound row to be rejected")
+	}
+
+	tree, err = filesys.LoadTree("box", tree.Path, util.NewLute())
+	if nil != err {
+		t.Fatalf("load tree failed: %s", err)
+	}
+	node := treenode.GetNodeInTree(tree, newBlockID)
+	if nil == node || ast.NodeParagraph != node.Type || "Write report" != node.Content() {
+		t.Fatalf("expected new paragraph with primary key content")
+	}
+	if attrView.ID != node.IALAttr(av.NodeAttrNameAvs) {
+		t.Fatalf("expected new block bound to attribute view, got [%s]", node.IALAttr(av.NodeAttrNameAvs))
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	blockValue := attrView.GetBlockKeyValues().GetValue(newBlockID)
+	if nil == blockValue || blockValue.IsDetached || newBlockID != blockValue.Block.ID {
+		t.Fatalf("expected row bound to new block")
+	}
+	if nil != attrView.GetBlockKeyValues().GetValue(rowID) {
+		t.Fatalf("expected old row ID removed")
+	}
+	if val := attrView.GetValue(textKeyID, newBlockID); nil == val || "note" != val.Text.Content {
+		t.Fatalf("expected row values moved to new block ID")
+	}
+	if 1 != len(attrView.Views[0].Table.RowIDs) || newBlockID != attrView.Views[0].Table.RowIDs[0] {
+		t.Fatalf("expected view row IDs updated, got %v", attrView.Views[0].Table.RowIDs)
+	}
+
+	srcAv, _ = av.ParseAttributeView(srcAv.ID)
+	if relIDs := srcAv.GetValue(relKey.ID, srcRowID).Relation.BlockIDs; 1 != len(relIDs) || newBlockID != relIDs[0] {
+		t.Fatalf("expected relation updated to new block ID, got %v", relIDs)
+	}
+
+	list := &ast.Node{ID: ast.NewNodeID(), Type: ast.NodeList, ListData: &ast.ListData{Typ: 1, Start: 1, Delimiter: '.'}}
+	list.SetIALAttr("id", list.ID)
+	li := &ast.Node{ID: ast.NewNodeID(), Type: ast.NodeListItem, ListData: &ast.ListData{Typ: 1, Start: 1, Delimiter: '.', Padding: 3, Num: 1, Marker: []byte("1.")}}
+	li.SetIALAttr("id", li.ID)
+	li.AppendChild(&ast.Node{ID: ast.NewNodeID(), Type: ast.NodeParagraph})
+	list.AppendChild(li)
+	tree.Root.AppendChild(list)
+	if err = filesys.WriteTree(tree); nil != err {
+		t.Fatalf("write tree failed: %s", err)
+	}
+	treenode.IndexBlockTree(tree)
+	listRowID := addTestAttributeViewRow(attrView, "Review report")
+	saveTestAttributeViews(t, attrView)
+	listItemID, err := MaterializeDetachedRow(attrView.ID, listRowID, list.ID)
+	if nil != err {
+		t.Fatalf("materialize detached row in list failed: %s", err)
+	}
+	tree, _ = filesys.LoadTree("box", tree.Path, util.NewLute())
+	node = treenode.GetNodeInTree(tree, listItemID)
+	if nil == node || ast.NodeListItem != node.Type || 2 != node.ListData.Num || "2." != string(node.ListData.Marker) || 3 != node.ListData.Padding {
+		t.Fatalf("expected new list item to continue the ordered list")
+	}
+}
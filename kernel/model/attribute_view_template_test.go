@@ -0,0 +1,225 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// newTestRollupAttributeView 创建一个包含 n 行的属性视图，每行通过汇总列对目标属性视图中关联行的数字求和。
+func newTestRollupAttributeView(tb testing.TB, n int) (attrView, destAv *av.AttributeView, rollupKey *av.Key) {
+	destAv = newTestAttributeView(tb)
+	destNumKey := addTestAttributeViewKey(destAv, "Amount", av.KeyTypeNumber)
+	attrView = newTestAttributeView(tb)
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	rollupKey = addTestAttributeViewKey(attrView, "Total", av.KeyTypeRollup)
+	rollupKey.Rollup = &av.Rollup{RelationKeyID: relKey.ID, KeyID: destNumKey.ID, Calc: &av.RollupCalc{Operator: av.CalcOperatorSum}}
+	tplKey := addTestAttributeViewKey(attrView, "Template", av.KeyTypeTemplate)
+	tplKey.Template = "{{.Block}}-{{.Block}}"
+
+	for i := 0; i < n; i++ {
+		destRowID := addTestAttributeViewRow(destAv, strconv.Itoa(i))
+		setTestAttributeViewValue(destAv, destNumKey.ID, destRowID, &av.Value{Number: &av.ValueNumber{Content: float64(i), IsNotEmpty: true}})
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{destRowID}}})
+	}
+	for _, a := range []*av.AttributeView{destAv, attrView} {
+		if err := av.SaveAttributeView(a); nil != err {
+			tb.Fatalf("save attribute view failed: %s", err)
+		}
+	}
+	return
+}
+
+func TestRenderAttributeViewCacheComputedCols(t *testing.T) {
+	setTestDataDir(t)
+	attrView, destAv, rollupKey := newTestRollupAttributeView(t, 1)
+	if err := setAttributeViewCacheComputedCols(&Operation{AvID: attrView.ID, Data: true}); nil != err {
+		t.Fatalf("enable computed cols cache failed: %s", err)
+	}
+
+	rollupResult := func() string {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		return table.Rows[0].Cells[3].Value.String()
+	}
+	if got := rollupResult(); "0" != got {
+		t.Fatalf("expected rollup [0], got [%s]", got)
+	}
+
+	// 绕过 SaveAttributeView 修改目标属性视图，缓存不会失效
+	destNumKeyID := rollupKey.Rollup.KeyID
+	destAv.GetValue(destNumKeyID, destAv.GetBlockKeyValues().Values[0].BlockID).Number.Content = 42
+	data, _ := gulu.JSON.MarshalJSON(destAv)
+	if err := os.WriteFile(av.GetAttributeViewDataPath(destAv.ID), data, 0644); nil != err {
+		t.Fatalf("write attribute view failed: %s", err)
+	}
+	if got := rollupResult(); "0" != got {
+		t.Fatalf("expected cached rollup [0], got [%s]", got)
+	}
+
+	// 保存目标属性视图后依赖它的缓存失效
+	saveTestAttributeViews(t, destAv)
+	if got := rollupResult(); "42" != got {
+		t.Fatalf("expected recomputed rollup [42], got [%s]", got)
+	}
+}
+
+func TestIsAttributeViewTemplateCacheable(t *testing.T) {
+	for tpl, expected := range map[string]bool{
+		".action{.Block}-.action{.Text}":                                true,
+		".action{now | date \"2006-01-02\"}":                            false,
+		".action{.updated | date \"2006-01-02\"}":                       false,
+		".action{.created}":                                             false,
+		".action{(queryBlocks \"SELECT * FROM blocks LIMIT 1\") | len}": false,
+	} {
+		if got := isAttributeViewTemplateCacheable(tpl); expected != got {
+			t.Fatalf("expected cacheable [%v] for template [%s], got [%v]", expected, tpl, got)
+		}
+	}
+}
+
+func BenchmarkRenderAttributeViewCacheComputedCols(b *testing.B) {
+	for _, cached := range []bool{false, true} {
+		b.Run("cached="+strconv.FormatBool(cached), func(b *testing.B) {
+			util.DataDir = b.TempDir()
+			attrView, _, _ := newTestRollupAttributeView(b, 100)
+			attrView.CacheComputedCols = cached
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := renderAttributeView(attrView, "", 1, -1, nil); nil != err {
+					b.Fatalf("render attribute view failed: %s", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderTemplateColAlias(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo")
+	textKey := attrView.KeyValues[1].Key
+	rowID := attrView.KeyValues[0].Values[0].BlockID
+	setTestAttributeViewValue(attrView, textKey.ID, rowID, &av.Value{Text: &av.ValueText{Content: "A-001"}})
+	saveTestAttributeViews(t, attrView)
+
+	if err := setAttributeViewColAlias(&Operation{AvID: attrView.ID, ID: textKey.ID, Data: "sku"}); nil != err {
+		t.Fatalf("set alias failed: %s", err)
+	}
+	if err := setAttributeViewColAlias(&Operation{AvID: attrView.ID, ID: attrView.KeyValues[0].Key.ID, Data: "sku"}); nil == err {
+		t.Fatalf("expected duplicate alias error")
+	}
+	if err := setAttributeViewColAlias(&Operation{AvID: attrView.ID, ID: textKey.ID, Data: "code"}); nil == err {
+		t.Fatalf("expected immutable alias error")
+	}
+
+	if err := updateAttributeViewColumn(&Operation{AvID: attrView.ID, ID: textKey.ID, Name: "Product code", Typ: string(av.KeyTypeText)}); nil != err {
+		t.Fatalf("rename column failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	var rowValues []*av.KeyValues
+	for _, keyValues := range attrView.KeyValues {
+		rowValues = append(rowValues, &av.KeyValues{Key: keyValues.Key, Values: []*av.Value{keyValues.GetValue(rowID)}})
+	}
+	if ret := renderTemplateCol(map[string]string{"id": rowID}, ".action{.alias.sku}", rowValues, 0, 0); "A-001" != ret {
+		t.Fatalf("unexpected template result [%s]", ret)
+	}
+}
+
+func TestRenderAttributeViewTemplateRowPosition(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	tplKey := addTestAttributeViewKey(attrView, "Position", av.KeyTypeTemplate)
+	tplKey.Template = "Item .action{.rowIndex} of .action{.rowCount}"
+	for _, text := range []string{"c", "hidden", "a", "b"} {
+		rowID := addTestAttributeViewRow(attrView, text)
+		setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: text}})
+	}
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: textKeyID, Operator: av.FilterOperatorIsNotEqual, Value: &av.Value{Text: &av.ValueText{Content: "hidden"}}}}
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: textKeyID, Order: av.SortOrderAsc}}
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rows := table.Rows
+	if 3 != len(rows) {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	if middle := rows[1]; "b" != middle.Cells[1].Value.Text.Content || "Item 2 of 3" != middle.Cells[2].Value.Template.Content {
+		t.Fatalf("unexpected middle row template [%s]", middle.Cells[2].Value.Template.Content)
+	}
+}
+
+func TestPreviewAttributeViewTemplate(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo")
+	rowID := attrView.KeyValues[0].Values[0].BlockID
+	numKey := addTestAttributeViewKey(attrView, "Price", av.KeyTypeNumber)
+	setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: 21, IsNotEmpty: true}})
+	saveTestAttributeViews(t, attrView)
+
+	rendered, err := PreviewAttributeViewTemplate(attrView.ID, rowID, ".action{mul .Price 2}")
+	if nil != err {
+		t.Fatalf("preview template failed: %s", err)
+	}
+	if "42" != rendered {
+		t.Fatalf("unexpected rendered content [%s]", rendered)
+	}
+
+	if _, err = PreviewAttributeViewTemplate(attrView.ID, rowID, ".action{if}"); nil == err {
+		t.Fatalf("expected template parse error")
+	}
+	if _, err = PreviewAttributeViewTemplate(attrView.ID, ast.NewNodeID(), ".action{.Price}"); nil == err {
+		t.Fatalf("expected row not found error")
+	}
+}
+
+func TestAddAttributeViewBlockPrimaryTemplate(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: textKeyID, Operator: av.FilterOperatorIsEqual, Value: &av.Value{Type: av.KeyTypeText, Text: &av.ValueText{Content: "Alpha"}}}}
+	saveTestAttributeViews(t, attrView)
+
+	if err := setAttributeViewPrimaryTemplate(&Operation{AvID: attrView.ID, Data: ".action{"}); nil == err {
+		t.Fatalf("expected invalid template to be rejected")
+	}
+	if err := setAttributeViewPrimaryTemplate(&Operation{AvID: attrView.ID, Data: ".action{.Text} task"}); nil != err {
+		t.Fatalf("set primary template failed: %s", err)
+	}
+
+	rowID := ast.NewNodeID()
+	if err := addAttributeViewBlock(rowID, &Operation{AvID: attrView.ID, IsDetached: true}, nil, nil); nil != err {
+		t.Fatalf("add detached row failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if got := attrView.GetValue(textKeyID, rowID).Text.Content; "Alpha" != got {
+		t.Fatalf("expected text [Alpha], got [%s]", got)
+	}
+	if got := attrView.GetValue(attrView.GetBlockKeyValues().Key.ID, rowID).Block.Content; "Alpha task" != got {
+		t.Fatalf("expected primary content [Alpha task], got [%s]", got)
+	}
+}
@@ -0,0 +1,1030 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// setTestDataDir 使用临时目录作为数据目录，测试结束后恢复。
+func setTestDataDir(t testing.TB) {
+	oldDataDir := util.DataDir
+	util.DataDir = t.TempDir()
+	t.Cleanup(func() { util.DataDir = oldDataDir })
+}
+
+// setTestConf 使用 appConf 作为测试配置，测试结束后恢复。
+func setTestConf(t testing.TB, appConf *AppConf) {
+	oldConf := Conf
+	appConf.m = &sync.Mutex{}
+	Conf = appConf
+	t.Cleanup(func() { Conf = oldConf })
+}
+
+// saveTestAttributeViews 保存属性视图，保存失败时结束测试。
+func saveTestAttributeViews(t testing.TB, attrViews ...*av.AttributeView) {
+	for _, attrView := range attrViews {
+		if err := av.SaveAttributeView(attrView); nil != err {
+			t.Fatalf("save attribute view failed: %s", err)
+		}
+	}
+}
+
+// renderTestAttributeViewTable 不分页渲染属性视图的当前表格视图，渲染失败时结束测试。
+func renderTestAttributeViewTable(t testing.TB, attrView *av.AttributeView, opts *RenderAttributeViewOptions) *av.Table {
+	viewable, err := renderAttributeView(attrView, "", 1, -1, opts)
+	if nil != err {
+		t.Fatalf("render attribute view failed: %s", err)
+	}
+	return viewable.(*av.Table)
+}
+
+// newTestAttributeView 构造一个包含主键列、文本列和若干游离行的属性视图，并保存到数据目录。
+func newTestAttributeView(t testing.TB, rowContents ...string) (attrView *av.AttributeView) {
+	blockKey := av.NewKey(ast.NewNodeID(), "Block", "", av.KeyTypeBlock)
+	textKey := av.NewKey(ast.NewNodeID(), "Text", "", av.KeyTypeText)
+	view := &av.View{
+		ID:         ast.NewNodeID(),
+		Name:       "Table",
+		LayoutType: av.LayoutTypeTable,
+		Table: &av.LayoutTable{
+			ID:       ast.NewNodeID(),
+			Columns:  []*av.ViewTableColumn{{ID: blockKey.ID}, {ID: textKey.ID}},
+			Filters:  []*av.ViewFilter{},
+			Sorts:    []*av.ViewSort{},
+			PageSize: 50,
+		},
+	}
+	attrView = &av.AttributeView{
+		ID:        ast.NewNodeID(),
+		KeyValues: []*av.KeyValues{{Key: blockKey}, {Key: textKey}},
+		ViewID:    view.ID,
+		Views:     []*av.View{view},
+	}
+
+	for _, content := range rowContents {
+		addTestAttributeViewRow(attrView, content)
+	}
+
+	saveTestAttributeViews(t, attrView)
+	return
+}
+
+// addTestAttributeViewKey 在属性视图中添加一个列并显示在所有视图中。
+func addTestAttributeViewKey(attrView *av.AttributeView, name string, keyType av.KeyType) (key *av.Key) {
+	key = av.NewKey(ast.NewNodeID(), name, "", keyType)
+	attrView.KeyValues = append(attrView.KeyValues, &av.KeyValues{Key: key})
+	for _, view := range attrView.Views {
+		view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: key.ID})
+	}
+	return
+}
+
+// setTestAttributeViewValue 设置单元格的值，val 只需要填充具体类型的字段。
+func setTestAttributeViewValue(attrView *av.AttributeView, keyID, rowID string, val *av.Value) {
+	keyValues, _ := attrView.GetKeyValues(keyID)
+	val.ID = ast.NewNodeID()
+	val.KeyID = keyID
+	val.BlockID = rowID
+	val.Type = keyValues.Key.Type
+	for i, v := range keyValues.Values {
+		if v.BlockID == rowID {
+			keyValues.Values[i] = val
+			return
+		}
+	}
+	keyValues.Values = append(keyValues.Values, val)
+}
+
+// addTestAttributeViewRow 在属性视图中添加一个游离行，返回行 ID。
+func addTestAttributeViewRow(attrView *av.AttributeView, content string) (rowID string) {
+	rowID = ast.NewNodeID()
+	addTestAttributeViewRowWithID(attrView, rowID, content)
+	return
+}
+
+// addTestAttributeViewRowWithID 使用指定的行 ID 添加一个游离行，行 ID 的时间戳部分决定了创建时间。
+func addTestAttributeViewRowWithID(attrView *av.AttributeView, rowID, content string) {
+	now := util.CurrentTimeMillis()
+	blockValues := attrView.GetBlockKeyValues()
+	blockValues.Values = append(blockValues.Values, &av.Value{
+		ID: ast.NewNodeID(), KeyID: blockValues.Key.ID, BlockID: rowID, Type: av.KeyTypeBlock, IsDetached: true,
+		Block: &av.ValueBlock{ID: rowID, Content: content, Created: now, Updated: now},
+	})
+}
+
+func TestRenderAttributeViewAllColumnsHidden(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo", "bar")
+
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	if table.AllColumnsHidden {
+		t.Fatalf("expected visible columns")
+	}
+
+	for _, col := range attrView.Views[0].Table.Columns {
+		col.Hidden = true
+	}
+	table = renderTestAttributeViewTable(t, attrView, nil)
+	if !table.AllColumnsHidden {
+		t.Fatalf("expected all columns hidden flag")
+	}
+	if 2 != len(table.Rows) {
+		t.Fatalf("expected 2 rows, got %d", len(table.Rows))
+	}
+
+	attrView.Views[0].Table.Columns = nil
+	table = renderTestAttributeViewTable(t, attrView, nil)
+	if 0 < len(table.Columns) || table.AllColumnsHidden {
+		t.Fatalf("expected no all columns hidden flag without columns")
+	}
+}
+
+func TestSortAttributeViewViews(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	first := attrView.Views[0]
+	first.Name = "b"
+	for _, name := range []string{"c", "a"} {
+		view := &av.View{ID: ast.NewNodeID(), Name: name, LayoutType: av.LayoutTypeTable, Table: &av.LayoutTable{ID: ast.NewNodeID(), Columns: first.Table.Columns}}
+		attrView.Views = append(attrView.Views, view)
+	}
+	saveTestAttributeViews(t, attrView)
+
+	viewNames := func() (ret string) {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		for _, v := range attrView.Views {
+			ret += v.Name
+		}
+		return
+	}
+
+	if err := sortAttributeViewViews(&Operation{AvID: attrView.ID, Data: "asc"}); nil != err {
+		t.Fatalf("sort views failed: %s", err)
+	}
+	if "abc" != viewNames() || first.ID != attrView.ViewID {
+		t.Fatalf("unexpected view order [%s]", viewNames())
+	}
+
+	if err := sortAttributeViewViews(&Operation{AvID: attrView.ID, Data: "desc"}); nil != err {
+		t.Fatalf("sort views failed: %s", err)
+	}
+	if "cba" != viewNames() {
+		t.Fatalf("unexpected view order [%s]", viewNames())
+	}
+
+	order := []interface{}{attrView.Views[1].ID, attrView.Views[2].ID}
+	if err := sortAttributeViewViews(&Operation{AvID: attrView.ID, Data: order}); nil != err {
+		t.Fatalf("sort views failed: %s", err)
+	}
+	if "bac" != viewNames() || first.ID != attrView.ViewID {
+		t.Fatalf("unexpected view order [%s]", viewNames())
+	}
+
+	if err := sortAttributeViewViews(&Operation{AvID: attrView.ID, Data: "foo"}); nil == err {
+		t.Fatalf("expected invalid criterion error")
+	}
+}
+
+func TestSetAttributeViewCalcPosition(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo")
+
+	calcPosition := func() av.CalcPosition {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		return table.CalcPosition
+	}
+
+	if av.CalcPositionBottom != calcPosition() {
+		t.Fatalf("expected default calc position bottom")
+	}
+
+	if err := setAttributeViewCalcPosition(&Operation{AvID: attrView.ID, Data: "both"}); nil != err {
+		t.Fatalf("set calc position failed: %s", err)
+	}
+	if av.CalcPositionBoth != calcPosition() {
+		t.Fatalf("unexpected calc position [%s]", calcPosition())
+	}
+
+	if err := setAttributeViewCalcPosition(&Operation{AvID: attrView.ID, Data: "middle"}); nil == err {
+		t.Fatalf("expected invalid calc position error")
+	}
+}
+
+func TestGetAttributeViewSchema(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "a", "b")
+	statusKey := addTestAttributeViewKey(attrView, "Status", av.KeyTypeSelect)
+	statusKey.Options = []*av.SelectOption{{Name: "Todo", Color: "1"}}
+	numKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	numKey.NumberFormat = av.NumberFormatPercent
+	attrView.Views[0].Table.Columns[1].Hidden = true
+	saveTestAttributeViews(t, attrView)
+
+	columns, err := GetAttributeViewSchema(attrView.ID, "")
+	if nil != err {
+		t.Fatalf("get attribute view schema failed: %s", err)
+	}
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	rendered := table.Columns
+	if len(rendered) != len(columns) {
+		t.Fatalf("expected %d columns, got %d", len(rendered), len(columns))
+	}
+	for i, col := range columns {
+		expected := rendered[i]
+		if col.ID != expected.ID || col.Name != expected.Name || col.Type != expected.Type || col.Hidden != expected.Hidden || col.NumberFormat != expected.NumberFormat || len(col.Options) != len(expected.Options) {
+			t.Fatalf("column [%d] mismatch: %+v != %+v", i, col, expected)
+		}
+	}
+
+	if _, err = GetAttributeViewSchema(attrView.ID, "not-exist"); nil == err {
+		t.Fatalf("expected view not found error")
+	}
+}
+
+func TestDuplicateAttributeView(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo")
+	boundRowID := addTestAttributeViewRow(attrView, "bound")
+	attrView.GetBlockKeyValues().GetValue(boundRowID).IsDetached = false
+	detachedRowID := attrView.GetBlockKeyValues().Values[0].BlockID
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: attrView.ID}
+	setTestAttributeViewValue(attrView, relKey.ID, detachedRowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{boundRowID}}})
+	textKeyID := attrView.KeyValues[1].Key.ID
+	attrView.Views[0].Table.ColumnGroups = []*av.ViewColumnGroup{{ID: ast.NewNodeID(), Name: "Group", ColumnIDs: []string{textKeyID, relKey.ID}}}
+	attrView.Views[0].Table.DefaultSorts = []*av.ViewSort{{Column: textKeyID, Order: av.SortOrderDesc}}
+	attrView.SortPresets = []*av.SortPreset{{ID: ast.NewNodeID(), Name: "By text", Sorts: []*av.ViewSort{{Column: textKeyID, Order: av.SortOrderAsc}}}}
+	saveTestAttributeViews(t, attrView)
+
+	newAvID, err := DuplicateAttributeView(attrView.ID, "Copy")
+	if nil != err {
+		t.Fatalf("duplicate attribute view failed: %s", err)
+	}
+	copied, err := av.ParseAttributeView(newAvID)
+	if nil != err {
+		t.Fatalf("parse duplicated attribute view failed: %s", err)
+	}
+
+	if "Copy" != copied.Name || len(attrView.KeyValues) != len(copied.KeyValues) || len(attrView.Views) != len(copied.Views) {
+		t.Fatalf("unexpected duplicated attribute view structure")
+	}
+	for i, keyValues := range copied.KeyValues {
+		if keyValues.Key.Name != attrView.KeyValues[i].Key.Name || keyValues.Key.ID == attrView.KeyValues[i].Key.ID {
+			t.Fatalf("unexpected duplicated key [%s]", keyValues.Key.Name)
+		}
+	}
+	if copied.GetView(copied.ViewID).Table.Columns[0].ID != copied.KeyValues[0].Key.ID {
+		t.Fatalf("expected view columns remapped")
+	}
+
+	blockValues := copied.GetBlockKeyValues().Values
+	if 2 != len(blockValues) {
+		t.Fatalf("expected 2 rows, got %d", len(blockValues))
+	}
+	for _, blockValue := range blockValues {
+		if !blockValue.IsDetached || boundRowID == blockValue.BlockID || detachedRowID == blockValue.BlockID {
+			t.Fatalf("expected remapped detached row [%s]", blockValue.Block.Content)
+		}
+	}
+
+	copiedRelKey := copied.KeyValues[2]
+	if newAvID != copiedRelKey.Key.Relation.AvID || 1 != len(copiedRelKey.Values) {
+		t.Fatalf("expected self relation rewired to the duplicated attribute view")
+	}
+	if got := copiedRelKey.Values[0].Relation.BlockIDs; 1 != len(got) || nil == copied.GetBlockKeyValues().GetValue(got[0]) {
+		t.Fatalf("expected relation linked to the duplicated row, got %v", got)
+	}
+
+	// 列分组、默认排序和排序预设引用的列也需要重新映射
+	copiedTextKeyID := copied.KeyValues[1].Key.ID
+	copiedTable := copied.GetView(copied.ViewID).Table
+	if group := copiedTable.ColumnGroups[0]; 2 != len(group.ColumnIDs) || copiedTextKeyID != group.ColumnIDs[0] || copiedRelKey.Key.ID != group.ColumnIDs[1] {
+		t.Fatalf("expected column group remapped, got %v", group.ColumnIDs)
+	}
+	if copiedTextKeyID != copiedTable.DefaultSorts[0].Column {
+		t.Fatalf("expected default sorts remapped")
+	}
+	if copiedTextKeyID != copied.SortPresets[0].Sorts[0].Column || attrView.SortPresets[0].ID == copied.SortPresets[0].ID {
+		t.Fatalf("expected sort presets remapped")
+	}
+	if 1 != len(renderTestAttributeViewTable(t, copied, nil).ColumnGroups) {
+		t.Fatalf("expected column group rendered in duplicated attribute view")
+	}
+}
+
+func TestDuplicateAttributeViewViewCopiesTableSettings(t *testing.T) {
+	setTestDataDir(t)
+	oldLangs := util.AttrViewLangs
+	util.AttrViewLangs = map[string]map[string]interface{}{util.Lang: {"table": "Table"}}
+	defer func() { util.AttrViewLangs = oldLangs }()
+	attrView := newTestAttributeView(t, "foo")
+	rowID := attrView.GetBlockKeyValues().Values[0].BlockID
+	textKeyID := attrView.KeyValues[1].Key.ID
+	masterTable := attrView.Views[0].Table
+	masterTable.DefaultSorts = []*av.ViewSort{{Column: textKeyID, Order: av.SortOrderDesc}}
+	masterTable.RowColors = map[string]string{rowID: "red"}
+	masterTable.TopRowIDs = []string{rowID}
+	saveTestAttributeViews(t, attrView)
+
+	newViewID := ast.NewNodeID()
+	if txErr := (&Transaction{}).doDuplicateAttrViewView(&Operation{AvID: attrView.ID, ID: newViewID, PreviousID: attrView.Views[0].ID}); nil != txErr {
+		t.Fatalf("duplicate view failed: %s", txErr.msg)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	table := attrView.GetView(newViewID).Table
+	if 1 != len(table.DefaultSorts) || textKeyID != table.DefaultSorts[0].Column || av.SortOrderDesc != table.DefaultSorts[0].Order {
+		t.Fatalf("expected default sorts copied")
+	}
+	if "red" != table.RowColors[rowID] || 1 != len(table.TopRowIDs) || rowID != table.TopRowIDs[0] {
+		t.Fatalf("expected row colors and top rows copied")
+	}
+}
+
+func TestSetAttributeViewViewLayout(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "foo")
+	view := attrView.Views[0]
+	view.Table.Filters = []*av.ViewFilter{{Column: attrView.KeyValues[1].Key.ID, Operator: av.FilterOperatorIsNotEmpty}}
+	saveTestAttributeViews(t, attrView)
+
+	tx := &Transaction{}
+	if txErr := tx.doSetAttrViewViewLayout(&Operation{AvID: attrView.ID, ID: view.ID, Data: "kanban"}); nil == txErr {
+		t.Fatalf("expected unsupported layout error")
+	}
+	if txErr := tx.doSetAttrViewViewLayout(&Operation{AvID: attrView.ID, ID: view.ID, Data: string(av.LayoutTypeTable)}); nil != txErr {
+		t.Fatalf("set view layout failed: %s", txErr.msg)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	view = attrView.GetView(view.ID)
+	if av.LayoutTypeTable != view.LayoutType || 1 != len(view.Table.Filters) || 2 != len(view.Table.Columns) {
+		t.Fatalf("expected table layout with filters and columns preserved")
+	}
+}
+
+func TestRenderAttributeViewOverrideSorts(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	for i, amount := range []float64{2, 3, 1} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: av.NewFormattedValueNumber(amount, av.NumberFormatNone)})
+	}
+	attrView.Views[0].Table.DefaultSorts = []*av.ViewSort{{Column: numKey.ID, Order: av.SortOrderAsc}}
+	saveTestAttributeViews(t, attrView)
+
+	amounts := func(opts *RenderAttributeViewOptions) (ret []float64) {
+		viewable, _, err := RenderAttributeView(attrView.ID, "", 1, -1, opts)
+		if nil != err {
+			t.Fatalf("render attribute view failed: %s", err)
+		}
+		for _, row := range viewable.(*av.Table).Rows {
+			ret = append(ret, row.Cells[2].Value.Number.Content)
+		}
+		return
+	}
+
+	overrideSorts := []*av.ViewSort{{Column: numKey.ID, Order: av.SortOrderDesc}}
+	if got := amounts(&RenderAttributeViewOptions{OverrideSorts: overrideSorts}); 3 != got[0] || 2 != got[1] || 1 != got[2] {
+		t.Fatalf("expected override sorts applied, got %v", got)
+	}
+	if attrView, _ = av.ParseAttributeView(attrView.ID); 0 != len(attrView.Views[0].Table.Sorts) {
+		t.Fatalf("override sorts should not be saved")
+	}
+
+	if err := resetAttributeViewSorts(&Operation{AvID: attrView.ID}); nil != err {
+		t.Fatalf("reset sorts failed: %s", err)
+	}
+	if got := amounts(nil); 1 != got[0] || 2 != got[1] || 3 != got[2] {
+		t.Fatalf("expected default sorts after reset, got %v", got)
+	}
+}
+
+func TestRenderAttributeViewLocale(t *testing.T) {
+	setTestDataDir(t)
+	setTestConf(t, &AppConf{Lang: "de_DE", Editor: conf.NewEditor()})
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	numKey.NumberFormat = av.NumberFormatCommas
+	dateKey := addTestAttributeViewKey(attrView, "Due", av.KeyTypeDate)
+	addTestAttributeViewKey(attrView, "Created", av.KeyTypeCreated)
+	rowID := "20240102030405-abcdefg"
+	addTestAttributeViewRowWithID(attrView, rowID, "a")
+	setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: 1234.5, IsNotEmpty: true}})
+	due := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local).UnixMilli()
+	setTestAttributeViewValue(attrView, dateKey.ID, rowID, &av.Value{Date: &av.ValueDate{Content: due, IsNotEmpty: true, IsNotTime: true}})
+	for _, column := range attrView.Views[0].Table.Columns {
+		if column.ID == numKey.ID {
+			column.Calc = &av.ColumnCalc{Operator: av.CalcOperatorSum}
+		}
+	}
+	saveTestAttributeViews(t, attrView)
+
+	rollupAv, destAv, rollupKey := newTestRollupAttributeView(t, 1)
+	destNumKeyValues := destAv.KeyValues[2]
+	destNumKeyValues.Key.NumberFormat = av.NumberFormatCommas
+	destNumKeyValues.Values[0].Number.Content = 1234.5
+	saveTestAttributeViews(t, destAv)
+
+	for _, c := range []struct {
+		locale, number, date, created string
+	}{
+		{"", "1,234.5", "", "2024-01-02 03:04"},
+		{"en-US", "1,234.5", "03/04/2024", "01/02/2024 03:04"},
+		{"de-DE", "1.234,5", "04.03.2024", "02.01.2024 03:04"},
+	} {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, &RenderAttributeViewOptions{Locale: c.locale})
+		cells := table.Rows[0].Cells
+		if c.number != cells[2].Value.Number.FormattedContent || c.date != cells[3].Value.Date.FormattedContent || c.created != cells[4].Value.Created.FormattedContent {
+			t.Fatalf("unexpected formatting for locale [%s]: [%s] [%s] [%s]", c.locale, cells[2].Value.Number.FormattedContent, cells[3].Value.Date.FormattedContent, cells[4].Value.Created.FormattedContent)
+		}
+		if calcResult := table.Columns[2].Calc.Result.Number.FormattedContent; c.number != calcResult {
+			t.Fatalf("unexpected calc result formatting for locale [%s]: [%s]", c.locale, calcResult)
+		}
+
+		rollupAv, _ = av.ParseAttributeView(rollupAv.ID)
+		table = renderTestAttributeViewTable(t, rollupAv, &RenderAttributeViewOptions{Locale: c.locale})
+		for _, cell := range table.Rows[0].Cells {
+			if cell.Value.KeyID == rollupKey.ID {
+				if contents := cell.Value.Rollup.Contents; 1 != len(contents) || c.number != contents[0].Number.FormattedContent {
+					t.Fatalf("unexpected rollup formatting for locale [%s]", c.locale)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderAttributeViewPagination(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "a", "b", "c", "d", "e")
+
+	for _, c := range []struct {
+		page, pageCount, rows int
+		hasMore               bool
+	}{
+		{1, 3, 2, true},
+		{2, 3, 2, true},
+		{3, 3, 1, false},
+	} {
+		viewable, err := renderAttributeView(attrView, "", c.page, 2, nil)
+		if nil != err {
+			t.Fatalf("render attribute view failed: %s", err)
+		}
+		table := viewable.(*av.Table)
+		if 5 != table.RowCount || c.pageCount != table.PageCount || c.hasMore != table.HasMore || c.rows != len(table.Rows) {
+			t.Fatalf("unexpected pagination on page %d: pageCount %d, hasMore %v, rows %d", c.page, table.PageCount, table.HasMore, len(table.Rows))
+		}
+	}
+}
+
+func TestRenderAttributeViewSummaryRow(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	for i, amount := range []float64{1, 2, 3} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: amount, IsNotEmpty: true}})
+	}
+	attrView.Views[0].Table.Columns[2].Calc = &av.ColumnCalc{Operator: av.CalcOperatorSum}
+	saveTestAttributeViews(t, attrView)
+	if err := setAttributeViewShowSummaryRow(&Operation{AvID: attrView.ID, Data: true}); nil != err {
+		t.Fatalf("set summary row failed: %s", err)
+	}
+
+	render := func() *av.Table {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		viewable, err := renderAttributeView(attrView, "", 1, 2, nil)
+		if nil != err {
+			t.Fatalf("render attribute view failed: %s", err)
+		}
+		return viewable.(*av.Table)
+	}
+
+	table := render()
+	if 3 != table.RowCount || 3 != len(table.Rows) {
+		t.Fatalf("expected 2 rows plus summary row, got %d rows and row count %d", len(table.Rows), table.RowCount)
+	}
+	summary := table.Rows[2]
+	if !summary.Summary || table.Rows[0].Summary {
+		t.Fatalf("expected summary row at bottom")
+	}
+	if summary.Cells[2].Value != table.Columns[2].Calc.Result || 6 != summary.Cells[2].Value.Number.Content {
+		t.Fatalf("summary cell does not match column calc")
+	}
+	if nil != summary.Cells[1].Value {
+		t.Fatalf("expected empty summary cell for column without calc")
+	}
+
+	if err := setAttributeViewCalcPosition(&Operation{AvID: attrView.ID, Data: "top"}); nil != err {
+		t.Fatalf("set calc position failed: %s", err)
+	}
+	if table = render(); !table.Rows[0].Summary || table.Rows[2].Summary {
+		t.Fatalf("expected summary row at top")
+	}
+}
+
+func TestRenderAttributeViewTransposed(t *testing.T) {
+	setTestDataDir(t)
+	attrView, _, _ := newTestRollupAttributeView(t, 2)
+	envKey := addTestAttributeViewKey(attrView, "Env", av.KeyTypeSelect)
+	var rowID string
+	for _, value := range attrView.GetBlockKeyValues().Values {
+		if "1" == value.Block.Content {
+			rowID = value.BlockID
+		}
+	}
+	setTestAttributeViewValue(attrView, envKey.ID, rowID, &av.Value{MSelect: []*av.ValueSelect{{Content: "prod", Color: "1"}}})
+	attrView.KeyValues[4].Key.Template = ".action{.Block}@.action{.Env}"
+	// 过滤条件不影响转置结果
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: attrView.KeyValues[0].Key.ID, Operator: av.FilterOperatorIsEqual, Value: &av.Value{Block: &av.ValueBlock{Content: "0"}}}}
+	saveTestAttributeViews(t, attrView)
+
+	pivot, err := RenderAttributeViewTransposed(attrView.ID, "", rowID)
+	if nil != err {
+		t.Fatalf("render transposed attribute view failed: %s", err)
+	}
+	var got []string
+	for _, pair := range pivot {
+		got = append(got, pair[0]+"="+pair[1])
+	}
+	if "Block=1,Text=,Relation=1,Total=1,Template=1@prod,Env=prod" != strings.Join(got, ",") {
+		t.Fatalf("unexpected transposed row %v", got)
+	}
+
+	if _, err = RenderAttributeViewTransposed(attrView.ID, "", ast.NewNodeID()); nil == err {
+		t.Fatalf("expected row not found error")
+	}
+}
+
+func TestRecomputeAttributeView(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	destRowID := addTestAttributeViewRow(destAv, "new name")
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	rowID := addTestAttributeViewRow(attrView, "row")
+	// 关联内容快照已经过期，并且引用了目标属性视图中已经删除的块
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{destRowID, ast.NewNodeID()}, Contents: []string{"old name", "deleted"}}})
+	// 没有对应行的孤立单元格
+	orphanRowID := ast.NewNodeID()
+	setTestAttributeViewValue(attrView, attrView.KeyValues[1].Key.ID, orphanRowID, &av.Value{Text: &av.ValueText{Content: "orphan"}})
+	attrView.Views[0].Table.RowIDs = []string{orphanRowID, rowID, rowID}
+	saveTestAttributeViews(t, attrView)
+
+	if err := RecomputeAttributeView(attrView.ID); nil != err {
+		t.Fatalf("recompute attribute view failed: %s", err)
+	}
+	recomputed, _ := av.ParseAttributeView(attrView.ID)
+	if nil != recomputed.GetValue(recomputed.KeyValues[1].Key.ID, orphanRowID) {
+		t.Fatalf("expected orphan cell pruned")
+	}
+	relation := recomputed.GetValue(relKey.ID, rowID).Relation
+	if 1 != len(relation.BlockIDs) || destRowID != relation.BlockIDs[0] || 1 != len(relation.Contents) || "new name" != relation.Contents[0] {
+		t.Fatalf("expected relation content refreshed, got %v %v", relation.BlockIDs, relation.Contents)
+	}
+	if 1 != len(recomputed.Views[0].Table.RowIDs) || rowID != recomputed.Views[0].Table.RowIDs[0] {
+		t.Fatalf("expected row IDs reconciled, got %v", recomputed.Views[0].Table.RowIDs)
+	}
+
+	// 多次调用的结果一致
+	data, _ := os.ReadFile(av.GetAttributeViewDataPath(attrView.ID))
+	if err := RecomputeAttributeView(attrView.ID); nil != err {
+		t.Fatalf("recompute attribute view failed: %s", err)
+	}
+	if again, _ := os.ReadFile(av.GetAttributeViewDataPath(attrView.ID)); !bytes.Equal(data, again) {
+		t.Fatalf("expected recompute to be idempotent")
+	}
+}
+
+func TestEncodeAttributeViewQuery(t *testing.T) {
+	filters := []*av.ViewFilter{
+		{Column: "20240101000000-aaaaaaa", Operator: av.FilterOperatorContains, Value: &av.Value{Type: av.KeyTypeText, Text: &av.ValueText{Content: "foo bar"}}},
+		{Column: "20240101000000-bbbbbbb", Operator: av.FilterOperatorIsRelativeToToday, Value: &av.Value{Type: av.KeyTypeDate},
+			RelativeDate: &av.RelativeDate{Count: 7, Unit: av.RelativeDateUnitDay, Direction: av.RelativeDateDirectionBefore}},
+	}
+	sorts := []*av.ViewSort{
+		{Column: "20240101000000-aaaaaaa", Order: av.SortOrderAsc},
+		{Column: "20240101000000-bbbbbbb", Order: av.SortOrderDesc},
+	}
+
+	query, err := EncodeAttributeViewQuery(filters, sorts)
+	if nil != err {
+		t.Fatalf("encode query failed: %s", err)
+	}
+	if strings.ContainsAny(query, "+/=?&") {
+		t.Fatalf("query [%s] is not URL safe", query)
+	}
+
+	gotFilters, gotSorts, err := DecodeAttributeViewQuery(query)
+	if nil != err {
+		t.Fatalf("decode query failed: %s", err)
+	}
+	want, _ := gulu.JSON.MarshalJSON(&attributeViewQuery{Filters: filters, Sorts: sorts})
+	got, _ := gulu.JSON.MarshalJSON(&attributeViewQuery{Filters: gotFilters, Sorts: gotSorts})
+	if !bytes.Equal(want, got) {
+		t.Fatalf("expected [%s], got [%s]", want, got)
+	}
+
+	invalidFilters := []*av.ViewFilter{{Column: "20240101000000-aaaaaaa", Operator: "Matches regex"}}
+	query, _ = EncodeAttributeViewQuery(invalidFilters, nil)
+	if _, _, err = DecodeAttributeViewQuery(query); nil == err {
+		t.Fatalf("expected unknown operator to be rejected")
+	}
+	query, _ = EncodeAttributeViewQuery(nil, []*av.ViewSort{{Column: "20240101000000-aaaaaaa", Order: "random"}})
+	if _, _, err = DecodeAttributeViewQuery(query); nil == err {
+		t.Fatalf("expected unknown sort order to be rejected")
+	}
+	if _, _, err = DecodeAttributeViewQuery("not a query!"); nil == err {
+		t.Fatalf("expected malformed query to be rejected")
+	}
+	query, _ = EncodeAttributeViewQuery([]*av.ViewFilter{{Column: "20240101000000-aaaaaaa", Operator: av.FilterOperatorContains}}, nil)
+	if _, _, err = DecodeAttributeViewQuery(query); nil == err {
+		t.Fatalf("expected value-based operator without value to be rejected")
+	}
+	query, _ = EncodeAttributeViewQuery([]*av.ViewFilter{{Column: "20240101000000-aaaaaaa", Operator: av.FilterOperatorIsEmpty}}, nil)
+	if _, _, err = DecodeAttributeViewQuery(query); nil != err {
+		t.Fatalf("expected operator without value to be accepted: %s", err)
+	}
+}
+
+func TestEncodeAttributeViewViewQuery(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	currentFilters := []*av.ViewFilter{{Column: textKeyID, Operator: av.FilterOperatorIsNotEmpty}}
+	otherFilters := []*av.ViewFilter{{Column: textKeyID, Operator: av.FilterOperatorContains, Value: &av.Value{Text: &av.ValueText{Content: "foo"}}}}
+	attrView.Views[0].Table.Filters = currentFilters
+	otherView := &av.View{ID: ast.NewNodeID(), Name: "Other", LayoutType: av.LayoutTypeTable, Table: &av.LayoutTable{Filters: otherFilters, Sorts: []*av.ViewSort{}}}
+	attrView.Views = append(attrView.Views, otherView)
+	saveTestAttributeViews(t, attrView)
+
+	for viewID, want := range map[string]av.FilterOperator{"": av.FilterOperatorIsNotEmpty, otherView.ID: av.FilterOperatorContains} {
+		query, err := EncodeAttributeViewViewQuery(attrView.ID, viewID)
+		if nil != err {
+			t.Fatalf("encode view query failed: %s", err)
+		}
+		filters, _, err := DecodeAttributeViewQuery(query)
+		if nil != err {
+			t.Fatalf("decode query failed: %s", err)
+		}
+		if 1 != len(filters) || want != filters[0].Operator {
+			t.Fatalf("expected filters of view [%s]", viewID)
+		}
+	}
+	if _, err := EncodeAttributeViewViewQuery(attrView.ID, ast.NewNodeID()); av.ErrViewNotFound != err {
+		t.Fatalf("expected view not found, got %v", err)
+	}
+}
+
+func TestSetAttributeViewFrozenColumns(t *testing.T) {
+	setTestDataDir(t)
+	oldLangs := util.AttrViewLangs
+	util.AttrViewLangs = map[string]map[string]interface{}{util.Lang: {"table": "Table"}}
+	defer func() { util.AttrViewLangs = oldLangs }()
+	attrView := newTestAttributeView(t)
+	addTestAttributeViewKey(attrView, "Score", av.KeyTypeNumber)
+	saveTestAttributeViews(t, attrView)
+
+	if err := setAttributeViewFrozenColumns(&Operation{AvID: attrView.ID, Data: float64(2)}); nil != err {
+		t.Fatalf("set frozen columns failed: %s", err)
+	}
+	if err := setAttributeViewFrozenColumns(&Operation{AvID: attrView.ID, Data: float64(-1)}); nil == err {
+		t.Fatalf("expected negative frozen column count to be rejected")
+	}
+
+	masterViewID := attrView.Views[0].ID
+	newViewID := ast.NewNodeID()
+	tx := &Transaction{}
+	if txErr := tx.doDuplicateAttrViewView(&Operation{AvID: attrView.ID, ID: newViewID, PreviousID: masterViewID}); nil != txErr {
+		t.Fatalf("duplicate view failed: %s", txErr.msg)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	for _, viewID := range []string{masterViewID, newViewID} {
+		viewable, err := renderAttributeView(attrView, viewID, 1, -1, nil)
+		if nil != err {
+			t.Fatalf("render attribute view failed: %s", err)
+		}
+		if got := viewable.(*av.Table).FrozenColumnCount; 2 != got {
+			t.Fatalf("expected view [%s] frozen column count [2], got [%d]", viewID, got)
+		}
+	}
+
+	attrView.GetView(newViewID).Table.FrozenColumnCount = 10
+	viewable, err := renderAttributeView(attrView, newViewID, 1, -1, nil)
+	if nil != err {
+		t.Fatalf("render attribute view failed: %s", err)
+	}
+	if got := viewable.(*av.Table).FrozenColumnCount; 3 != got {
+		t.Fatalf("expected frozen column count clamped to [3], got [%d]", got)
+	}
+}
+
+func TestRenderAttributeViewRightPinnedColumns(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	actionsKey := addTestAttributeViewKey(attrView, "Actions", av.KeyTypeText)
+	notesKey := addTestAttributeViewKey(attrView, "Notes", av.KeyTypeText)
+	addTestAttributeViewKey(attrView, "Score", av.KeyTypeNumber)
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	setTestAttributeViewValue(attrView, actionsKey.ID, rowID, &av.Value{Text: &av.ValueText{Content: "edit"}})
+	attrView.Views[0].Table.Columns[0].Pin = true // 主键列固定在左侧
+	saveTestAttributeViews(t, attrView)
+
+	for _, keyID := range []string{actionsKey.ID, notesKey.ID} {
+		if err := setAttributeViewColPinSide(&Operation{AvID: attrView.ID, ID: keyID, Data: string(av.PinSideRight)}); nil != err {
+			t.Fatalf("set pin side failed: %s", err)
+		}
+	}
+	if err := setAttributeViewColPinSide(&Operation{AvID: attrView.ID, ID: notesKey.ID, Data: "top"}); nil == err {
+		t.Fatalf("expected invalid pin side to be rejected")
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	var names []string
+	for _, col := range table.Columns {
+		names = append(names, col.Name)
+	}
+	if "Block,Text,Score,Actions,Notes" != strings.Join(names, ",") {
+		t.Fatalf("unexpected column order [%s]", strings.Join(names, ","))
+	}
+	if !table.Columns[0].Pin || "" != table.Columns[0].PinSide {
+		t.Fatalf("expected block column pinned left")
+	}
+	if got := table.Rows[0].Cells[3].Value.String(); "edit" != got {
+		t.Fatalf("expected actions cell [edit] to follow its column, got [%s]", got)
+	}
+}
+
+func TestApplyAttributeViewSortPreset(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	numKey := addTestAttributeViewKey(attrView, "Priority", av.KeyTypeNumber)
+	first := attrView.Views[0]
+	second := &av.View{ID: ast.NewNodeID(), Name: "Other", LayoutType: av.LayoutTypeTable, Table: &av.LayoutTable{ID: ast.NewNodeID(), Columns: first.Table.Columns, Filters: []*av.ViewFilter{}, Sorts: []*av.ViewSort{}}}
+	attrView.Views = append(attrView.Views, second)
+	saveTestAttributeViews(t, attrView)
+
+	presetID := ast.NewNodeID()
+	sorts := []interface{}{
+		map[string]interface{}{"column": numKey.ID, "order": "DESC"},
+		map[string]interface{}{"column": textKeyID, "order": "ASC"},
+	}
+	if err := addAttributeViewSortPreset(&Operation{AvID: attrView.ID, ID: presetID, Name: "By priority then name", Data: sorts}); nil != err {
+		t.Fatalf("add sort preset failed: %s", err)
+	}
+	if err := addAttributeViewSortPreset(&Operation{AvID: attrView.ID, ID: ast.NewNodeID(), Name: "Bad", Data: []interface{}{map[string]interface{}{"column": "missing", "order": "ASC"}}}); nil == err {
+		t.Fatalf("expected preset with missing column to be rejected")
+	}
+
+	for _, view := range []*av.View{first, second} {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		attrView.ViewID = view.ID
+		saveTestAttributeViews(t, attrView)
+		if err := applyAttributeViewSortPreset(&Operation{AvID: attrView.ID, ID: presetID}); nil != err {
+			t.Fatalf("apply sort preset failed: %s", err)
+		}
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	if 1 != len(attrView.SortPresets) {
+		t.Fatalf("expected 1 sort preset, got %d", len(attrView.SortPresets))
+	}
+	for _, view := range attrView.Views {
+		sorts := view.Table.Sorts
+		if 2 != len(sorts) || numKey.ID != sorts[0].Column || av.SortOrderDesc != sorts[0].Order || textKeyID != sorts[1].Column || av.SortOrderAsc != sorts[1].Order {
+			t.Fatalf("view [%s] has unexpected sorts", view.Name)
+		}
+	}
+}
+
+func TestRenderAttributeViewStableSort(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Priority", av.KeyTypeNumber)
+	var rowIDs []string
+	for i := 0; i < 100; i++ {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: float64(i % 2), IsNotEmpty: true}})
+		rowIDs = append(rowIDs, rowID)
+	}
+	attrView.Views[0].Table.Sorts = []*av.ViewSort{{Column: numKey.ID, Order: av.SortOrderAsc}}
+	saveTestAttributeViews(t, attrView)
+
+	render := func(page int) (ret []string) {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		viewable, err := renderAttributeView(attrView, "", page, 30, nil)
+		if nil != err {
+			t.Fatalf("render attribute view failed: %s", err)
+		}
+		for _, row := range viewable.(*av.Table).Rows {
+			ret = append(ret, row.ID)
+		}
+		return
+	}
+
+	var first []string
+	for page := 1; page <= 4; page++ {
+		first = append(first, render(page)...)
+	}
+	for i := 0; i < 5; i++ {
+		var again []string
+		for page := 1; page <= 4; page++ {
+			again = append(again, render(page)...)
+		}
+		if strings.Join(first, ",") != strings.Join(again, ",") {
+			t.Fatalf("expected identical ordering across renders")
+		}
+	}
+
+	// 排序值相同的行按行 ID 排列
+	sort.Strings(rowIDs)
+	var evens, odds []string
+	for _, rowID := range rowIDs {
+		if val := attrView.GetValue(numKey.ID, rowID); 0 == val.Number.Content {
+			evens = append(evens, rowID)
+		} else {
+			odds = append(odds, rowID)
+		}
+	}
+	if strings.Join(append(evens, odds...), ",") != strings.Join(first, ",") {
+		t.Fatalf("expected rows with equal sort values ordered by row ID")
+	}
+}
+
+func TestRenderAttributeViewColumnGroups(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	budgetKey := addTestAttributeViewKey(attrView, "Budget", av.KeyTypeNumber)
+	spentKey := addTestAttributeViewKey(attrView, "Spent", av.KeyTypeNumber)
+	remainingKey := addTestAttributeViewKey(attrView, "Remaining", av.KeyTypeNumber)
+	addTestAttributeViewRow(attrView, "foo")
+	saveTestAttributeViews(t, attrView)
+
+	groups := []interface{}{map[string]interface{}{"name": "Financials", "columnIds": []interface{}{remainingKey.ID, budgetKey.ID, spentKey.ID}}}
+	if err := setAttributeViewColumnGroups(&Operation{AvID: attrView.ID, Data: groups}); nil != err {
+		t.Fatalf("set column groups failed: %s", err)
+	}
+	invalid := []interface{}{
+		map[string]interface{}{"name": "A", "columnIds": []interface{}{budgetKey.ID}},
+		map[string]interface{}{"name": "B", "columnIds": []interface{}{budgetKey.ID}},
+	}
+	if err := setAttributeViewColumnGroups(&Operation{AvID: attrView.ID, Data: invalid}); nil == err {
+		t.Fatalf("expected column in multiple groups to be rejected")
+	}
+
+	renderGroups := func() (ret []string) {
+		attrView, _ = av.ParseAttributeView(attrView.ID)
+		table := renderTestAttributeViewTable(t, attrView, nil)
+		names := map[string]string{}
+		for _, col := range table.Columns {
+			names[col.ID] = col.Name
+		}
+		for _, group := range table.ColumnGroups {
+			var cols []string
+			for _, colID := range group.ColumnIDs {
+				cols = append(cols, names[colID])
+			}
+			ret = append(ret, group.Name+":"+strings.Join(cols, ","))
+		}
+		return
+	}
+
+	if got := strings.Join(renderGroups(), ";"); "Financials:Budget,Spent,Remaining" != got {
+		t.Fatalf("unexpected column groups [%s]", got)
+	}
+	if 2 != len(attrView.Views[0].Table.Columns)-len(attrView.Views[0].Table.ColumnGroups[0].ColumnIDs) {
+		t.Fatalf("expected block and text columns ungrouped")
+	}
+
+	// 调整列顺序后分组中的列顺序保持一致
+	if err := sortAttributeViewColumn(&Operation{AvID: attrView.ID, ID: remainingKey.ID, PreviousID: textKeyID}); nil != err {
+		t.Fatalf("sort column failed: %s", err)
+	}
+	if got := strings.Join(renderGroups(), ";"); "Financials:Remaining,Budget,Spent" != got {
+		t.Fatalf("unexpected column groups after sort [%s]", got)
+	}
+
+	if err := removeAttributeViewColumn(&Operation{AvID: attrView.ID, ID: spentKey.ID}); nil != err {
+		t.Fatalf("remove column failed: %s", err)
+	}
+	if got := strings.Join(renderGroups(), ";"); "Financials:Remaining,Budget" != got {
+		t.Fatalf("unexpected column groups after remove [%s]", got)
+	}
+	if ids := attrView.Views[0].Table.ColumnGroups[0].ColumnIDs; 2 != len(ids) {
+		t.Fatalf("expected removed column pruned from saved group, got %v", ids)
+	}
+}
+
+func TestRenderAttributeViewGroupCalcs(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	amountKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	for i, group := range []string{"a", "b", "a", "b", "a"} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: group}})
+		setTestAttributeViewValue(attrView, amountKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: float64(i + 1), IsNotEmpty: true}})
+	}
+	table := attrView.Views[0].Table
+	table.Columns[2].Calc = &av.ColumnCalc{Operator: av.CalcOperatorSum}
+	saveTestAttributeViews(t, attrView)
+	if err := setAttributeViewGroupBy(&Operation{AvID: attrView.ID, KeyID: textKeyID}); nil != err {
+		t.Fatalf("set group by failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	viewable, err := renderAttributeView(attrView, "", 1, 2, nil)
+	if nil != err {
+		t.Fatalf("render attribute view failed: %s", err)
+	}
+	rendered := viewable.(*av.Table)
+	if 2 != len(rendered.Groups) {
+		t.Fatalf("expected 2 groups, got %d", len(rendered.Groups))
+	}
+	groups := map[string]*av.TableGroup{}
+	for _, group := range rendered.Groups {
+		groups[group.Value] = group
+	}
+	if nil == groups["a"] || nil == groups["b"] || 3 != groups["a"].Count || 2 != groups["b"].Count {
+		t.Fatalf("unexpected groups")
+	}
+
+	groupSum := 0.0
+	for _, group := range rendered.Groups {
+		groupSum += group.Calcs[amountKey.ID].Result.Number.Content
+	}
+	grandTotal := rendered.GroupTotalCalcs[amountKey.ID].Result.Number.Content
+	if 15 != grandTotal || groupSum != grandTotal {
+		t.Fatalf("expected group sums [%v] to add up to grand total [%v]", groupSum, grandTotal)
+	}
+	if 9 != groups["a"].Calcs[amountKey.ID].Result.Number.Content {
+		t.Fatalf("expected group [a] sum [9], got [%v]", groups["a"].Calcs[amountKey.ID].Result.Number.Content)
+	}
+}
+
+func TestRenderAttributeViewGroupPercent(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	textKeyID := attrView.KeyValues[1].Key.ID
+	for i, group := range []string{"a", "b", "a"} {
+		rowID := addTestAttributeViewRow(attrView, strconv.Itoa(i))
+		setTestAttributeViewValue(attrView, textKeyID, rowID, &av.Value{Text: &av.ValueText{Content: group}})
+	}
+	attrView.Views[0].Table.GroupBy = textKeyID
+	saveTestAttributeViews(t, attrView)
+	if err := setAttributeViewShowGroupPercent(&Operation{AvID: attrView.ID, Data: true}); nil != err {
+		t.Fatalf("set show group percent failed: %s", err)
+	}
+
+	attrView, _ = av.ParseAttributeView(attrView.ID)
+	table := renderTestAttributeViewTable(t, attrView, nil)
+	percents := map[string]float64{}
+	for _, group := range table.Groups {
+		percents[group.Value] = group.Percent
+	}
+	if 2 != len(percents) || 0.01 < math.Abs(66.67-percents["a"]) || 0.01 < math.Abs(33.33-percents["b"]) {
+		t.Fatalf("unexpected group percents %v", percents)
+	}
+	if 0.0001 < math.Abs(100-percents["a"]-percents["b"]) {
+		t.Fatalf("expected group percents sum to 100")
+	}
+}
@@ -161,6 +161,59 @@ func getNodeRefText(node *ast.Node) string {
 	return getNodeRefText0(node)
 }
 
+// getBlockRichRefText 返回块 id 的引用锚文本，和 getNodeRefText 的区别是保留了加粗、链接等行级元素的 Markdown 格式。
+// trees 用于缓存已经加载的文档树，键为文档 ID。
+func getBlockRichRefText(id string, trees map[string]*parse.Tree) (ret string) {
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return
+	}
+
+	tree := trees[bt.RootID]
+	if nil == tree {
+		var err error
+		if tree, err = loadTreeByBlockID(id); nil != err {
+			return
+		}
+		trees[bt.RootID] = tree
+	}
+
+	node := treenode.GetNodeInTree(tree, id)
+	if nil == node {
+		return
+	}
+
+	if ret = node.IALAttr("name"); "" != ret {
+		ret = strings.TrimSpace(ret)
+		ret = util.EscapeHTML(ret)
+		return
+	}
+
+	if ast.NodeDocument == node.Type {
+		// 文档标题中不包含行级元素，返回空以便调用方使用纯文本
+		return
+	}
+	if node.IsContainerBlock() {
+		if node = treenode.FirstLeafBlock(node); nil == node {
+			return
+		}
+	}
+	if ast.NodeParagraph != node.Type && ast.NodeHeading != node.Type {
+		return getNodeRefText0(node)
+	}
+
+	ret = treenode.ExportNodeStdMd(node, util.NewLute())
+	ret = strings.TrimSpace(ret)
+	if ast.NodeHeading == node.Type {
+		ret = strings.TrimSpace(strings.TrimLeft(ret, "#"))
+	}
+	ret = strings.ReplaceAll(ret, "\n", "")
+	if Conf.Editor.BlockRefDynamicAnchorTextMaxLen < utf8.RuneCountInString(ret) {
+		ret = gulu.Str.SubStr(ret, Conf.Editor.BlockRefDynamicAnchorTextMaxLen) + "..."
+	}
+	return
+}
+
 func getNodeRefText0(node *ast.Node) string {
 	switch node.Type {
 	case ast.NodeBlockQueryEmbed:
@@ -222,6 +222,9 @@ func InitConf() {
 	if 1 > len(Conf.Editor.Emoji) {
 		Conf.Editor.Emoji = []string{}
 	}
+	if nil == Conf.Editor.AllowedAttrViewKeyTypes {
+		Conf.Editor.AllowedAttrViewKeyTypes = []string{}
+	}
 	if 9 > Conf.Editor.FontSize || 72 < Conf.Editor.FontSize {
 		Conf.Editor.FontSize = 16
 	}
@@ -54,6 +54,7 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
+	"github.com/xuri/excelize/v2"
 )
 
 func ExportAv2CSV(avID string) (zipPath string, err error) {
@@ -71,11 +72,12 @@ func ExportAv2CSV(avID string) (zipPath string, err error) {
 
 	name := util.FilterFileName(attrView.Name)
 
-	table, err := renderAttributeViewTable(attrView, view)
+	table, err := renderAttributeViewTable(attrView, view, nil)
 	if nil != err {
 		logging.LogErrorf("render attribute view [%s] table failed: %s", avID, err)
 		return
 	}
+	renderAttributeViewOrderedCols(attrView, table)
 
 	exportFolder := filepath.Join(util.TempDir, "export", "csv", name)
 	if err = os.MkdirAll(exportFolder, 0755); nil != err {
@@ -173,6 +175,604 @@ func ExportAv2CSV(avID string) (zipPath string, err error) {
 	return
 }
 
+// ExportAttributeViewXLSX 将属性视图的表格视图导出为 Excel 文件，返回文件内容。
+// 导出时应用视图的过滤和排序，数字、日期和复选框导出为对应类型的单元格，计算列导出渲染后的值。
+func ExportAttributeViewXLSX(avID, viewID string) (ret []byte, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	view := attrView.GetView(viewID)
+	if nil == view {
+		if view, err = attrView.GetCurrentView(); nil != err {
+			return
+		}
+	}
+	if av.LayoutTypeTable != view.LayoutType {
+		err = fmt.Errorf("unsupported layout type [%s]", view.LayoutType)
+		return
+	}
+
+	table, err := renderAttributeViewTable(attrView, view, nil)
+	if nil != err {
+		logging.LogErrorf("render attribute view [%s] table failed: %s", avID, err)
+		return
+	}
+	table.FilterRows(attrView)
+	table.FilterRowsByBindingState()
+	table.SortRows()
+	renderAttributeViewOrderedCols(attrView, table)
+
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := f.GetSheetName(0)
+
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#F2F2F2"}},
+	})
+	if nil != err {
+		return
+	}
+	dateStyle, err := f.NewStyle(&excelize.Style{NumFmt: 14}) // yyyy/m/d
+	if nil != err {
+		return
+	}
+	dateTimeStyle, err := f.NewStyle(&excelize.Style{NumFmt: 22}) // yyyy/m/d h:mm
+	if nil != err {
+		return
+	}
+
+	var colIndexes []int
+	for i, col := range table.Columns {
+		if !col.Hidden {
+			colIndexes = append(colIndexes, i)
+		}
+	}
+
+	for x, i := range colIndexes {
+		col := table.Columns[i]
+		cellName, _ := excelize.CoordinatesToCellName(x+1, 1)
+		f.SetCellStr(sheet, cellName, col.Name)
+		f.SetCellStyle(sheet, cellName, cellName, headerStyle)
+
+		// 列宽使用像素，Excel 列宽大约是字符数，按每个字符 7 像素换算
+		if width, parseErr := strconv.ParseFloat(strings.TrimSuffix(col.Width, "px"), 64); nil == parseErr && 0 < width {
+			colName, _ := excelize.ColumnNumberToName(x + 1)
+			f.SetColWidth(sheet, colName, colName, width/7)
+		}
+	}
+
+	for y, row := range table.Rows {
+		for x, i := range colIndexes {
+			cell := row.Cells[i]
+			if nil == cell.Value {
+				continue
+			}
+
+			cellName, _ := excelize.CoordinatesToCellName(x+1, y+2)
+			val := cell.Value
+			switch table.Columns[i].Type.BaseType() {
+			case av.KeyTypeNumber:
+				if nil != val.Number && val.Number.IsNotEmpty {
+					f.SetCellFloat(sheet, cellName, val.Number.Content, -1, 64)
+				}
+			case av.KeyTypeDate:
+				if nil != val.Date && val.Date.IsNotEmpty {
+					if val.Date.HasEndDate { // 日期范围无法使用一个日期单元格表示
+						f.SetCellStr(sheet, cellName, av.NewFormattedValueDate(val.Date.Content, val.Date.Content2, av.DateFormatNone, val.Date.IsNotTime).FormattedContent)
+					} else {
+						f.SetCellValue(sheet, cellName, xlsxLocalTime(val.Date.Content))
+						if val.Date.IsNotTime {
+							f.SetCellStyle(sheet, cellName, cellName, dateStyle)
+						} else {
+							f.SetCellStyle(sheet, cellName, cellName, dateTimeStyle)
+						}
+					}
+				}
+			case av.KeyTypeCreated:
+				if nil != val.Created && val.Created.IsNotEmpty {
+					f.SetCellValue(sheet, cellName, xlsxLocalTime(val.Created.Content))
+					f.SetCellStyle(sheet, cellName, cellName, dateTimeStyle)
+				}
+			case av.KeyTypeUpdated:
+				if nil != val.Updated && val.Updated.IsNotEmpty {
+					f.SetCellValue(sheet, cellName, xlsxLocalTime(val.Updated.Content))
+					f.SetCellStyle(sheet, cellName, cellName, dateTimeStyle)
+				}
+			case av.KeyTypeCheckbox:
+				f.SetCellBool(sheet, cellName, nil != val.Checkbox && val.Checkbox.Checked)
+			case av.KeyTypeSelect, av.KeyTypeMSelect:
+				var contents []string
+				for _, opt := range val.MSelect {
+					contents = append(contents, opt.Content)
+				}
+				f.SetCellStr(sheet, cellName, strings.Join(contents, ", "))
+			case av.KeyTypeMAsset:
+				var contents []string
+				for _, asset := range val.MAsset {
+					contents = append(contents, asset.Content)
+				}
+				f.SetCellStr(sheet, cellName, strings.Join(contents, ", "))
+			default:
+				f.SetCellStr(sheet, cellName, val.String())
+			}
+		}
+	}
+
+	if "" != view.Name {
+		// 工作表名称不能超过 31 个字符且不能包含特殊字符，设置失败时使用默认名称
+		name := []rune(strings.NewReplacer("[", "", "]", "", ":", "", "*", "", "?", "", "/", "", "\\", "").Replace(view.Name))
+		if 31 < len(name) {
+			name = name[:31]
+		}
+		if 0 < len(name) {
+			f.SetSheetName(sheet, string(name))
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if nil != err {
+		logging.LogErrorf("write xlsx of attribute view [%s] failed: %s", avID, err)
+		return
+	}
+	ret = buf.Bytes()
+	return
+}
+
+// ExportAttributeViewJSONSchema 将属性视图的结构（列和视图）导出为 JSON Schema，描述一行数据的对象结构，供外部工具校验导入的数据。
+// 属性名使用列 ID，列名放在 title 中；计算列标记为只读。
+func ExportAttributeViewJSONSchema(avID string) (ret []byte, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	properties := map[string]interface{}{}
+	var required []string
+	for _, keyValues := range attrView.KeyValues {
+		key := keyValues.Key
+		property := map[string]interface{}{"title": key.Name}
+		switch key.Type.BaseType() {
+		case av.KeyTypeNumber:
+			property["type"] = "number"
+			if key.Type.IsComputedNumber() {
+				property["readOnly"] = true
+			}
+		case av.KeyTypeCheckbox:
+			property["type"] = "boolean"
+		case av.KeyTypeDate:
+			property["type"] = "string"
+			property["format"] = "date-time"
+		case av.KeyTypeCreated, av.KeyTypeUpdated:
+			property["type"] = "string"
+			property["format"] = "date-time"
+			property["readOnly"] = true
+		case av.KeyTypeURL:
+			property["type"] = "string"
+			property["format"] = "uri"
+		case av.KeyTypeEmail:
+			property["type"] = "string"
+			property["format"] = "email"
+		case av.KeyTypeSelect:
+			property["type"] = "string"
+			if enum := jsonSchemaOptionEnum(key); 0 < len(enum) {
+				property["enum"] = enum
+			}
+		case av.KeyTypeMSelect:
+			items := map[string]interface{}{"type": "string"}
+			if enum := jsonSchemaOptionEnum(key); 0 < len(enum) {
+				items["enum"] = enum
+			}
+			property["type"] = "array"
+			property["items"] = items
+			property["uniqueItems"] = true
+		case av.KeyTypeMAsset:
+			property["type"] = "array"
+			property["items"] = map[string]interface{}{"type": "string"}
+		case av.KeyTypeRelation:
+			// 关联列的值是目标属性视图中的行 ID
+			property["type"] = "array"
+			property["items"] = map[string]interface{}{"type": "string"}
+			property["uniqueItems"] = true
+			if nil != key.Relation && 0 < key.Relation.MaxEntries {
+				property["maxItems"] = key.Relation.MaxEntries
+			}
+		case av.KeyTypeRollup:
+			property["type"] = "array"
+			property["readOnly"] = true
+		case av.KeyTypeText:
+			property["type"] = "string"
+			if key.Type.IsComputedText() {
+				property["readOnly"] = true
+			}
+		case av.KeyTypeTemplate:
+			property["type"] = "string"
+			property["readOnly"] = true
+		case av.KeyTypeBlock:
+			property["type"] = "string"
+			required = append(required, key.ID)
+		default:
+			property["type"] = "string"
+		}
+		properties[key.ID] = property
+	}
+
+	var views []map[string]interface{}
+	for _, view := range attrView.Views {
+		var columns []string
+		if nil != view.Table {
+			for _, col := range view.Table.Columns {
+				columns = append(columns, col.ID)
+			}
+		}
+		views = append(views, map[string]interface{}{"id": view.ID, "name": view.Name, "layout": view.LayoutType, "columns": columns})
+	}
+
+	schema := map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"$id":        "siyuan://av/" + attrView.ID,
+		"title":      attrView.Name,
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+		"x-views":    views, // 视图不属于行数据结构，使用扩展字段导出
+	}
+	ret, err = gulu.JSON.MarshalIndentJSON(schema, "", "  ")
+	return
+}
+
+func jsonSchemaOptionEnum(key *av.Key) (ret []string) {
+	for _, opt := range key.Options {
+		ret = append(ret, opt.Name)
+	}
+	return
+}
+
+// ExportAttributeViewJSON 将属性视图 viewID 中过滤和排序后的行导出为 JSON 对象数组，每行的属性名使用列 ID，id 为行 ID。
+// includeComputed 为 true 时导出渲染后的值（包括模板列、汇总列、关联列、创建时间列和更新时间列），否则只导出保存的原始值，计算列没有原始值时为 null。
+func ExportAttributeViewJSON(avID, viewID string, includeComputed bool) (ret []byte, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	viewable, err := renderAttributeView(attrView, viewID, 1, -1, nil)
+	if nil != err {
+		return
+	}
+
+	table, ok := viewable.(*av.Table)
+	if !ok {
+		err = fmt.Errorf("unsupported layout type [%s]", viewable.GetType())
+		return
+	}
+
+	rows := []map[string]interface{}{}
+	for _, row := range table.Rows {
+		obj := map[string]interface{}{"id": row.ID}
+		for i, col := range table.Columns {
+			val := attrView.GetValue(col.ID, row.ID)
+			if includeComputed {
+				val = row.Cells[i].Value
+			}
+			obj[col.ID] = attributeViewJSONValue(val, includeComputed)
+		}
+		rows = append(rows, obj)
+	}
+	ret, err = gulu.JSON.MarshalIndentJSON(rows, "", "  ")
+	return
+}
+
+// attributeViewJSONValue 将值转换为 JSON 中的简单值，原始值和 ExportAttributeViewJSONSchema 描述的类型一致。
+func attributeViewJSONValue(val *av.Value, rendered bool) interface{} {
+	if nil == val {
+		return nil
+	}
+
+	switch val.Type.BaseType() {
+	case av.KeyTypeNumber:
+		if nil == val.Number || !val.Number.IsNotEmpty {
+			return nil
+		}
+		return val.Number.Content
+	case av.KeyTypeDate:
+		if nil == val.Date || !val.Date.IsNotEmpty {
+			return nil
+		}
+		return time.UnixMilli(val.Date.Content).Format(time.RFC3339)
+	case av.KeyTypeCreated:
+		if nil == val.Created || 1 > val.Created.Content {
+			return nil
+		}
+		return time.UnixMilli(val.Created.Content).Format(time.RFC3339)
+	case av.KeyTypeUpdated:
+		if nil == val.Updated || 1 > val.Updated.Content {
+			return nil
+		}
+		return time.UnixMilli(val.Updated.Content).Format(time.RFC3339)
+	case av.KeyTypeCheckbox:
+		return nil != val.Checkbox && val.Checkbox.Checked
+	case av.KeyTypeMSelect:
+		ret := []string{}
+		for _, opt := range val.MSelect {
+			ret = append(ret, opt.Content)
+		}
+		return ret
+	case av.KeyTypeMAsset:
+		ret := []string{}
+		for _, asset := range val.MAsset {
+			ret = append(ret, asset.Content)
+		}
+		return ret
+	case av.KeyTypeRelation:
+		if nil == val.Relation {
+			return []string{}
+		}
+		if rendered { // 渲染后的关联列导出关联行的主键内容
+			return append([]string{}, val.Relation.Contents...)
+		}
+		return append([]string{}, val.Relation.BlockIDs...)
+	case av.KeyTypeRollup:
+		ret := []string{}
+		if nil != val.Rollup {
+			for _, content := range val.Rollup.Contents {
+				ret = append(ret, content.String())
+			}
+		}
+		return ret
+	}
+	return val.String()
+}
+
+// notionColors 是 Notion 选项颜色，思源的选项颜色（1-13）按顺序循环映射。
+var notionColors = []string{"default", "gray", "brown", "orange", "yellow", "green", "blue", "purple", "pink", "red"}
+
+// ExportAttributeViewNotionJSON 将属性视图导出为类似 Notion 数据库结构的 JSON，包括数据库属性定义和所有行（page），用于迁移到其他工具。
+// 映射的限制：
+//   - 主键列映射为 title，文本列映射为 rich_text，选项颜色按顺序映射为 Notion 的颜色名称
+//   - 关联列映射为 relation，database_id 为目标属性视图 ID，值为目标行 ID
+//   - 模板列、汇总列、块属性列以及各种计算数字列导出为 rich_text，值是渲染后的文本，不保留计算规则
+//   - 累计求和列、行差值列和占比列依赖视图中的行顺序，导出时为空
+//   - Notion 中的属性名不能重复，重名的列会在名称后追加列 ID
+func ExportAttributeViewNotionJSON(avID string) (ret []byte, err error) {
+	attrView, err := av.ParseAttributeView(avID)
+	if nil != err {
+		return
+	}
+
+	cells, err := renderAttributeViewAllCells(attrView)
+	if nil != err {
+		return
+	}
+
+	names := map[string]string{}
+	usedNames := map[string]bool{}
+	properties := map[string]interface{}{}
+	for _, keyValues := range attrView.KeyValues {
+		key := keyValues.Key
+		name := key.Name
+		if usedNames[name] {
+			name += " (" + key.ID + ")"
+		}
+		usedNames[name] = true
+		names[key.ID] = name
+
+		typ, config := notionPropertyType(key)
+		properties[name] = map[string]interface{}{"id": key.ID, "name": name, "type": typ, typ: config}
+	}
+
+	pages := []map[string]interface{}{}
+	for _, blockValue := range attrView.GetBlockKeyValues().Values {
+		rowCells, ok := cells[blockValue.BlockID]
+		if !ok {
+			continue
+		}
+
+		pageProperties := map[string]interface{}{}
+		for _, keyValues := range attrView.KeyValues {
+			typ, _ := notionPropertyType(keyValues.Key)
+			pageProperties[names[keyValues.Key.ID]] = map[string]interface{}{"id": keyValues.Key.ID, "type": typ, typ: notionPropertyValue(typ, rowCells[keyValues.Key.ID])}
+		}
+		pages = append(pages, map[string]interface{}{"object": "page", "id": blockValue.BlockID, "properties": pageProperties})
+	}
+
+	database := map[string]interface{}{
+		"object":     "database",
+		"id":         attrView.ID,
+		"title":      notionRichText(attrView.Name),
+		"properties": properties,
+		"pages":      pages,
+	}
+	ret, err = gulu.JSON.MarshalIndentJSON(database, "", "  ")
+	return
+}
+
+// notionPropertyType 返回列对应的 Notion 属性类型和属性配置。
+func notionPropertyType(key *av.Key) (typ string, config map[string]interface{}) {
+	config = map[string]interface{}{}
+	switch key.Type {
+	case av.KeyTypeBlock:
+		typ = "title"
+	case av.KeyTypeNumber:
+		typ = "number"
+		format := "number"
+		switch key.NumberFormat {
+		case av.NumberFormatCommas:
+			format = "number_with_commas"
+		case av.NumberFormatPercent:
+			format = "percent"
+		case av.NumberFormatUSDollar:
+			format = "dollar"
+		case av.NumberFormatYuan:
+			format = "yuan"
+		case av.NumberFormatEuro:
+			format = "euro"
+		case av.NumberFormatPound:
+			format = "pound"
+		case av.NumberFormatYen:
+			format = "yen"
+		case av.NumberFormatRuble:
+			format = "ruble"
+		case av.NumberFormatRupee:
+			format = "rupee"
+		case av.NumberFormatWon:
+			format = "won"
+		case av.NumberFormatCanadianDollar:
+			format = "canadian_dollar"
+		case av.NumberFormatFranc:
+			format = "franc"
+		}
+		config["format"] = format
+	case av.KeyTypeSelect, av.KeyTypeMSelect:
+		typ = "select"
+		if av.KeyTypeMSelect == key.Type {
+			typ = "multi_select"
+		}
+		options := []map[string]interface{}{}
+		for _, opt := range key.Options {
+			options = append(options, map[string]interface{}{"name": opt.Name, "color": notionColor(opt.Color)})
+		}
+		config["options"] = options
+	case av.KeyTypeDate:
+		typ = "date"
+	case av.KeyTypeCheckbox:
+		typ = "checkbox"
+	case av.KeyTypeURL:
+		typ = "url"
+	case av.KeyTypeEmail:
+		typ = "email"
+	case av.KeyTypePhone:
+		typ = "phone_number"
+	case av.KeyTypeMAsset:
+		typ = "files"
+	case av.KeyTypeCreated:
+		typ = "created_time"
+	case av.KeyTypeUpdated:
+		typ = "last_edited_time"
+	case av.KeyTypeRelation:
+		typ = "relation"
+		if nil != key.Relation {
+			config["database_id"] = key.Relation.AvID
+			if key.Relation.IsTwoWay {
+				config["type"] = "dual_property"
+				config["dual_property"] = map[string]interface{}{"synced_property_id": key.Relation.BackKeyID}
+			} else {
+				config["type"] = "single_property"
+				config["single_property"] = map[string]interface{}{}
+			}
+		}
+	default:
+		// 文本列和计算列
+		typ = "rich_text"
+	}
+	return
+}
+
+// notionPropertyValue 将渲染后的值转换为 Notion 属性类型 typ 的值。
+func notionPropertyValue(typ string, val *av.Value) interface{} {
+	switch typ {
+	case "number":
+		if nil == val || nil == val.Number || !val.Number.IsNotEmpty {
+			return nil
+		}
+		return val.Number.Content
+	case "select":
+		if nil == val || 1 > len(val.MSelect) {
+			return nil
+		}
+		return map[string]interface{}{"name": val.MSelect[0].Content, "color": notionColor(val.MSelect[0].Color)}
+	case "multi_select":
+		ret := []map[string]interface{}{}
+		if nil != val {
+			for _, opt := range val.MSelect {
+				ret = append(ret, map[string]interface{}{"name": opt.Content, "color": notionColor(opt.Color)})
+			}
+		}
+		return ret
+	case "date":
+		if nil == val || nil == val.Date || !val.Date.IsNotEmpty {
+			return nil
+		}
+		layout := time.RFC3339
+		if val.Date.IsNotTime {
+			layout = "2006-01-02"
+		}
+		ret := map[string]interface{}{"start": time.UnixMilli(val.Date.Content).Format(layout), "end": nil}
+		if val.Date.HasEndDate && val.Date.IsNotEmpty2 {
+			ret["end"] = time.UnixMilli(val.Date.Content2).Format(layout)
+		}
+		return ret
+	case "checkbox":
+		return nil != val && nil != val.Checkbox && val.Checkbox.Checked
+	case "files":
+		ret := []map[string]interface{}{}
+		if nil != val {
+			for _, asset := range val.MAsset {
+				name := asset.Name
+				if "" == name {
+					name = path.Base(asset.Content)
+				}
+				ret = append(ret, map[string]interface{}{"name": name, "type": "external", "external": map[string]interface{}{"url": asset.Content}})
+			}
+		}
+		return ret
+	case "created_time":
+		if nil == val || nil == val.Created || 1 > val.Created.Content {
+			return nil
+		}
+		return time.UnixMilli(val.Created.Content).Format(time.RFC3339)
+	case "last_edited_time":
+		if nil == val || nil == val.Updated || 1 > val.Updated.Content {
+			return nil
+		}
+		return time.UnixMilli(val.Updated.Content).Format(time.RFC3339)
+	case "relation":
+		ret := []map[string]interface{}{}
+		if nil != val && nil != val.Relation {
+			for _, blockID := range val.Relation.BlockIDs {
+				ret = append(ret, map[string]interface{}{"id": blockID})
+			}
+		}
+		return ret
+	case "url", "email", "phone_number":
+		if nil == val || "" == val.String() {
+			return nil
+		}
+		return val.String()
+	}
+
+	// title 和 rich_text
+	var content string
+	if nil != val {
+		content = val.String()
+	}
+	return notionRichText(content)
+}
+
+func notionRichText(content string) []map[string]interface{} {
+	ret := []map[string]interface{}{}
+	if "" == content {
+		return ret
+	}
+	return append(ret, map[string]interface{}{"type": "text", "text": map[string]interface{}{"content": content}, "plain_text": content})
+}
+
+func notionColor(color string) string {
+	i, err := strconv.Atoi(color)
+	if nil != err || 1 > i {
+		return notionColors[0]
+	}
+	return notionColors[(i-1)%len(notionColors)]
+}
+
+// xlsxLocalTime 将毫秒时间戳转换为本地时间，Excel 单元格中的时间不带时区，需要使用本地时间的字面值。
+func xlsxLocalTime(millis int64) time.Time {
+	t := time.UnixMilli(millis).Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
 func Export2Liandi(id string) (err error) {
 	tree, err := loadTreeByBlockID(id)
 	if nil != err {
@@ -2199,11 +2799,12 @@ func exportTree(tree *parse.Tree, wysiwyg, expandKaTexMacros, keepFold bool,
 			return ast.WalkContinue
 		}
 
-		table, err := renderAttributeViewTable(attrView, view)
+		table, err := renderAttributeViewTable(attrView, view, nil)
 		if nil != err {
 			logging.LogErrorf("render attribute view [%s] table failed: %s", avID, err)
 			return ast.WalkContinue
 		}
+		renderAttributeViewOrderedCols(attrView, table)
 
 		var aligns []int
 		for range table.Columns {
@@ -0,0 +1,223 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/av"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportAttributeViewXLSX(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t)
+	numKey := addTestAttributeViewKey(attrView, "Amount", av.KeyTypeNumber)
+	checkKey := addTestAttributeViewKey(attrView, "Done", av.KeyTypeCheckbox)
+	tagsKey := addTestAttributeViewKey(attrView, "Tags", av.KeyTypeMSelect)
+	attrView.Views[0].Table.Columns[2].Width = "140px"
+	rowID := addTestAttributeViewRow(attrView, "foo")
+	setTestAttributeViewValue(attrView, numKey.ID, rowID, &av.Value{Number: &av.ValueNumber{Content: 42.5, IsNotEmpty: true}})
+	setTestAttributeViewValue(attrView, checkKey.ID, rowID, &av.Value{Checkbox: &av.ValueCheckbox{Checked: true}})
+	setTestAttributeViewValue(attrView, tagsKey.ID, rowID, &av.Value{MSelect: []*av.ValueSelect{{Content: "a"}, {Content: "b"}}})
+	saveTestAttributeViews(t, attrView)
+
+	data, err := ExportAttributeViewXLSX(attrView.ID, "")
+	if nil != err {
+		t.Fatalf("export xlsx failed: %s", err)
+	}
+
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if nil != err {
+		t.Fatalf("open xlsx failed: %s", err)
+	}
+	defer f.Close()
+	sheet := f.GetSheetName(0)
+
+	if header, _ := f.GetCellValue(sheet, "C1"); "Amount" != header {
+		t.Fatalf("unexpected header [%s]", header)
+	}
+	if cellType, _ := f.GetCellType(sheet, "C2"); excelize.CellTypeNumber != cellType && excelize.CellTypeUnset != cellType {
+		t.Fatalf("expected numeric cell, got type [%d]", cellType)
+	}
+	if v, _ := f.GetCellValue(sheet, "C2"); "42.5" != v {
+		t.Fatalf("unexpected number [%s]", v)
+	}
+	if cellType, _ := f.GetCellType(sheet, "D2"); excelize.CellTypeBool != cellType {
+		t.Fatalf("expected boolean cell, got type [%d]", cellType)
+	}
+	if v, _ := f.GetCellValue(sheet, "E2"); "a, b" != v {
+		t.Fatalf("unexpected tags [%s]", v)
+	}
+	if width, _ := f.GetColWidth(sheet, "C"); 20 != width {
+		t.Fatalf("unexpected column width [%v]", width)
+	}
+}
+
+func TestExportAttributeViewJSONSchema(t *testing.T) {
+	setTestDataDir(t)
+	attrView := newTestAttributeView(t, "a")
+	statusKey := addTestAttributeViewKey(attrView, "Status", av.KeyTypeSelect)
+	statusKey.Options = []*av.SelectOption{{Name: "Todo"}, {Name: "Done"}}
+	relKey := addTestAttributeViewKey(attrView, "Related", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: attrView.ID}
+	totalKey := addTestAttributeViewKey(attrView, "Total", av.KeyTypeRunningTotal)
+	saveTestAttributeViews(t, attrView)
+
+	data, err := ExportAttributeViewJSONSchema(attrView.ID)
+	if nil != err {
+		t.Fatalf("export json schema failed: %s", err)
+	}
+	var schema struct {
+		Type       string                            `json:"type"`
+		Required   []string                          `json:"required"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+		Views      []map[string]interface{}          `json:"x-views"`
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &schema); nil != err {
+		t.Fatalf("unmarshal json schema failed: %s", err)
+	}
+	if "object" != schema.Type || 1 != len(schema.Required) || attrView.KeyValues[0].Key.ID != schema.Required[0] || 1 != len(schema.Views) {
+		t.Fatalf("unexpected schema %s", data)
+	}
+
+	status := schema.Properties[statusKey.ID]
+	if enum, _ := status["enum"].([]interface{}); "string" != status["type"] || 2 != len(enum) || "Todo" != enum[0] || "Done" != enum[1] {
+		t.Fatalf("expected select option enum, got %v", status)
+	}
+	rel := schema.Properties[relKey.ID]
+	if items, _ := rel["items"].(map[string]interface{}); "array" != rel["type"] || nil == items || "string" != items["type"] {
+		t.Fatalf("expected relation array, got %v", rel)
+	}
+	if total := schema.Properties[totalKey.ID]; "number" != total["type"] || true != total["readOnly"] {
+		t.Fatalf("expected read-only number for computed column, got %v", total)
+	}
+}
+
+func TestExportAttributeViewJSON(t *testing.T) {
+	setTestDataDir(t)
+	attrView, _, rollupKey := newTestRollupAttributeView(t, 2)
+	relKeyID := rollupKey.Rollup.RelationKeyID
+	numKey := addTestAttributeViewKey(attrView, "Score", av.KeyTypeNumber)
+	for _, value := range attrView.GetBlockKeyValues().Values {
+		if "1" == value.Block.Content {
+			setTestAttributeViewValue(attrView, numKey.ID, value.BlockID, &av.Value{Number: av.NewFormattedValueNumber(9, av.NumberFormatNone)})
+		}
+	}
+	attrView.Views[0].Table.Filters = []*av.ViewFilter{{Column: attrView.KeyValues[0].Key.ID, Operator: av.FilterOperatorIsEqual, Value: &av.Value{Block: &av.ValueBlock{Content: "1"}}}}
+	saveTestAttributeViews(t, attrView)
+
+	export := func(includeComputed bool) map[string]interface{} {
+		data, err := ExportAttributeViewJSON(attrView.ID, "", includeComputed)
+		if nil != err {
+			t.Fatalf("export attribute view json failed: %s", err)
+		}
+		var rows []map[string]interface{}
+		if err = gulu.JSON.UnmarshalJSON(data, &rows); nil != err {
+			t.Fatalf("unmarshal exported json failed: %s", err)
+		}
+		if 1 != len(rows) {
+			t.Fatalf("expected filtered rows, got %d", len(rows))
+		}
+		return rows[0]
+	}
+
+	raw := export(false)
+	if nil != raw[rollupKey.ID] || 9.0 != raw[numKey.ID] || "1" != raw[attrView.KeyValues[0].Key.ID] {
+		t.Fatalf("unexpected raw row %v", raw)
+	}
+	if relations, _ := raw[relKeyID].([]interface{}); 1 != len(relations) || !ast.IsNodeIDPattern(relations[0].(string)) {
+		t.Fatalf("expected raw relation row IDs, got %v", raw[relKeyID])
+	}
+
+	computed := export(true)
+	if rollup, _ := computed[rollupKey.ID].([]interface{}); 1 != len(rollup) || "1" != rollup[0] {
+		t.Fatalf("expected rendered rollup, got %v", computed[rollupKey.ID])
+	}
+	if relations, _ := computed[relKeyID].([]interface{}); 1 != len(relations) || "1" != relations[0] {
+		t.Fatalf("expected rendered relation contents, got %v", computed[relKeyID])
+	}
+	if 9.0 != computed[numKey.ID] {
+		t.Fatalf("unexpected computed number %v", computed[numKey.ID])
+	}
+}
+
+func TestExportAttributeViewNotionJSON(t *testing.T) {
+	setTestDataDir(t)
+	destAv := newTestAttributeView(t)
+	destRowID := addTestAttributeViewRow(destAv, "dest")
+	saveTestAttributeViews(t, destAv)
+
+	attrView := newTestAttributeView(t)
+	attrView.Name = "Tasks"
+	statusKey := addTestAttributeViewKey(attrView, "Status", av.KeyTypeSelect)
+	statusKey.Options = []*av.SelectOption{{Name: "Todo", Color: "1"}, {Name: "Done", Color: "6"}}
+	relKey := addTestAttributeViewKey(attrView, "Relation", av.KeyTypeRelation)
+	relKey.Relation = &av.Relation{AvID: destAv.ID}
+	tplKey := addTestAttributeViewKey(attrView, "Template", av.KeyTypeTemplate)
+	tplKey.Template = ".action{.Status}!"
+	rowID := addTestAttributeViewRow(attrView, "task")
+	setTestAttributeViewValue(attrView, statusKey.ID, rowID, &av.Value{MSelect: []*av.ValueSelect{{Content: "Done", Color: "6"}}})
+	setTestAttributeViewValue(attrView, relKey.ID, rowID, &av.Value{Relation: &av.ValueRelation{BlockIDs: []string{destRowID}}})
+	saveTestAttributeViews(t, attrView)
+
+	data, err := ExportAttributeViewNotionJSON(attrView.ID)
+	if nil != err {
+		t.Fatalf("export notion json failed: %s", err)
+	}
+	var database struct {
+		Object     string                            `json:"object"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+		Pages      []struct {
+			ID         string                            `json:"id"`
+			Properties map[string]map[string]interface{} `json:"properties"`
+		} `json:"pages"`
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &database); nil != err {
+		t.Fatalf("unmarshal notion json failed: %s", err)
+	}
+	if "database" != database.Object || 1 != len(database.Pages) || rowID != database.Pages[0].ID {
+		t.Fatalf("unexpected database structure")
+	}
+
+	status := database.Properties["Status"]
+	options := status["select"].(map[string]interface{})["options"].([]interface{})
+	if "select" != status["type"] || 2 != len(options) || "Done" != options[1].(map[string]interface{})["name"] || "green" != options[1].(map[string]interface{})["color"] {
+		t.Fatalf("unexpected select property %v", status)
+	}
+	relation := database.Properties["Relation"]
+	if "relation" != relation["type"] || destAv.ID != relation["relation"].(map[string]interface{})["database_id"] {
+		t.Fatalf("unexpected relation property %v", relation)
+	}
+	if "title" != database.Properties["Block"]["type"] || "rich_text" != database.Properties["Template"]["type"] {
+		t.Fatalf("unexpected title or template property type")
+	}
+
+	page := database.Pages[0].Properties
+	if "Done" != page["Status"]["select"].(map[string]interface{})["name"] {
+		t.Fatalf("unexpected select value %v", page["Status"])
+	}
+	if relIDs := page["Relation"]["relation"].([]interface{}); 1 != len(relIDs) || destRowID != relIDs[0].(map[string]interface{})["id"] {
+		t.Fatalf("unexpected relation value %v", page["Relation"])
+	}
+	if texts := page["Template"]["rich_text"].([]interface{}); 1 != len(texts) || "Done!" != texts[0].(map[string]interface{})["plain_text"] {
+		t.Fatalf("unexpected template value %v", page["Template"])
+	}
+}
@@ -1314,6 +1314,23 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 			}
 		}
 
+		// 属性视图发生冲突时在值级别合并本地版本，避免多端编辑同一个属性视图的不同单元格时互相覆盖
+		for _, file := range mergeResult.Conflicts {
+			if !strings.HasPrefix(file.Path, "/storage/av/") || !strings.HasSuffix(file.Path, ".json") {
+				continue
+			}
+
+			avID := strings.TrimSuffix(path.Base(file.Path), ".json")
+			if !ast.IsNodeIDPattern(avID) {
+				continue
+			}
+
+			conflictPath := filepath.Join(util.TempDir, "repo", "sync", "conflicts", mergeResult.Time.Format("2006-01-02-150405"), file.Path)
+			if mergeErr := mergeConflictedAttributeView(avID, conflictPath); nil != mergeErr {
+				logging.LogErrorf("merge conflicted attribute view [%s] failed: %s", avID, mergeErr)
+			}
+		}
+
 		historyDir := filepath.Join(util.HistoryDir, mergeResult.Time.Format("2006-01-02-150405")+"-sync")
 		indexHistoryDir(filepath.Base(historyDir), luteEngine)
 	}
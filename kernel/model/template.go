@@ -306,7 +306,7 @@ func renderTemplate(p, id string, preview bool) (string, error) {
 						return ast.WalkContinue
 					}
 
-					table, renderErr := renderAttributeViewTable(attrView, view)
+					table, renderErr := renderAttributeViewTable(attrView, view, nil)
 					if nil != renderErr {
 						logging.LogErrorf("render attribute view [%s] table failed: %s", n.AttributeViewID, renderErr)
 						return ast.WalkContinue
@@ -219,16 +219,50 @@ func performTx(tx *Transaction) (ret *TxErr) {
 			ret = tx.doSetAttrViewFilters(op)
 		case "setAttrViewSorts":
 			ret = tx.doSetAttrViewSorts(op)
+		case "setAttrViewDefaultSorts":
+			ret = tx.doSetAttrViewDefaultSorts(op)
+		case "resetAttrViewSorts":
+			ret = tx.doResetAttrViewSorts(op)
+		case "addAttrViewSortPreset":
+			ret = tx.doAddAttrViewSortPreset(op)
+		case "applyAttrViewSortPreset":
+			ret = tx.doApplyAttrViewSortPreset(op)
 		case "setAttrViewPageSize":
 			ret = tx.doSetAttrViewPageSize(op)
+		case "setAttrViewCalcPosition":
+			ret = tx.doSetAttrViewCalcPosition(op)
+		case "setAttrViewShowSummaryRow":
+			ret = tx.doSetAttrViewShowSummaryRow(op)
+		case "setAttrViewFrozenColumns":
+			ret = tx.doSetAttrViewFrozenColumns(op)
+		case "setAttrViewRowBindingFilter":
+			ret = tx.doSetAttrViewRowBindingFilter(op)
+		case "setAttrViewColumnGroups":
+			ret = tx.doSetAttrViewColumnGroups(op)
+		case "setAttrViewGroupBy":
+			ret = tx.doSetAttrViewGroupBy(op)
+		case "setAttrViewShowGroupPercent":
+			ret = tx.doSetAttrViewShowGroupPercent(op)
+		case "setAttrViewVisibilityFormula":
+			ret = tx.doSetAttrViewVisibilityFormula(op)
+		case "clearAttrViewRowOrder":
+			ret = tx.doClearAttrViewRowOrder(op)
+		case "setAttrViewRowTop":
+			ret = tx.doSetAttrViewRowTop(op)
+		case "setAttrViewRowColor":
+			ret = tx.doSetAttrViewRowColor(op)
 		case "setAttrViewColWidth":
 			ret = tx.doSetAttrViewColumnWidth(op)
+		case "setAttrViewColWidthAllViews":
+			ret = tx.doSetAttrViewColumnWidthAllViews(op)
 		case "setAttrViewColWrap":
 			ret = tx.doSetAttrViewColumnWrap(op)
 		case "setAttrViewColHidden":
 			ret = tx.doSetAttrViewColumnHidden(op)
 		case "setAttrViewColPin":
 			ret = tx.doSetAttrViewColumnPin(op)
+		case "setAttrViewColPinSide":
+			ret = tx.doSetAttrViewColPinSide(op)
 		case "setAttrViewColIcon":
 			ret = tx.doSetAttrViewColumnIcon(op)
 		case "insertAttrViewBlock":
@@ -237,24 +271,66 @@ func performTx(tx *Transaction) (ret *TxErr) {
 			ret = tx.doRemoveAttrViewBlock(op)
 		case "addAttrViewCol":
 			ret = tx.doAddAttrViewColumn(op)
+		case "clearAttrViewColRelationRollup":
+			ret = tx.doClearAttrViewColRelationRollup(op)
+		case "addAttrViewColWithConfig":
+			ret = tx.doAddAttrViewColumnWithConfig(op)
 		case "updateAttrViewCol":
 			ret = tx.doUpdateAttrViewColumn(op)
+		case "convertAttrViewColTextToSelect":
+			ret = tx.doConvertAttrViewColTextToSelect(op)
 		case "removeAttrViewCol":
 			ret = tx.doRemoveAttrViewColumn(op)
 		case "sortAttrViewRow":
 			ret = tx.doSortAttrViewRow(op)
 		case "sortAttrViewCol":
 			ret = tx.doSortAttrViewColumn(op)
+		case "fillDownAttrViewCell":
+			ret = tx.doFillDownAttrViewCell(op)
+		case "addAttrViewMSelectOptionToRows":
+			ret = tx.doAddAttrViewMSelectOptionToRows(op)
 		case "updateAttrViewCell":
 			ret = tx.doUpdateAttrViewCell(op)
+		case "setAttrViewCellLocked":
+			ret = tx.doSetAttrViewCellLocked(op)
+		case "toggleAttrViewCheckbox":
+			ret = tx.doToggleAttrViewCheckbox(op)
 		case "updateAttrViewColOptions":
 			ret = tx.doUpdateAttrViewColOptions(op)
+		case "sortAttrViewColOptionsByUsage":
+			ret = tx.doSortAttrViewColOptionsByUsage(op)
 		case "removeAttrViewColOption":
 			ret = tx.doRemoveAttrViewColOption(op)
+		case "sortAttrViewCellOption":
+			ret = tx.doSortAttrViewCellOption(op)
 		case "updateAttrViewColOption":
 			ret = tx.doUpdateAttrViewColOption(op)
 		case "setAttrViewColCalc":
 			ret = tx.doSetAttrViewColCalc(op)
+		case "updateAttrViewColSourceKey":
+			ret = tx.doUpdateAttrViewColSourceKey(op)
+		case "updateAttrViewColCountMode":
+			ret = tx.doUpdateAttrViewColCountMode(op)
+		case "updateAttrViewColFormula":
+			ret = tx.doUpdateAttrViewColFormula(op)
+		case "setAttrViewColAlias":
+			ret = tx.doSetAttrViewColAlias(op)
+		case "setAttrViewCacheComputedCols":
+			ret = tx.doSetAttrViewCacheComputedCols(op)
+		case "setAttrViewKeepDeletedRows":
+			ret = tx.doSetAttrViewKeepDeletedRows(op)
+		case "setAttrViewPrimaryTemplate":
+			ret = tx.doSetAttrViewPrimaryTemplate(op)
+		case "setAttrViewColMask":
+			ret = tx.doSetAttrViewColMask(op)
+		case "setAttrViewColEmptyPlaceholder":
+			ret = tx.doSetAttrViewColEmptyPlaceholder(op)
+		case "setAttrViewColRelationDisplayLimit":
+			ret = tx.doSetAttrViewColRelationDisplayLimit(op)
+		case "setAttrViewColRelationMaxEntries":
+			ret = tx.doSetAttrViewColRelationMaxEntries(op)
+		case "updateAttrViewColAttrName":
+			ret = tx.doUpdateAttrViewColAttrName(op)
 		case "updateAttrViewColNumberFormat":
 			ret = tx.doUpdateAttrViewColNumberFormat(op)
 		case "replaceAttrViewBlock":
@@ -269,12 +345,18 @@ func performTx(tx *Transaction) (ret *TxErr) {
 			ret = tx.doSetAttrViewViewName(op)
 		case "setAttrViewViewIcon":
 			ret = tx.doSetAttrViewViewIcon(op)
+		case "setAttrViewViewLayout":
+			ret = tx.doSetAttrViewViewLayout(op)
 		case "duplicateAttrViewView":
 			ret = tx.doDuplicateAttrViewView(op)
 		case "sortAttrViewView":
 			ret = tx.doSortAttrViewView(op)
+		case "sortAttrViewViews":
+			ret = tx.doSortAttrViewViews(op)
 		case "updateAttrViewColRelation":
 			ret = tx.doUpdateAttrViewColRelation(op)
+		case "splitTwoWayRelation":
+			ret = tx.doSplitTwoWayRelation(op)
 		case "updateAttrViewColRollup":
 			ret = tx.doUpdateAttrViewColRollup(op)
 		}
@@ -814,7 +896,13 @@ func syncDelete2AttributeView(node *ast.Node) {
 
 		for i, blockValue := range blockValues.Values {
 			if blockValue.Block.ID == node.ID {
-				blockValues.Values = append(blockValues.Values[:i], blockValues.Values[i+1:]...)
+				if attrView.KeepDeletedRows {
+					// 块在属性视图外被删除时将行转换为游离行，保留该行的值
+					blockValue.IsDetached = true
+					blockValue.IsBlockDeleted = true
+				} else {
+					blockValues.Values = append(blockValues.Values[:i], blockValues.Values[i+1:]...)
+				}
 				changedAv = true
 				break
 			}
@@ -1238,6 +1326,7 @@ type Operation struct {
 	RowID             string   `json:"rowID"`             // 属性视图行 ID
 	IsTwoWay          bool     `json:"isTwoWay"`          // 属性视图关联列是否是双向关系
 	BackRelationKeyID string   `json:"backRelationKeyID"` // 属性视图关联列回链关联列的 ID
+	OverrideLock      bool     `json:"overrideLock"`      // 是否忽略属性视图单元格的锁定强制修改
 }
 
 type Transaction struct {
@@ -1401,6 +1490,23 @@ func refreshDynamicRefTexts(updatedDefNodes map[string]*ast.Node, updatedTrees m
 	}
 
 	// 2. 更新属性视图主键内容
+	updateAttributeViewBlockContents(updatedDefNodes)
+
+	// 3. 保存变更
+	for _, tree := range changedRefTree {
+		indexWriteJSONQueue(tree)
+	}
+}
+
+// broadcastRefreshAttributeView 通知前端刷新属性视图 avID，测试时可以替换。
+var broadcastRefreshAttributeView = func(avID string) {
+	util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+}
+
+// updateAttributeViewBlockContents 更新绑定了 updatedDefNodes 的属性视图主键内容。
+// 主键内容变化后，关联了这些属性视图的其他属性视图中的关联列内容也需要刷新。
+func updateAttributeViewBlockContents(updatedDefNodes map[string]*ast.Node) {
+	refreshAvIDs := map[string]bool{}
 	for _, updatedDefNode := range updatedDefNodes {
 		avs := updatedDefNode.IALAttr(av.NodeAttrNameAvs)
 		if "" == avs {
@@ -1432,14 +1538,16 @@ func refreshDynamicRefTexts(updatedDefNodes map[string]*ast.Node, updatedTrees m
 			}
 			if changedAv {
 				av.SaveAttributeView(attrView)
-				util.BroadcastByType("protyle", "refreshAttributeView", 0, "", map[string]interface{}{"id": avID})
+				refreshAvIDs[avID] = true
+				for _, srcAvID := range av.GetSrcAvIDs(avID) {
+					refreshAvIDs[srcAvID] = true
+				}
 			}
 		}
 	}
 
-	// 3. 保存变更
-	for _, tree := range changedRefTree {
-		indexWriteJSONQueue(tree)
+	for avID := range refreshAvIDs {
+		broadcastRefreshAttributeView(avID)
 	}
 }
 
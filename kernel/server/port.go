@@ -36,7 +36,9 @@ func killRunningKernel() {
 	defer logging.Recover()
 
 	now := time.Now()
-	defer logging.LogInfof("check running kernel elapsed [%dms]", time.Since(now).Milliseconds())
+	defer func() {
+		logging.LogInfof("check running kernel elapsed [%dms]", time.Since(now).Milliseconds())
+	}()
 
 	processes, err := goPS.Processes()
 	if nil != err {
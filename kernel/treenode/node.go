@@ -716,7 +716,7 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 			case av.KeyTypeNumber: // 格式化数字
 				if nil != tableCell.Value && nil != tableCell.Value.Number && tableCell.Value.Number.IsNotEmpty {
 					tableCell.Value.Number.Format = col.NumberFormat
-					tableCell.Value.Number.FormatNumber()
+					tableCell.Value.Number.FormatNumber("")
 				}
 			case av.KeyTypeTemplate: // 渲染模板列
 				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeTemplate, Template: &av.ValueTemplate{Content: col.Template}}
@@ -786,7 +786,7 @@ func renderAttributeViewTable(attrView *av.AttributeView, view *av.View) (ret *a
 					}
 					if av.KeyTypeNumber == destKey.Type {
 						destVal.Number.Format = destKey.NumberFormat
-						destVal.Number.FormatNumber()
+						destVal.Number.FormatNumber("")
 					}
 
 					cell.Value.Rollup.Contents = append(cell.Value.Rollup.Contents, destVal.Clone())
@@ -851,8 +851,8 @@ func FillAttributeViewTableCellNilValue(tableCell *av.TableCell, rowID, colID st
 	}
 
 	tableCell.Value.Type = tableCell.ValueType
-	switch tableCell.ValueType {
-	case av.KeyTypeText:
+	switch tableCell.ValueType.BaseType() {
+	case av.KeyTypeText, av.KeyTypeBlockAttr, av.KeyTypeCreatedBy, av.KeyTypeUpdatedBy:
 		if nil == tableCell.Value.Text {
 			tableCell.Value.Text = &av.ValueText{}
 		}
@@ -917,8 +917,8 @@ func FillAttributeViewTableCellNilValue(tableCell *av.TableCell, rowID, colID st
 
 func GetAttributeViewDefaultValue(valueID, keyID, blockID string, typ av.KeyType) (ret *av.Value) {
 	ret = &av.Value{ID: valueID, KeyID: keyID, BlockID: blockID, Type: typ}
-	switch typ {
-	case av.KeyTypeText:
+	switch typ.BaseType() {
+	case av.KeyTypeText, av.KeyTypeBlockAttr, av.KeyTypeCreatedBy, av.KeyTypeUpdatedBy:
 		ret.Text = &av.ValueText{}
 	case av.KeyTypeNumber:
 		ret.Number = &av.ValueNumber{}